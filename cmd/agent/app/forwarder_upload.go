@@ -0,0 +1,93 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/DataDog/datadog-agent/cmd/agent/common"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/forwarder"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	AgentCmd.AddCommand(forwarderCmd)
+	forwarderCmd.AddCommand(forwarderUploadCmd)
+}
+
+var forwarderCmd = &cobra.Command{
+	Use:   "forwarder",
+	Short: "Manage the payloads captured by the forwarder",
+	Long:  ``,
+}
+
+var forwarderUploadCmd = &cobra.Command{
+	Use:   "upload <dir>",
+	Short: "Send the payloads captured by the forwarder",
+	Long: `Send the payloads written in <dir> by an agent with forwarder_capture_enabled, using the endpoints
+and the API keys of the configuration. The payloads are sent in the order they were created, with
+their original timestamps. The files are removed once they are sent. If the upload is interrupted,
+running the command again sends the remaining payloads.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if flagNoColor {
+			color.NoColor = true
+		}
+
+		// The secrets are resolved as the API keys are required to send the payloads.
+		err := common.SetupConfig(confFilePath)
+		if err != nil {
+			return fmt.Errorf("unable to set up global agent configuration: %v", err)
+		}
+
+		err = config.SetupLogger(loggerName, config.GetEnvDefault("DD_LOG_LEVEL", "off"), "", "", false, true, false)
+		if err != nil {
+			fmt.Printf("Cannot setup logger, exiting: %v\n", err)
+			return err
+		}
+
+		return uploadForwarderCapture(args[0])
+	},
+}
+
+func uploadForwarderCapture(folderPath string) error {
+	keysPerDomain, err := config.GetMultipleEndpoints()
+	if err != nil {
+		return fmt.Errorf("misconfiguration of agent endpoints: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	stats, err := forwarder.UploadCapturedTransactions(ctx, folderPath, forwarder.NewOptions(keysPerDomain))
+	fmt.Printf("Files uploaded: %v\n", stats.FilesUploaded)
+	fmt.Printf("Payloads sent: %v\n", stats.TransactionsSent)
+	if stats.TransactionsDropped > 0 {
+		fmt.Fprintln(color.Output, color.YellowString("Payloads rejected by the intake: %v", stats.TransactionsDropped))
+	}
+	if err != nil {
+		return fmt.Errorf("the upload stopped before the end, run the command again to send the remaining payloads: %v", err)
+	}
+	return nil
+}
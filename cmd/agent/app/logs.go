@@ -0,0 +1,117 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/cmd/agent/common"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	logsConfig "github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/dryrun"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	AgentCmd.AddCommand(logsCmd)
+	logsCmd.AddCommand(logsTestCmd)
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Logs related commands",
+	Long:  ``,
+}
+
+var logsTestCmd = &cobra.Command{
+	Use:   "test <config file> <sample file>",
+	Short: "Run a sample file through a logs config and print what would be sent",
+	Long: `Decode the sample file with the logs configs of the integration config file (YAML, or JSON if the file has
+a .json extension), apply the global and the logs config processing rules and print the payloads that would be sent,
+the logs excluded and the rules masking them. Nothing is sent.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if flagNoColor {
+			color.NoColor = true
+		}
+
+		err := common.SetupConfigWithoutSecrets(confFilePath, "")
+		if err != nil {
+			return fmt.Errorf("unable to set up global agent configuration: %v", err)
+		}
+
+		err = config.SetupLogger(loggerName, config.GetEnvDefault("DD_LOG_LEVEL", "off"), "", "", false, true, false)
+		if err != nil {
+			fmt.Printf("Cannot setup logger, exiting: %v\n", err)
+			return err
+		}
+
+		return testLogsConfig(args[0], args[1])
+	},
+}
+
+func testLogsConfig(configPath, samplePath string) error {
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("unable to read the logs config: %v", err)
+	}
+	var configs []*logsConfig.LogsConfig
+	if strings.ToLower(filepath.Ext(configPath)) == ".json" {
+		configs, err = logsConfig.ParseJSON(data)
+	} else {
+		configs, err = logsConfig.ParseYAML(data)
+	}
+	if err != nil {
+		return err
+	}
+	if len(configs) == 0 {
+		return fmt.Errorf("no logs config found in %s", configPath)
+	}
+
+	sample, err := ioutil.ReadFile(samplePath)
+	if err != nil {
+		return fmt.Errorf("unable to read the sample: %v", err)
+	}
+
+	globalProcessingRules, err := logsConfig.GlobalProcessingRules()
+	if err != nil {
+		return fmt.Errorf("invalid global processing rules: %v", err)
+	}
+
+	for i, c := range configs {
+		if len(configs) > 1 {
+			fmt.Println(color.CyanString("=== Logs config %d (type: %s) ===", i+1, c.Type))
+		}
+		results, err := dryrun.Run(c, globalProcessingRules, sample)
+		if err != nil {
+			return fmt.Errorf("invalid logs config: %v", err)
+		}
+		printDryRunResults(results)
+	}
+	return nil
+}
+
+func printDryRunResults(results []*dryrun.Result) {
+	dropped := 0
+	for _, result := range results {
+		if result.IsDropped() {
+			dropped++
+			fmt.Printf("%s by rule %q: %s\n", color.RedString("EXCLUDED"), result.DropRule, result.Content)
+			continue
+		}
+		fmt.Printf("%s %s\n", color.GreenString("SENT"), result.Payload)
+		if len(result.RedactingRules) > 0 {
+			fmt.Printf("  %s by rules: %s\n", color.YellowString("REDACTED"), strings.Join(result.RedactingRules, ", "))
+		}
+	}
+	fmt.Printf("\n%d logs sent, %d logs excluded\n", len(results)-dropped, dropped)
+}
@@ -0,0 +1,134 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/cmd/agent/common"
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
+	"github.com/DataDog/datadog-agent/pkg/api/util"
+	"github.com/DataDog/datadog-agent/pkg/config"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	AgentCmd.AddCommand(metricsCmd)
+	metricsCmd.AddCommand(metricsQueryCmd)
+	metricsQueryCmd.Flags().BoolVarP(&jsonStatus, "json", "j", false, "print out raw json")
+	metricsQueryCmd.Flags().BoolVarP(&prettyPrintJSON, "pretty-json", "p", false, "pretty print JSON")
+}
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Inspect the metrics sent by a running agent",
+	Long:  ``,
+}
+
+var metricsQueryCmd = &cobra.Command{
+	Use:   "query <metric_name> [<tag>...]",
+	Short: "Print the points recently flushed by a running agent for a metric",
+	Long: `Print the points of the last flushes of a running agent for the metrics matching the name,
+per context. '*' matches any sequence of characters in the name. Only the contexts having all
+the given tags are printed, a tag without value matches the tags having this key.
+The flush history has to be enabled with aggregator_flush_history_size.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if flagNoColor {
+			color.NoColor = true
+		}
+
+		err := common.SetupConfigWithoutSecrets(confFilePath, "")
+		if err != nil {
+			return fmt.Errorf("unable to set up global agent configuration: %v", err)
+		}
+
+		err = config.SetupLogger(loggerName, config.GetEnvDefault("DD_LOG_LEVEL", "off"), "", "", false, true, false)
+		if err != nil {
+			fmt.Printf("Cannot setup logger, exiting: %v\n", err)
+			return err
+		}
+
+		return queryMetrics(args[0], args[1:])
+	},
+}
+
+func queryMetrics(name string, tags []string) error {
+	c := util.GetClient(false) // FIX: get certificates right then make this true
+
+	// Set session token
+	err := util.SetAuthToken()
+	if err != nil {
+		return err
+	}
+	ipcAddress, err := config.GetIPCAddress()
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"name": {name}, "tag": tags}
+	urlstr := fmt.Sprintf("https://%v:%v/agent/metrics/query?%s", ipcAddress, config.Datadog.GetInt("cmd_port"), query.Encode())
+	r, err := util.DoGet(c, urlstr)
+	if err != nil {
+		var errMap = make(map[string]string)
+		json.Unmarshal(r, &errMap) //nolint:errcheck
+		// If the error has been marshalled into a json object, check it and return it properly
+		if e, found := errMap["error"]; found {
+			return errors.New(e)
+		}
+		fmt.Printf("Could not reach agent: %v \nMake sure the agent is running before querying the metrics.\n", err)
+		return err
+	}
+
+	if prettyPrintJSON {
+		var prettyJSON bytes.Buffer
+		json.Indent(&prettyJSON, r, "", "  ") //nolint:errcheck
+		fmt.Println(prettyJSON.String())
+		return nil
+	} else if jsonStatus {
+		fmt.Println(string(r))
+		return nil
+	}
+
+	var flushed []aggregator.FlushedMetric
+	if err := json.Unmarshal(r, &flushed); err != nil {
+		return err
+	}
+	if len(flushed) == 0 {
+		fmt.Println("No metric matching the query was flushed recently.")
+		return nil
+	}
+	printFlushedMetrics(color.Output, flushed)
+	return nil
+}
+
+func printFlushedMetrics(w io.Writer, flushed []aggregator.FlushedMetric) {
+	for _, m := range flushed {
+		fmt.Fprintf(w, "\n=== %s (%s) ===\n", color.GreenString(m.Name), m.Type)
+		if m.Host != "" {
+			fmt.Fprintf(w, "Host: %s\n", m.Host)
+		}
+		fmt.Fprintf(w, "Tags: [%s]\n", strings.Join(m.Tags, " "))
+		for _, p := range m.Points {
+			ts := time.Unix(0, int64(p.Ts*float64(time.Second))).Format("2006-01-02 15:04:05 MST")
+			if d := p.Distribution; d != nil {
+				fmt.Fprintf(w, "  %s  count: %s sum: %v min: %v max: %v avg: %v\n", ts, color.CyanString("%d", d.Count), d.Sum, d.Min, d.Max, d.Avg)
+			} else {
+				fmt.Fprintf(w, "  %s  %s\n", ts, color.CyanString("%v", p.Value))
+			}
+		}
+	}
+}
@@ -85,6 +85,7 @@ var (
 
 	orchestratorForwarder  *forwarder.DefaultForwarder
 	eventPlatformForwarder epforwarder.EventPlatformForwarder
+	metricSerializer       *serializer.Serializer
 	configService          *remoteconfig.Service
 
 	runCmd = &cobra.Command{
@@ -384,8 +385,8 @@ func StartAgent() error {
 	eventPlatformForwarder.Start()
 
 	// setup the aggregator
-	s := serializer.NewSerializer(common.Forwarder, orchestratorForwarder)
-	agg := aggregator.InitAggregator(s, eventPlatformForwarder, hostname)
+	metricSerializer = serializer.NewSerializer(common.Forwarder, orchestratorForwarder)
+	agg := aggregator.InitAggregator(metricSerializer, eventPlatformForwarder, hostname)
 	agg.AddAgentStartupTelemetry(version.AgentVersion)
 
 	// start dogstatsd
@@ -401,7 +402,7 @@ func StartAgent() error {
 	// Start OTLP intake
 	if otlp.IsEnabled(config.Datadog) {
 		var err error
-		common.OTLP, err = otlp.BuildAndStart(common.MainCtx, config.Datadog, s)
+		common.OTLP, err = otlp.BuildAndStart(common.MainCtx, config.Datadog, metricSerializer)
 		if err != nil {
 			log.Errorf("Could not start OTLP: %s", err)
 		}
@@ -454,7 +455,7 @@ func StartAgent() error {
 	misconfig.ToLog()
 
 	// setup the metadata collector
-	common.MetadataScheduler = metadata.NewScheduler(s)
+	common.MetadataScheduler = metadata.NewScheduler(metricSerializer)
 	if err := metadata.SetupMetadataCollection(common.MetadataScheduler, metadata.AllDefaultCollectors); err != nil {
 		return err
 	}
@@ -502,6 +503,9 @@ func StopAgent() {
 	clcrunnerapi.StopCLCRunnerServer()
 	jmx.StopJmxfetch()
 	aggregator.StopDefaultAggregator()
+	if metricSerializer != nil {
+		metricSerializer.Stop()
+	}
 	if common.Forwarder != nil {
 		common.Forwarder.Stop()
 	}
@@ -72,8 +72,9 @@ extensions for special Datadog features.`,
 	confPath   string
 	socketPath string
 
-	metaScheduler *metadata.Scheduler
-	statsd        *dogstatsd.Server
+	metaScheduler    *metadata.Scheduler
+	statsd           *dogstatsd.Server
+	metricSerializer *serializer.Serializer
 )
 
 const (
@@ -185,7 +186,7 @@ func runAgent(ctx context.Context) (err error) {
 	}
 	f := forwarder.NewDefaultForwarder(forwarder.NewOptions(keysPerDomain))
 	f.Start() //nolint:errcheck
-	s := serializer.NewSerializer(f, nil)
+	metricSerializer = serializer.NewSerializer(f, nil)
 
 	hname, err := util.GetHostname(context.TODO())
 	if err != nil {
@@ -195,7 +196,7 @@ func runAgent(ctx context.Context) (err error) {
 	log.Debugf("Using hostname: %s", hname)
 
 	// setup the metadata collector
-	metaScheduler = metadata.NewScheduler(s)
+	metaScheduler = metadata.NewScheduler(metricSerializer)
 	if err = metadata.SetupMetadataCollection(metaScheduler, []string{"host"}); err != nil {
 		metaScheduler.Stop()
 		return
@@ -216,7 +217,7 @@ func runAgent(ctx context.Context) (err error) {
 		tagger.Init()
 	}
 
-	aggregatorInstance := aggregator.InitAggregator(s, nil, hname)
+	aggregatorInstance := aggregator.InitAggregator(metricSerializer, nil, hname)
 
 	statsd, err = dogstatsd.NewServer(aggregatorInstance, nil)
 	if err != nil {
@@ -273,6 +274,10 @@ func stopAgent(cancel context.CancelFunc) {
 		statsd.Stop()
 	}
 
+	if metricSerializer != nil {
+		metricSerializer.Stop()
+	}
+
 	log.Info("See ya!")
 	log.Flush()
 	return
@@ -119,6 +119,8 @@ var (
 	aggregatorOrchestratorMetadata             = expvar.Int{}
 	aggregatorOrchestratorMetadataErrors       = expvar.Int{}
 	aggregatorDogstatsdContexts                = expvar.Int{}
+	aggregatorDogstatsdContextLimitDrops       = expvar.Int{}
+	aggregatorDogstatsdContextLimitCollapses   = expvar.Int{}
 	aggregatorEventPlatformEvents              = expvar.Map{}
 	aggregatorEventPlatformEventsErrors        = expvar.Map{}
 
@@ -130,6 +132,8 @@ var (
 		nil, "Count of hostname update")
 	tlmDogstatsdContexts = telemetry.NewGauge("aggregator", "dogstatsd_contexts",
 		nil, "Count the number of dogstatsd contexts in the aggregator")
+	tlmDogstatsdContextLimits = telemetry.NewCounter("aggregator", "dogstatsd_context_limits",
+		[]string{"metric_name", "tag", "action"}, "Count the dogstatsd samples dropped or collapsed because of the context limits")
 
 	// Hold series to be added to aggregated series on each flush
 	recurrentSeries     metrics.Series
@@ -168,6 +172,9 @@ func init() {
 	aggregatorExpvars.Set("OrchestratorMetadata", &aggregatorOrchestratorMetadata)
 	aggregatorExpvars.Set("OrchestratorMetadataErrors", &aggregatorOrchestratorMetadataErrors)
 	aggregatorExpvars.Set("DogstatsdContexts", &aggregatorDogstatsdContexts)
+	aggregatorExpvars.Set("DogstatsdContextLimitDrops", &aggregatorDogstatsdContextLimitDrops)
+	aggregatorExpvars.Set("DogstatsdContextLimitCollapses", &aggregatorDogstatsdContextLimitCollapses)
+	aggregatorExpvars.Set("DogstatsdContextLimits", expvar.Func(func() interface{} { return contextLimitsStats.get() }))
 	aggregatorExpvars.Set("EventPlatformEvents", &aggregatorEventPlatformEvents)
 	aggregatorExpvars.Set("EventPlatformEventsErrors", &aggregatorEventPlatformEventsErrors)
 
@@ -209,7 +216,6 @@ func StopDefaultAggregator() {
 
 // BufferedAggregator aggregates metrics in buckets for dogstatsd Metrics
 type BufferedAggregator struct {
-	bufferedMetricIn       chan []metrics.MetricSample
 	bufferedMetricInWithTs chan []metrics.MetricSample
 	bufferedServiceCheckIn chan []*metrics.ServiceCheck
 	bufferedEventIn        chan []*metrics.Event
@@ -227,7 +233,13 @@ type BufferedAggregator struct {
 	// Used by the Dogstatsd Batcher.
 	MetricSamplePool *metrics.MetricSamplePool
 
-	statsdSampler          TimeSampler
+	// statsdWorkers aggregate the dogstatsd samples, the contexts are sharded between them by statsdSharder
+	statsdWorkers []*timeSamplerWorker
+	statsdSharder *ContextSharder
+	// tagFilters remove tags from the metrics before they are aggregated, nil if there is no filter
+	tagFilters *tagFilterList
+	// flushHistory keeps the metrics of the last flushes, nil if it is disabled
+	flushHistory           *flushHistory
 	checkSamplers          map[check.ID]*CheckSampler
 	serviceChecks          metrics.ServiceChecks
 	events                 metrics.Events
@@ -266,8 +278,19 @@ func NewBufferedAggregator(s serializer.MetricSerializer, eventPlatformForwarder
 		agentName = flavor.HerokuAgent
 	}
 
+	pipelineCount := config.Datadog.GetInt("dogstatsd_pipeline_count")
+	if pipelineCount < 1 {
+		log.Warnf("Invalid dogstatsd_pipeline_count %d, using 1 pipeline", pipelineCount)
+		pipelineCount = 1
+	}
+	tagFilters := newTagFilterListFromConfig()
+	metricSamplePool := metrics.NewMetricSamplePool(MetricSamplePoolBatchSize)
+	statsdWorkers := make([]*timeSamplerWorker, pipelineCount)
+	for i := range statsdWorkers {
+		statsdWorkers[i] = newTimeSamplerWorker(bucketSize, bufferSize, metricSamplePool, newContextLimiterFromConfig(pipelineCount), tagFilters)
+	}
+
 	aggregator := &BufferedAggregator{
-		bufferedMetricIn:       make(chan []metrics.MetricSample, bufferSize),
 		bufferedMetricInWithTs: make(chan []metrics.MetricSample, bufferSize),
 		bufferedServiceCheckIn: make(chan []*metrics.ServiceCheck, bufferSize),
 		bufferedEventIn:        make(chan []*metrics.Event, bufferSize),
@@ -282,9 +305,12 @@ func NewBufferedAggregator(s serializer.MetricSerializer, eventPlatformForwarder
 		orchestratorMetadataIn: make(chan senderOrchestratorMetadata, bufferSize),
 		eventPlatformIn:        make(chan senderEventPlatformEvent, bufferSize),
 
-		MetricSamplePool: metrics.NewMetricSamplePool(MetricSamplePoolBatchSize),
+		MetricSamplePool: metricSamplePool,
 
-		statsdSampler:           *NewTimeSampler(bucketSize),
+		statsdWorkers:           statsdWorkers,
+		statsdSharder:           newContextSharder(pipelineCount, tagFilters),
+		tagFilters:              tagFilters,
+		flushHistory:            newFlushHistory(config.Datadog.GetInt("aggregator_flush_history_size")),
 		checkSamplers:           make(map[check.ID]*CheckSampler),
 		flushInterval:           flushInterval,
 		serializer:              s,
@@ -301,6 +327,10 @@ func NewBufferedAggregator(s serializer.MetricSerializer, eventPlatformForwarder
 		ServerlessFlushDone:     make(chan struct{}),
 	}
 
+	for _, worker := range statsdWorkers {
+		go worker.run()
+	}
+
 	return aggregator
 }
 
@@ -326,8 +356,25 @@ func (agg *BufferedAggregator) GetChannels() (chan *metrics.MetricSample, chan m
 }
 
 // GetBufferedChannels returns a channel which can be subsequently used to send MetricSamples, Event or ServiceCheck
+// The MetricSamples channel is the one of the first dogstatsd pipeline, see GetBufferedMetricsChannels to use all of them.
 func (agg *BufferedAggregator) GetBufferedChannels() (chan []metrics.MetricSample, chan []*metrics.Event, chan []*metrics.ServiceCheck) {
-	return agg.bufferedMetricIn, agg.bufferedEventIn, agg.bufferedServiceCheckIn
+	return agg.statsdWorkers[0].samplesChan, agg.bufferedEventIn, agg.bufferedServiceCheckIn
+}
+
+// GetBufferedMetricsChannels returns the channels of the dogstatsd pipelines, the samples have to be
+// sent to the channel of their pipeline, given by a ContextSharder returned by NewContextSharder.
+func (agg *BufferedAggregator) GetBufferedMetricsChannels() []chan []metrics.MetricSample {
+	channels := make([]chan []metrics.MetricSample, len(agg.statsdWorkers))
+	for i, worker := range agg.statsdWorkers {
+		channels[i] = worker.samplesChan
+	}
+	return channels
+}
+
+// NewContextSharder returns a ContextSharder giving the dogstatsd pipeline of the samples,
+// a ContextSharder is not safe for concurrent usage.
+func (agg *BufferedAggregator) NewContextSharder() *ContextSharder {
+	return newContextSharder(len(agg.statsdWorkers), agg.tagFilters)
 }
 
 // GetBufferedMetricsWithTsChannel returns the channel to send MetricSamples containing their timestamp.
@@ -377,6 +424,7 @@ func (agg *BufferedAggregator) registerSender(id check.ID) error {
 		config.Datadog.GetInt("check_sampler_bucket_commits_count_expiry"),
 		config.Datadog.GetBool("check_sampler_expire_metrics"),
 		config.Datadog.GetDuration("check_sampler_stateful_metric_expiration_time"),
+		agg.tagFilters,
 	)
 	return nil
 }
@@ -452,9 +500,52 @@ func (agg *BufferedAggregator) addEvent(e metrics.Event) {
 	agg.events = append(agg.events, &e)
 }
 
-// addSample adds the metric sample
+// addSample sends the metric sample to the time sampler of its pipeline, the timestamp is in seconds
 func (agg *BufferedAggregator) addSample(metricSample *metrics.MetricSample, timestamp float64) {
-	agg.statsdSampler.addSample(metricSample, timestamp)
+	// sent by value to not allocate for each sample
+	sample := *metricSample
+	sample.Timestamp = timestamp * float64(time.Second)
+	agg.statsdWorkers[agg.statsdSharder.Shard(&sample)].sampleWithTsChan <- sample
+}
+
+// addSamplesWithTs sends the metric samples to the time samplers of their pipeline
+func (agg *BufferedAggregator) addSamplesWithTs(ms []metrics.MetricSample) {
+	if len(agg.statsdWorkers) == 1 {
+		agg.statsdWorkers[0].samplesWithTsChan <- append([]metrics.MetricSample(nil), ms...)
+		return
+	}
+	batches := make([][]metrics.MetricSample, len(agg.statsdWorkers))
+	for i := range ms {
+		shard := agg.statsdSharder.Shard(&ms[i])
+		batches[shard] = append(batches[shard], ms[i])
+	}
+	for shard, batch := range batches {
+		if len(batch) > 0 {
+			agg.statsdWorkers[shard].samplesWithTsChan <- batch
+		}
+	}
+}
+
+// flushTimeSamplers flushes the time samplers of all the pipelines in parallel and merges their series and sketches
+func (agg *BufferedAggregator) flushTimeSamplers(before float64) (metrics.Series, metrics.SketchSeriesList) {
+	results := make(chan timeSamplerFlush, len(agg.statsdWorkers))
+	for _, worker := range agg.statsdWorkers {
+		worker.flushChan <- flushTrigger{before: before, results: results}
+	}
+
+	var series metrics.Series
+	var sketches metrics.SketchSeriesList
+	contexts := 0
+	for range agg.statsdWorkers {
+		result := <-results
+		series = append(series, result.series...)
+		sketches = append(sketches, result.sketches...)
+		contexts += result.contexts
+	}
+
+	aggregatorDogstatsdContexts.Set(int64(contexts))
+	tlmDogstatsdContexts.Set(float64(contexts))
+	return series, sketches
 }
 
 // GetSeriesAndSketches grabs all the series & sketches from the queue and clears the queue
@@ -464,7 +555,7 @@ func (agg *BufferedAggregator) GetSeriesAndSketches(before time.Time) (metrics.S
 	agg.mu.Lock()
 	defer agg.mu.Unlock()
 
-	series, sketches := agg.statsdSampler.flush(float64(before.UnixNano()) / float64(time.Second))
+	series, sketches := agg.flushTimeSamplers(float64(before.UnixNano()) / float64(time.Second))
 	for _, checkSampler := range agg.checkSamplers {
 		s, sk := checkSampler.flush()
 		series = append(series, s...)
@@ -591,6 +682,9 @@ func (agg *BufferedAggregator) sendSketches(start time.Time, sketches metrics.Sk
 
 func (agg *BufferedAggregator) flushSeriesAndSketches(start time.Time, waitForSerializer bool) {
 	series, sketches := agg.GetSeriesAndSketches(start)
+	if agg.flushHistory != nil {
+		agg.flushHistory.add(series, sketches)
+	}
 
 	agg.sendSketches(start, sketches, waitForSerializer)
 	agg.sendSeries(start, series, waitForSerializer)
@@ -717,9 +811,11 @@ func (agg *BufferedAggregator) Stop() {
 
 	timeout := config.Datadog.GetDuration("aggregator_stop_timeout") * time.Second
 	if timeout > 0 {
-		done := make(chan struct{})
+		done := make(chan struct{}, 1)
 		go func() {
 			agg.Flush(time.Now(), true)
+			// the flush uses the time samplers, they are stopped once it is done even if it timed out
+			agg.stopTimeSamplers()
 			done <- struct{}{}
 		}()
 
@@ -728,8 +824,16 @@ func (agg *BufferedAggregator) Stop() {
 		case <-time.After(timeout):
 			log.Errorf("flushing data after stop timed out")
 		}
+		return
 	}
 
+	agg.stopTimeSamplers()
+}
+
+func (agg *BufferedAggregator) stopTimeSamplers() {
+	for _, worker := range agg.statsdWorkers {
+		worker.stop()
+	}
 }
 
 func (agg *BufferedAggregator) run() {
@@ -774,8 +878,6 @@ func (agg *BufferedAggregator) run() {
 			tlmProcessed.Inc("histogram_bucket")
 			agg.handleSenderBucket(checkHistogramBucket)
 		case metric := <-agg.metricIn:
-			aggregatorDogstatsdMetricSample.Add(1)
-			tlmProcessed.Inc("dogstatsd_metrics")
 			agg.addSample(metric, timeNowNano())
 		case event := <-agg.eventIn:
 			aggregatorEvent.Add(1)
@@ -786,19 +888,7 @@ func (agg *BufferedAggregator) run() {
 			tlmProcessed.Inc("service_checks")
 			agg.addServiceCheck(serviceCheck)
 		case ms := <-agg.bufferedMetricInWithTs:
-			aggregatorDogstatsdMetricSample.Add(int64(len(ms)))
-			tlmProcessed.Add(float64(len(ms)), "dogstatsd_metrics")
-			for i := 0; i < len(ms); i++ {
-				agg.addSample(&ms[i], ms[i].Timestamp/float64(time.Second))
-			}
-			agg.MetricSamplePool.PutBatch(ms)
-		case ms := <-agg.bufferedMetricIn:
-			aggregatorDogstatsdMetricSample.Add(int64(len(ms)))
-			tlmProcessed.Add(float64(len(ms)), "dogstatsd_metrics")
-			t := timeNowNano()
-			for i := 0; i < len(ms); i++ {
-				agg.addSample(&ms[i], t)
-			}
+			agg.addSamplesWithTs(ms)
 			agg.MetricSamplePool.PutBatch(ms)
 		case serviceChecks := <-agg.bufferedServiceCheckIn:
 			aggregatorServiceCheck.Add(int64(len(serviceChecks)))
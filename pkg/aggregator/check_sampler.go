@@ -26,12 +26,12 @@ type CheckSampler struct {
 	lastBucketValue map[ckey.ContextKey]int64
 }
 
-// newCheckSampler returns a newly initialized CheckSampler
-func newCheckSampler(expirationCount int, expireMetrics bool, statefulTimeout time.Duration) *CheckSampler {
+// newCheckSampler returns a newly initialized CheckSampler removing the tags filtered out by the tag filters
+func newCheckSampler(expirationCount int, expireMetrics bool, statefulTimeout time.Duration, tagFilters *tagFilterList) *CheckSampler {
 	return &CheckSampler{
 		series:          make([]*metrics.Serie, 0),
 		sketches:        make(metrics.SketchSeriesList, 0),
-		contextResolver: newCountBasedContextResolver(expirationCount),
+		contextResolver: newCountBasedContextResolver(expirationCount, tagFilters),
 		metrics:         metrics.NewCheckMetrics(expireMetrics, statefulTimeout),
 		sketchMap:       make(sketchMap),
 		lastBucketValue: make(map[ckey.ContextKey]int64),
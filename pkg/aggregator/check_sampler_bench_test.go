@@ -24,7 +24,7 @@ func benchmarkAddBucket(bucketValue int64, b *testing.B) {
 		forwarder.NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(map[string][]string{"hello": {"world"}}))),
 		nil,
 	)
-	checkSampler := newCheckSampler(1, true, 1000)
+	checkSampler := newCheckSampler(1, true, 1000, nil)
 
 	bucket := &metrics.HistogramBucket{
 		Name:       "my.histogram",
@@ -43,7 +43,7 @@ func benchmarkAddBucket(bucketValue int64, b *testing.B) {
 }
 
 func benchmarkAddBucketWideBounds(bucketValue int64, b *testing.B) {
-	checkSampler := newCheckSampler(1, true, 1000)
+	checkSampler := newCheckSampler(1, true, 1000, nil)
 
 	bounds := []float64{0, .0005, .001, .003, .005, .007, .01, .015, .02, .025, .03, .04, .05, .06, .07, .08, .09, .1, .5, 1, 5, 10}
 	bucket := &metrics.HistogramBucket{
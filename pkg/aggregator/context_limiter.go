@@ -0,0 +1,252 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	contextLimitActionDrop     = "drop"
+	contextLimitActionCollapse = "collapse"
+
+	// maximum number of metrics reported in the context limits stats
+	maxContextLimitStatsMetrics = 100
+	otherMetricsStatsName       = "other"
+
+	// maximum number of values tracked per tag key of a metric, enough to find the tag with the
+	// most values without holding every value of the high cardinality tags
+	maxTrackedTagValues = 100
+)
+
+var contextLimitsStats = newContextLimitStats()
+
+// contextLimiter limits the number of contexts tracked by a context resolver, globally and per
+// metric name. Once a limit is reached, the samples of new contexts are either dropped or
+// collapsed into the context without the tag of the metric having the most values.
+// Not safe for concurrent usage.
+type contextLimiter struct {
+	globalLimit    int
+	perMetricLimit int
+	collapse       bool
+
+	total    int
+	byMetric map[string]*metricContexts
+}
+
+// metricContexts holds the number of contexts of a metric and the values of their tags.
+type metricContexts struct {
+	count int
+	// number of contexts per tag value, per tag key, up to maxTrackedTagValues values per key
+	tagValues map[string]map[string]int
+}
+
+// newContextLimiter returns a contextLimiter, or nil if there is no limit.
+func newContextLimiter(globalLimit, perMetricLimit int, collapse bool) *contextLimiter {
+	if globalLimit <= 0 && perMetricLimit <= 0 {
+		return nil
+	}
+	return &contextLimiter{
+		globalLimit:    globalLimit,
+		perMetricLimit: perMetricLimit,
+		collapse:       collapse,
+		byMetric:       make(map[string]*metricContexts),
+	}
+}
+
+// newContextLimiterFromConfig returns the contextLimiter of a dogstatsd pipeline, the limits are
+// split between the pipelines.
+func newContextLimiterFromConfig(pipelineCount int) *contextLimiter {
+	collapse := false
+	switch action := config.Datadog.GetString("dogstatsd_context_limit_action"); action {
+	case contextLimitActionDrop:
+	case contextLimitActionCollapse:
+		collapse = true
+	default:
+		log.Warnf("Invalid dogstatsd_context_limit_action %q, the samples of the contexts over the limits will be dropped", action)
+	}
+
+	perPipeline := func(limit int) int {
+		return (limit + pipelineCount - 1) / pipelineCount
+	}
+	return newContextLimiter(
+		perPipeline(config.Datadog.GetInt("dogstatsd_context_limit")),
+		perPipeline(config.Datadog.GetInt("dogstatsd_context_limit_per_metric")),
+		collapse,
+	)
+}
+
+// accept returns true if a new context of the metric can be tracked.
+func (l *contextLimiter) accept(name string) bool {
+	if l.globalLimit > 0 && l.total >= l.globalLimit {
+		return false
+	}
+	if m, ok := l.byMetric[name]; ok && l.perMetricLimit > 0 && m.count >= l.perMetricLimit {
+		return false
+	}
+	return true
+}
+
+// acceptCollapsed returns true if a new collapsed context can be tracked, they are only
+// subject to the global limit.
+func (l *contextLimiter) acceptCollapsed() bool {
+	return l.globalLimit <= 0 || l.total < l.globalLimit
+}
+
+// track registers a new context.
+func (l *contextLimiter) track(name string, tags []string) {
+	l.total++
+	m, ok := l.byMetric[name]
+	if !ok {
+		m = &metricContexts{tagValues: make(map[string]map[string]int)}
+		l.byMetric[name] = m
+	}
+	m.count++
+	for _, tag := range tags {
+		key, value := splitTag(tag)
+		values, ok := m.tagValues[key]
+		if !ok {
+			values = make(map[string]int)
+			m.tagValues[key] = values
+		}
+		if _, ok := values[value]; ok || len(values) < maxTrackedTagValues {
+			values[value]++
+		}
+	}
+}
+
+// remove unregisters an expired context.
+func (l *contextLimiter) remove(name string, tags []string) {
+	m, ok := l.byMetric[name]
+	if !ok {
+		return
+	}
+	l.total--
+	m.count--
+	if m.count <= 0 {
+		delete(l.byMetric, name)
+		return
+	}
+	for _, tag := range tags {
+		key, value := splitTag(tag)
+		values := m.tagValues[key]
+		if _, ok := values[value]; !ok {
+			// the value was not tracked
+			continue
+		}
+		if values[value]--; values[value] <= 0 {
+			delete(values, value)
+		}
+		if len(values) == 0 {
+			delete(m.tagValues, key)
+		}
+	}
+}
+
+// offendingTag returns the key of the tag of the metric having the most values,
+// or an empty string if no tag has more than one value. The tags having more than
+// maxTrackedTagValues values are ranked by their key.
+func (l *contextLimiter) offendingTag(name string) string {
+	m, ok := l.byMetric[name]
+	if !ok {
+		return ""
+	}
+	offending, maxValues := "", 1
+	for key, values := range m.tagValues {
+		if len(values) > maxValues || (len(values) == maxValues && maxValues > 1 && key < offending) {
+			offending, maxValues = key, len(values)
+		}
+	}
+	return offending
+}
+
+// stripTag returns the tags without the ones having the given key, and false if there is none.
+func stripTag(tags []string, key string) ([]string, bool) {
+	stripped := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if k, _ := splitTag(tag); k != key {
+			stripped = append(stripped, tag)
+		}
+	}
+	return stripped, len(stripped) != len(tags)
+}
+
+func splitTag(tag string) (string, string) {
+	if i := strings.IndexByte(tag, ':'); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+// contextLimitStats holds the number of samples dropped and collapsed because of the context limits,
+// per metric. It is shared by the pipelines.
+type contextLimitStats struct {
+	mu       sync.Mutex
+	byMetric map[string]*metricLimitStats
+}
+
+// metricLimitStats is the context limits stats of a metric.
+type metricLimitStats struct {
+	Dropped   int64
+	Collapsed int64
+	// Tag is the key of the tag having the most values when the limit was last reached
+	Tag string
+}
+
+func newContextLimitStats() *contextLimitStats {
+	return &contextLimitStats{byMetric: make(map[string]*metricLimitStats)}
+}
+
+// record registers a sample dropped or collapsed because of the context limits.
+func (s *contextLimitStats) record(name, tag string, collapsed bool) {
+	action, outcome := contextLimitActionDrop, "dropped"
+	if collapsed {
+		action, outcome = contextLimitActionCollapse, "collapsed"
+		aggregatorDogstatsdContextLimitCollapses.Add(1)
+	} else {
+		aggregatorDogstatsdContextLimitDrops.Add(1)
+	}
+
+	s.mu.Lock()
+	stats, ok := s.byMetric[name]
+	if !ok {
+		if len(s.byMetric) >= maxContextLimitStatsMetrics {
+			name = otherMetricsStatsName
+			stats, ok = s.byMetric[name]
+		}
+		if !ok {
+			log.Warnf("Dogstatsd context limit reached for metric %q, the samples of its new contexts are %s (tag with the most values: %q)", name, outcome, tag)
+			stats = &metricLimitStats{}
+			s.byMetric[name] = stats
+		}
+	}
+	if collapsed {
+		stats.Collapsed++
+	} else {
+		stats.Dropped++
+	}
+	if tag != "" {
+		stats.Tag = tag
+	}
+	s.mu.Unlock()
+
+	tlmDogstatsdContextLimits.Inc(name, tag, action)
+}
+
+// get returns a copy of the stats.
+func (s *contextLimitStats) get() map[string]metricLimitStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]metricLimitStats, len(s.byMetric))
+	for name, metricStats := range s.byMetric {
+		stats[name] = *metricStats
+	}
+	return stats
+}
@@ -0,0 +1,139 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build test
+
+package aggregator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+func limitedSample(name string, tags ...string) *metrics.MetricSample {
+	return &metrics.MetricSample{
+		Name:       name,
+		Value:      1,
+		Mtype:      metrics.GaugeType,
+		Tags:       tags,
+		SampleRate: 1,
+	}
+}
+
+func TestContextLimiterDisabled(t *testing.T) {
+	assert.Nil(t, newContextLimiter(0, 0, true))
+}
+
+func TestContextLimiterPerMetric(t *testing.T) {
+	contextLimitsStats = newContextLimitStats()
+	resolver := newTimestampContextResolver(newContextLimiter(0, 2, false), nil)
+
+	key1, ok := resolver.trackContext(limitedSample("my.metric", "env:prod", "request_id:1"), 1)
+	require.True(t, ok)
+	_, ok = resolver.trackContext(limitedSample("my.metric", "env:prod", "request_id:2"), 2)
+	require.True(t, ok)
+
+	// known contexts and other metrics are still tracked
+	key, ok := resolver.trackContext(limitedSample("my.metric", "request_id:1", "env:prod"), 3)
+	assert.True(t, ok)
+	assert.Equal(t, key1, key)
+	_, ok = resolver.trackContext(limitedSample("my.other.metric", "env:prod"), 3)
+	assert.True(t, ok)
+
+	_, ok = resolver.trackContext(limitedSample("my.metric", "env:prod", "request_id:3"), 3)
+	assert.False(t, ok)
+	assert.Equal(t, 3, resolver.length())
+	assert.Equal(t, map[string]metricLimitStats{"my.metric": {Dropped: 1, Tag: "request_id"}}, contextLimitsStats.get())
+
+	// the expired contexts are not counted anymore
+	resolver.expireContexts(2.5)
+	_, ok = resolver.trackContext(limitedSample("my.metric", "env:prod", "request_id:3"), 4)
+	assert.True(t, ok)
+	assert.Equal(t, 2, resolver.resolver.limiter.byMetric["my.metric"].count)
+	assert.Equal(t, map[string]int{"1": 1, "3": 1}, resolver.resolver.limiter.byMetric["my.metric"].tagValues["request_id"])
+}
+
+func TestContextLimiterTrackedTagValues(t *testing.T) {
+	limiter := newContextLimiter(0, 1000, false)
+	for i := 0; i < maxTrackedTagValues*2; i++ {
+		limiter.track("my.metric", []string{"env:prod", fmt.Sprintf("request_id:%d", i)})
+	}
+
+	// the values over the limit are not tracked
+	m := limiter.byMetric["my.metric"]
+	assert.Equal(t, maxTrackedTagValues*2, m.count)
+	assert.Len(t, m.tagValues["request_id"], maxTrackedTagValues)
+	assert.Equal(t, "request_id", limiter.offendingTag("my.metric"))
+
+	limiter.remove("my.metric", []string{"env:prod", fmt.Sprintf("request_id:%d", maxTrackedTagValues)})
+	limiter.remove("my.metric", []string{"env:prod", "request_id:0"})
+	assert.Equal(t, maxTrackedTagValues*2-2, m.count)
+	assert.Len(t, m.tagValues["request_id"], maxTrackedTagValues-1)
+	assert.Equal(t, map[string]int{"prod": maxTrackedTagValues*2 - 2}, m.tagValues["env"])
+}
+
+func TestContextLimiterGlobal(t *testing.T) {
+	contextLimitsStats = newContextLimitStats()
+	resolver := newTimestampContextResolver(newContextLimiter(2, 0, true), nil)
+
+	_, ok := resolver.trackContext(limitedSample("my.metric.1"), 1)
+	require.True(t, ok)
+	_, ok = resolver.trackContext(limitedSample("my.metric.2"), 1)
+	require.True(t, ok)
+
+	// there is no tag to collapse
+	_, ok = resolver.trackContext(limitedSample("my.metric.3"), 1)
+	assert.False(t, ok)
+	assert.Equal(t, map[string]metricLimitStats{"my.metric.3": {Dropped: 1}}, contextLimitsStats.get())
+}
+
+func TestContextLimiterCollapse(t *testing.T) {
+	contextLimitsStats = newContextLimitStats()
+	resolver := newTimestampContextResolver(newContextLimiter(4, 2, true), nil)
+
+	_, ok := resolver.trackContext(limitedSample("my.metric", "env:prod", "request_id:1"), 1)
+	require.True(t, ok)
+	_, ok = resolver.trackContext(limitedSample("my.metric", "env:prod", "request_id:2"), 1)
+	require.True(t, ok)
+
+	// the new contexts are collapsed into the context without the request_id tag
+	expected := generateContextKey(limitedSample("my.metric", "env:prod"))
+	key, ok := resolver.trackContext(limitedSample("my.metric", "env:prod", "request_id:3"), 1)
+	assert.True(t, ok)
+	assert.Equal(t, expected, key)
+	key, ok = resolver.trackContext(limitedSample("my.metric", "request_id:4", "env:prod"), 1)
+	assert.True(t, ok)
+	assert.Equal(t, expected, key)
+
+	context, ok := resolver.get(expected)
+	require.True(t, ok)
+	assert.Equal(t, []string{"env:prod"}, context.Tags)
+	assert.Equal(t, 3, resolver.length())
+	assert.Equal(t, map[string]metricLimitStats{"my.metric": {Collapsed: 2, Tag: "request_id"}}, contextLimitsStats.get())
+
+	// the collapsed contexts are subject to the global limit
+	_, ok = resolver.trackContext(limitedSample("my.other.metric"), 1)
+	require.True(t, ok)
+	_, ok = resolver.trackContext(limitedSample("my.metric", "env:staging", "request_id:5"), 1)
+	assert.False(t, ok)
+	assert.Equal(t, metricLimitStats{Dropped: 1, Collapsed: 2, Tag: "request_id"}, contextLimitsStats.get()["my.metric"])
+}
+
+func TestTimeSamplerContextLimits(t *testing.T) {
+	contextLimitsStats = newContextLimitStats()
+	sampler := newTimeSampler(10, newContextLimiter(0, 1, false), nil)
+
+	sampler.addSample(limitedSample("my.metric", "request_id:1"), 12345)
+	sampler.addSample(limitedSample("my.metric", "request_id:2"), 12345)
+
+	series, _ := sampler.flush(12360)
+	require.Len(t, series, 1)
+	assert.Equal(t, []string{"request_id:1"}, series[0].Tags)
+}
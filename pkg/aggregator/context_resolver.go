@@ -27,6 +27,10 @@ type contextResolver struct {
 	// buffer slice allocated once per contextResolver to combine and sort
 	// tags, origin detection tags and k8s tags.
 	tagsBuffer *tagset.HashingTagsAccumulator
+	// limiter limits the number of contexts tracked by trackLimitedContext, nil if there is no limit
+	limiter *contextLimiter
+	// tagFilter removes the tags filtered out by the metric tag filters, nil if there is no filter
+	tagFilter *tagFilter
 }
 
 // generateContextKey generates the contextKey associated with the context of the metricSample
@@ -34,34 +38,92 @@ func (cr *contextResolver) generateContextKey(metricSampleContext metrics.Metric
 	return cr.keyGenerator.Generate(metricSampleContext.GetName(), metricSampleContext.GetHost(), cr.tagsBuffer)
 }
 
-func newContextResolver() *contextResolver {
+func newContextResolver(tagFilters *tagFilterList) *contextResolver {
 	return &contextResolver{
 		contextsByKey: make(map[ckey.ContextKey]*Context),
 		keyGenerator:  ckey.NewKeyGenerator(),
 		tagsBuffer:    tagset.NewHashingTagsAccumulator(),
+		tagFilter:     newTagFilter(tagFilters),
+	}
+}
+
+// fillTags fills tagsBuffer with the tags of the context, without the ones removed by the tag filter
+func (cr *contextResolver) fillTags(metricSampleContext metrics.MetricSampleContext) {
+	metricSampleContext.GetTags(cr.tagsBuffer) // tags here are not sorted and can contain duplicates
+	if cr.tagFilter != nil {
+		cr.tagFilter.apply(metricSampleContext, cr.tagsBuffer)
 	}
 }
 
 // trackContext returns the contextKey associated with the context of the metricSample and tracks that context
 func (cr *contextResolver) trackContext(metricSampleContext metrics.MetricSampleContext) ckey.ContextKey {
-	metricSampleContext.GetTags(cr.tagsBuffer)               // tags here are not sorted and can contain duplicates
+	cr.fillTags(metricSampleContext)
 	contextKey := cr.generateContextKey(metricSampleContext) // the generator will remove duplicates from cr.tagsBuffer (and doesn't mind the order)
 
 	if _, ok := cr.contextsByKey[contextKey]; !ok {
-		// making a copy of tags for the context since tagsBuffer
-		// will be reused later. This allow us to allocate one slice
-		// per context instead of one per sample.
-		cr.contextsByKey[contextKey] = &Context{
-			Name: metricSampleContext.GetName(),
-			Tags: cr.tagsBuffer.Copy(),
-			Host: metricSampleContext.GetHost(),
-		}
+		cr.addContext(contextKey, metricSampleContext)
 	}
 
 	cr.tagsBuffer.Reset()
 	return contextKey
 }
 
+// trackLimitedContext is trackContext enforcing the limits of the context limiter of the resolver,
+// it returns false if the sample is dropped.
+func (cr *contextResolver) trackLimitedContext(metricSampleContext metrics.MetricSampleContext) (ckey.ContextKey, bool) {
+	if cr.limiter == nil {
+		return cr.trackContext(metricSampleContext), true
+	}
+	defer cr.tagsBuffer.Reset()
+
+	cr.fillTags(metricSampleContext)
+	contextKey := cr.generateContextKey(metricSampleContext)
+	if _, ok := cr.contextsByKey[contextKey]; ok {
+		return contextKey, true
+	}
+
+	name := metricSampleContext.GetName()
+	if cr.limiter.accept(name) {
+		cr.limiter.track(name, cr.tagsBuffer.Get())
+		cr.addContext(contextKey, metricSampleContext)
+		return contextKey, true
+	}
+
+	tag := cr.limiter.offendingTag(name)
+	if cr.limiter.collapse && tag != "" {
+		if tags, stripped := stripTag(cr.tagsBuffer.Get(), tag); stripped {
+			cr.tagsBuffer.Reset()
+			cr.tagsBuffer.Append(tags...)
+			contextKey = cr.generateContextKey(metricSampleContext)
+			_, ok := cr.contextsByKey[contextKey]
+			if !ok && cr.limiter.acceptCollapsed() {
+				cr.limiter.track(name, cr.tagsBuffer.Get())
+				cr.addContext(contextKey, metricSampleContext)
+				ok = true
+			}
+			if ok {
+				contextLimitsStats.record(name, tag, true)
+				return contextKey, true
+			}
+		}
+	}
+
+	contextLimitsStats.record(name, tag, false)
+	return 0, false
+}
+
+// addContext tracks the context in tagsBuffer
+func (cr *contextResolver) addContext(contextKey ckey.ContextKey, metricSampleContext metrics.MetricSampleContext) {
+	// making a copy of tags for the context since tagsBuffer
+	// will be reused later. This allow us to allocate one slice
+	// per context instead of one per sample.
+	cr.contextsByKey[contextKey] = &Context{
+		Name: metricSampleContext.GetName(),
+		Tags: cr.tagsBuffer.Copy(),
+		Host: metricSampleContext.GetHost(),
+	}
+}
+
 func (cr *contextResolver) get(key ckey.ContextKey) (*Context, bool) {
 	ctx, found := cr.contextsByKey[key]
 	return ctx, found
@@ -73,6 +135,11 @@ func (cr *contextResolver) length() int {
 
 func (cr *contextResolver) removeKeys(expiredContextKeys []ckey.ContextKey) {
 	for _, expiredContextKey := range expiredContextKeys {
+		if cr.limiter != nil {
+			if context, ok := cr.contextsByKey[expiredContextKey]; ok {
+				cr.limiter.remove(context.Name, context.Tags)
+			}
+		}
 		delete(cr.contextsByKey, expiredContextKey)
 	}
 }
@@ -83,9 +150,11 @@ type timestampContextResolver struct {
 	lastSeenByKey map[ckey.ContextKey]float64
 }
 
-func newTimestampContextResolver() *timestampContextResolver {
+func newTimestampContextResolver(limiter *contextLimiter, tagFilters *tagFilterList) *timestampContextResolver {
+	resolver := newContextResolver(tagFilters)
+	resolver.limiter = limiter
 	return &timestampContextResolver{
-		resolver:      newContextResolver(),
+		resolver:      resolver,
 		lastSeenByKey: make(map[ckey.ContextKey]float64),
 	}
 }
@@ -101,11 +170,15 @@ func (cr *timestampContextResolver) updateTrackedContext(contextKey ckey.Context
 	return nil
 }
 
-// trackContext returns the contextKey associated with the context of the metricSample and tracks that context
-func (cr *timestampContextResolver) trackContext(metricSampleContext metrics.MetricSampleContext, currentTimestamp float64) ckey.ContextKey {
-	contextKey := cr.resolver.trackContext(metricSampleContext)
+// trackContext returns the contextKey associated with the context of the metricSample and tracks that context,
+// it returns false if the sample is dropped because of the context limits
+func (cr *timestampContextResolver) trackContext(metricSampleContext metrics.MetricSampleContext, currentTimestamp float64) (ckey.ContextKey, bool) {
+	contextKey, ok := cr.resolver.trackLimitedContext(metricSampleContext)
+	if !ok {
+		return contextKey, false
+	}
 	cr.lastSeenByKey[contextKey] = currentTimestamp
-	return contextKey
+	return contextKey, true
 }
 
 func (cr *timestampContextResolver) length() int {
@@ -147,9 +220,9 @@ type countBasedContextResolver struct {
 	expireCountInterval int64
 }
 
-func newCountBasedContextResolver(expireCountInterval int) *countBasedContextResolver {
+func newCountBasedContextResolver(expireCountInterval int, tagFilters *tagFilterList) *countBasedContextResolver {
 	return &countBasedContextResolver{
-		resolver:            newContextResolver(),
+		resolver:            newContextResolver(tagFilters),
 		expireCountByKey:    make(map[ckey.ContextKey]int64),
 		expireCount:         0,
 		expireCountInterval: int64(expireCountInterval),
@@ -70,7 +70,7 @@ func TestTrackContext(t *testing.T) {
 		Tags: mSample3.Tags,
 		Host: mSample3.Host,
 	}
-	contextResolver := newContextResolver()
+	contextResolver := newContextResolver(nil)
 
 	// Track the 2 contexts
 	contextKey1 := contextResolver.trackContext(&mSample1)
@@ -107,11 +107,11 @@ func TestExpireContexts(t *testing.T) {
 		Tags:       []string{"foo", "bar", "baz"},
 		SampleRate: 1,
 	}
-	contextResolver := newTimestampContextResolver()
+	contextResolver := newTimestampContextResolver(nil, nil)
 
 	// Track the 2 contexts
-	contextKey1 := contextResolver.trackContext(&mSample1, 4)
-	contextKey2 := contextResolver.trackContext(&mSample2, 6)
+	contextKey1, _ := contextResolver.trackContext(&mSample1, 4)
+	contextKey2, _ := contextResolver.trackContext(&mSample2, 6)
 
 	// With an expireTimestap of 3, both contexts are still valid
 	assert.Len(t, contextResolver.expireContexts(3), 0)
@@ -137,7 +137,7 @@ func TestCountBasedExpireContexts(t *testing.T) {
 	mSample1 := metrics.MetricSample{Name: "my.metric.name1"}
 	mSample2 := metrics.MetricSample{Name: "my.metric.name2"}
 	mSample3 := metrics.MetricSample{Name: "my.metric.name3"}
-	contextResolver := newCountBasedContextResolver(2)
+	contextResolver := newCountBasedContextResolver(2, nil)
 
 	contextKey1 := contextResolver.trackContext(&mSample1)
 	contextKey2 := contextResolver.trackContext(&mSample2)
@@ -158,7 +158,7 @@ func TestCountBasedExpireContexts(t *testing.T) {
 }
 
 func TestTagDeduplication(t *testing.T) {
-	resolver := newContextResolver()
+	resolver := newContextResolver(nil)
 
 	ckey := resolver.trackContext(&metrics.MetricSample{
 		Name: "foo",
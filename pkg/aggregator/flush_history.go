@@ -0,0 +1,194 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+// FlushedMetric is a context of the series and sketches flushed by the aggregator, with its
+// points of the recent flushes.
+type FlushedMetric struct {
+	Name   string         `json:"name"`
+	Host   string         `json:"host"`
+	Tags   []string       `json:"tags"`
+	Type   string         `json:"type"`
+	Points []FlushedPoint `json:"points"`
+}
+
+// FlushedPoint is a point of a FlushedMetric. The distributions points only hold the summary
+// of their sketch.
+type FlushedPoint struct {
+	Ts           float64              `json:"ts"`
+	Value        float64              `json:"value"`
+	Distribution *DistributionSummary `json:"distribution,omitempty"`
+}
+
+// DistributionSummary holds the basic stats of a distribution point.
+type DistributionSummary struct {
+	Count int64   `json:"count"`
+	Sum   float64 `json:"sum"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+}
+
+const distributionMetricType = "distribution"
+
+// flushHistory keeps the metrics of the last flushes of the aggregator in a ring buffer.
+// The metrics are copied so that the flushed series and sketches are not retained.
+type flushHistory struct {
+	mu      sync.Mutex
+	flushes [][]FlushedMetric
+	next    int
+}
+
+// newFlushHistory returns a flushHistory keeping the last size flushes, or nil if size is 0.
+func newFlushHistory(size int) *flushHistory {
+	if size <= 0 {
+		return nil
+	}
+	return &flushHistory{flushes: make([][]FlushedMetric, size)}
+}
+
+// add records the series and sketches of a flush, replacing the oldest flush.
+func (h *flushHistory) add(series metrics.Series, sketches metrics.SketchSeriesList) {
+	flushed := make([]FlushedMetric, 0, len(series)+len(sketches))
+	for _, serie := range series {
+		points := make([]FlushedPoint, 0, len(serie.Points))
+		for _, p := range serie.Points {
+			points = append(points, FlushedPoint{Ts: p.Ts, Value: p.Value})
+		}
+		flushed = append(flushed, FlushedMetric{
+			Name:   serie.Name,
+			Host:   serie.Host,
+			Tags:   copyTags(serie.Tags),
+			Type:   serie.MType.String(),
+			Points: points,
+		})
+	}
+	for _, sketch := range sketches {
+		points := make([]FlushedPoint, 0, len(sketch.Points))
+		for _, p := range sketch.Points {
+			basic := p.Sketch.Basic
+			points = append(points, FlushedPoint{
+				Ts:    float64(p.Ts),
+				Value: basic.Avg,
+				Distribution: &DistributionSummary{
+					Count: basic.Cnt,
+					Sum:   basic.Sum,
+					Min:   basic.Min,
+					Max:   basic.Max,
+					Avg:   basic.Avg,
+				},
+			})
+		}
+		flushed = append(flushed, FlushedMetric{
+			Name:   sketch.Name,
+			Host:   sketch.Host,
+			Tags:   copyTags(sketch.Tags),
+			Type:   distributionMetricType,
+			Points: points,
+		})
+	}
+
+	h.mu.Lock()
+	h.flushes[h.next] = flushed
+	h.next = (h.next + 1) % len(h.flushes)
+	h.mu.Unlock()
+}
+
+// query returns the contexts of the recent flushes of the metrics matching the name pattern and
+// having all the tags, with their points sorted by timestamp. A tag without value matches the
+// tags having this key.
+func (h *flushHistory) query(name string, tags []string) ([]FlushedMetric, error) {
+	pattern, err := compileGlob(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metric name %q: %v", name, err)
+	}
+
+	byContext := make(map[string]*FlushedMetric)
+	h.mu.Lock()
+	for _, flushed := range h.flushes {
+		for i := range flushed {
+			m := &flushed[i]
+			if !pattern.MatchString(m.Name) || !hasTags(m.Tags, tags) {
+				continue
+			}
+			key := contextString(m)
+			result, ok := byContext[key]
+			if !ok {
+				result = &FlushedMetric{Name: m.Name, Host: m.Host, Tags: m.Tags, Type: m.Type}
+				byContext[key] = result
+			}
+			result.Points = append(result.Points, m.Points...)
+		}
+	}
+	h.mu.Unlock()
+
+	keys := make([]string, 0, len(byContext))
+	for key := range byContext {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	results := make([]FlushedMetric, 0, len(keys))
+	for _, key := range keys {
+		m := byContext[key]
+		sort.Slice(m.Points, func(i, j int) bool { return m.Points[i].Ts < m.Points[j].Ts })
+		results = append(results, *m)
+	}
+	return results, nil
+}
+
+// QueryFlushedMetrics returns the contexts of the metrics recently flushed by the default aggregator
+// matching the name pattern and having all the tags. `*` matches any sequence of characters in the
+// name, a tag without value matches the tags having this key.
+func QueryFlushedMetrics(name string, tags []string) ([]FlushedMetric, error) {
+	if aggregatorInstance == nil {
+		return nil, errors.New("Aggregator was not initialized")
+	}
+	if aggregatorInstance.flushHistory == nil {
+		return nil, errors.New("the flush history is disabled, set aggregator_flush_history_size to enable it")
+	}
+	return aggregatorInstance.flushHistory.query(name, tags)
+}
+
+func hasTags(tags []string, filters []string) bool {
+	for _, filter := range filters {
+		found := false
+		for _, tag := range tags {
+			if tag == filter || (strings.IndexByte(filter, ':') < 0 && strings.HasPrefix(tag, filter+":")) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// contextString returns a string identifying the context of a flushed metric, the order
+// of its tags doesn't matter.
+func contextString(m *FlushedMetric) string {
+	tags := copyTags(m.Tags)
+	sort.Strings(tags)
+	return m.Name + "|" + m.Host + "|" + strings.Join(tags, ",") + "|" + m.Type
+}
+
+func copyTags(tags []string) []string {
+	copied := make([]string, len(tags))
+	copy(copied, tags)
+	return copied
+}
@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build test
+
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+)
+
+func TestFlushHistoryDisabled(t *testing.T) {
+	assert.Nil(t, newFlushHistory(0))
+}
+
+func TestFlushHistory(t *testing.T) {
+	h := newFlushHistory(2)
+
+	flush := func(ts float64, value float64) {
+		sketch := &quantile.Sketch{}
+		sketch.Insert(quantile.Default(), value, value+2)
+		h.add(metrics.Series{
+			{Name: "my.count", Tags: []string{"env:prod", "role:db"}, Host: "host", MType: metrics.APICountType, Points: []metrics.Point{{Ts: ts, Value: value}}},
+			{Name: "my.count", Tags: []string{"env:staging"}, Host: "host", MType: metrics.APICountType, Points: []metrics.Point{{Ts: ts, Value: value}}},
+			{Name: "other.gauge", Tags: []string{"env:prod"}, MType: metrics.APIGaugeType, Points: []metrics.Point{{Ts: ts, Value: value}}},
+		}, metrics.SketchSeriesList{
+			{Name: "my.distribution", Tags: []string{"env:prod"}, Host: "host", Points: []metrics.SketchPoint{{Sketch: sketch, Ts: int64(ts)}}},
+		})
+	}
+	flush(10, 1)
+	flush(20, 2)
+	// the first flush is replaced
+	flush(30, 3)
+
+	results, err := h.query("my.count", []string{"env:prod"})
+	require.NoError(t, err)
+	assert.Equal(t, []FlushedMetric{{
+		Name:   "my.count",
+		Host:   "host",
+		Tags:   []string{"env:prod", "role:db"},
+		Type:   "count",
+		Points: []FlushedPoint{{Ts: 20, Value: 2}, {Ts: 30, Value: 3}},
+	}}, results)
+
+	// a tag without value matches its key, the name can be a pattern
+	results, err = h.query("my.*", []string{"env"})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, []string{"env:prod", "role:db"}, results[0].Tags)
+	assert.Equal(t, []string{"env:staging"}, results[1].Tags)
+	assert.Equal(t, "my.distribution", results[2].Name)
+	assert.Equal(t, "distribution", results[2].Type)
+	assert.Equal(t, []FlushedPoint{
+		{Ts: 20, Value: 3, Distribution: &DistributionSummary{Count: 2, Sum: 6, Min: 2, Max: 4, Avg: 3}},
+		{Ts: 30, Value: 4, Distribution: &DistributionSummary{Count: 2, Sum: 8, Min: 3, Max: 5, Avg: 4}},
+	}, results[2].Points)
+
+	results, err = h.query("my.count", []string{"env:prod", "role:cache"})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
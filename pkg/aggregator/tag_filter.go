@@ -0,0 +1,165 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	tagFilterActionInclude = "include"
+	tagFilterActionExclude = "exclude"
+
+	// maximum number of metric names whose rule is cached by a tagFilter
+	maxTagFilterCacheSize = 10000
+)
+
+// tagFilterList holds the rules removing tags from the metrics matching a name pattern.
+// It is immutable and can be shared between context resolvers.
+type tagFilterList struct {
+	rules []tagFilterRule
+}
+
+// tagFilterRule removes the tags with the listed keys, or all the other tags for an include rule.
+type tagFilterRule struct {
+	pattern *regexp.Regexp
+	include bool
+	keys    map[string]struct{}
+}
+
+// newTagFilterList returns a tagFilterList applying the filters in order, or nil if there is none.
+func newTagFilterList(filters []config.MetricTagFilter) (*tagFilterList, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	rules := make([]tagFilterRule, 0, len(filters))
+	for i, filter := range filters {
+		if filter.MetricName == "" {
+			return nil, fmt.Errorf("metric tag filter %d: metric_name is required", i)
+		}
+
+		var include bool
+		switch filter.Action {
+		case tagFilterActionInclude:
+			include = true
+		case tagFilterActionExclude:
+		default:
+			return nil, fmt.Errorf("metric tag filter %d: invalid action %q, expected %q or %q", i, filter.Action, tagFilterActionInclude, tagFilterActionExclude)
+		}
+
+		pattern, err := compileGlob(filter.MetricName)
+		if err != nil {
+			return nil, fmt.Errorf("metric tag filter %d: invalid metric_name %q: %v", i, filter.MetricName, err)
+		}
+
+		keys := make(map[string]struct{}, len(filter.Tags))
+		for _, key := range filter.Tags {
+			keys[key] = struct{}{}
+		}
+		rules = append(rules, tagFilterRule{pattern: pattern, include: include, keys: keys})
+	}
+	return &tagFilterList{rules: rules}, nil
+}
+
+// newTagFilterListFromConfig returns the tagFilterList of the metric_tag_filterlist setting,
+// or nil if it isn't set or is invalid.
+func newTagFilterListFromConfig() *tagFilterList {
+	filters, err := config.GetMetricTagFilterList()
+	if err != nil {
+		return nil
+	}
+	list, err := newTagFilterList(filters)
+	if err != nil {
+		log.Errorf("Invalid metric_tag_filterlist, the tags of the metrics won't be filtered: %v", err)
+		return nil
+	}
+	return list
+}
+
+// compileGlob returns a regexp matching the metric names matching the pattern, `*` matches
+// any sequence of characters.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$")
+}
+
+// keep returns true if the tag is kept by the rule.
+func (r *tagFilterRule) keep(tag string) bool {
+	key, _ := splitTag(tag)
+	_, listed := r.keys[key]
+	return listed == r.include
+}
+
+// tagFilter applies a tagFilterList, caching the rule of each metric name.
+// Not safe for concurrent usage.
+type tagFilter struct {
+	list *tagFilterList
+	// rule of each metric name, nil if no rule matches
+	rules map[string]*tagFilterRule
+}
+
+func newTagFilter(list *tagFilterList) *tagFilter {
+	if list == nil {
+		return nil
+	}
+	return &tagFilter{
+		list:  list,
+		rules: make(map[string]*tagFilterRule),
+	}
+}
+
+// rule returns the first rule matching the metric name, or nil if there is none.
+func (f *tagFilter) rule(name string) *tagFilterRule {
+	if rule, ok := f.rules[name]; ok {
+		return rule
+	}
+
+	var rule *tagFilterRule
+	for i := range f.list.rules {
+		if f.list.rules[i].pattern.MatchString(name) {
+			rule = &f.list.rules[i]
+			break
+		}
+	}
+
+	if len(f.rules) >= maxTagFilterCacheSize {
+		f.rules = make(map[string]*tagFilterRule)
+	}
+	f.rules[name] = rule
+	return rule
+}
+
+// apply removes the tags filtered out for the metric from the tags buffer.
+func (f *tagFilter) apply(metricSampleContext metrics.MetricSampleContext, tags *tagset.HashingTagsAccumulator) {
+	if !isTagFilterable(metricSampleContext) {
+		return
+	}
+	if rule := f.rule(metricSampleContext.GetName()); rule != nil {
+		tags.Retain(rule.keep)
+	}
+}
+
+// isTagFilterable returns false for the metrics computed from the previous value of their context,
+// their contexts can't be aggregated together.
+func isTagFilterable(metricSampleContext metrics.MetricSampleContext) bool {
+	switch ctx := metricSampleContext.(type) {
+	case *metrics.MetricSample:
+		switch ctx.Mtype {
+		case metrics.RateType, metrics.MonotonicCountType, metrics.HistorateType:
+			return false
+		}
+	case *metrics.HistogramBucket:
+		return !ctx.Monotonic
+	}
+	return true
+}
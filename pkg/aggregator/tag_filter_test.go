@@ -0,0 +1,153 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build test
+
+package aggregator
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+)
+
+func testTagFilterList(t *testing.T) *tagFilterList {
+	list, err := newTagFilterList([]config.MetricTagFilter{
+		{MetricName: "http.request.*", Action: "exclude", Tags: []string{"request_id", "debug"}},
+		{MetricName: "http.request.latency", Action: "include", Tags: []string{"env"}},
+		{MetricName: "queue.size", Action: "include", Tags: []string{"env"}},
+	})
+	require.NoError(t, err)
+	return list
+}
+
+func TestNewTagFilterList(t *testing.T) {
+	list, err := newTagFilterList(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, list)
+
+	_, err = newTagFilterList([]config.MetricTagFilter{{Action: "exclude", Tags: []string{"env"}}})
+	assert.Error(t, err)
+	_, err = newTagFilterList([]config.MetricTagFilter{{MetricName: "my.metric", Action: "drop", Tags: []string{"env"}}})
+	assert.Error(t, err)
+}
+
+func TestTagFilter(t *testing.T) {
+	filter := newTagFilter(testTagFilterList(t))
+	resolver := newContextResolver(testTagFilterList(t))
+
+	for _, tc := range []struct {
+		name     string
+		tags     []string
+		expected []string
+	}{
+		// the first matching rule applies
+		{"http.request.latency", []string{"env:prod", "request_id:1", "debug", "path:/"}, []string{"env:prod", "path:/"}},
+		{"http.request.count", []string{"env:prod", "request_id:1"}, []string{"env:prod"}},
+		{"queue.size", []string{"env:prod", "queue:a", "request_id:1"}, []string{"env:prod"}},
+		{"queue.size.max", []string{"env:prod", "queue:a"}, []string{"env:prod", "queue:a"}},
+		{"http_request", []string{"request_id:1"}, []string{"request_id:1"}},
+	} {
+		resolver.fillTags(limitedSample(tc.name, tc.tags...))
+		assert.Equal(t, tc.expected, resolver.tagsBuffer.Get(), tc.name)
+		resolver.tagsBuffer.Reset()
+	}
+
+	assert.Len(t, filter.rules, 0)
+	filter.rule("queue.size")
+	filter.rule("queue.size.max")
+	assert.Equal(t, &filter.list.rules[2], filter.rules["queue.size"])
+	assert.Nil(t, filter.rules["queue.size.max"])
+}
+
+func TestTimeSamplerTagFilter(t *testing.T) {
+	sampler := newTimeSampler(10, nil, testTagFilterList(t))
+
+	for i, requestID := range []string{"request_id:1", "request_id:2", "request_id:3"} {
+		sampler.addSample(&metrics.MetricSample{
+			Name:       "http.request.count",
+			Value:      float64(i + 1),
+			Mtype:      metrics.CountType,
+			Tags:       []string{"env:prod", requestID},
+			SampleRate: 1,
+		}, 12345)
+		sampler.addSample(&metrics.MetricSample{
+			Name:       "http.request.duration",
+			Value:      float64(i + 1),
+			Mtype:      metrics.DistributionType,
+			Tags:       []string{requestID, "env:prod"},
+			SampleRate: 1,
+		}, 12345)
+	}
+	assert.Equal(t, 2, sampler.contextResolver.length())
+
+	series, sketches := sampler.flush(12360)
+
+	// the counts of the contexts are summed
+	require.Len(t, series, 1)
+	assert.Equal(t, "http.request.count", series[0].Name)
+	assert.Equal(t, []string{"env:prod"}, series[0].Tags)
+	require.Len(t, series[0].Points, 1)
+	assert.Equal(t, 6.0, series[0].Points[0].Value)
+
+	// the sketches of the contexts are merged
+	expSketch := &quantile.Sketch{}
+	expSketch.Insert(quantile.Default(), 1, 2, 3)
+	require.Len(t, sketches, 1)
+	metrics.AssertSketchSeriesEqual(t, metrics.SketchSeries{
+		Name:       "http.request.duration",
+		Tags:       []string{"env:prod"},
+		Interval:   10,
+		Points:     []metrics.SketchPoint{{Ts: 12340, Sketch: expSketch}},
+		ContextKey: generateContextKey(limitedSample("http.request.duration", "env:prod")),
+	}, sketches[0])
+}
+
+func TestCheckSamplerTagFilter(t *testing.T) {
+	checkSampler := newCheckSampler(1, true, 1*time.Second, testTagFilterList(t))
+
+	for i, requestID := range []string{"request_id:1", "request_id:2"} {
+		for _, mtype := range []metrics.MetricType{metrics.CountType, metrics.RateType} {
+			checkSampler.addSample(&metrics.MetricSample{
+				Name:       "http.request.count",
+				Value:      float64(i + 1),
+				Mtype:      mtype,
+				Tags:       []string{"env:prod", requestID},
+				SampleRate: 1,
+				Timestamp:  12345 + float64(i),
+			})
+		}
+	}
+	checkSampler.commit(12349)
+	series, _ := checkSampler.flush()
+
+	// the rates are computed from the previous sample of their context, their tags are kept
+	require.Len(t, series, 1)
+	assert.Equal(t, metrics.APICountType, series[0].MType)
+	assert.Equal(t, []string{"env:prod"}, series[0].Tags)
+	assert.Equal(t, 3.0, series[0].Points[0].Value)
+
+	var tags []string
+	for _, ctx := range checkSampler.contextResolver.resolver.contextsByKey {
+		tags = append(tags, ctx.Tags...)
+	}
+	sort.Strings(tags)
+	assert.Equal(t, []string{"env:prod", "env:prod", "env:prod", "request_id:1", "request_id:2"}, tags)
+}
+
+func TestContextSharderTagFilter(t *testing.T) {
+	sharder := newContextSharder(8, testTagFilterList(t))
+	shard := sharder.Shard(limitedSample("http.request.count", "env:prod"))
+	for _, requestID := range []string{"request_id:1", "request_id:2", "request_id:3", "request_id:4"} {
+		assert.Equal(t, shard, sharder.Shard(limitedSample("http.request.count", "env:prod", requestID)))
+	}
+}
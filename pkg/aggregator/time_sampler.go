@@ -32,12 +32,18 @@ type TimeSampler struct {
 
 // NewTimeSampler returns a newly initialized TimeSampler
 func NewTimeSampler(interval int64) *TimeSampler {
+	return newTimeSampler(interval, nil, nil)
+}
+
+// newTimeSampler returns a newly initialized TimeSampler limiting its contexts with the limiter
+// and removing the tags filtered out by the tag filters
+func newTimeSampler(interval int64, limiter *contextLimiter, tagFilters *tagFilterList) *TimeSampler {
 	if interval == 0 {
 		interval = bucketSize
 	}
 	return &TimeSampler{
 		interval:                    interval,
-		contextResolver:             newTimestampContextResolver(),
+		contextResolver:             newTimestampContextResolver(limiter, tagFilters),
 		metricsByTimestamp:          map[int64]metrics.ContextMetrics{},
 		counterLastSampledByContext: map[ckey.ContextKey]float64{},
 		sketchMap:                   make(sketchMap),
@@ -55,7 +61,10 @@ func (s *TimeSampler) isBucketStillOpen(bucketStartTimestamp, timestamp int64) b
 // Add the metricSample to the correct bucket
 func (s *TimeSampler) addSample(metricSample *metrics.MetricSample, timestamp float64) {
 	// Keep track of the context
-	contextKey := s.contextResolver.trackContext(metricSample, timestamp)
+	contextKey, ok := s.contextResolver.trackContext(metricSample, timestamp)
+	if !ok {
+		return
+	}
 	bucketStart := s.calculateBucketStart(timestamp)
 
 	switch metricSample.Mtype {
@@ -186,8 +195,6 @@ func (s *TimeSampler) flush(timestamp float64) (metrics.Series, metrics.SketchSe
 	s.contextResolver.expireContexts(timestamp - config.Datadog.GetFloat64("dogstatsd_context_expiry_seconds"))
 	s.lastCutOffTime = cutoffTime
 
-	aggregatorDogstatsdContexts.Set(int64(s.contextResolver.length()))
-	tlmDogstatsdContexts.Set(float64(s.contextResolver.length()))
 	return series, sketches
 }
 
@@ -0,0 +1,166 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+)
+
+// ContextSharder returns the dogstatsd pipeline a metric sample has to be sent to.
+// All the samples of a context are sent to the same pipeline so that a context is
+// aggregated by a single TimeSampler.
+// The shard is computed from the name, host and tags of the sample, the tags added by
+// the origin detection are not known yet at this point, they are the same for all the
+// samples of an origin. The tags removed by the metric tag filters are not part of the
+// shard so that the contexts aggregated together end up in the same pipeline.
+// Not safe for concurrent usage.
+type ContextSharder struct {
+	pipelineCount int
+	keyGenerator  *ckey.KeyGenerator
+	tagsBuffer    *tagset.HashingTagsAccumulator
+	tagFilter     *tagFilter
+}
+
+// newContextSharder returns a ContextSharder distributing the contexts between pipelineCount pipelines.
+func newContextSharder(pipelineCount int, tagFilters *tagFilterList) *ContextSharder {
+	if pipelineCount < 1 {
+		pipelineCount = 1
+	}
+	return &ContextSharder{
+		pipelineCount: pipelineCount,
+		keyGenerator:  ckey.NewKeyGenerator(),
+		tagsBuffer:    tagset.NewHashingTagsAccumulator(),
+		tagFilter:     newTagFilter(tagFilters),
+	}
+}
+
+// Shard returns the index of the pipeline of the sample.
+func (s *ContextSharder) Shard(sample *metrics.MetricSample) int {
+	if s.pipelineCount == 1 {
+		return 0
+	}
+	s.tagsBuffer.Append(sample.Tags...)
+	if s.tagFilter != nil {
+		s.tagFilter.apply(sample, s.tagsBuffer)
+	}
+	key := s.keyGenerator.Generate(sample.Name, sample.Host, s.tagsBuffer)
+	s.tagsBuffer.Reset()
+	return int(uint64(key) % uint64(s.pipelineCount))
+}
+
+// flushTrigger asks a timeSamplerWorker to flush the buckets closed before the given timestamp, in seconds.
+type flushTrigger struct {
+	before  float64
+	results chan<- timeSamplerFlush
+}
+
+// timeSamplerFlush holds the result of the flush of a timeSamplerWorker.
+type timeSamplerFlush struct {
+	series   metrics.Series
+	sketches metrics.SketchSeriesList
+	contexts int
+}
+
+// timeSamplerWorker aggregates the dogstatsd samples of a pipeline with its own TimeSampler,
+// in its own goroutine.
+type timeSamplerWorker struct {
+	sampler *TimeSampler
+
+	// samplesChan receives the batches of dogstatsd samples, the samples are added at the time
+	// they are processed.
+	samplesChan chan []metrics.MetricSample
+	// samplesWithTsChan receives the batches of samples carrying their timestamp, in nanoseconds.
+	samplesWithTsChan chan []metrics.MetricSample
+	// sampleWithTsChan receives the samples sent one by one, carrying their timestamp, in nanoseconds.
+	sampleWithTsChan chan metrics.MetricSample
+	flushChan        chan flushTrigger
+	stopChan         chan struct{}
+	// stopped is closed once the worker is stopped
+	stopped chan struct{}
+
+	metricSamplePool *metrics.MetricSamplePool
+}
+
+func newTimeSamplerWorker(bucketSize int64, bufferSize int, metricSamplePool *metrics.MetricSamplePool, limiter *contextLimiter, tagFilters *tagFilterList) *timeSamplerWorker {
+	return &timeSamplerWorker{
+		sampler:           newTimeSampler(bucketSize, limiter, tagFilters),
+		samplesChan:       make(chan []metrics.MetricSample, bufferSize),
+		samplesWithTsChan: make(chan []metrics.MetricSample, bufferSize),
+		sampleWithTsChan:  make(chan metrics.MetricSample, bufferSize),
+		flushChan:         make(chan flushTrigger),
+		stopChan:          make(chan struct{}),
+		stopped:           make(chan struct{}),
+		metricSamplePool:  metricSamplePool,
+	}
+}
+
+func (w *timeSamplerWorker) run() {
+	defer close(w.stopped)
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case ms := <-w.samplesChan:
+			w.addSamples(ms)
+		case ms := <-w.samplesWithTsChan:
+			w.addSamplesWithTs(ms)
+		case m := <-w.sampleWithTsChan:
+			w.addSampleWithTs(&m)
+		case trigger := <-w.flushChan:
+			// the samples queued before the flush is triggered are part of the flushed buckets
+			for n := len(w.samplesChan); n > 0; n-- {
+				w.addSamples(<-w.samplesChan)
+			}
+			for n := len(w.samplesWithTsChan); n > 0; n-- {
+				w.addSamplesWithTs(<-w.samplesWithTsChan)
+			}
+			for n := len(w.sampleWithTsChan); n > 0; n-- {
+				m := <-w.sampleWithTsChan
+				w.addSampleWithTs(&m)
+			}
+			series, sketches := w.sampler.flush(trigger.before)
+			trigger.results <- timeSamplerFlush{
+				series:   series,
+				sketches: sketches,
+				contexts: w.sampler.contextResolver.length(),
+			}
+		}
+	}
+}
+
+func (w *timeSamplerWorker) addSamples(ms []metrics.MetricSample) {
+	aggregatorDogstatsdMetricSample.Add(int64(len(ms)))
+	tlmProcessed.Add(float64(len(ms)), "dogstatsd_metrics")
+	t := timeNowNano()
+	for i := 0; i < len(ms); i++ {
+		w.sampler.addSample(&ms[i], t)
+	}
+	w.metricSamplePool.PutBatch(ms)
+}
+
+func (w *timeSamplerWorker) addSamplesWithTs(ms []metrics.MetricSample) {
+	aggregatorDogstatsdMetricSample.Add(int64(len(ms)))
+	tlmProcessed.Add(float64(len(ms)), "dogstatsd_metrics")
+	for i := 0; i < len(ms); i++ {
+		w.sampler.addSample(&ms[i], ms[i].Timestamp/float64(time.Second))
+	}
+}
+
+func (w *timeSamplerWorker) addSampleWithTs(m *metrics.MetricSample) {
+	aggregatorDogstatsdMetricSample.Add(1)
+	tlmProcessed.Inc("dogstatsd_metrics")
+	w.sampler.addSample(m, m.Timestamp/float64(time.Second))
+}
+
+// stop stops the worker and waits for it to exit.
+func (w *timeSamplerWorker) stop() {
+	close(w.stopChan)
+	<-w.stopped
+}
@@ -0,0 +1,126 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build test
+
+package aggregator
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/serializer"
+)
+
+func TestContextSharder(t *testing.T) {
+	sharder := newContextSharder(1, nil)
+	assert.Equal(t, 0, sharder.Shard(&metrics.MetricSample{Name: "my.metric", Tags: []string{"foo", "bar"}}))
+
+	sharder = newContextSharder(8, nil)
+	shards := map[int]struct{}{}
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("my.metric.%d", i)
+		shard := sharder.Shard(&metrics.MetricSample{Name: name, Host: "host", Tags: []string{"foo", "bar"}})
+		require.True(t, shard >= 0 && shard < 8)
+		// the order of the tags doesn't change the context
+		assert.Equal(t, shard, sharder.Shard(&metrics.MetricSample{Name: name, Host: "host", Tags: []string{"bar", "foo"}}))
+		shards[shard] = struct{}{}
+	}
+	assert.True(t, len(shards) > 1)
+}
+
+func TestTimeSamplerPipelines(t *testing.T) {
+	config.Datadog.Set("dogstatsd_pipeline_count", 4)
+	defer config.Datadog.Set("dogstatsd_pipeline_count", 1)
+
+	agg := NewBufferedAggregator(nil, nil, "hostname", DefaultFlushInterval)
+	require.Len(t, agg.statsdWorkers, 4)
+	require.Len(t, agg.GetBufferedMetricsChannels(), 4)
+	defer func() {
+		for _, worker := range agg.statsdWorkers {
+			worker.stop()
+		}
+	}()
+
+	timestamp := timeNowNano() - 100
+	var expected []string
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("my.metric.%d", i)
+		expected = append(expected, name)
+		// two samples per context, they have to be aggregated by the same sampler
+		for j := 0; j < 2; j++ {
+			agg.addSample(&metrics.MetricSample{
+				Name:       name,
+				Value:      1,
+				Mtype:      metrics.CountType,
+				Tags:       []string{"foo", "bar"},
+				SampleRate: 1,
+			}, timestamp)
+		}
+	}
+	agg.addSamplesWithTs([]metrics.MetricSample{{
+		Name:       "my.distribution",
+		Value:      1,
+		Mtype:      metrics.DistributionType,
+		SampleRate: 1,
+		Timestamp:  timestamp * float64(time.Second),
+	}})
+
+	series, sketches := agg.flushTimeSamplers(timeNowNano())
+
+	var names []string
+	for _, serie := range series {
+		names = append(names, serie.Name)
+		require.Len(t, serie.Points, 1)
+		assert.Equal(t, 2.0, serie.Points[0].Value)
+	}
+	sort.Strings(names)
+	sort.Strings(expected)
+	assert.Equal(t, expected, names)
+	require.Len(t, sketches, 1)
+	assert.Equal(t, "my.distribution", sketches[0].Name)
+	assert.Equal(t, int64(51), aggregatorDogstatsdContexts.Value())
+}
+
+func TestTimeSamplersStoppedAfterStopTimeout(t *testing.T) {
+	config.Datadog.Set("aggregator_stop_timeout", 1)
+	defer config.Datadog.Set("aggregator_stop_timeout", 2)
+
+	s := &serializer.MockSerializer{}
+	s.On("SendServiceChecks", mock.Anything).Return(nil)
+	s.On("SendSeries", mock.Anything).Return(nil)
+	s.On("SendSketch", mock.Anything).Return(nil)
+	agg := NewBufferedAggregator(s, nil, "hostname", DefaultFlushInterval)
+	go agg.run()
+
+	// the flush after stop times out
+	agg.flushMutex.Lock()
+	agg.Stop()
+	for _, worker := range agg.statsdWorkers {
+		select {
+		case <-worker.stopped:
+			require.Fail(t, "the time sampler is stopped while the flush is running")
+		default:
+		}
+	}
+
+	// the time samplers are stopped once the flush is done
+	agg.flushMutex.Unlock()
+	for _, worker := range agg.statsdWorkers {
+		select {
+		case <-worker.stopped:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "the time sampler is not stopped")
+		}
+	}
+}
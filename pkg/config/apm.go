@@ -29,6 +29,17 @@ func setupAPM(config Config) {
 	config.SetKnown("apm_config.obfuscation.remove_stack_traces")
 	config.SetKnown("apm_config.obfuscation.redis.enabled")
 	config.SetKnown("apm_config.obfuscation.memcached.enabled")
+	config.SetKnown("apm_config.obfuscation.sql.dbms")
+	config.SetKnown("apm_config.obfuscation.sql.table_names")
+	config.SetKnown("apm_config.obfuscation.sql.collect_commands")
+	config.SetKnown("apm_config.obfuscation.graphql.enabled")
+	config.SetKnown("apm_config.obfuscation.graphql.keep_values")
+	config.SetKnown("apm_config.obfuscation.grpc.enabled")
+	config.SetKnown("apm_config.obfuscation.grpc.keep_values")
+	config.SetKnown("apm_config.obfuscation.aws.enabled")
+	config.SetKnown("apm_config.obfuscation.aws.keep_values")
+	config.SetKnown("apm_config.obfuscation.messaging.enabled")
+	config.SetKnown("apm_config.obfuscation.messaging.keep_values")
 	config.SetKnown("apm_config.filter_tags.require")
 	config.SetKnown("apm_config.filter_tags.reject")
 	config.SetKnown("apm_config.extra_sample_rate")
@@ -44,6 +55,7 @@ func setupAPM(config Config) {
 	config.SetKnown("apm_config.bucket_size_seconds")
 	config.SetKnown("apm_config.watchdog_check_delay")
 	config.SetKnown("apm_config.sync_flushing")
+	config.SetKnown("apm_config.tail_sampling.policies")
 
 	if runtime.GOARCH == "386" && runtime.GOOS == "windows" {
 		// on Windows-32 bit, the trace agent isn't installed.  Set the default to disabled
@@ -57,6 +69,12 @@ func setupAPM(config Config) {
 	config.BindEnvAndSetDefault("apm_config.windows_pipe_buffer_size", 1_000_000, "DD_APM_WINDOWS_PIPE_BUFFER_SIZE")                          //nolint:errcheck
 	config.BindEnvAndSetDefault("apm_config.windows_pipe_security_descriptor", "D:AI(A;;GA;;;WD)", "DD_APM_WINDOWS_PIPE_SECURITY_DESCRIPTOR") //nolint:errcheck
 	config.BindEnvAndSetDefault("apm_config.remote_tagger", false, "DD_APM_REMOTE_TAGGER")                                                    //nolint:errcheck
+	config.BindEnvAndSetDefault("apm_config.zipkin_receiver.enabled", false, "DD_APM_ZIPKIN_RECEIVER_ENABLED")                                //nolint:errcheck
+	config.BindEnvAndSetDefault("apm_config.jaeger_receiver.enabled", false, "DD_APM_JAEGER_RECEIVER_ENABLED")                                //nolint:errcheck
+	config.BindEnvAndSetDefault("apm_config.tail_sampling.enabled", false, "DD_APM_TAIL_SAMPLING_ENABLED")                                    //nolint:errcheck
+	config.BindEnvAndSetDefault("apm_config.tail_sampling.decision_wait_seconds", 10, "DD_APM_TAIL_SAMPLING_DECISION_WAIT_SECONDS")           //nolint:errcheck
+	config.BindEnvAndSetDefault("apm_config.tail_sampling.max_traces", 10_000, "DD_APM_TAIL_SAMPLING_MAX_TRACES")                             //nolint:errcheck
+	config.BindEnvAndSetDefault("apm_config.tail_sampling.max_spans", 100_000, "DD_APM_TAIL_SAMPLING_MAX_SPANS")                              //nolint:errcheck
 
 	config.BindEnv("apm_config.max_catalog_services", "DD_APM_MAX_CATALOG_SERVICES")
 	config.BindEnv("apm_config.receiver_timeout", "DD_APM_RECEIVER_TIMEOUT")
@@ -150,10 +150,21 @@ type MappingProfile struct {
 
 // MetricMapping represent one mapping rule
 type MetricMapping struct {
-	Match     string            `mapstructure:"match" json:"match"`
-	MatchType string            `mapstructure:"match_type" json:"match_type"`
-	Name      string            `mapstructure:"name" json:"name"`
-	Tags      map[string]string `mapstructure:"tags" json:"tags"`
+	Match      string            `mapstructure:"match" json:"match"`
+	MatchType  string            `mapstructure:"match_type" json:"match_type"`
+	MatchTags  map[string]string `mapstructure:"match_tags" json:"match_tags"`
+	Name       string            `mapstructure:"name" json:"name"`
+	Tags       map[string]string `mapstructure:"tags" json:"tags"`
+	StaticTags []string          `mapstructure:"static_tags" json:"static_tags"`
+	MetricType string            `mapstructure:"metric_type" json:"metric_type"`
+	Drop       bool              `mapstructure:"drop" json:"drop"`
+}
+
+// MetricTagFilter represent a rule removing tags from the metrics matching a name pattern
+type MetricTagFilter struct {
+	MetricName string   `mapstructure:"metric_name" json:"metric_name"`
+	Action     string   `mapstructure:"action" json:"action"`
+	Tags       []string `mapstructure:"tags" json:"tags"`
 }
 
 // Warnings represent the warnings in the config
@@ -365,6 +376,7 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("histogram_percentiles", []string{"0.95"})
 	config.BindEnvAndSetDefault("aggregator_stop_timeout", 2)
 	config.BindEnvAndSetDefault("aggregator_buffer_size", 100)
+	config.BindEnvAndSetDefault("aggregator_flush_history_size", 0)          // number of flushes kept for the `agent metrics query` command, 0 means disabled
 	config.BindEnvAndSetDefault("basic_telemetry_add_container_tags", false) // configure adding the agent container tags to the basic agent telemetry metrics (e.g. `datadog.agent.running`)
 	// Serializer
 	config.BindEnvAndSetDefault("enable_stream_payload_serialization", true)
@@ -377,6 +389,12 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("serializer_max_payload_size", 2*megaByte+megaByte/2)
 	config.BindEnvAndSetDefault("serializer_max_uncompressed_payload_size", 4*megaByte)
 
+	// Compression of the payloads, the algorithm selected at build time with its default level is used by default
+	config.BindEnvAndSetDefault("serializer_compression_kind", "")
+	config.BindEnvAndSetDefault("serializer_compression_level", 0)
+	config.SetKnown("serializer_compression_per_endpoint")
+
+	config.BindEnvAndSetDefault("use_v2_api.series", false) // send the series as streamed protobuf payloads to the v2 series intake
 	config.BindEnvAndSetDefault("use_v2_api.events", false)
 	config.BindEnvAndSetDefault("use_v2_api.service_checks", false)
 	// Serializer: allow user to blacklist any kind of payload to be sent
@@ -385,6 +403,11 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("enable_payloads.service_checks", true)
 	config.BindEnvAndSetDefault("enable_payloads.sketches", true)
 	config.BindEnvAndSetDefault("enable_payloads.json_to_v1_intake", true)
+	// Serializer: export the metrics to a Prometheus remote write URL
+	config.BindEnvAndSetDefault("metrics_remote_write_url", "")
+	config.BindEnvAndSetDefault("metrics_remote_write_headers", map[string]string{})
+	config.BindEnvAndSetDefault("metrics_remote_write_timeout", 10) // in seconds
+	config.BindEnvAndSetDefault("metrics_remote_write_queue_size", 10)
 
 	// Forwarder
 	config.BindEnvAndSetDefault("additional_endpoints", map[string][]string{})
@@ -401,14 +424,24 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("forwarder_backoff_max", 64)
 	config.BindEnvAndSetDefault("forwarder_recovery_interval", DefaultForwarderRecoveryInterval)
 	config.BindEnvAndSetDefault("forwarder_recovery_reset", false)
+	config.BindEnvAndSetDefault("forwarder_retry_queue_per_endpoint", false)
+	config.BindEnvAndSetDefault("forwarder_retry_queue_endpoint_priorities", map[string]int{})
 
 	// Forwarder storage on disk
 	config.BindEnvAndSetDefault("forwarder_storage_path", "")
 	config.BindEnvAndSetDefault("forwarder_outdated_file_in_days", 10)
 	config.BindEnvAndSetDefault("forwarder_flush_to_disk_mem_ratio", 0.5)
 	config.BindEnvAndSetDefault("forwarder_storage_max_size_in_bytes", 0) // 0 means disabled. This is a BETA feature.
+	config.BindEnvAndSetDefault("forwarder_storage_encryption_key", "")   // Empty means the transactions are stored unencrypted.
 	config.BindEnvAndSetDefault("forwarder_storage_max_disk_ratio", 0.80) // Do not store transactions on disk when the disk usage exceeds 80% of the disk capacity. Use 80% as some applications do not behave well when the disk space is very small.
 
+	// Forwarder capture to files
+	config.BindEnvAndSetDefault("forwarder_capture_enabled", false)
+	config.BindEnvAndSetDefault("forwarder_capture_path", "")
+	config.BindEnvAndSetDefault("forwarder_capture_file_max_size_in_bytes", 10*1024*1024)
+	config.BindEnvAndSetDefault("forwarder_capture_file_rotation_interval", 300) // in seconds
+	config.BindEnvAndSetDefault("forwarder_capture_max_size_in_bytes", 1024*1024*1024)
+
 	// Forwarder channels buffer size
 	config.BindEnvAndSetDefault("forwarder_high_prio_buffer_size", 100)
 	config.BindEnvAndSetDefault("forwarder_low_prio_buffer_size", 100)
@@ -431,9 +464,17 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("dogstatsd_packet_buffer_size", 32)
 	config.BindEnvAndSetDefault("dogstatsd_packet_buffer_flush_timeout", 100*time.Millisecond)
 	config.BindEnvAndSetDefault("dogstatsd_queue_size", 1024)
+	// Number of pipelines aggregating the dogstatsd samples, the contexts are sharded between them.
+	config.BindEnvAndSetDefault("dogstatsd_pipeline_count", 1)
+	// Limits of the number of dogstatsd contexts, 0 means no limit. The samples of the new contexts over
+	// the limits are dropped, or collapsed by removing the tag of the metric having the most values.
+	config.BindEnvAndSetDefault("dogstatsd_context_limit", 0)
+	config.BindEnvAndSetDefault("dogstatsd_context_limit_per_metric", 0)
+	config.BindEnvAndSetDefault("dogstatsd_context_limit_action", "drop") // Options are: drop, collapse
 
 	config.BindEnvAndSetDefault("dogstatsd_non_local_traffic", false)
-	config.BindEnvAndSetDefault("dogstatsd_socket", "") // Notice: empty means feature disabled
+	config.BindEnvAndSetDefault("dogstatsd_socket", "")           // Notice: empty means feature disabled
+	config.BindEnvAndSetDefault("dogstatsd_remote_write_port", 0) // Notice: 0 means Prometheus remote write receiver disabled
 	config.BindEnvAndSetDefault("dogstatsd_stats_port", 5000)
 	config.BindEnvAndSetDefault("dogstatsd_stats_enable", false)
 	config.BindEnvAndSetDefault("dogstatsd_stats_buffer", 10)
@@ -469,6 +510,15 @@ func InitConfig(config Config) {
 		return mappings
 	})
 
+	config.BindEnv("metric_tag_filterlist")
+	config.SetEnvKeyTransformer("metric_tag_filterlist", func(in string) interface{} {
+		var filters []MetricTagFilter
+		if err := json.Unmarshal([]byte(in), &filters); err != nil {
+			log.Errorf(`"metric_tag_filterlist" can not be parsed: %v`, err)
+		}
+		return filters
+	})
+
 	config.BindEnvAndSetDefault("statsd_forward_host", "")
 	config.BindEnvAndSetDefault("statsd_forward_port", 0)
 	config.BindEnvAndSetDefault("statsd_metric_namespace", "")
@@ -731,6 +781,9 @@ func InitConfig(config Config) {
 	// This field lets you increase the read timeout to prevent the client from
 	// timing out too early in such a situation. Value in seconds.
 	config.BindEnvAndSetDefault("logs_config.docker_client_read_timeout", 30)
+	// Additional OTLP/HTTP receiver to dual-ship logs to when logs are sent over HTTP.
+	config.BindEnvAndSetDefault("logs_config.otlp_http_endpoint", "")
+	config.BindEnvAndSetDefault("logs_config.otlp_http_headers", map[string]string{})
 	// Internal Use Only: avoid modifying those configuration parameters, this could lead to unexpected results.
 	config.BindEnvAndSetDefault("logs_config.run_path", defaultRunPath)
 	config.BindEnvAndSetDefault("logs_config.use_http", false)
@@ -754,8 +807,17 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("logs_config.auto_multi_line_default_sample_size", 500)
 	config.BindEnvAndSetDefault("logs_config.auto_multi_line_default_match_timeout", 30) // Seconds
 	config.BindEnvAndSetDefault("logs_config.auto_multi_line_default_match_threshold", 0.48)
+	config.BindEnvAndSetDefault("logs_config.auto_multi_line_default_stack_trace_threshold", 0.05)
+	config.BindEnvAndSetDefault("logs_config.auto_multi_line_default_max_lines", 0) // 0 means no limit
 
 	config.BindEnvAndSetDefault("logs_config.auditor_ttl", DefaultAuditorTTL) // in hours
+	// Storage of the auditor registry, either "json" or "journal"
+	config.BindEnvAndSetDefault("logs_config.auditor_storage", "json")
+	// Disk buffer for the logs that can't be sent over HTTP, 0 means disabled
+	config.BindEnvAndSetDefault("logs_config.disk_buffer_max_size_in_bytes", 0)
+	config.BindEnvAndSetDefault("logs_config.disk_buffer_path", "")
+	config.BindEnvAndSetDefault("logs_config.disk_buffer_max_disk_ratio", 0.80)
+	config.BindEnvAndSetDefault("logs_config.disk_buffer_drop_policy", "drop_oldest")
 	// Timeout in milliseonds used when performing agreggation operations,
 	// including multi-line log processing rules and chunked line reaggregation.
 	// It may be useful to increase it when logs writing is slowed down, that
@@ -1478,6 +1540,22 @@ func getDogstatsdMappingProfilesConfig(config Config) ([]MappingProfile, error)
 	return mappings, nil
 }
 
+// GetMetricTagFilterList returns the rules used by the aggregator to remove tags from the metrics
+func GetMetricTagFilterList() ([]MetricTagFilter, error) {
+	return getMetricTagFilterListConfig(Datadog)
+}
+
+func getMetricTagFilterListConfig(config Config) ([]MetricTagFilter, error) {
+	var filters []MetricTagFilter
+	if config.IsSet("metric_tag_filterlist") {
+		err := config.UnmarshalKey("metric_tag_filterlist", &filters)
+		if err != nil {
+			return []MetricTagFilter{}, log.Errorf("Could not parse metric_tag_filterlist: %v", err)
+		}
+	}
+	return filters, nil
+}
+
 // IsCLCRunner returns whether the Agent is in cluster check runner mode
 func IsCLCRunner() bool {
 	if !Datadog.GetBool("clc_runner_enabled") {
@@ -991,6 +991,43 @@ func TestDogstatsdMappingProfilesEnv(t *testing.T) {
 	assert.Equal(t, mappings, expected)
 }
 
+func TestMetricTagFilterList(t *testing.T) {
+	datadogYaml := `
+metric_tag_filterlist:
+  - metric_name: "http.request.*"
+    action: exclude
+    tags:
+      - request_id
+      - user_id
+  - metric_name: "queue.size"
+    action: include
+    tags:
+      - env
+`
+	testConfig := setupConfFromYAML(datadogYaml)
+
+	filters, err := getMetricTagFilterListConfig(testConfig)
+
+	expected := []MetricTagFilter{
+		{MetricName: "http.request.*", Action: "exclude", Tags: []string{"request_id", "user_id"}},
+		{MetricName: "queue.size", Action: "include", Tags: []string{"env"}},
+	}
+	assert.Nil(t, err)
+	assert.EqualValues(t, expected, filters)
+}
+
+func TestMetricTagFilterListEnv(t *testing.T) {
+	env := "DD_METRIC_TAG_FILTERLIST"
+	err := os.Setenv(env, `[{"metric_name":"http.request.*","action":"exclude","tags":["request_id"]}]`)
+	assert.Nil(t, err)
+	defer os.Unsetenv(env)
+	expected := []MetricTagFilter{
+		{MetricName: "http.request.*", Action: "exclude", Tags: []string{"request_id"}},
+	}
+	filters, _ := GetMetricTagFilterList()
+	assert.Equal(t, expected, filters)
+}
+
 func TestPrometheusScrapeChecksEnv(t *testing.T) {
 	env := "DD_PROMETHEUS_SCRAPE_CHECKS"
 	err := os.Setenv(env, `[{"configurations":[{"timeout":5,"send_distribution_buckets":true}],"autodiscovery":{"kubernetes_container_names":["my-app"],"kubernetes_annotations":{"include":{"custom_label":"true"}}}}]`)
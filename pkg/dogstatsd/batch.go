@@ -10,25 +10,36 @@ import (
 // batcher batches multiple metrics before submission
 // this struct is not safe for concurrent use
 type batcher struct {
-	samples      []metrics.MetricSample
-	samplesCount int
+	// samples are batched per pipeline of the aggregator
+	samples      [][]metrics.MetricSample
+	samplesCount []int
 
 	events        []*metrics.Event
 	serviceChecks []*metrics.ServiceCheck
 
 	// output channels
-	choutSamples       chan<- []metrics.MetricSample
+	choutSamples       []chan []metrics.MetricSample
 	choutEvents        chan<- []*metrics.Event
 	choutServiceChecks chan<- []*metrics.ServiceCheck
 
 	metricSamplePool *metrics.MetricSamplePool
+	sharder          *aggregator.ContextSharder
 }
 
 func newBatcher(agg *aggregator.BufferedAggregator) *batcher {
-	s, e, sc := agg.GetBufferedChannels()
+	_, e, sc := agg.GetBufferedChannels()
+	s := agg.GetBufferedMetricsChannels()
+
+	samples := make([][]metrics.MetricSample, len(s))
+	for i := range samples {
+		samples[i] = agg.MetricSamplePool.GetBatch()
+	}
+
 	return &batcher{
-		samples:            agg.MetricSamplePool.GetBatch(),
+		samples:            samples,
+		samplesCount:       make([]int, len(s)),
 		metricSamplePool:   agg.MetricSamplePool,
+		sharder:            agg.NewContextSharder(),
 		choutSamples:       s,
 		choutEvents:        e,
 		choutServiceChecks: sc,
@@ -36,11 +47,12 @@ func newBatcher(agg *aggregator.BufferedAggregator) *batcher {
 }
 
 func (b *batcher) appendSample(sample metrics.MetricSample) {
-	if b.samplesCount == len(b.samples) {
-		b.flushSamples()
+	shard := b.sharder.Shard(&sample)
+	if b.samplesCount[shard] == len(b.samples[shard]) {
+		b.flushSamples(shard)
 	}
-	b.samples[b.samplesCount] = sample
-	b.samplesCount++
+	b.samples[shard][b.samplesCount[shard]] = sample
+	b.samplesCount[shard]++
 }
 
 func (b *batcher) appendEvent(event *metrics.Event) {
@@ -51,21 +63,23 @@ func (b *batcher) appendServiceCheck(serviceCheck *metrics.ServiceCheck) {
 	b.serviceChecks = append(b.serviceChecks, serviceCheck)
 }
 
-func (b *batcher) flushSamples() {
-	if b.samplesCount > 0 {
+func (b *batcher) flushSamples(shard int) {
+	if b.samplesCount[shard] > 0 {
 		t1 := time.Now()
-		b.choutSamples <- b.samples[:b.samplesCount]
+		b.choutSamples[shard] <- b.samples[shard][:b.samplesCount[shard]]
 		t2 := time.Now()
 		tlmChannel.Observe(float64(t2.Sub(t1).Nanoseconds()), "metrics")
 
-		b.samplesCount = 0
-		b.samples = b.metricSamplePool.GetBatch()
+		b.samplesCount[shard] = 0
+		b.samples[shard] = b.metricSamplePool.GetBatch()
 	}
 }
 
 // flush pushes all batched metrics to the aggregator.
 func (b *batcher) flush() {
-	b.flushSamples()
+	for shard := range b.samples {
+		b.flushSamples(shard)
+	}
 	if len(b.events) > 0 {
 		t1 := time.Now()
 		b.choutEvents <- b.events
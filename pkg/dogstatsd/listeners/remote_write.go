@@ -0,0 +1,205 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/packets"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// RemoteWritePath is the path the Prometheus remote write requests are sent to
+	RemoteWritePath = "/api/v1/write"
+
+	// maxRemoteWriteRequestSize is the maximum size of a decompressed remote write request
+	maxRemoteWriteRequestSize = 32 * 1024 * 1024
+
+	metricNameLabel = "__name__"
+)
+
+var (
+	remoteWriteExpvars        = expvar.NewMap("dogstatsd-remote-write")
+	remoteWriteRequests       = expvar.Int{}
+	remoteWriteRequestErrors  = expvar.Int{}
+	remoteWriteSamples        = expvar.Int{}
+	remoteWriteDroppedSamples = expvar.Int{}
+
+	// tag separators of the dogstatsd protocol can't be used in tags
+	tagValueReplacer = strings.NewReplacer(",", "_", "|", "_", "\n", "_")
+	// name separators of the dogstatsd protocol can't be used in names, Prometheus uses `:` in recording rules names
+	metricNameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "\n", "_")
+)
+
+func init() {
+	remoteWriteExpvars.Set("Requests", &remoteWriteRequests)
+	remoteWriteExpvars.Set("RequestErrors", &remoteWriteRequestErrors)
+	remoteWriteExpvars.Set("Samples", &remoteWriteSamples)
+	remoteWriteExpvars.Set("DroppedSamples", &remoteWriteDroppedSamples)
+}
+
+// RemoteWriteListener implements the StatsdListener interface for the Prometheus remote write protocol.
+// It receives the snappy compressed protobuf requests over HTTP and converts their samples into
+// dogstatsd gauges, the labels of the series are converted into tags.
+// Origin detection is not implemented for remote write.
+type RemoteWriteListener struct {
+	listener        net.Listener
+	server          *http.Server
+	packetsBuffer   *packets.Buffer
+	packetAssembler *packets.Assembler
+	maxMessageSize  int
+}
+
+// NewRemoteWriteListener returns an idle Prometheus remote write listener
+func NewRemoteWriteListener(packetOut chan packets.Packets, sharedPacketPoolManager *packets.PoolManager) (*RemoteWriteListener, error) {
+	var url string
+	if config.Datadog.GetBool("dogstatsd_non_local_traffic") == true {
+		// Listen to all network interfaces
+		url = fmt.Sprintf(":%d", config.Datadog.GetInt("dogstatsd_remote_write_port"))
+	} else {
+		url = net.JoinHostPort(config.GetBindHost(), config.Datadog.GetString("dogstatsd_remote_write_port"))
+	}
+
+	listener, err := net.Listen("tcp", url)
+	if err != nil {
+		return nil, fmt.Errorf("can't listen: %s", err)
+	}
+
+	packetsBufferSize := config.Datadog.GetInt("dogstatsd_packet_buffer_size")
+	flushTimeout := config.Datadog.GetDuration("dogstatsd_packet_buffer_flush_timeout")
+
+	packetsBuffer := packets.NewBuffer(uint(packetsBufferSize), flushTimeout, packetOut)
+	packetAssembler := packets.NewAssembler(flushTimeout, packetsBuffer, sharedPacketPoolManager, packets.RemoteWrite)
+
+	l := &RemoteWriteListener{
+		listener:        listener,
+		packetsBuffer:   packetsBuffer,
+		packetAssembler: packetAssembler,
+		maxMessageSize:  config.Datadog.GetInt("dogstatsd_buffer_size"),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(RemoteWritePath, l.handleWrite)
+	l.server = &http.Server{
+		Handler:     mux,
+		ReadTimeout: 30 * time.Second,
+	}
+	log.Debugf("dogstatsd-remote-write: %s successfully initialized", listener.Addr())
+	return l, nil
+}
+
+// Listen runs the HTTP server. Should be called in its own goroutine
+func (l *RemoteWriteListener) Listen() {
+	log.Infof("dogstatsd-remote-write: starting to listen on %s", l.listener.Addr())
+	if err := l.server.Serve(l.listener); err != nil && err != http.ErrServerClosed {
+		log.Errorf("dogstatsd-remote-write: error serving requests: %v", err)
+	}
+}
+
+// Stop closes the HTTP server and stops listening
+func (l *RemoteWriteListener) Stop() {
+	l.server.Close()
+	l.packetAssembler.Close()
+	l.packetsBuffer.Close()
+}
+
+func (l *RemoteWriteListener) handleWrite(w http.ResponseWriter, r *http.Request) {
+	remoteWriteRequests.Add(1)
+	if r.Method != http.MethodPost {
+		remoteWriteRequestErrors.Add(1)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	series, err := readWriteRequest(w, r)
+	if err != nil {
+		// the remote write clients don't retry the requests failing with a client error
+		log.Debugf("dogstatsd-remote-write: invalid request: %v", err)
+		remoteWriteRequestErrors.Add(1)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var message []byte
+	for _, ts := range series {
+		for _, value := range ts.values {
+			message = appendMessage(message[:0], ts.labels, value)
+			if len(message) == 0 || len(message) > l.maxMessageSize {
+				remoteWriteDroppedSamples.Add(1)
+				continue
+			}
+			remoteWriteSamples.Add(1)
+			l.packetAssembler.AddMessage(message)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func readWriteRequest(w http.ResponseWriter, r *http.Request) ([]remoteWriteTimeSeries, error) {
+	compressed, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRemoteWriteRequestSize))
+	if err != nil {
+		return nil, fmt.Errorf("can't read the request: %v", err)
+	}
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("can't decompress the request: %v", err)
+	}
+	if size > maxRemoteWriteRequestSize {
+		return nil, fmt.Errorf("the decompressed request is too large: %d bytes", size)
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("can't decompress the request: %v", err)
+	}
+	return unmarshalWriteRequest(data)
+}
+
+// appendMessage appends the sample as a dogstatsd gauge to the message, the labels are converted into tags.
+// Nothing is appended if the series has no name or if the value is not finite, this includes the
+// staleness markers of Prometheus.
+func appendMessage(message []byte, labels []remoteWriteLabel, value float64) []byte {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return message
+	}
+	name := ""
+	for _, label := range labels {
+		if label.name == metricNameLabel {
+			name = label.value
+			break
+		}
+	}
+	if name == "" {
+		return message
+	}
+
+	message = append(message, metricNameReplacer.Replace(name)...)
+	message = append(message, ':')
+	message = strconv.AppendFloat(message, value, 'f', -1, 64)
+	message = append(message, "|g"...)
+	separator := "|#"
+	for _, label := range labels {
+		if label.name == metricNameLabel || label.value == "" {
+			continue
+		}
+		message = append(message, separator...)
+		message = append(message, label.name...)
+		message = append(message, ':')
+		message = append(message, tagValueReplacer.Replace(label.value)...)
+		separator = ","
+	}
+	return message
+}
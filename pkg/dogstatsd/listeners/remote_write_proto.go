@@ -0,0 +1,146 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The Prometheus remote write messages are decoded by hand to only read the
+// fields used by the listener, see https://github.com/prometheus/prometheus/blob/main/prompb/remote.proto
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+const (
+	writeRequestTimeSeriesField = 1
+	timeSeriesLabelsField       = 1
+	timeSeriesSamplesField      = 2
+	labelNameField              = 1
+	labelValueField             = 2
+	sampleValueField            = 1
+)
+
+type remoteWriteLabel struct {
+	name  string
+	value string
+}
+
+type remoteWriteTimeSeries struct {
+	labels []remoteWriteLabel
+	values []float64
+}
+
+// unmarshalWriteRequest returns the time series of a Prometheus remote write request.
+func unmarshalWriteRequest(b []byte) ([]remoteWriteTimeSeries, error) {
+	var series []remoteWriteTimeSeries
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != writeRequestTimeSeriesField || typ != protowire.BytesType {
+			return nil
+		}
+		ts, err := unmarshalTimeSeries(value)
+		if err != nil {
+			return err
+		}
+		series = append(series, ts)
+		return nil
+	})
+	return series, err
+}
+
+func unmarshalTimeSeries(b []byte) (remoteWriteTimeSeries, error) {
+	var ts remoteWriteTimeSeries
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case timeSeriesLabelsField:
+			label, err := unmarshalLabel(value)
+			if err != nil {
+				return err
+			}
+			ts.labels = append(ts.labels, label)
+		case timeSeriesSamplesField:
+			sample, err := unmarshalSample(value)
+			if err != nil {
+				return err
+			}
+			ts.values = append(ts.values, sample)
+		}
+		return nil
+	})
+	return ts, err
+}
+
+func unmarshalLabel(b []byte) (remoteWriteLabel, error) {
+	var label remoteWriteLabel
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case labelNameField:
+			label.name = string(value)
+		case labelValueField:
+			label.value = string(value)
+		}
+		return nil
+	})
+	return label, err
+}
+
+func unmarshalSample(b []byte) (float64, error) {
+	var sample float64
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != sampleValueField || typ != protowire.Fixed64Type {
+			return nil
+		}
+		v, n := protowire.ConsumeFixed64(value)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		sample = math.Float64frombits(v)
+		return nil
+	})
+	return sample, err
+}
+
+// consumeFields calls fn with the raw value of each field of the message, the value of
+// the length-delimited fields is their content.
+func consumeFields(b []byte, fn func(num protowire.Number, typ protowire.Type, value []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("invalid field tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		value := b
+		if typ == protowire.BytesType {
+			var m int
+			value, m = protowire.ConsumeBytes(b)
+			if m < 0 {
+				return fmt.Errorf("invalid field %d: %v", num, protowire.ParseError(m))
+			}
+			n = m
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return fmt.Errorf("invalid field %d: %v", num, protowire.ParseError(n))
+			}
+			value = b[:n]
+		}
+		if err := fn(num, typ, value); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
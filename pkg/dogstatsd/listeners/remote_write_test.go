@@ -0,0 +1,153 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/packets"
+)
+
+var (
+	packetPoolRemoteWrite        = packets.NewPool(config.Datadog.GetInt("dogstatsd_buffer_size"))
+	packetPoolManagerRemoteWrite = packets.NewPoolManager(packetPoolRemoteWrite)
+)
+
+func TestRemoteWriteReceive(t *testing.T) {
+	port, err := getAvailableTCPPort()
+	require.NoError(t, err)
+	config.Datadog.SetDefault("dogstatsd_remote_write_port", port)
+	defer config.Datadog.SetDefault("dogstatsd_remote_write_port", 0)
+
+	packetChannel := make(chan packets.Packets)
+	s, err := NewRemoteWriteListener(packetChannel, packetPoolManagerRemoteWrite)
+	require.NoError(t, err)
+	go s.Listen()
+	defer s.Stop()
+
+	request := marshalWriteRequest(
+		[]remoteWriteTimeSeries{
+			{
+				labels: []remoteWriteLabel{{"__name__", "http_requests_total"}, {"code", "200"}, {"path", "/a,b"}},
+				values: []float64{12, math.NaN()},
+			},
+			{
+				labels: []remoteWriteLabel{{"__name__", "job:latency:p99"}, {"job", "api"}},
+				values: []float64{0.25},
+			},
+			{
+				labels: []remoteWriteLabel{{"job", "unnamed"}},
+				values: []float64{1},
+			},
+		},
+	)
+	resp, err := http.Post(fmt.Sprintf("http://127.0.0.1:%d%s", port, RemoteWritePath), "application/x-protobuf", bytes.NewReader(snappy.Encode(nil, request)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	select {
+	case pkts := <-packetChannel:
+		require.Len(t, pkts, 1)
+		assert.Equal(t, "http_requests_total:12|g|#code:200,path:/a_b\njob_latency_p99:0.25|g|#job:api", string(pkts[0].Contents))
+		assert.Equal(t, packets.RemoteWrite, pkts[0].Source)
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+}
+
+func TestRemoteWriteInvalidRequest(t *testing.T) {
+	port, err := getAvailableTCPPort()
+	require.NoError(t, err)
+	config.Datadog.SetDefault("dogstatsd_remote_write_port", port)
+	defer config.Datadog.SetDefault("dogstatsd_remote_write_port", 0)
+
+	s, err := NewRemoteWriteListener(nil, packetPoolManagerRemoteWrite)
+	require.NoError(t, err)
+	go s.Listen()
+	defer s.Stop()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", port, RemoteWritePath)
+	resp, err := http.Post(url, "application/x-protobuf", bytes.NewReader([]byte("not snappy")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestUnmarshalWriteRequest(t *testing.T) {
+	series := []remoteWriteTimeSeries{
+		{
+			labels: []remoteWriteLabel{{"__name__", "up"}, {"instance", "localhost:9090"}},
+			values: []float64{1, 0},
+		},
+	}
+	actual, err := unmarshalWriteRequest(marshalWriteRequest(series))
+	require.NoError(t, err)
+	assert.Equal(t, series, actual)
+
+	_, err = unmarshalWriteRequest([]byte{0x0a, 0x10, 0x01})
+	assert.Error(t, err)
+}
+
+// marshalWriteRequest encodes the series as a Prometheus remote write request, the samples
+// have a timestamp and the request has metadata to make sure the unused fields are skipped.
+func marshalWriteRequest(series []remoteWriteTimeSeries) []byte {
+	var request []byte
+	for _, ts := range series {
+		var b []byte
+		for _, label := range ts.labels {
+			var l []byte
+			l = protowire.AppendTag(l, labelNameField, protowire.BytesType)
+			l = protowire.AppendString(l, label.name)
+			l = protowire.AppendTag(l, labelValueField, protowire.BytesType)
+			l = protowire.AppendString(l, label.value)
+			b = protowire.AppendTag(b, timeSeriesLabelsField, protowire.BytesType)
+			b = protowire.AppendBytes(b, l)
+		}
+		for _, value := range ts.values {
+			var s []byte
+			s = protowire.AppendTag(s, sampleValueField, protowire.Fixed64Type)
+			s = protowire.AppendFixed64(s, math.Float64bits(value))
+			s = protowire.AppendTag(s, 2, protowire.VarintType)
+			s = protowire.AppendVarint(s, uint64(time.Now().UnixNano()/int64(time.Millisecond)))
+			b = protowire.AppendTag(b, timeSeriesSamplesField, protowire.BytesType)
+			b = protowire.AppendBytes(b, s)
+		}
+		request = protowire.AppendTag(request, writeRequestTimeSeriesField, protowire.BytesType)
+		request = protowire.AppendBytes(request, b)
+	}
+	// metadata
+	request = protowire.AppendTag(request, 3, protowire.BytesType)
+	request = protowire.AppendBytes(request, protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), 1))
+	return request
+}
+
+// getAvailableTCPPort requests a random port number and makes sure it is available
+func getAvailableTCPPort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return -1, fmt.Errorf("can't find an available tcp port: %s", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -23,6 +23,23 @@ const (
 	matchTypeRegex    = "regex"
 )
 
+// Metric types a mapping can override the type of a metric with
+const (
+	MetricTypeGauge        = "gauge"
+	MetricTypeCount        = "count"
+	MetricTypeDistribution = "distribution"
+	MetricTypeHistogram    = "histogram"
+	MetricTypeTiming       = "timing"
+)
+
+var allowedMetricTypes = map[string]struct{}{
+	MetricTypeGauge:        {},
+	MetricTypeCount:        {},
+	MetricTypeDistribution: {},
+	MetricTypeHistogram:    {},
+	MetricTypeTiming:       {},
+}
+
 // MetricMapper contains mappings and cache instance
 type MetricMapper struct {
 	Profiles []MappingProfile
@@ -34,19 +51,29 @@ type MappingProfile struct {
 	Name     string
 	Prefix   string
 	Mappings []*MetricMapping
+	// the results of the profiles matching on tags depend on the tags, they can't be cached by metric name
+	matchesTags bool
 }
 
 // MetricMapping represent one mapping rule
 type MetricMapping struct {
-	name  string
-	tags  map[string]string
-	regex *regexp.Regexp
+	name       string
+	tags       map[string]string
+	staticTags []string
+	matchTags  map[string]*regexp.Regexp
+	metricType string
+	drop       bool
+	regex      *regexp.Regexp
 }
 
 // MapResult represent the outcome of the mapping
 type MapResult struct {
-	Name    string
-	Tags    []string
+	Name string
+	Tags []string
+	// MetricType is the type the metric must be converted to, empty to keep its type
+	MetricType string
+	// Drop is true if the metric must be dropped
+	Drop    bool
 	matched bool
 }
 
@@ -69,17 +96,39 @@ func NewMetricMapper(configProfiles []config.MappingProfile, cacheSize int) (*Me
 			if matchType != matchTypeWildcard && matchType != matchTypeRegex {
 				return nil, fmt.Errorf("profile: %s, mapping num %d: invalid match type, must be `wildcard` or `regex`", profile.Name, i)
 			}
-			if currentMapping.Name == "" {
+			if currentMapping.Name == "" && !currentMapping.Drop {
 				return nil, fmt.Errorf("profile: %s, mapping num %d: name is required", profile.Name, i)
 			}
 			if currentMapping.Match == "" {
 				return nil, fmt.Errorf("profile: %s, mapping num %d: match is required", profile.Name, i)
 			}
+			if _, found := allowedMetricTypes[currentMapping.MetricType]; currentMapping.MetricType != "" && !found {
+				return nil, fmt.Errorf("profile: %s, mapping num %d: invalid metric type `%s`, must be one of `gauge`, `count`, `distribution`, `histogram` or `timing`", profile.Name, i, currentMapping.MetricType)
+			}
 			regex, err := buildRegex(currentMapping.Match, matchType)
 			if err != nil {
 				return nil, err
 			}
-			profile.Mappings = append(profile.Mappings, &MetricMapping{name: currentMapping.Name, tags: currentMapping.Tags, regex: regex})
+			matchTags := make(map[string]*regexp.Regexp, len(currentMapping.MatchTags))
+			for tagKey, tagValueRe := range currentMapping.MatchTags {
+				tagRegex, err := regexp.Compile("^" + tagValueRe + "$")
+				if err != nil {
+					return nil, fmt.Errorf("profile: %s, mapping num %d: invalid match_tags regex `%s` for tag `%s`: %v", profile.Name, i, tagValueRe, tagKey, err)
+				}
+				matchTags[tagKey] = tagRegex
+			}
+			if len(matchTags) > 0 {
+				profile.matchesTags = true
+			}
+			profile.Mappings = append(profile.Mappings, &MetricMapping{
+				name:       currentMapping.Name,
+				tags:       currentMapping.Tags,
+				staticTags: currentMapping.StaticTags,
+				matchTags:  matchTags,
+				metricType: currentMapping.MetricType,
+				drop:       currentMapping.Drop,
+				regex:      regex,
+			})
 		}
 		profiles = append(profiles, profile)
 	}
@@ -108,45 +157,75 @@ func buildRegex(matchRe string, matchType string) (*regexp.Regexp, error) {
 	return regex, nil
 }
 
-// Map returns a MapResult
-func (m *MetricMapper) Map(metricName string) *MapResult {
+// Map returns a MapResult if the metric is mapped, the tags of the metric are used
+// by the mappings matching on tags.
+func (m *MetricMapper) Map(metricName string, tags []string) *MapResult {
 	for _, profile := range m.Profiles {
 		if !strings.HasPrefix(metricName, profile.Prefix) && profile.Prefix != "*" {
 			continue
 		}
-		result, cached := m.cache.get(metricName)
-		if cached {
-			if result.matched {
-				return result
+		if !profile.matchesTags {
+			result, cached := m.cache.get(metricName)
+			if cached {
+				if result.matched {
+					return result
+				}
+				return nil
 			}
-			return nil
 		}
 		for _, mapping := range profile.Mappings {
 			matches := mapping.regex.FindStringSubmatchIndex(metricName)
-			if len(matches) == 0 {
+			if len(matches) == 0 || !mapping.matchesTags(tags) {
 				continue
 			}
 
-			name := string(mapping.regex.ExpandString(
-				[]byte{},
-				mapping.name,
-				metricName,
-				matches,
-			))
-
-			var tags []string
-			for tagKey, tagValueExpr := range mapping.tags {
-				tagValue := string(mapping.regex.ExpandString([]byte{}, tagValueExpr, metricName, matches))
-				tags = append(tags, tagKey+":"+tagValue)
+			mapResult := &MapResult{Drop: mapping.drop, MetricType: mapping.metricType, matched: true}
+			if !mapping.drop {
+				mapResult.Name = string(mapping.regex.ExpandString(
+					[]byte{},
+					mapping.name,
+					metricName,
+					matches,
+				))
+
+				for tagKey, tagValueExpr := range mapping.tags {
+					tagValue := string(mapping.regex.ExpandString([]byte{}, tagValueExpr, metricName, matches))
+					mapResult.Tags = append(mapResult.Tags, tagKey+":"+tagValue)
+				}
+				mapResult.Tags = append(mapResult.Tags, mapping.staticTags...)
 			}
 
-			mapResult := &MapResult{Name: name, matched: true, Tags: tags}
-			m.cache.add(metricName, mapResult)
+			if !profile.matchesTags {
+				m.cache.add(metricName, mapResult)
+			}
 			return mapResult
 		}
-		mapResult := &MapResult{matched: false}
-		m.cache.add(metricName, mapResult)
+		if !profile.matchesTags {
+			m.cache.add(metricName, &MapResult{matched: false})
+		}
 		return nil
 	}
 	return nil
 }
+
+// matchesTags returns true if, for each tag key the mapping matches on, one of the tags
+// has this key and a value matching the regex of the mapping.
+func (m *MetricMapping) matchesTags(tags []string) bool {
+	for tagKey, tagRegex := range m.matchTags {
+		found := false
+		for _, tag := range tags {
+			key, value := tag, ""
+			if i := strings.IndexByte(tag, ':'); i >= 0 {
+				key, value = tag[:i], tag[i+1:]
+			}
+			if key == tagKey && tagRegex.MatchString(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -324,6 +324,52 @@ dogstatsd_mapper_profiles:
 				{Name: "foo.bar1.duration", Tags: []string{"bar:bar", "foo:foo_name"}, matched: true},
 			},
 		},
+		{
+			name: "Drop",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.legacy.*"
+        drop: true
+      - match: "test.job.*"
+        name: "test.job"
+        tags:
+          job: "$1"
+`,
+			packets: []string{
+				"test.legacy.requests",
+				"test.job.my_job",
+			},
+			expectedResults: []MapResult{
+				{Drop: true, matched: true},
+				{Name: "test.job", Tags: []string{"job:my_job"}, matched: true},
+			},
+		},
+		{
+			name: "Metric type and static tags",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.job.duration.*"
+        name: "test.job.duration"
+        metric_type: distribution
+        tags:
+          job: "$1"
+        static_tags:
+          - "team:core"
+          - "legacy"
+`,
+			packets: []string{
+				"test.job.duration.my_job",
+			},
+			expectedResults: []MapResult{
+				{Name: "test.job.duration", Tags: []string{"job:my_job", "team:core", "legacy"}, MetricType: MetricTypeDistribution, matched: true},
+			},
+		},
 	}
 
 	for _, scenario := range scenarios {
@@ -333,7 +379,7 @@ dogstatsd_mapper_profiles:
 
 			var actualResults []MapResult
 			for _, packet := range scenario.packets {
-				mapResult := mapper.Map(packet)
+				mapResult := mapper.Map(packet, nil)
 				if mapResult != nil {
 					actualResults = append(actualResults, *mapResult)
 				}
@@ -469,6 +515,39 @@ dogstatsd_mapper_profiles:
 			},
 			expectedError: "invalid match type",
 		},
+		{
+			name: "Invalid metric type",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.job.duration"
+        name: "test.job.duration"
+        metric_type: set
+`,
+			packets: []string{
+				"test.job.duration",
+			},
+			expectedError: "invalid metric type",
+		},
+		{
+			name: "Invalid match_tags regex",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.job.duration"
+        name: "test.job.duration"
+        match_tags:
+          env: "(prod"
+`,
+			packets: []string{
+				"test.job.duration",
+			},
+			expectedError: "invalid match_tags regex",
+		},
 		{
 			name: "Missing profile name",
 			config: `
@@ -510,6 +589,32 @@ dogstatsd_mapper_profiles:
 	}
 }
 
+func TestMappingsMatchTags(t *testing.T) {
+	mapper, err := getMapper(`
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.requests"
+        match_tags:
+          env: "staging|dev"
+        drop: true
+      - match: "test.requests"
+        match_tags:
+          service: "legacy-.*"
+        name: "test.legacy.requests"
+        metric_type: count
+`)
+	require.NoError(t, err)
+
+	assert.Equal(t, &MapResult{Drop: true, matched: true}, mapper.Map("test.requests", []string{"env:dev"}))
+	assert.Equal(t, &MapResult{Name: "test.legacy.requests", MetricType: MetricTypeCount, matched: true}, mapper.Map("test.requests", []string{"env:prod", "service:legacy-api"}))
+	// the results are not cached when the mappings match on tags
+	assert.Nil(t, mapper.Map("test.requests", []string{"env:prod", "service:api"}))
+	assert.Nil(t, mapper.Map("test.requests", []string{"environment:dev"}))
+	assert.Nil(t, mapper.Map("test.requests", nil))
+}
+
 func getMapper(configString string) (*MetricMapper, error) {
 	var profiles []config.MappingProfile
 	config.Datadog.SetConfigType("yaml")
@@ -15,6 +15,8 @@ const (
 	UDS
 	// NamedPipe Windows named pipe listner
 	NamedPipe
+	// RemoteWrite Prometheus remote write listener
+	RemoteWrite
 )
 
 // Packet represents a statsd packet ready to process,
@@ -3,6 +3,8 @@ package dogstatsd
 import (
 	"bytes"
 	"fmt"
+
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/mapper"
 )
 
 type metricType int
@@ -84,6 +86,21 @@ func parseMetricSampleMetricType(rawMetricType []byte) (metricType, error) {
 	return 0, fmt.Errorf("invalid metric type: %q", rawMetricType)
 }
 
+// mappedMetricType returns the metric type a mapping converts a metric to
+func mappedMetricType(mappedType string) metricType {
+	switch mappedType {
+	case mapper.MetricTypeCount:
+		return countType
+	case mapper.MetricTypeDistribution:
+		return distributionType
+	case mapper.MetricTypeHistogram:
+		return histogramType
+	case mapper.MetricTypeTiming:
+		return timingType
+	}
+	return gaugeType
+}
+
 func parseMetricSampleSampleRate(rawSampleRate []byte) (float64, error) {
 	return parseFloat64(rawSampleRate)
 }
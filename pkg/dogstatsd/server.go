@@ -45,6 +45,7 @@ var (
 	dogstatsdMetricPackets            = expvar.Int{}
 	dogstatsdPacketsLastSec           = expvar.Int{}
 	dogstatsdUnterminatedMetricErrors = expvar.Int{}
+	dogstatsdMetricsDroppedByMapper   = expvar.Int{}
 
 	tlmProcessed = telemetry.NewCounter("dogstatsd", "processed",
 		[]string{"message_type", "state", "origin"}, "Count of service checks/events/metrics processed by dogstatsd")
@@ -70,6 +71,7 @@ func init() {
 	dogstatsdExpvars.Set("MetricParseErrors", &dogstatsdMetricParseErrors)
 	dogstatsdExpvars.Set("MetricPackets", &dogstatsdMetricPackets)
 	dogstatsdExpvars.Set("UnterminatedMetricErrors", &dogstatsdUnterminatedMetricErrors)
+	dogstatsdExpvars.Set("MetricsDroppedByMapper", &dogstatsdMetricsDroppedByMapper)
 }
 
 // used in debug mode to add the origin on the processed metric as a tag
@@ -262,6 +264,15 @@ func NewServer(aggregator *aggregator.BufferedAggregator, extraTags []string) (*
 		}
 	}
 
+	if config.Datadog.GetInt("dogstatsd_remote_write_port") > 0 {
+		remoteWriteListener, err := listeners.NewRemoteWriteListener(packetsChannel, sharedPacketPoolManager)
+		if err != nil {
+			log.Errorf(err.Error())
+		} else {
+			tmpListeners = append(tmpListeners, remoteWriteListener)
+		}
+	}
+
 	if len(tmpListeners) == 0 {
 		return nil, fmt.Errorf("listening on neither udp nor socket, please check your configuration")
 	}
@@ -630,11 +641,22 @@ func (s *Server) parseMetricMessage(metricSamples []metrics.MetricSample, parser
 	}
 
 	if s.mapper != nil {
-		mapResult := s.mapper.Map(sample.name)
+		mapResult := s.mapper.Map(sample.name, sample.tags)
+		if mapResult != nil && mapResult.Drop {
+			log.Tracef("Dogstatsd mapper: metric %q dropped", sample.name)
+			dogstatsdMetricsDroppedByMapper.Add(1)
+			if len(sample.values) > 0 {
+				s.sharedFloat64List.put(sample.values)
+			}
+			return metricSamples, nil
+		}
 		if mapResult != nil {
 			log.Tracef("Dogstatsd mapper: metric mapped from %q to %q with tags %v", sample.name, mapResult.Name, mapResult.Tags)
 			sample.name = mapResult.Name
 			sample.tags = append(sample.tags, mapResult.Tags...)
+			if mapResult.MetricType != "" && sample.metricType != setType {
+				sample.metricType = mappedMetricType(mapResult.MetricType)
+			}
 		}
 	}
 	metricSamples = enrichMetricSample(metricSamples, sample, s.metricPrefix, s.metricPrefixBlacklist, s.metricBlocklist, s.defaultHostname, origin, s.entityIDPrecedenceEnabled, s.ServerlessMode)
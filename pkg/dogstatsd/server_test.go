@@ -776,6 +776,34 @@ dogstatsd_mapper_profiles:
 			expectedSamples:   nil,
 			expectedCacheSize: 999,
 		},
+		{
+			name: "Drop and metric type override",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.job.duration.*"
+        match_tags:
+          env: "dev"
+        drop: true
+      - match: "test.job.duration.*"
+        name: "test.job.duration"
+        metric_type: distribution
+        tags:
+          job_name: "$1"
+        static_tags:
+          - "team:core"
+`,
+			packets: []string{
+				"test.job.duration.my_job_name:666|c|#env:dev",
+				"test.job.duration.my_job_name:666|c|#env:prod",
+			},
+			expectedSamples: []MetricSample{
+				{Name: "test.job.duration", Tags: []string{"env:prod", "job_name:my_job_name", "team:core"}, Mtype: metrics.DistributionType, Value: 666.0},
+			},
+			expectedCacheSize: 1000,
+		},
 	}
 
 	samples := []metrics.MetricSample{}
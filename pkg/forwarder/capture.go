@@ -0,0 +1,226 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/internal/retry"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/hashicorp/go-multierror"
+)
+
+const captureUploadMaxAttempts = 3
+
+// transactionsCapture writes the transactions to files instead of sending them when
+// `forwarder_capture_enabled` is set. The files are sent later by UploadCapturedTransactions.
+type transactionsCapture struct {
+	rootPath           string
+	maxFileSizeInBytes int
+	maxSizeInBytes     int64
+	rotationInterval   time.Duration
+	// writers are the file writers by domain of the transactions
+	writers map[string]*retry.TransactionsFileWriter
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func newTransactionsCapture(rootPath string, maxFileSizeInBytes int, maxSizeInBytes int64, rotationInterval time.Duration) *transactionsCapture {
+	return &transactionsCapture{
+		rootPath:           rootPath,
+		maxFileSizeInBytes: maxFileSizeInBytes,
+		maxSizeInBytes:     maxSizeInBytes,
+		rotationInterval:   rotationInterval,
+		writers:            make(map[string]*retry.TransactionsFileWriter),
+	}
+}
+
+// registerDomain creates the files writer of a domain. `configDomain` is the domain as defined in the
+// configuration which, unlike `domains`, doesn't depend on the version of the Agent.
+func (c *transactionsCapture) registerDomain(configDomain string, domains []string, resolver resolver.DomainResolver) error {
+	folderPath, err := retry.GetDomainFolderPath(c.rootPath, configDomain)
+	if err != nil {
+		return err
+	}
+	writer, err := retry.NewTransactionsFileWriter(folderPath, c.maxFileSizeInBytes, c.maxSizeInBytes, resolver)
+	if err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		c.writers[domain] = writer
+	}
+	return nil
+}
+
+func (c *transactionsCapture) start() {
+	c.stop = make(chan struct{})
+	c.stopped = make(chan struct{})
+	go func() {
+		defer close(c.stopped)
+		ticker := time.NewTicker(c.rotationInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.flush()
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (c *transactionsCapture) stopAndFlush() {
+	close(c.stop)
+	<-c.stopped
+	c.flush()
+}
+
+func (c *transactionsCapture) add(transactions []*transaction.HTTPTransaction) error {
+	var errs error
+	for _, t := range transactions {
+		writer, found := c.writers[t.Domain]
+		if !found {
+			errs = multierror.Append(errs, fmt.Errorf("no capture files for the domain %v", t.Domain))
+			continue
+		}
+		if err := writer.Add(t); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (c *transactionsCapture) flush() {
+	flushed := make(map[*retry.TransactionsFileWriter]struct{})
+	for domain, writer := range c.writers {
+		if _, found := flushed[writer]; found {
+			continue
+		}
+		flushed[writer] = struct{}{}
+		if err := writer.Flush(); err != nil {
+			log.Errorf("Cannot write the capture file of the domain %v: %v", domain, err)
+		}
+	}
+}
+
+// CaptureUploadStats contains the result of UploadCapturedTransactions.
+type CaptureUploadStats struct {
+	FilesUploaded       int
+	TransactionsSent    int
+	TransactionsDropped int
+}
+
+// UploadCapturedTransactions sends the transactions written in `folderPath` by a forwarder
+// with `forwarder_capture_enabled`, to the domains and with the API keys of `options`.
+// The transactions are sent in the order they were created, with their original payloads
+// and so their original timestamps. A file is removed once all its transactions are sent.
+// When a transaction cannot be sent, the upload stops and the file is rewritten with the
+// transactions not sent yet so that the upload can be resumed without sending them twice.
+func UploadCapturedTransactions(ctx context.Context, folderPath string, options *Options) (CaptureUploadStats, error) {
+	var stats CaptureUploadStats
+	client := newHTTPClient()
+
+	for configDomain, resolver := range options.DomainResolvers {
+		domain, _ := config.AddAgentVersionToDomain(configDomain, "app")
+		resolver.SetBaseDomain(domain)
+
+		domainFolderPath, err := retry.GetDomainFolderPath(folderPath, configDomain)
+		if err != nil {
+			return stats, err
+		}
+		files, err := retry.GetTransactionsFiles(domainFolderPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		for _, file := range files {
+			if err := uploadCaptureFile(ctx, client, file, resolver, &stats); err != nil {
+				return stats, fmt.Errorf("cannot upload %v: %v", file, err)
+			}
+			// The file may have been removed by the disk usage limit of a running forwarder
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return stats, err
+			}
+			stats.FilesUploaded++
+		}
+	}
+	return stats, nil
+}
+
+func uploadCaptureFile(ctx context.Context, client *http.Client, file string, resolver resolver.DomainResolver, stats *CaptureUploadStats) error {
+	transactions, err := retry.ReadTransactionsFile(file, resolver)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].GetCreatedAt().Before(transactions[j].GetCreatedAt())
+	})
+
+	for i, t := range transactions {
+		statusCode, err := uploadCapturedTransaction(ctx, client, t)
+		if err != nil {
+			if i > 0 {
+				// Keep only the transactions not sent yet so that they are not sent twice when the upload is resumed
+				if errRewrite := retry.RewriteTransactionsFile(file, transactions[i:], resolver); errRewrite != nil {
+					log.Errorf("Cannot remove the transactions already sent from %v, they will be sent again: %v", file, errRewrite)
+				}
+			}
+			return err
+		}
+
+		// The invalid transactions and the ones rejected by the intake are dropped, see HTTPTransaction.Process
+		if statusCode == 0 || statusCode >= 400 {
+			stats.TransactionsDropped++
+		} else {
+			stats.TransactionsSent++
+		}
+	}
+	return nil
+}
+
+// uploadCapturedTransaction sends a transaction, retrying up to captureUploadMaxAttempts times,
+// and returns the status code of the response.
+func uploadCapturedTransaction(ctx context.Context, client *http.Client, t transaction.Transaction) (int, error) {
+	tr, ok := t.(*transaction.HTTPTransaction)
+	if !ok {
+		return 0, fmt.Errorf("unsupported transaction type %T", t)
+	}
+	var statusCode int
+	tr.CompletionHandler = func(_ *transaction.HTTPTransaction, code int, _ []byte, _ error) {
+		statusCode = code
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := tr.Process(ctx, client)
+		if ctx.Err() != nil {
+			// A canceled transaction is not reported as an error by HTTPTransaction.Process
+			return 0, ctx.Err()
+		}
+		if err == nil {
+			return statusCode, nil
+		}
+		if attempt == captureUploadMaxAttempts {
+			return 0, err
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/endpoints"
+)
+
+func TestCaptureAndUploadTransactions(t *testing.T) {
+	capturePath, err := ioutil.TempDir("", "forwarder_capture")
+	require.NoError(t, err)
+	defer os.RemoveAll(capturePath)
+
+	var mutex sync.Mutex
+	var payloads []string
+	var apiKeys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		defer mutex.Unlock()
+		payloads = append(payloads, string(body))
+		apiKeys = append(apiKeys, r.Header.Get(apiHTTPHeaderKey))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	mockConfig := config.Mock()
+	mockConfig.Set("forwarder_capture_enabled", true)
+	mockConfig.Set("forwarder_capture_path", capturePath)
+	defer mockConfig.Set("forwarder_capture_enabled", false)
+	defer mockConfig.Set("forwarder_capture_path", "")
+
+	keysPerDomains := map[string][]string{ts.URL: {"api_key"}}
+	options := NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(keysPerDomains))
+	options.EnabledFeatures = SetFeature(options.EnabledFeatures, CoreFeatures)
+	f := NewDefaultForwarder(options)
+	require.NoError(t, f.Start())
+
+	payload1 := []byte("payload1")
+	payload2 := []byte("payload2")
+	require.NoError(t, f.SubmitV1Series(Payloads{&payload1}, make(http.Header)))
+	require.NoError(t, f.SubmitEvents(Payloads{&payload2}, make(http.Header)))
+	f.Stop()
+
+	// The transactions are written to files instead of being sent.
+	assert.Empty(t, payloads)
+	files, err := filepath.Glob(filepath.Join(capturePath, "*", "*.capture"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	content, err := ioutil.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "api_key")
+
+	options = NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(keysPerDomains))
+	stats, err := UploadCapturedTransactions(context.Background(), capturePath, options)
+	require.NoError(t, err)
+	assert.Equal(t, CaptureUploadStats{FilesUploaded: 1, TransactionsSent: 2}, stats)
+	assert.ElementsMatch(t, []string{"payload1", "payload2"}, payloads)
+	assert.Equal(t, []string{"api_key", "api_key"}, apiKeys)
+
+	files, err = filepath.Glob(filepath.Join(capturePath, "*", "*.capture"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestUploadCapturedTransactionsCanceled(t *testing.T) {
+	capturePath, err := ioutil.TempDir("", "forwarder_capture")
+	require.NoError(t, err)
+	defer os.RemoveAll(capturePath)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	keysPerDomains := map[string][]string{ts.URL: {"api_key"}}
+	capture := newTransactionsCapture(capturePath, 1, 1024*1024, 0)
+	resolvers := resolver.NewSingleDomainResolvers(keysPerDomains)
+	require.NoError(t, capture.registerDomain(ts.URL, []string{ts.URL}, resolvers[ts.URL]))
+
+	f := &DefaultForwarder{domainResolvers: resolvers}
+	payload := []byte("payload")
+	transactions := f.createHTTPTransactions(endpoints.SeriesEndpoint, Payloads{&payload}, false, make(http.Header))
+	require.NoError(t, capture.add(transactions))
+
+	// The file is kept when the upload is interrupted.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	options := NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(keysPerDomains))
+	stats, err := UploadCapturedTransactions(ctx, capturePath, options)
+	assert.Error(t, err)
+	assert.Equal(t, CaptureUploadStats{}, stats)
+
+	files, err := filepath.Glob(filepath.Join(capturePath, "*", "*.capture"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestUploadCapturedTransactionsResumed(t *testing.T) {
+	capturePath, err := ioutil.TempDir("", "forwarder_capture")
+	require.NoError(t, err)
+	defer os.RemoveAll(capturePath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var mutex sync.Mutex
+	var payloads []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		defer mutex.Unlock()
+		if ctx.Err() == nil && len(payloads) == 1 {
+			// The upload is interrupted while sending the second transaction
+			cancel()
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		payloads = append(payloads, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	keysPerDomains := map[string][]string{ts.URL: {"api_key"}}
+	capture := newTransactionsCapture(capturePath, 1024, 1024*1024, 0)
+	resolvers := resolver.NewSingleDomainResolvers(keysPerDomains)
+	require.NoError(t, capture.registerDomain(ts.URL, []string{ts.URL}, resolvers[ts.URL]))
+
+	f := &DefaultForwarder{domainResolvers: resolvers}
+	payload1 := []byte("payload1")
+	payload2 := []byte("payload2")
+	transactions := f.createHTTPTransactions(endpoints.SeriesEndpoint, Payloads{&payload1, &payload2}, false, make(http.Header))
+	require.NoError(t, capture.add(transactions))
+	capture.flush()
+
+	options := NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(keysPerDomains))
+	stats, err := UploadCapturedTransactions(ctx, capturePath, options)
+	assert.Error(t, err)
+	assert.Equal(t, CaptureUploadStats{TransactionsSent: 1}, stats)
+
+	// The transactions already sent are not sent again when the upload is resumed.
+	stats, err = UploadCapturedTransactions(context.Background(), capturePath, options)
+	require.NoError(t, err)
+	assert.Equal(t, CaptureUploadStats{FilesUploaded: 1, TransactionsSent: 1}, stats)
+	assert.Equal(t, []string{"payload1", "payload2"}, payloads)
+}
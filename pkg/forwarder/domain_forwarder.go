@@ -6,6 +6,7 @@
 package forwarder
 
 import (
+	"expvar"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -21,6 +22,16 @@ var (
 	flushInterval = 5 * time.Second
 )
 
+// transactionRetryQueue stores the transactions to retry of a domainForwarder.
+// It is implemented by retry.TransactionRetryQueue and retry.EndpointRetryQueues.
+type transactionRetryQueue interface {
+	Add(t transaction.Transaction) (int, error)
+	ExtractTransactions() ([]transaction.Transaction, error)
+	GetTransactionCount() int
+	GetTransactionCountByEndpoint() map[string]int
+	GetMaxMemSizeInBytes() int
+}
+
 // domainForwarder is in charge of sending Transactions to Datadog backend over
 // HTTP and retrying them if needed. One domainForwarder is created per HTTP
 // backend.
@@ -34,7 +45,7 @@ type domainForwarder struct {
 	stopRetry                 chan bool
 	stopConnectionReset       chan bool
 	workers                   []*Worker
-	retryQueue                *retry.TransactionRetryQueue
+	retryQueue                transactionRetryQueue
 	connectionResetInterval   time.Duration
 	internalState             uint32
 	m                         sync.Mutex // To control Start/Stop races
@@ -44,7 +55,7 @@ type domainForwarder struct {
 
 func newDomainForwarder(
 	domain string,
-	retryQueue *retry.TransactionRetryQueue,
+	retryQueue transactionRetryQueue,
 	numberOfWorkers int,
 	connectionResetInterval time.Duration,
 	transactionPrioritySorter retry.TransactionPrioritySorter) *domainForwarder {
@@ -102,9 +113,7 @@ func (f *domainForwarder) retryTransactions(retryBefore time.Time) {
 		}
 	}
 
-	transactionCount := f.retryQueue.GetTransactionCount()
-	transactionsRetryQueueSize.Set(int64(transactionCount))
-	tlmTxRetryQueueSize.Set(float64(transactionCount), f.domain)
+	f.updateRetryQueueSizeTelemetry()
 
 	if droppedRetryQueueFull+droppedWorkerBusy > 0 {
 		log.Errorf("Dropped %d transactions in this retry attempt:%d for exceeding the retry queue payloads size limit of %d, %d because the workers are too busy",
@@ -129,11 +138,29 @@ func (f *domainForwarder) addToTransactionRetryQueue(t transaction.Transaction)
 
 func (f *domainForwarder) requeueTransaction(t transaction.Transaction) {
 	f.addToTransactionRetryQueue(t)
-	retryQueueSize := f.retryQueue.GetTransactionCount()
 	transactionsRequeuedByEndpoint.Add(t.GetEndpointName(), 1)
 	transactionsRequeued.Add(1)
+	f.updateRetryQueueSizeTelemetry()
+}
+
+// updateRetryQueueSizeTelemetry reports the number of transactions in the retry queue, in total
+// and for each endpoint.
+func (f *domainForwarder) updateRetryQueueSizeTelemetry() {
+	retryQueueSize := f.retryQueue.GetTransactionCount()
 	transactionsRetryQueueSize.Set(int64(retryQueueSize))
 	tlmTxRetryQueueSize.Set(float64(retryQueueSize), f.domain)
+
+	if retryQueueSize == 0 {
+		transactionsRetryQueueSizeByDomain.Delete(f.domain)
+		return
+	}
+	sizeByEndpoint := &expvar.Map{}
+	for endpointName, size := range f.retryQueue.GetTransactionCountByEndpoint() {
+		endpointSize := &expvar.Int{}
+		endpointSize.Set(int64(size))
+		sizeByEndpoint.Set(endpointName, endpointSize)
+	}
+	transactionsRetryQueueSizeByDomain.Set(f.domain, sizeByEndpoint)
 }
 
 func (f *domainForwarder) handleFailedTransactions() {
@@ -9,10 +9,12 @@ package forwarder
 
 import (
 	"bytes"
+	"expvar"
 	"testing"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
 	"github.com/DataDog/datadog-agent/pkg/forwarder/internal/retry"
 	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
 	"github.com/stretchr/testify/assert"
@@ -145,6 +147,42 @@ func TestRetryTransactions(t *testing.T) {
 	assert.Equal(t, int64(1), transaction.TransactionsDropped.Value())
 }
 
+func TestRetryTransactionsPerEndpoint(t *testing.T) {
+	sorter := transaction.SortByCreatedTimeAndPriority{HighPriorityFirst: true, EndpointPriorities: map[string]int{"series_v1": 1}}
+	dropSorter := transaction.SortByCreatedTimeAndPriority{HighPriorityFirst: false, EndpointPriorities: map[string]int{"series_v1": 1}}
+	retryQueue := retry.BuildEndpointRetryQueues(1, 0, "", 0, dropSorter, resolver.NewSingleDomainResolver("domain/", nil))
+	forwarder := newDomainForwarder("per_endpoint", retryQueue, 0, 0, sorter)
+	forwarder.init()
+
+	payload := []byte{1}
+	newTransaction := func(endpointName string) *transaction.HTTPTransaction {
+		tr := transaction.NewHTTPTransaction()
+		tr.Domain = "domain/"
+		tr.Endpoint = transaction.Endpoint{Route: endpointName, Name: endpointName}
+		tr.Payload = &payload
+		return tr
+	}
+
+	// Each endpoint has its own retry queue holding a single transaction
+	forwarder.requeueTransaction(newTransaction("metadata_v2"))
+	forwarder.requeueTransaction(newTransaction("metadata_v2"))
+	series := newTransaction("series_v1")
+	forwarder.requeueTransaction(series)
+	requireLenForwarderRetryQueue(t, forwarder, 2)
+
+	sizeByEndpoint, ok := transactionsRetryQueueSizeByDomain.Get("per_endpoint").(*expvar.Map)
+	require.True(t, ok)
+	assert.Equal(t, "1", sizeByEndpoint.Get("metadata_v2").String())
+	assert.Equal(t, "1", sizeByEndpoint.Get("series_v1").String())
+
+	// The endpoints with the highest priority are retried first
+	forwarder.retryTransactions(time.Now())
+	requireLenForwarderRetryQueue(t, forwarder, 0)
+	require.Len(t, forwarder.lowPrio, 2)
+	assert.Equal(t, series, <-forwarder.lowPrio)
+	assert.Nil(t, transactionsRetryQueueSizeByDomain.Get("per_endpoint"))
+}
+
 func TestForwarderRetry(t *testing.T) {
 	forwarder := newDomainForwarderForTest(0)
 	forwarder.Start()
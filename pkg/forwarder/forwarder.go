@@ -59,6 +59,7 @@ type Forwarder interface {
 	Start() error
 	Stop()
 	SubmitV1Series(payload Payloads, extra http.Header) error
+	SubmitSeries(payload Payloads, extra http.Header) error
 	SubmitV1Intake(payload Payloads, extra http.Header) error
 	SubmitV1CheckRuns(payload Payloads, extra http.Header) error
 	SubmitEvents(payload Payloads, extra http.Header) error
@@ -103,6 +104,10 @@ type Options struct {
 	DomainResolvers                map[string]resolver.DomainResolver
 	ConnectionResetInterval        time.Duration
 	CompletionHandler              transaction.HTTPCompletionHandler
+	// RetryQueuePerEndpoint enables a retry queue per endpoint for each domain
+	RetryQueuePerEndpoint bool
+	// RetryQueueEndpointPriorities are the priorities of the endpoints when retrying transactions
+	RetryQueueEndpointPriorities map[string]int
 }
 
 // SetFeature sets forwarder features in a feature set
@@ -162,6 +167,11 @@ func NewOptionsWithResolvers(domainResolvers map[string]resolver.DomainResolver)
 		APIKeyValidationInterval:       time.Duration(validationInterval) * time.Minute,
 		DomainResolvers:                domainResolvers,
 		ConnectionResetInterval:        time.Duration(config.Datadog.GetInt("forwarder_connection_reset_interval")) * time.Second,
+		RetryQueuePerEndpoint:          config.Datadog.GetBool("forwarder_retry_queue_per_endpoint"),
+	}
+
+	if err := config.Datadog.UnmarshalKey("forwarder_retry_queue_endpoint_priorities", &option.RetryQueueEndpointPriorities); err != nil {
+		log.Errorf("Invalid 'forwarder_retry_queue_endpoint_priorities', all the endpoints have the same priority: %v", err)
 	}
 
 	if config.Datadog.IsSet(forwarderRetryQueueMaxSizeKey) {
@@ -201,6 +211,9 @@ type DefaultForwarder struct {
 	m                sync.Mutex // To control Start/Stop races
 
 	completionHandler transaction.HTTPCompletionHandler
+
+	// capture is set when the transactions are written to files instead of being sent
+	capture *transactionsCapture
 }
 
 // NewDefaultForwarder returns a new DefaultForwarder.
@@ -246,12 +259,36 @@ func NewDefaultForwarder(options *Options) *DefaultForwarder {
 		log.Infof("Retry queue storage on disk is disabled because the feature is unavailable for this process.")
 	}
 
+	if config.Datadog.GetBool("forwarder_capture_enabled") {
+		if getAgentFolder(options) != "" {
+			capturePath := config.Datadog.GetString("forwarder_capture_path")
+			if capturePath == "" {
+				capturePath = path.Join(config.Datadog.GetString("run_path"), "forwarder_capture")
+			}
+			f.capture = newTransactionsCapture(
+				capturePath,
+				config.Datadog.GetInt("forwarder_capture_file_max_size_in_bytes"),
+				config.Datadog.GetInt64("forwarder_capture_max_size_in_bytes"),
+				config.Datadog.GetDuration("forwarder_capture_file_rotation_interval")*time.Second)
+			// The API keys are not validated as the intake may not be reachable.
+			f.healthChecker.disableAPIKeyChecking = true
+		} else {
+			log.Infof("Capture of the transactions is disabled because the feature is unavailable for this process.")
+		}
+	}
+
 	flushToDiskMemRatio := config.Datadog.GetFloat64("forwarder_flush_to_disk_mem_ratio")
-	domainForwarderSort := transaction.SortByCreatedTimeAndPriority{HighPriorityFirst: true}
-	transactionContainerSort := transaction.SortByCreatedTimeAndPriority{HighPriorityFirst: false}
+	domainForwarderSort := transaction.SortByCreatedTimeAndPriority{
+		HighPriorityFirst:  true,
+		EndpointPriorities: options.RetryQueueEndpointPriorities,
+	}
+	transactionContainerSort := transaction.SortByCreatedTimeAndPriority{
+		HighPriorityFirst:  false,
+		EndpointPriorities: options.RetryQueueEndpointPriorities,
+	}
 
-	for domain, resolver := range options.DomainResolvers {
-		domain, _ := config.AddAgentVersionToDomain(domain, "app")
+	for configDomain, resolver := range options.DomainResolvers {
+		domain, _ := config.AddAgentVersionToDomain(configDomain, "app")
 		resolver.SetBaseDomain(domain)
 		if resolver.GetAPIKeys() == nil || len(resolver.GetAPIKeys()) == 0 {
 			log.Errorf("No API keys for domain '%s', dropping domain ", domain)
@@ -265,13 +302,24 @@ func NewDefaultForwarder(options *Options) *DefaultForwarder {
 				}
 			}
 
-			transactionContainer := retry.BuildTransactionRetryQueue(
-				options.RetryQueuePayloadsTotalMaxSize,
-				flushToDiskMemRatio,
-				domainFolderPath,
-				storageMaxSize,
-				transactionContainerSort,
-				resolver)
+			var transactionContainer transactionRetryQueue
+			if options.RetryQueuePerEndpoint {
+				transactionContainer = retry.BuildEndpointRetryQueues(
+					options.RetryQueuePayloadsTotalMaxSize,
+					flushToDiskMemRatio,
+					domainFolderPath,
+					storageMaxSize,
+					transactionContainerSort,
+					resolver)
+			} else {
+				transactionContainer = retry.BuildTransactionRetryQueue(
+					options.RetryQueuePayloadsTotalMaxSize,
+					flushToDiskMemRatio,
+					domainFolderPath,
+					storageMaxSize,
+					transactionContainerSort,
+					resolver)
+			}
 			f.domainResolvers[domain] = resolver
 			fwd := newDomainForwarder(
 				domain,
@@ -284,6 +332,13 @@ func NewDefaultForwarder(options *Options) *DefaultForwarder {
 			for _, v := range resolver.GetAlternateDomains() {
 				f.domainForwarders[v] = fwd
 			}
+
+			if f.capture != nil {
+				domains := append([]string{domain}, resolver.GetAlternateDomains()...)
+				if err := f.capture.registerDomain(configDomain, domains, resolver); err != nil {
+					log.Errorf("Cannot capture the transactions of the domain '%v': %v", domain, err)
+				}
+			}
 		}
 	}
 
@@ -315,18 +370,23 @@ func (f *DefaultForwarder) Start() error {
 		return fmt.Errorf("the forwarder is already started")
 	}
 
-	for _, df := range f.domainForwarders {
-		_ = df.Start()
-	}
+	if f.capture != nil {
+		f.capture.start()
+		log.Infof("Forwarder started, writing the transactions to %v instead of sending them", f.capture.rootPath)
+	} else {
+		for _, df := range f.domainForwarders {
+			_ = df.Start()
+		}
 
-	// log endpoints configuration
-	endpointLogs := make([]string, 0, len(f.domainResolvers))
-	for domain, dr := range f.domainResolvers {
-		endpointLogs = append(endpointLogs, fmt.Sprintf("\"%s\" (%v api key(s))",
-			domain, len(dr.GetAPIKeys())))
+		// log endpoints configuration
+		endpointLogs := make([]string, 0, len(f.domainResolvers))
+		for domain, dr := range f.domainResolvers {
+			endpointLogs = append(endpointLogs, fmt.Sprintf("\"%s\" (%v api key(s))",
+				domain, len(dr.GetAPIKeys())))
+		}
+		log.Infof("Forwarder started, sending to %v endpoint(s) with %v worker(s) each: %s",
+			len(endpointLogs), f.NumberOfWorkers, strings.Join(endpointLogs, " ; "))
 	}
-	log.Infof("Forwarder started, sending to %v endpoint(s) with %v worker(s) each: %s",
-		len(endpointLogs), f.NumberOfWorkers, strings.Join(endpointLogs, " ; "))
 
 	f.healthChecker.Start()
 	f.internalState = Started
@@ -348,7 +408,9 @@ func (f *DefaultForwarder) Stop() {
 	f.internalState = Stopped
 
 	purgeTimeout := config.Datadog.GetDuration("forwarder_stop_timeout") * time.Second
-	if purgeTimeout > 0 {
+	if f.capture != nil {
+		f.capture.stopAndFlush()
+	} else if purgeTimeout > 0 {
 		var wg sync.WaitGroup
 
 		for _, df := range f.domainForwarders {
@@ -442,6 +504,10 @@ func (f *DefaultForwarder) sendHTTPTransactions(transactions []*transaction.HTTP
 		return fmt.Errorf("the forwarder is not started")
 	}
 
+	if f.capture != nil {
+		return f.capture.add(transactions)
+	}
+
 	for _, t := range transactions {
 		f.domainForwarders[t.Domain].sendHTTPTransactions(t)
 	}
@@ -498,6 +564,12 @@ func (f *DefaultForwarder) SubmitV1Series(payload Payloads, extra http.Header) e
 	return f.sendHTTPTransactions(transactions)
 }
 
+// SubmitSeries will send protobuf series payloads to the v2 series endpoint.
+func (f *DefaultForwarder) SubmitSeries(payload Payloads, extra http.Header) error {
+	transactions := f.createHTTPTransactions(endpoints.SeriesEndpoint, payload, false, extra)
+	return f.sendHTTPTransactions(transactions)
+}
+
 // SubmitV1CheckRuns will send service checks to v1 endpoint (this will be removed once
 // the backend handles v2 endpoints).
 func (f *DefaultForwarder) SubmitV1CheckRuns(payload Payloads, extra http.Header) error {
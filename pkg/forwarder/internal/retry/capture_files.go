@@ -0,0 +1,244 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const captureFileExtension = ".capture"
+
+// The file names start with the time they are written, with a nanosecond precision so that
+// the files can be sorted by name.
+const captureFileFormat = "2006_01_02__15_04_05.000000000_"
+
+// TransactionsFileWriter writes the transactions of a domain to files instead of sending them.
+// The transactions are buffered in memory and a new file is written each time the size of
+// the payloads reaches `maxFileSizeInBytes` or when Flush is called.
+// The oldest files are removed when the files would exceed the disk usage limit.
+// The files use the same format as the `.retry` files.
+type TransactionsFileWriter struct {
+	serializer         *HTTPTransactionsSerializer
+	codec              *retryFileCodec
+	folderPath         string
+	diskUsageLimit     *diskUsageLimit
+	maxFileSizeInBytes int
+	bufferSizeInBytes  int
+	transactionCount   int
+	mutex              sync.Mutex
+}
+
+// NewTransactionsFileWriter creates a new instance of TransactionsFileWriter writing its files in `folderPath`.
+// The files use at most `maxSizeInBytes` and leave `forwarder_storage_max_disk_ratio` of the disk free.
+func NewTransactionsFileWriter(
+	folderPath string,
+	maxFileSizeInBytes int,
+	maxSizeInBytes int64,
+	resolver resolver.DomainResolver) (*TransactionsFileWriter, error) {
+	if err := os.MkdirAll(folderPath, 0700); err != nil {
+		return nil, err
+	}
+	codec, err := newRetryFileCodec(config.Datadog.GetString("forwarder_storage_encryption_key"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransactionsFileWriter{
+		serializer:         NewHTTPTransactionsSerializer(resolver),
+		codec:              codec,
+		folderPath:         folderPath,
+		diskUsageLimit:     newDiskUsageLimit(folderPath, filesystem.NewDisk(), maxSizeInBytes, config.Datadog.GetFloat64("forwarder_storage_max_disk_ratio")),
+		maxFileSizeInBytes: maxFileSizeInBytes,
+	}, nil
+}
+
+// Add adds a transaction to the current file. The file is written when it is full.
+func (w *TransactionsFileWriter) Add(t transaction.Transaction) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := t.SerializeTo(w.serializer); err != nil {
+		return err
+	}
+	w.transactionCount++
+	w.bufferSizeInBytes += t.GetPayloadSize()
+
+	if w.bufferSizeInBytes >= w.maxFileSizeInBytes {
+		return w.writeFile()
+	}
+	return nil
+}
+
+// Flush writes the transactions added since the last file was written to a new file.
+func (w *TransactionsFileWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.transactionCount == 0 {
+		return nil
+	}
+	return w.writeFile()
+}
+
+func (w *TransactionsFileWriter) writeFile() error {
+	w.transactionCount = 0
+	w.bufferSizeInBytes = 0
+
+	bytes, err := w.serializer.GetBytesAndReset()
+	if err != nil {
+		return err
+	}
+	if bytes, err = w.codec.encode(bytes); err != nil {
+		return err
+	}
+	if err := w.makeRoomFor(int64(len(bytes))); err != nil {
+		return err
+	}
+
+	filename := time.Now().UTC().Format(captureFileFormat)
+	file, err := ioutil.TempFile(w.folderPath, filename+"*"+captureFileExtension)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(bytes); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return file.Close()
+}
+
+// makeRoomFor removes the oldest files until a file of `bufferSize` bytes fits in the disk usage limit.
+// The files are listed each time as they can be removed by the upload command.
+func (w *TransactionsFileWriter) makeRoomFor(bufferSize int64) error {
+	maxSizeInBytes := w.diskUsageLimit.getMaxSizeInBytes()
+	if bufferSize > maxSizeInBytes {
+		return fmt.Errorf("The payload is too big. Current:%v Maximum:%v", bufferSize, maxSizeInBytes)
+	}
+
+	files, err := getTransactionsFileInfos(w.folderPath)
+	if err != nil {
+		return err
+	}
+	var currentSizeInBytes int64
+	for _, file := range files {
+		currentSizeInBytes += file.Size()
+	}
+
+	maxStorageInBytes, err := w.diskUsageLimit.computeAvailableSpace(currentSizeInBytes)
+	if err != nil {
+		return err
+	}
+	for len(files) > 0 && currentSizeInBytes+bufferSize > maxStorageInBytes {
+		filename := path.Join(w.folderPath, files[0].Name())
+		log.Errorf("Maximum disk space for captured transactions is reached. Removing %s", filename)
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		currentSizeInBytes -= files[0].Size()
+		files = files[1:]
+	}
+	return nil
+}
+
+// GetTransactionsFiles returns the files written by TransactionsFileWriter in `folderPath`,
+// the oldest first.
+func GetTransactionsFiles(folderPath string) ([]string, error) {
+	infos, err := getTransactionsFileInfos(folderPath)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, info := range infos {
+		files = append(files, path.Join(folderPath, info.Name()))
+	}
+	return files, nil
+}
+
+func getTransactionsFileInfos(folderPath string) ([]os.FileInfo, error) {
+	entries, err := ioutil.ReadDir(folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// ioutil.ReadDir sorts the entries by name
+	var files []os.FileInfo
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && filepath.Ext(entry.Name()) == captureFileExtension {
+			files = append(files, entry)
+		}
+	}
+	return files, nil
+}
+
+// ReadTransactionsFile reads the transactions of a file written by TransactionsFileWriter.
+// The API keys of the transactions are restored from `resolver`.
+func ReadTransactionsFile(filePath string, resolver resolver.DomainResolver) ([]transaction.Transaction, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	codec, err := newRetryFileCodec(config.Datadog.GetString("forwarder_storage_encryption_key"))
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := codec.decode(content)
+	if err != nil {
+		return nil, err
+	}
+	transactions, _, err := NewHTTPTransactionsSerializer(resolver).Deserialize(bytes)
+	return transactions, err
+}
+
+// RewriteTransactionsFile replaces the transactions of a file written by TransactionsFileWriter
+// with `transactions`, for instance to keep only the transactions not uploaded yet.
+// The file is replaced atomically.
+func RewriteTransactionsFile(filePath string, transactions []transaction.Transaction, resolver resolver.DomainResolver) error {
+	serializer := NewHTTPTransactionsSerializer(resolver)
+	for _, t := range transactions {
+		if err := t.SerializeTo(serializer); err != nil {
+			return err
+		}
+	}
+	bytes, err := serializer.GetBytesAndReset()
+	if err != nil {
+		return err
+	}
+	codec, err := newRetryFileCodec(config.Datadog.GetString("forwarder_storage_encryption_key"))
+	if err != nil {
+		return err
+	}
+	if bytes, err = codec.encode(bytes); err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(filePath), filepath.Base(filePath)+"*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(bytes); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err = file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), filePath)
+}
@@ -0,0 +1,117 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionsFileWriter(t *testing.T) {
+	a := assert.New(t)
+	path, err := ioutil.TempDir("", "tests")
+	a.NoError(err)
+	defer os.RemoveAll(path)
+
+	r := resolver.NewSingleDomainResolver(domainName, []string{"api_key"})
+	w, err := NewTransactionsFileWriter(path, 10, 1000, r)
+	a.NoError(err)
+
+	// A new file is written when the size of the payloads reaches the maximum file size.
+	tr1 := createTransactionWithEndpoint("endpoint1", 6)
+	tr1.Headers.Set("DD-Api-Key", "api_key")
+	a.NoError(w.Add(tr1))
+	a.NoError(w.Add(createTransactionWithEndpoint("endpoint2", 6)))
+	a.NoError(w.Add(createTransactionWithEndpoint("endpoint3", 6)))
+	files, err := GetTransactionsFiles(path)
+	a.NoError(err)
+	a.Len(files, 1)
+
+	a.NoError(w.Flush())
+	a.NoError(w.Flush())
+	files, err = GetTransactionsFiles(path)
+	a.NoError(err)
+	a.Len(files, 2)
+
+	content, err := ioutil.ReadFile(files[0])
+	a.NoError(err)
+	a.NotContains(string(content), "api_key")
+
+	transactions, err := ReadTransactionsFile(files[0], r)
+	a.NoError(err)
+	a.Equal([]string{"endpoint1", "endpoint2"}, getEndpointsFromTransactions(transactions))
+	a.Equal("api_key", transactions[0].(*transaction.HTTPTransaction).Headers.Get("DD-Api-Key"))
+
+	transactions, err = ReadTransactionsFile(files[1], r)
+	a.NoError(err)
+	a.Equal([]string{"endpoint3"}, getEndpointsFromTransactions(transactions))
+}
+
+func TestTransactionsFileWriterRemovesOldestFiles(t *testing.T) {
+	a := assert.New(t)
+	path, err := ioutil.TempDir("", "tests")
+	a.NoError(err)
+	defer os.RemoveAll(path)
+
+	r := resolver.NewSingleDomainResolver(domainName, []string{"api_key"})
+	w, err := NewTransactionsFileWriter(path, 1, 1000, r)
+	a.NoError(err)
+	disk := diskUsageRetrieverMock{diskUsage: &filesystem.DiskUsage{Available: 10000, Total: 10000}}
+
+	// Allow two files
+	a.NoError(w.Add(createTransactionWithEndpoint("endpoint1", 1)))
+	files, err := getTransactionsFileInfos(path)
+	a.NoError(err)
+	a.Len(files, 1)
+	w.diskUsageLimit = newDiskUsageLimit(path, disk, 2*files[0].Size(), 1)
+	a.NoError(w.Add(createTransactionWithEndpoint("endpoint2", 1)))
+	a.NoError(w.Add(createTransactionWithEndpoint("endpoint3", 1)))
+
+	filenames, err := GetTransactionsFiles(path)
+	a.NoError(err)
+	a.Len(filenames, 2)
+	var endpoints []string
+	for _, filename := range filenames {
+		transactions, err := ReadTransactionsFile(filename, r)
+		a.NoError(err)
+		endpoints = append(endpoints, getEndpointsFromTransactions(transactions)...)
+	}
+	a.Equal([]string{"endpoint2", "endpoint3"}, endpoints)
+}
+
+func TestRewriteTransactionsFile(t *testing.T) {
+	a := assert.New(t)
+	path, err := ioutil.TempDir("", "tests")
+	a.NoError(err)
+	defer os.RemoveAll(path)
+
+	r := resolver.NewSingleDomainResolver(domainName, []string{"api_key"})
+	w, err := NewTransactionsFileWriter(path, 100, 1000, r)
+	a.NoError(err)
+	a.NoError(w.Add(createTransactionWithEndpoint("endpoint1", 1)))
+	a.NoError(w.Add(createTransactionWithEndpoint("endpoint2", 1)))
+	a.NoError(w.Flush())
+	files, err := GetTransactionsFiles(path)
+	a.NoError(err)
+	a.Len(files, 1)
+
+	transactions, err := ReadTransactionsFile(files[0], r)
+	a.NoError(err)
+	a.NoError(RewriteTransactionsFile(files[0], transactions[1:], r))
+
+	newFiles, err := GetTransactionsFiles(path)
+	a.NoError(err)
+	a.Equal(files, newFiles)
+	transactions, err = ReadTransactionsFile(files[0], r)
+	a.NoError(err)
+	a.Equal([]string{"endpoint2"}, getEndpointsFromTransactions(transactions))
+}
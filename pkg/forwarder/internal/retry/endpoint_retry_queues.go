@@ -0,0 +1,176 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"io/ioutil"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/hashicorp/go-multierror"
+)
+
+// EndpointRetryQueues stores the transactions of a domain in one TransactionRetryQueue per endpoint.
+// Each queue has its own memory and disk budget so that an endpoint failing for a long time
+// cannot make the transactions of the other endpoints dropped.
+type EndpointRetryQueues struct {
+	queues            map[string]*TransactionRetryQueue
+	newQueue          func(endpointName string) *TransactionRetryQueue
+	maxMemSizeInBytes int
+	mutex             sync.RWMutex
+}
+
+// BuildEndpointRetryQueues builds a new instance of EndpointRetryQueues. `maxMemSizeInBytes` and
+// `storageMaxSize` are the budgets of each endpoint. When `optionalDomainFolderPath` is set, the
+// transactions of an endpoint are stored on disk in a sub folder named after the endpoint and the
+// transactions stored by a previous run of the Agent are reloaded.
+func BuildEndpointRetryQueues(
+	maxMemSizeInBytes int,
+	flushToStorageRatio float64,
+	optionalDomainFolderPath string,
+	storageMaxSize int64,
+	dropPrioritySorter TransactionPrioritySorter,
+	resolver resolver.DomainResolver) *EndpointRetryQueues {
+	newQueue := func(endpointName string) *TransactionRetryQueue {
+		var folderPath string
+		if optionalDomainFolderPath != "" {
+			folderPath = path.Join(optionalDomainFolderPath, endpointName)
+		}
+		return BuildTransactionRetryQueue(
+			maxMemSizeInBytes,
+			flushToStorageRatio,
+			folderPath,
+			storageMaxSize,
+			dropPrioritySorter,
+			resolver)
+	}
+
+	var storedEndpointNames []string
+	if optionalDomainFolderPath != "" && storageMaxSize > 0 {
+		storedEndpointNames = getStoredEndpointNames(optionalDomainFolderPath)
+	}
+	return newEndpointRetryQueues(maxMemSizeInBytes, newQueue, storedEndpointNames)
+}
+
+// newEndpointRetryQueues creates a new instance of EndpointRetryQueues. The queues of the endpoints
+// in `storedEndpointNames` are created so that their transactions stored on disk are retried.
+func newEndpointRetryQueues(
+	maxMemSizeInBytes int,
+	newQueue func(endpointName string) *TransactionRetryQueue,
+	storedEndpointNames []string) *EndpointRetryQueues {
+	q := &EndpointRetryQueues{
+		queues:            make(map[string]*TransactionRetryQueue),
+		newQueue:          newQueue,
+		maxMemSizeInBytes: maxMemSizeInBytes,
+	}
+
+	// The transactions without endpoint and the ones stored on disk when the retry queue
+	// was shared by all the endpoints are stored in the domain folder.
+	q.queues[""] = newQueue("")
+	for _, endpointName := range storedEndpointNames {
+		q.queues[endpointName] = newQueue(endpointName)
+	}
+	return q
+}
+
+// getStoredEndpointNames returns the names of the endpoint folders of a domain folder.
+func getStoredEndpointNames(domainFolderPath string) []string {
+	entries, err := ioutil.ReadDir(domainFolderPath)
+	if err != nil {
+		return nil
+	}
+	var endpointNames []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			endpointNames = append(endpointNames, entry.Name())
+		}
+	}
+	return endpointNames
+}
+
+// Add adds a new transaction to the retry queue of its endpoint.
+// See TransactionRetryQueue.Add for more details.
+func (q *EndpointRetryQueues) Add(t transaction.Transaction) (int, error) {
+	return q.getQueue(t.GetEndpointName()).Add(t)
+}
+
+// ExtractTransactions extracts the transactions of the retry queues of all the endpoints.
+// See TransactionRetryQueue.ExtractTransactions for more details.
+func (q *EndpointRetryQueues) ExtractTransactions() ([]transaction.Transaction, error) {
+	var transactions []transaction.Transaction
+	var errs error
+	for _, queue := range q.getQueues() {
+		queueTransactions, err := queue.ExtractTransactions()
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		transactions = append(transactions, queueTransactions...)
+	}
+	return transactions, errs
+}
+
+// GetTransactionCount gets the number of transactions in the retry queues of all the endpoints
+func (q *EndpointRetryQueues) GetTransactionCount() int {
+	count := 0
+	for _, queue := range q.getQueues() {
+		count += queue.GetTransactionCount()
+	}
+	return count
+}
+
+// GetTransactionCountByEndpoint gets the number of transactions in the retry queues for each endpoint
+func (q *EndpointRetryQueues) GetTransactionCountByEndpoint() map[string]int {
+	counts := make(map[string]int)
+	for _, queue := range q.getQueues() {
+		for endpointName, count := range queue.GetTransactionCountByEndpoint() {
+			counts[endpointName] += count
+		}
+	}
+	return counts
+}
+
+// GetMaxMemSizeInBytes gets the maximum memory usage for storing the transactions of an endpoint
+func (q *EndpointRetryQueues) GetMaxMemSizeInBytes() int {
+	return q.maxMemSizeInBytes
+}
+
+func (q *EndpointRetryQueues) getQueue(endpointName string) *TransactionRetryQueue {
+	q.mutex.RLock()
+	queue, found := q.queues[endpointName]
+	q.mutex.RUnlock()
+	if found {
+		return queue
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if queue, found = q.queues[endpointName]; !found {
+		queue = q.newQueue(endpointName)
+		q.queues[endpointName] = queue
+	}
+	return queue
+}
+
+// getQueues returns the retry queues sorted by endpoint name.
+func (q *EndpointRetryQueues) getQueues() []*TransactionRetryQueue {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	endpointNames := make([]string, 0, len(q.queues))
+	for endpointName := range q.queues {
+		endpointNames = append(endpointNames, endpointName)
+	}
+	sort.Strings(endpointNames)
+
+	queues := make([]*TransactionRetryQueue, 0, len(endpointNames))
+	for _, endpointName := range endpointNames {
+		queues = append(queues, q.queues[endpointName])
+	}
+	return queues
+}
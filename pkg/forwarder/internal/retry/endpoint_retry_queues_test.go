@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"path"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointRetryQueuesIndependentBudgets(t *testing.T) {
+	a := assert.New(t)
+	q := newEndpointRetryQueues(10, func(endpointName string) *TransactionRetryQueue {
+		return NewTransactionRetryQueue(createDropPrioritySorter(), nil, 10, 0.1, NewTransactionRetryQueueTelemetry("domain"))
+	}, nil)
+	transactionsCount := transactionsCountTelemetry.expvar.Value()
+
+	dropCount, err := q.Add(createTransactionWithEndpoint("series_v1", 6))
+	a.NoError(err)
+	a.Equal(0, dropCount)
+
+	// Filling the queue of metadata_v2 doesn't drop the transactions of series_v1.
+	for i := 0; i < 3; i++ {
+		_, err = q.Add(createTransactionWithEndpoint("metadata_v2", 4))
+		a.NoError(err)
+	}
+	dropCount, err = q.Add(createTransactionWithEndpoint("metadata_v2", 4))
+	a.NoError(err)
+	a.Equal(1, dropCount)
+
+	a.Equal(3, q.GetTransactionCount())
+	a.Equal(map[string]int{"series_v1": 1, "metadata_v2": 2}, q.GetTransactionCountByEndpoint())
+	a.Equal(10, q.GetMaxMemSizeInBytes())
+	// The telemetry of the domain is the sum of the telemetry of the queues.
+	a.Equal(transactionsCount+3, transactionsCountTelemetry.expvar.Value())
+
+	transactions, err := q.ExtractTransactions()
+	a.NoError(err)
+	a.Equal([]string{"metadata_v2", "metadata_v2", "series_v1"}, getEndpointsFromTransactions(transactions))
+	a.Equal(0, q.GetTransactionCount())
+	a.Empty(q.GetTransactionCountByEndpoint())
+	a.Equal(transactionsCount, transactionsCountTelemetry.expvar.Value())
+}
+
+func TestEndpointRetryQueuesReloadStoredEndpoints(t *testing.T) {
+	a := assert.New(t)
+	domainFolder, clean := createTmpFolder(a)
+	defer clean()
+
+	newQueue := func(endpointName string) *TransactionRetryQueue {
+		disk := diskUsageRetrieverMock{
+			diskUsage: &filesystem.DiskUsage{
+				Available: 10000,
+				Total:     10000,
+			}}
+		folder := path.Join(domainFolder, endpointName)
+		storage, err := newOnDiskRetryQueue(
+			NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver(domainName, nil)),
+			&retryFileCodec{},
+			folder,
+			newDiskUsageLimit(folder, disk, 1000, 1),
+			newOnDiskRetryQueueTelemetry("domain"))
+		a.NoError(err)
+		return NewTransactionRetryQueue(createDropPrioritySorter(), storage, 10, 1, NewTransactionRetryQueueTelemetry("domain"))
+	}
+
+	q := newEndpointRetryQueues(10, newQueue, getStoredEndpointNames(domainFolder))
+	for i := 0; i < 3; i++ {
+		_, err := q.Add(createTransactionWithEndpoint("series_v1", 6))
+		a.NoError(err)
+	}
+
+	// The files stored by the queue of series_v1 are reloaded when the queues are created again.
+	a.Equal([]string{"series_v1"}, getStoredEndpointNames(domainFolder))
+	q = newEndpointRetryQueues(10, newQueue, getStoredEndpointNames(domainFolder))
+	for _, expectedCount := range []int{1, 1, 0} {
+		transactions, err := q.ExtractTransactions()
+		a.NoError(err)
+		a.Len(transactions, expectedCount)
+	}
+}
+
+func createTransactionWithEndpoint(endpointName string, payloadSize int) *transaction.HTTPTransaction {
+	tr := createTransactionWithPayloadSize(payloadSize)
+	tr.Domain = domainName
+	tr.Endpoint = transaction.Endpoint{Route: "/" + endpointName, Name: endpointName}
+	return tr
+}
@@ -109,6 +109,11 @@ func (p *FileRemovalPolicy) forEachDomainPath(callback func(folderPath string) (
 }
 
 func (p *FileRemovalPolicy) getFolderPathForDomain(domainName string) (string, error) {
+	return GetDomainFolderPath(p.rootPath, domainName)
+}
+
+// GetDomainFolderPath returns the path of the folder storing the files of a domain in `rootPath`.
+func GetDomainFolderPath(rootPath string, domainName string) (string, error) {
 	// Use md5 for the folder name as the domainName is an url which can contain invalid charaters for a file path.
 	h := md5.New()
 	if _, err := io.WriteString(h, domainName); err != nil {
@@ -116,13 +121,18 @@ func (p *FileRemovalPolicy) getFolderPathForDomain(domainName string) (string, e
 	}
 	folder := fmt.Sprintf("%x", h.Sum(nil))
 
-	return path.Join(p.rootPath, folder), nil
+	return path.Join(rootPath, folder), nil
 }
 
 func (p *FileRemovalPolicy) removeUnknownDomain(folderPath string) ([]string, error) {
 	files, err := p.removeRetryFiles(folderPath, func(filename string) bool { return true })
 
-	// Try to remove the folder if it is empty
+	// Try to remove the endpoint folders and the folder if they are empty
+	if subFolders, subFoldersErr := p.getEndpointFolders(folderPath); subFoldersErr == nil {
+		for _, subFolder := range subFolders {
+			_ = os.Remove(subFolder)
+		}
+	}
 	_ = os.Remove(folderPath)
 	return files, err
 }
@@ -157,6 +167,8 @@ func (p *FileRemovalPolicy) removeRetryFiles(folderPath string, shouldRemove fun
 	return filesRemoved, errs
 }
 
+// getRetryFiles returns the retry files and the quarantined retry files of a domain folder
+// and of its endpoint folders (see EndpointRetryQueues).
 func (p *FileRemovalPolicy) getRetryFiles(folder string) ([]string, error) {
 	entries, err := ioutil.ReadDir(folder)
 	if err != nil {
@@ -164,9 +176,39 @@ func (p *FileRemovalPolicy) getRetryFiles(folder string) ([]string, error) {
 	}
 	var files []string
 	for _, entry := range entries {
-		if entry.Mode().IsRegular() && filepath.Ext(entry.Name()) == retryTransactionsExtension {
-			files = append(files, path.Join(folder, entry.Name()))
+		entryPath := path.Join(folder, entry.Name())
+		if entry.Mode().IsRegular() && isRetryFile(entry.Name()) {
+			files = append(files, entryPath)
+		} else if entry.IsDir() {
+			endpointEntries, err := ioutil.ReadDir(entryPath)
+			if err != nil {
+				return nil, err
+			}
+			for _, endpointEntry := range endpointEntries {
+				if endpointEntry.Mode().IsRegular() && isRetryFile(endpointEntry.Name()) {
+					files = append(files, path.Join(entryPath, endpointEntry.Name()))
+				}
+			}
 		}
 	}
 	return files, nil
 }
+
+func (p *FileRemovalPolicy) getEndpointFolders(folder string) ([]string, error) {
+	entries, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	var folders []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			folders = append(folders, path.Join(folder, entry.Name()))
+		}
+	}
+	return folders, nil
+}
+
+func isRetryFile(filename string) bool {
+	ext := filepath.Ext(filename)
+	return ext == retryTransactionsExtension || ext == quarantinedRetryFileExtension
+}
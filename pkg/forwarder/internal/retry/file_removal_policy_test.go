@@ -55,10 +55,44 @@ func TestFileRemovalPolicyOutdatedFiles(t *testing.T) {
 	modTime = time.Now().Add(time.Duration(-1*24) * time.Hour)
 	a.NoError(os.Chtimes(file3, modTime, modTime))
 
+	// Quarantined files are also removed when they are outdated.
+	file4 := createFile(a, domain, "file4"+quarantinedRetryFileExtension)
+	file5 := createFile(a, domain, "file5"+quarantinedRetryFileExtension)
+	modTime = time.Now().Add(time.Duration(-3*24) * time.Hour)
+	a.NoError(os.Chtimes(file4, modTime, modTime))
+
+	pathsRemoved, err := p.RemoveOutdatedFiles()
+	a.NoError(err)
+	assertFilenamesEqual(a, []string{file2, file4}, pathsRemoved)
+	assertFilenamesEqual(a, []string{file1, file3, file5}, getRemainingFiles(a, root))
+}
+
+func TestFileRemovalPolicyEndpointFolders(t *testing.T) {
+	a := assert.New(t)
+	root, clean := createTmpFolder(a)
+	defer clean()
+	p, err := NewFileRemovalPolicy(root, 2, FileRemovalPolicyTelemetry{})
+	a.NoError(err)
+
+	domain, err := p.RegisterDomain("domain")
+	a.NoError(err)
+
+	file1 := createRetryFile(a, path.Join(domain, "series_v1"), "file1")
+	file2 := createRetryFile(a, path.Join(domain, "series_v1"), "file2")
+	file3 := createRetryFile(a, path.Join(root, "unknownDomain", "series_v1"), "file3")
+
+	modTime := time.Now().Add(time.Duration(-3*24) * time.Hour)
+	a.NoError(os.Chtimes(file2, modTime, modTime))
+
 	pathsRemoved, err := p.RemoveOutdatedFiles()
 	a.NoError(err)
 	assertFilenamesEqual(a, []string{file2}, pathsRemoved)
-	assertFilenamesEqual(a, []string{file1, file3}, getRemainingFiles(a, root))
+
+	pathsRemoved, err = p.RemoveUnknownDomains()
+	a.NoError(err)
+	assertFilenamesEqual(a, []string{file3}, pathsRemoved)
+	assertFilenamesEqual(a, []string{file1}, getRemainingFiles(a, root))
+	a.NoDirExists(path.Join(root, "unknownDomain"))
 }
 
 func TestFileRemovalPolicyExistingDomain(t *testing.T) {
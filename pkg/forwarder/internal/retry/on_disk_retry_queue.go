@@ -12,6 +12,7 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
@@ -20,19 +21,30 @@ import (
 )
 
 const retryTransactionsExtension = ".retry"
+const quarantinedRetryFileExtension = ".quarantine"
 const retryFileFormat = "2006_01_02__15_04_05_"
 
 type onDiskRetryQueue struct {
 	serializer         *HTTPTransactionsSerializer
+	codec              *retryFileCodec
 	storagePath        string
 	diskUsageLimit     *diskUsageLimit
 	filenames          []string
+	quarantinedFiles   []quarantinedFile
 	currentSizeInBytes int64
 	telemetry          onDiskRetryQueueTelemetry
 }
 
+// quarantinedFile is a quarantined retry file, its size is counted in the disk space used
+// until it is removed.
+type quarantinedFile struct {
+	filename    string
+	sizeInBytes int64
+}
+
 func newOnDiskRetryQueue(
 	serializer *HTTPTransactionsSerializer,
+	codec *retryFileCodec,
 	storagePath string,
 	diskUsageLimit *diskUsageLimit,
 	telemetry onDiskRetryQueueTelemetry) (*onDiskRetryQueue, error) {
@@ -43,6 +55,7 @@ func newOnDiskRetryQueue(
 
 	storage := &onDiskRetryQueue{
 		serializer:     serializer,
+		codec:          codec,
 		storagePath:    storagePath,
 		diskUsageLimit: diskUsageLimit,
 		telemetry:      telemetry,
@@ -77,6 +90,9 @@ func (s *onDiskRetryQueue) Serialize(transactions []transaction.Transaction) err
 	if err != nil {
 		return err
 	}
+	if bytes, err = s.codec.encode(bytes); err != nil {
+		return err
+	}
 	bufferSize := int64(len(bytes))
 
 	if err := s.makeRoomFor(bufferSize); err != nil {
@@ -111,19 +127,32 @@ func (s *onDiskRetryQueue) Deserialize() ([]transaction.Transaction, error) {
 	s.telemetry.addDeserializeCount()
 	index := len(s.filenames) - 1
 	path := s.filenames[index]
-	bytes, err := ioutil.ReadFile(path)
-
-	// Remove the file even in case of a read failure.
-	if errRemoveFile := s.removeFileAt(index); errRemoveFile != nil {
-		return nil, errRemoveFile
-	}
-
+	content, err := ioutil.ReadFile(path)
 	if err != nil {
+		// Remove the file even in case of a read failure.
+		if errRemoveFile := s.removeFileAt(index); errRemoveFile != nil {
+			return nil, errRemoveFile
+		}
 		return nil, err
 	}
 
-	transactions, errorsCount, err := s.serializer.Deserialize(bytes)
+	var transactions []transaction.Transaction
+	var errorsCount int
+	bytes, err := s.codec.decode(content)
+	if err == nil {
+		transactions, errorsCount, err = s.serializer.Deserialize(bytes)
+	}
 	if err != nil {
+		// Keep the file for investigation instead of retrying it again and again.
+		if errQuarantine := s.quarantineFileAt(index); errQuarantine != nil {
+			return nil, errQuarantine
+		}
+		s.telemetry.setCurrentSizeInBytes(s.getCurrentSizeInBytes())
+		s.telemetry.setFilesCount(s.getFilesCount())
+		return nil, fmt.Errorf("cannot read the retry file %s, the file is moved to quarantine: %v", path, err)
+	}
+
+	if err := s.removeFileAt(index); err != nil {
 		return nil, err
 	}
 	s.telemetry.addDeserializeErrorsCount(errorsCount)
@@ -153,6 +182,12 @@ func (s *onDiskRetryQueue) makeRoomFor(bufferSize int64) error {
 	if err != nil {
 		return err
 	}
+	// The quarantined files are removed first as they are not retried.
+	for len(s.quarantinedFiles) > 0 && s.currentSizeInBytes+bufferSize > maxStorageInBytes {
+		if err := s.removeQuarantinedFileAt(0); err != nil {
+			return err
+		}
+	}
 	for len(s.filenames) > 0 && s.currentSizeInBytes+bufferSize > maxStorageInBytes {
 		index := 0
 		filename := s.filenames[index]
@@ -186,16 +221,53 @@ func (s *onDiskRetryQueue) removeFileAt(index int) error {
 	return nil
 }
 
+// quarantineFileAt renames a file which cannot be read so that it is not retried and is
+// removed by FileRemovalPolicy when it becomes outdated, or earlier when room is needed.
+func (s *onDiskRetryQueue) quarantineFileAt(index int) error {
+	filename := s.filenames[index]
+	s.filenames = append(s.filenames[:index], s.filenames[index+1:]...)
+
+	size, err := util.GetFileSize(filename)
+	if err != nil {
+		return err
+	}
+
+	quarantinedFilename := strings.TrimSuffix(filename, retryTransactionsExtension) + quarantinedRetryFileExtension
+	if err := os.Rename(filename, quarantinedFilename); err != nil {
+		return err
+	}
+	log.Warnf("The retry file %s cannot be read and is moved to %s", filename, quarantinedFilename)
+
+	s.quarantinedFiles = append(s.quarantinedFiles, quarantinedFile{filename: quarantinedFilename, sizeInBytes: size})
+	s.telemetry.addFilesQuarantinedCount()
+	return nil
+}
+
+// removeQuarantinedFileAt removes a quarantined file to make room for new retry files. The
+// file may already have been removed by FileRemovalPolicy.
+func (s *onDiskRetryQueue) removeQuarantinedFileAt(index int) error {
+	file := s.quarantinedFiles[index]
+	s.quarantinedFiles = append(s.quarantinedFiles[:index], s.quarantinedFiles[index+1:]...)
+	s.currentSizeInBytes -= file.sizeInBytes
+
+	log.Errorf("Maximum disk space for retry transactions is reached. Removing the quarantined file %s", file.filename)
+	if err := os.Remove(file.filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 func (s *onDiskRetryQueue) reloadExistingRetryFiles() error {
-	files, sizeInBytes, err := s.getExistingRetryFiles()
+	files, sizeInBytes, err := s.getExistingRetryFiles(retryTransactionsExtension)
 	if err != nil {
 		return err
 	}
-	s.currentSizeInBytes = sizeInBytes
+	quarantinedFiles, quarantinedSizeInBytes, err := s.getExistingRetryFiles(quarantinedRetryFileExtension)
+	if err != nil {
+		return err
+	}
+	s.currentSizeInBytes = sizeInBytes + quarantinedSizeInBytes
 
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].ModTime().Before(files[j].ModTime())
-	})
 	var filenames []string
 	for _, file := range files {
 		fullPath := path.Join(s.storagePath, file.Name())
@@ -203,10 +275,18 @@ func (s *onDiskRetryQueue) reloadExistingRetryFiles() error {
 	}
 	s.telemetry.setReloadedRetryFilesCount(len(filenames))
 	s.filenames = append(s.filenames, filenames...)
+
+	// The quarantined files are not retried but they still use disk space.
+	for _, file := range quarantinedFiles {
+		fullPath := path.Join(s.storagePath, file.Name())
+		s.quarantinedFiles = append(s.quarantinedFiles, quarantinedFile{filename: fullPath, sizeInBytes: file.Size()})
+	}
 	return nil
 }
 
-func (s *onDiskRetryQueue) getExistingRetryFiles() ([]os.FileInfo, int64, error) {
+// getExistingRetryFiles returns the files with the given extension sorted by modification
+// time, and their total size.
+func (s *onDiskRetryQueue) getExistingRetryFiles(extension string) ([]os.FileInfo, int64, error) {
 	entries, err := ioutil.ReadDir(s.storagePath)
 	if err != nil {
 		return nil, 0, err
@@ -214,10 +294,13 @@ func (s *onDiskRetryQueue) getExistingRetryFiles() ([]os.FileInfo, int64, error)
 	var files []os.FileInfo
 	currentSizeInBytes := int64(0)
 	for _, entry := range entries {
-		if entry.Mode().IsRegular() && filepath.Ext(entry.Name()) == retryTransactionsExtension {
+		if entry.Mode().IsRegular() && filepath.Ext(entry.Name()) == extension {
 			currentSizeInBytes += entry.Size()
 			files = append(files, entry)
 		}
 	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
 	return files, currentSizeInBytes, nil
 }
@@ -8,6 +8,7 @@ package retry
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 
@@ -48,7 +49,7 @@ func TestOnDiskRetryQueueMaxSize(t *testing.T) {
 	path, clean := createTmpFolder(a)
 	defer clean()
 
-	maxSizeInBytes := int64(100)
+	maxSizeInBytes := int64(300)
 	q := newTestOnDiskRetryQueue(a, path, maxSizeInBytes)
 
 	i := 0
@@ -91,6 +92,118 @@ func TestOnDiskRetryQueueReloadExistingRetryFiles(t *testing.T) {
 	a.Equal([]string{"endpoint1", "endpoint2"}, getEndpointsFromTransactions(transactions))
 }
 
+func TestOnDiskRetryQueueEncryption(t *testing.T) {
+	a := assert.New(t)
+	path, clean := createTmpFolder(a)
+	defer clean()
+
+	q := newTestOnDiskRetryQueueWithEncryptionKey(a, path, 1000, "key")
+	tr := transaction.NewHTTPTransaction()
+	tr.Domain = domainName
+	tr.Endpoint.Name = "endpoint1"
+	tr.Headers.Set("DD-Api-Key", "secret_api_key")
+	a.NoError(q.Serialize([]transaction.Transaction{tr}))
+
+	content, err := ioutil.ReadFile(q.filenames[0])
+	a.NoError(err)
+	a.NotContains(string(content), "endpoint1")
+
+	// The files cannot be read with another key and are moved to quarantine.
+	newRetryQueue := newTestOnDiskRetryQueueWithEncryptionKey(a, path, 1000, "other key")
+	_, err = newRetryQueue.Deserialize()
+	a.Error(err)
+	a.Equal(0, newRetryQueue.getFilesCount())
+	a.Equal(int64(len(content)), newRetryQueue.getCurrentSizeInBytes())
+	quarantinedFiles := getFilesWithExtension(a, path, quarantinedRetryFileExtension)
+	a.Len(quarantinedFiles, 1)
+	a.NoError(os.Remove(quarantinedFiles[0]))
+}
+
+func TestOnDiskRetryQueueCorruptedFile(t *testing.T) {
+	a := assert.New(t)
+	path, clean := createTmpFolder(a)
+	defer clean()
+
+	q := newTestOnDiskRetryQueue(a, path, 1000)
+	a.NoError(q.Serialize(createHTTPTransactionCollectionTests("endpoint1")))
+	a.NoError(q.Serialize(createHTTPTransactionCollectionTests("endpoint2")))
+
+	content, err := ioutil.ReadFile(q.filenames[1])
+	a.NoError(err)
+	content[len(content)-1]++
+	a.NoError(ioutil.WriteFile(q.filenames[1], content, 0600))
+
+	_, err = q.Deserialize()
+	a.Error(err)
+	a.Equal(1, q.getFilesCount())
+	a.Len(getFilesWithExtension(a, path, quarantinedRetryFileExtension), 1)
+
+	// The other files are still retried.
+	transactions, err := q.Deserialize()
+	a.NoError(err)
+	a.Equal([]string{"endpoint1"}, getEndpointsFromTransactions(transactions))
+	// The quarantined file still uses disk space.
+	a.Equal(int64(len(content)), q.getCurrentSizeInBytes())
+
+	// Quarantined files are not reloaded but their size is counted.
+	newRetryQueue := newTestOnDiskRetryQueue(a, path, 1000)
+	a.Equal(0, newRetryQueue.getFilesCount())
+	a.Equal(int64(len(content)), newRetryQueue.getCurrentSizeInBytes())
+	a.NoError(os.Remove(getFilesWithExtension(a, path, quarantinedRetryFileExtension)[0]))
+}
+
+func TestOnDiskRetryQueueMaxSizeWithQuarantinedFiles(t *testing.T) {
+	a := assert.New(t)
+	path, clean := createTmpFolder(a)
+	defer clean()
+
+	q := newTestOnDiskRetryQueue(a, path, 1000)
+	a.NoError(q.Serialize(createHTTPTransactionCollectionTests("endpoint1")))
+	a.NoError(q.Serialize(createHTTPTransactionCollectionTests("endpoint2")))
+	sizeInBytes := q.getCurrentSizeInBytes()
+
+	content, err := ioutil.ReadFile(q.filenames[1])
+	a.NoError(err)
+	content[len(content)-1]++
+	a.NoError(ioutil.WriteFile(q.filenames[1], content, 0600))
+	_, err = q.Deserialize()
+	a.Error(err)
+	a.Len(getFilesWithExtension(a, path, quarantinedRetryFileExtension), 1)
+
+	// The quarantined file is removed before the files which are retried.
+	q.diskUsageLimit.maxSizeInBytes = sizeInBytes
+	a.NoError(q.Serialize(createHTTPTransactionCollectionTests("endpoint3")))
+	a.Len(getFilesWithExtension(a, path, quarantinedRetryFileExtension), 0)
+	a.Equal(2, q.getFilesCount())
+	a.Equal(sizeInBytes, q.getCurrentSizeInBytes())
+
+	for _, endpoint := range []string{"endpoint3", "endpoint1"} {
+		transactions, err := q.Deserialize()
+		a.NoError(err)
+		a.Equal([]string{endpoint}, getEndpointsFromTransactions(transactions))
+	}
+}
+
+func TestOnDiskRetryQueueFileWithoutHeader(t *testing.T) {
+	a := assert.New(t)
+	path, clean := createTmpFolder(a)
+	defer clean()
+
+	// Files written by previous versions of the Agent only contain the serialized transactions.
+	serializer := NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver(domainName, nil))
+	for _, tr := range createHTTPTransactionCollectionTests("endpoint1") {
+		a.NoError(tr.SerializeTo(serializer))
+	}
+	bytes, err := serializer.GetBytesAndReset()
+	a.NoError(err)
+	a.NoError(ioutil.WriteFile(filepath.Join(path, "legacy"+retryTransactionsExtension), bytes, 0600))
+
+	q := newTestOnDiskRetryQueueWithEncryptionKey(a, path, 1000, "key")
+	transactions, err := q.Deserialize()
+	a.NoError(err)
+	a.Equal([]string{"endpoint1"}, getEndpointsFromTransactions(transactions))
+}
+
 func createHTTPTransactionCollectionTests(endpoints ...string) []transaction.Transaction {
 	var transactions []transaction.Transaction
 
@@ -118,7 +231,19 @@ func getEndpointsFromTransactions(transactions []transaction.Transaction) []stri
 	return endpoints
 }
 
+func getFilesWithExtension(a *assert.Assertions, folder string, extension string) []string {
+	files, err := filepath.Glob(filepath.Join(folder, "*"+extension))
+	a.NoError(err)
+	return files
+}
+
 func newTestOnDiskRetryQueue(a *assert.Assertions, path string, maxSizeInBytes int64) *onDiskRetryQueue {
+	return newTestOnDiskRetryQueueWithEncryptionKey(a, path, maxSizeInBytes, "")
+}
+
+func newTestOnDiskRetryQueueWithEncryptionKey(a *assert.Assertions, path string, maxSizeInBytes int64, encryptionKey string) *onDiskRetryQueue {
+	codec, err := newRetryFileCodec(encryptionKey)
+	a.NoError(err)
 	telemetry := newOnDiskRetryQueueTelemetry("domain")
 	disk := diskUsageRetrieverMock{
 		diskUsage: &filesystem.DiskUsage{
@@ -126,7 +251,7 @@ func newTestOnDiskRetryQueue(a *assert.Assertions, path string, maxSizeInBytes i
 			Total:     10000,
 		}}
 	diskUsageLimit := newDiskUsageLimit("", disk, maxSizeInBytes, 1)
-	storage, err := newOnDiskRetryQueue(NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver(domainName, nil)), path, diskUsageLimit, telemetry)
+	storage, err := newOnDiskRetryQueue(NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver(domainName, nil)), codec, path, diskUsageLimit, telemetry)
 	a.NoError(err)
 	return storage
 }
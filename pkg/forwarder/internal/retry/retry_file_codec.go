@@ -0,0 +1,125 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The content of a `.retry` file is made of:
+//   - `retryFileMagic`
+//   - the version of the format (1 byte)
+//   - the flags (1 byte), `retryFileEncryptedFlag` is set when the data is encrypted
+//   - the SHA-256 checksum of the data
+//   - the data: the serialized transactions, or the nonce followed by the serialized
+//     transactions encrypted with AES-256-GCM when `retryFileEncryptedFlag` is set.
+//
+// The files written by the previous versions of the Agent only contain the serialized transactions.
+// As a protobuf message starts with a field key, they never start with `retryFileMagic`.
+const (
+	retryFileMagic         = "\xfeRTRY"
+	retryFileVersion       = 1
+	retryFileEncryptedFlag = 1
+	retryFileHeaderSize    = len(retryFileMagic) + 2 + sha256.Size
+)
+
+var (
+	errCorruptedRetryFile  = errors.New("the retry file is corrupted")
+	errMissingRetryFileKey = errors.New("the retry file is encrypted but `forwarder_storage_encryption_key` is not set")
+)
+
+// retryFileCodec encodes and decodes the content of the `.retry` files.
+type retryFileCodec struct {
+	// aead encrypts the data, nil when the encryption is disabled.
+	aead cipher.AEAD
+}
+
+// newRetryFileCodec creates a new instance of retryFileCodec. The data is encrypted when
+// `encryptionKey` is not empty, with a 256-bit key derived from `encryptionKey`.
+func newRetryFileCodec(encryptionKey string) (*retryFileCodec, error) {
+	if encryptionKey == "" {
+		return &retryFileCodec{}, nil
+	}
+
+	key := sha256.Sum256([]byte(encryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &retryFileCodec{aead: aead}, nil
+}
+
+// encode returns the content of a `.retry` file storing `data`.
+func (c *retryFileCodec) encode(data []byte) ([]byte, error) {
+	var flags byte
+	if c.aead != nil {
+		nonce := make([]byte, c.aead.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, err
+		}
+		data = c.aead.Seal(nonce, nonce, data, nil)
+		flags |= retryFileEncryptedFlag
+	}
+
+	checksum := sha256.Sum256(data)
+	content := make([]byte, 0, retryFileHeaderSize+len(data))
+	content = append(content, retryFileMagic...)
+	content = append(content, retryFileVersion, flags)
+	content = append(content, checksum[:]...)
+	return append(content, data...), nil
+}
+
+// decode returns the data stored in the content of a `.retry` file. It returns
+// `errCorruptedRetryFile` when the checksum or the encryption doesn't match the data.
+func (c *retryFileCodec) decode(content []byte) ([]byte, error) {
+	if !bytes.HasPrefix(content, []byte(retryFileMagic)) {
+		// File written by a previous version of the Agent
+		return content, nil
+	}
+	if len(content) < retryFileHeaderSize {
+		return nil, errCorruptedRetryFile
+	}
+
+	version := content[len(retryFileMagic)]
+	flags := content[len(retryFileMagic)+1]
+	if version != retryFileVersion {
+		return nil, fmt.Errorf("unsupported retry file version %v", version)
+	}
+
+	checksum := content[len(retryFileMagic)+2 : retryFileHeaderSize]
+	data := content[retryFileHeaderSize:]
+	if computed := sha256.Sum256(data); !bytes.Equal(checksum, computed[:]) {
+		return nil, errCorruptedRetryFile
+	}
+
+	if flags&retryFileEncryptedFlag == 0 {
+		return data, nil
+	}
+	if c.aead == nil {
+		return nil, errMissingRetryFileKey
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errCorruptedRetryFile
+	}
+	data, err := c.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		// The checksum matches so the key is likely not the one used to encrypt the file.
+		return nil, fmt.Errorf("cannot decrypt the retry file, `forwarder_storage_encryption_key` may have changed: %v", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,66 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryFileCodec(t *testing.T) {
+	a := assert.New(t)
+	data := []byte("transactions")
+
+	for _, encryptionKey := range []string{"", "key"} {
+		codec, err := newRetryFileCodec(encryptionKey)
+		a.NoError(err)
+
+		content, err := codec.encode(data)
+		a.NoError(err)
+		a.Equal(encryptionKey == "", string(content[retryFileHeaderSize:]) == string(data))
+
+		decoded, err := codec.decode(content)
+		a.NoError(err)
+		a.Equal(data, decoded)
+
+		content[len(content)-1]++
+		_, err = codec.decode(content)
+		a.Equal(errCorruptedRetryFile, err)
+
+		_, err = codec.decode(content[:retryFileHeaderSize-1])
+		a.Equal(errCorruptedRetryFile, err)
+	}
+}
+
+func TestRetryFileCodecEncryptionKey(t *testing.T) {
+	a := assert.New(t)
+	codec, err := newRetryFileCodec("key")
+	a.NoError(err)
+	content, err := codec.encode([]byte("transactions"))
+	a.NoError(err)
+
+	noEncryptionCodec, err := newRetryFileCodec("")
+	a.NoError(err)
+	_, err = noEncryptionCodec.decode(content)
+	a.Equal(errMissingRetryFileKey, err)
+
+	otherCodec, err := newRetryFileCodec("other key")
+	a.NoError(err)
+	_, err = otherCodec.decode(content)
+	a.Error(err)
+}
+
+func TestRetryFileCodecFileWithoutHeader(t *testing.T) {
+	a := assert.New(t)
+	codec, err := newRetryFileCodec("key")
+	a.NoError(err)
+
+	data := []byte{0x08, 0x01}
+	decoded, err := codec.decode(data)
+	a.NoError(err)
+	a.Equal(data, decoded)
+}
@@ -51,6 +51,23 @@ func (g *gaugeExpvar) set(v float64, tagsValue ...string) {
 	g.expvar.Set(int64(v))
 }
 
+func (g *gaugeExpvar) add(v float64, tagsValue ...string) {
+	g.gauge.Add(v, tagsValue...)
+	g.expvar.Add(int64(v))
+}
+
+// queueGauge reports the value of a retry queue to a gauge shared by all the retry queues of
+// a domain, when there is one retry queue per endpoint. The gauge is the sum of their values.
+type queueGauge struct {
+	gauge *gaugeExpvar
+	value float64
+}
+
+func (g *queueGauge) set(v float64, domainName string) {
+	g.gauge.add(v-g.value, domainName)
+	g.value = v
+}
+
 var (
 	removalPolicyExpvar                  = expvar.Map{}
 	newRemovalPolicyCountTelemetry       *gaugeExpvar
@@ -74,6 +91,7 @@ var (
 	filesRemovedCountTelemetry              *counterExpvar
 	deserializeErrorsCountTelemetry         *counterExpvar
 	deserializeTransactionsCountTelemetry   *counterExpvar
+	filesQuarantinedCountTelemetry          *counterExpvar
 )
 
 func init() {
@@ -185,6 +203,12 @@ func init() {
 		domainTag,
 		"The number of transactions read from the disk",
 		&fileStorageExpvar)
+	filesQuarantinedCountTelemetry = newCounterExpvar(
+		"file_storage",
+		"files_quarantined_count",
+		domainTag,
+		"The number of files quarantined because they cannot be read",
+		&fileStorageExpvar)
 }
 
 // FileRemovalPolicyTelemetry handles the telemetry for FileRemovalPolicy.
@@ -207,22 +231,26 @@ func (FileRemovalPolicyTelemetry) setFilesFromUnknownDomainCount(count int) {
 
 // TransactionRetryQueueTelemetry handles the telemetry for TransactionRetryQueue
 type TransactionRetryQueueTelemetry struct {
-	domainName string
+	domainName            string
+	currentMemSizeInBytes *queueGauge
+	transactionsCount     *queueGauge
 }
 
 // NewTransactionRetryQueueTelemetry creates a new TransactionRetryQueueTelemetry
 func NewTransactionRetryQueueTelemetry(domainName string) TransactionRetryQueueTelemetry {
 	return TransactionRetryQueueTelemetry{
-		domainName: domainName,
+		domainName:            domainName,
+		currentMemSizeInBytes: &queueGauge{gauge: currentMemSizeInBytesTelemetry},
+		transactionsCount:     &queueGauge{gauge: transactionsCountTelemetry},
 	}
 }
 
 func (t TransactionRetryQueueTelemetry) setCurrentMemSizeInBytes(count int) {
-	currentMemSizeInBytesTelemetry.set(float64(count), t.domainName)
+	t.currentMemSizeInBytes.set(float64(count), t.domainName)
 }
 
 func (t TransactionRetryQueueTelemetry) setTransactionsCount(count int) {
-	transactionsCountTelemetry.set(float64(count), t.domainName)
+	t.transactionsCount.set(float64(count), t.domainName)
 }
 
 func (t TransactionRetryQueueTelemetry) addTransactionsDroppedCount(count int) {
@@ -234,12 +262,18 @@ func (t TransactionRetryQueueTelemetry) incErrorsCount() {
 }
 
 type onDiskRetryQueueTelemetry struct {
-	domainName string
+	domainName              string
+	currentSizeInBytes      *queueGauge
+	filesCount              *queueGauge
+	reloadedRetryFilesCount *queueGauge
 }
 
 func newOnDiskRetryQueueTelemetry(domainName string) onDiskRetryQueueTelemetry {
 	return onDiskRetryQueueTelemetry{
-		domainName: domainName,
+		domainName:              domainName,
+		currentSizeInBytes:      &queueGauge{gauge: currentSizeInBytesTelemetry},
+		filesCount:              &queueGauge{gauge: filesCountTelemetry},
+		reloadedRetryFilesCount: &queueGauge{gauge: startupReloadedRetryFilesCountTelemetry},
 	}
 }
 
@@ -256,15 +290,15 @@ func (t onDiskRetryQueueTelemetry) setFileSize(count int64) {
 }
 
 func (t onDiskRetryQueueTelemetry) setCurrentSizeInBytes(count int64) {
-	currentSizeInBytesTelemetry.set(float64(count), t.domainName)
+	t.currentSizeInBytes.set(float64(count), t.domainName)
 }
 
 func (t onDiskRetryQueueTelemetry) setFilesCount(count int) {
-	filesCountTelemetry.set(float64(count), t.domainName)
+	t.filesCount.set(float64(count), t.domainName)
 }
 
 func (t onDiskRetryQueueTelemetry) setReloadedRetryFilesCount(count int) {
-	startupReloadedRetryFilesCountTelemetry.set(float64(count), t.domainName)
+	t.reloadedRetryFilesCount.set(float64(count), t.domainName)
 }
 
 func (t onDiskRetryQueueTelemetry) addFilesRemovedCount() {
@@ -279,6 +313,10 @@ func (t onDiskRetryQueueTelemetry) addDeserializeTransactionsCount(count int) {
 	deserializeTransactionsCountTelemetry.add(float64(count), t.domainName)
 }
 
+func (t onDiskRetryQueueTelemetry) addFilesQuarantinedCount() {
+	filesQuarantinedCountTelemetry.add(1, t.domainName)
+}
+
 func toCamelCase(s string) string {
 	parts := strings.Split(s, "_")
 	var camelCase string
@@ -32,6 +32,7 @@ type TransactionPrioritySorter interface {
 // limit is exceeded.
 type TransactionRetryQueue struct {
 	transactions                  []transaction.Transaction
+	transactionCountByEndpoint    map[string]int
 	currentMemSizeInBytes         int
 	maxMemSizeInBytes             int
 	flushToStorageRatio           float64
@@ -56,8 +57,12 @@ func BuildTransactionRetryQueue(
 		serializer := NewHTTPTransactionsSerializer(resolver)
 		diskRatio := config.Datadog.GetFloat64("forwarder_storage_max_disk_ratio")
 
-		diskUsageLimit := newDiskUsageLimit(optionalDomainFolderPath, filesystem.NewDisk(), storageMaxSize, diskRatio)
-		storage, err = newOnDiskRetryQueue(serializer, optionalDomainFolderPath, diskUsageLimit, newOnDiskRetryQueueTelemetry(resolver.GetBaseDomain()))
+		var codec *retryFileCodec
+		codec, err = newRetryFileCodec(config.Datadog.GetString("forwarder_storage_encryption_key"))
+		if err == nil {
+			diskUsageLimit := newDiskUsageLimit(optionalDomainFolderPath, filesystem.NewDisk(), storageMaxSize, diskRatio)
+			storage, err = newOnDiskRetryQueue(serializer, codec, optionalDomainFolderPath, diskUsageLimit, newOnDiskRetryQueueTelemetry(resolver.GetBaseDomain()))
+		}
 
 		// If the storage on disk cannot be used, log the error and continue.
 		// Returning `nil, err` would mean not using `TransactionRetryQueue` and so not using `forwarder_retry_queue_payloads_max_size` config.
@@ -82,6 +87,7 @@ func NewTransactionRetryQueue(
 	flushToStorageRatio float64,
 	telemetry TransactionRetryQueueTelemetry) *TransactionRetryQueue {
 	return &TransactionRetryQueue{
+		transactionCountByEndpoint:    make(map[string]int),
 		maxMemSizeInBytes:             maxMemSizeInBytes,
 		flushToStorageRatio:           flushToStorageRatio,
 		dropPrioritySorter:            dropPrioritySorter,
@@ -129,6 +135,7 @@ func (tc *TransactionRetryQueue) Add(t transaction.Transaction) (int, error) {
 	}
 
 	tc.transactions = append(tc.transactions, t)
+	tc.transactionCountByEndpoint[t.GetEndpointName()]++
 	tc.currentMemSizeInBytes += payloadSize
 	tc.telemetry.setCurrentMemSizeInBytes(tc.currentMemSizeInBytes)
 	tc.telemetry.setTransactionsCount(len(tc.transactions))
@@ -149,6 +156,7 @@ func (tc *TransactionRetryQueue) ExtractTransactions() ([]transaction.Transactio
 	if len(tc.transactions) > 0 {
 		transactions = tc.transactions
 		tc.transactions = nil
+		tc.transactionCountByEndpoint = make(map[string]int)
 	} else if tc.optionalTransactionSerializer != nil {
 		transactions, err = tc.optionalTransactionSerializer.Deserialize()
 		if err != nil {
@@ -178,6 +186,18 @@ func (tc *TransactionRetryQueue) GetTransactionCount() int {
 	return len(tc.transactions)
 }
 
+// GetTransactionCountByEndpoint gets the number of transactions in the container for each endpoint
+func (tc *TransactionRetryQueue) GetTransactionCountByEndpoint() map[string]int {
+	tc.mutex.RLock()
+	defer tc.mutex.RUnlock()
+
+	counts := make(map[string]int, len(tc.transactionCountByEndpoint))
+	for endpointName, count := range tc.transactionCountByEndpoint {
+		counts[endpointName] = count
+	}
+	return counts
+}
+
 // GetMaxMemSizeInBytes gets the maximum memory usage for storing transactions
 func (tc *TransactionRetryQueue) GetMaxMemSizeInBytes() int {
 	tc.mutex.RLock()
@@ -214,9 +234,18 @@ func (tc *TransactionRetryQueue) extractTransactionsFromMemory(payloadSizeInByte
 		transaction := tc.transactions[i]
 		sizeInBytesExtracted += transaction.GetPayloadSize()
 		transactionsExtracted = append(transactionsExtracted, transaction)
+		tc.removeFromTransactionCount(transaction.GetEndpointName())
 	}
 
 	tc.transactions = tc.transactions[i:]
 	tc.currentMemSizeInBytes -= sizeInBytesExtracted
 	return transactionsExtracted
 }
+
+func (tc *TransactionRetryQueue) removeFromTransactionCount(endpointName string) {
+	if tc.transactionCountByEndpoint[endpointName] <= 1 {
+		delete(tc.transactionCountByEndpoint, endpointName)
+	} else {
+		tc.transactionCountByEndpoint[endpointName]--
+	}
+}
@@ -18,14 +18,15 @@ import (
 var (
 	transactionsIntakeOrchestrator = map[orchestrator.NodeType]*expvar.Int{}
 
-	highPriorityQueueFull            = expvar.Int{}
-	transactionsInputBytesByEndpoint = expvar.Map{}
-	transactionsInputCountByEndpoint = expvar.Map{}
-	transactionsRequeued             = expvar.Int{}
-	transactionsRequeuedByEndpoint   = expvar.Map{}
-	transactionsRetried              = expvar.Int{}
-	transactionsRetriedByEndpoint    = expvar.Map{}
-	transactionsRetryQueueSize       = expvar.Int{}
+	highPriorityQueueFull              = expvar.Int{}
+	transactionsInputBytesByEndpoint   = expvar.Map{}
+	transactionsInputCountByEndpoint   = expvar.Map{}
+	transactionsRequeued               = expvar.Int{}
+	transactionsRequeuedByEndpoint     = expvar.Map{}
+	transactionsRetried                = expvar.Int{}
+	transactionsRetriedByEndpoint      = expvar.Map{}
+	transactionsRetryQueueSize         = expvar.Int{}
+	transactionsRetryQueueSizeByDomain = expvar.Map{}
 
 	tlmTxInputBytes = telemetry.NewCounter("transactions", "input_bytes",
 		[]string{"domain", "endpoint"}, "Incoming transaction sizes in bytes")
@@ -95,6 +96,7 @@ func initTransactionsExpvars() {
 	transactionsInputCountByEndpoint.Init()
 	transactionsRequeuedByEndpoint.Init()
 	transactionsRetriedByEndpoint.Init()
+	transactionsRetryQueueSizeByDomain.Init()
 	transaction.TransactionsExpvars.Set("InputCountByEndpoint", &transactionsInputCountByEndpoint)
 	transaction.TransactionsExpvars.Set("InputBytesByEndpoint", &transactionsInputBytesByEndpoint)
 	transaction.TransactionsExpvars.Set("HighPriorityQueueFull", &highPriorityQueueFull)
@@ -103,4 +105,5 @@ func initTransactionsExpvars() {
 	transaction.TransactionsExpvars.Set("Retried", &transactionsRetried)
 	transaction.TransactionsExpvars.Set("RetriedByEndpoint", &transactionsRetriedByEndpoint)
 	transaction.TransactionsExpvars.Set("RetryQueueSize", &transactionsRetryQueueSize)
+	transaction.TransactionsExpvars.Set("RetryQueueSizeByDomain", &transactionsRetryQueueSizeByDomain)
 }
@@ -92,6 +92,11 @@ func (tf *MockedForwarder) SubmitV1Series(payload Payloads, extra http.Header) e
 	return tf.Called(payload, extra).Error(0)
 }
 
+// SubmitSeries updates the internal mock struct
+func (tf *MockedForwarder) SubmitSeries(payload Payloads, extra http.Header) error {
+	return tf.Called(payload, extra).Error(0)
+}
+
 // SubmitV1Intake updates the internal mock struct
 func (tf *MockedForwarder) SubmitV1Intake(payload Payloads, extra http.Header) error {
 	return tf.Called(payload, extra).Error(0)
@@ -10,11 +10,14 @@ import "sort"
 // SortByCreatedTimeAndPriority sorts transactions by creation time and priority
 type SortByCreatedTimeAndPriority struct {
 	HighPriorityFirst bool
+	// EndpointPriorities are the priorities of the endpoints, compared before the priority
+	// of the transactions. The endpoints not listed have the priority 0.
+	EndpointPriorities map[string]int
 }
 
 // Sort sorts transactions by creation time and priority
 func (s SortByCreatedTimeAndPriority) Sort(transactions []Transaction) {
-	sorter := byCreatedTimeAndPriority(transactions)
+	sorter := byCreatedTimeAndPriority{transactions: transactions, endpointPriorities: s.EndpointPriorities}
 	if s.HighPriorityFirst {
 		sort.Sort(sorter)
 	} else {
@@ -22,13 +25,25 @@ func (s SortByCreatedTimeAndPriority) Sort(transactions []Transaction) {
 	}
 }
 
-type byCreatedTimeAndPriority []Transaction
+type byCreatedTimeAndPriority struct {
+	transactions       []Transaction
+	endpointPriorities map[string]int
+}
 
-func (v byCreatedTimeAndPriority) Len() int      { return len(v) }
-func (v byCreatedTimeAndPriority) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v byCreatedTimeAndPriority) Len() int { return len(v.transactions) }
+func (v byCreatedTimeAndPriority) Swap(i, j int) {
+	v.transactions[i], v.transactions[j] = v.transactions[j], v.transactions[i]
+}
 func (v byCreatedTimeAndPriority) Less(i, j int) bool {
-	if v[i].GetPriority() != v[j].GetPriority() {
-		return v[i].GetPriority() > v[j].GetPriority()
+	ti, tj := v.transactions[i], v.transactions[j]
+	if len(v.endpointPriorities) > 0 {
+		pi, pj := v.endpointPriorities[ti.GetEndpointName()], v.endpointPriorities[tj.GetEndpointName()]
+		if pi != pj {
+			return pi > pj
+		}
+	}
+	if ti.GetPriority() != tj.GetPriority() {
+		return ti.GetPriority() > tj.GetPriority()
 	}
-	return v[i].GetCreatedAt().After(v[j].GetCreatedAt())
+	return ti.GetCreatedAt().After(tj.GetCreatedAt())
 }
@@ -0,0 +1,44 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package transaction
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortByCreatedTimeAndPriority(t *testing.T) {
+	now := time.Now()
+	newTransaction := func(endpointName string, priority Priority, age time.Duration) Transaction {
+		tr := NewHTTPTransaction()
+		tr.Endpoint = Endpoint{Name: endpointName}
+		tr.Priority = priority
+		tr.CreatedAt = now.Add(-age)
+		return tr
+	}
+	oldMetadata := newTransaction("metadata_v2", TransactionPriorityHigh, 2*time.Second)
+	newMetadata := newTransaction("metadata_v2", TransactionPriorityNormal, time.Second)
+	oldSeries := newTransaction("series_v1", TransactionPriorityNormal, 2*time.Second)
+	newSeries := newTransaction("series_v1", TransactionPriorityNormal, time.Second)
+	events := newTransaction("events_v2", TransactionPriorityHigh, time.Second)
+
+	transactions := []Transaction{oldMetadata, newMetadata, oldSeries, newSeries, events}
+	SortByCreatedTimeAndPriority{HighPriorityFirst: true}.Sort(transactions)
+	assert.Equal(t, []Transaction{events, oldMetadata, newMetadata, newSeries, oldSeries}, transactions)
+
+	sorter := SortByCreatedTimeAndPriority{
+		HighPriorityFirst:  true,
+		EndpointPriorities: map[string]int{"series_v1": 2, "metadata_v2": 1},
+	}
+	sorter.Sort(transactions)
+	assert.Equal(t, []Transaction{newSeries, oldSeries, oldMetadata, newMetadata, events}, transactions)
+
+	sorter.HighPriorityFirst = false
+	sorter.Sort(transactions)
+	assert.Equal(t, []Transaction{events, newMetadata, oldMetadata, oldSeries, newSeries}, transactions)
+}
@@ -56,7 +56,8 @@ func NewAgent(sources *config.LogSources, services *service.Services, processing
 	// We pass the health handle to the auditor because it's the end of the pipeline and the most
 	// critical part. Arguably it could also be plugged to the destination.
 	auditorTTL := time.Duration(coreConfig.Datadog.GetInt("logs_config.auditor_ttl")) * time.Hour
-	auditor := auditor.New(coreConfig.Datadog.GetString("logs_config.run_path"), auditor.DefaultRegistryFilename, auditorTTL, health)
+	auditorStorage := auditor.NewStorage(coreConfig.Datadog.GetString("logs_config.auditor_storage"), coreConfig.Datadog.GetString("logs_config.run_path"), auditor.DefaultRegistryFilename)
+	auditor := auditor.NewWithStorage(auditorStorage, auditorTTL, health)
 	destinationsCtx := client.NewDestinationsContext()
 	diagnosticMessageReceiver := diagnostic.NewBufferedMessageReceiver()
 
@@ -6,9 +6,6 @@
 package auditor
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sync"
@@ -64,18 +61,24 @@ type RegistryAuditor struct {
 	chansMutex    sync.Mutex
 	inputChan     chan *message.Message
 	registry      map[string]*RegistryEntry
-	registryPath  string
+	changes       map[string]struct{} // identifiers updated or removed since the last flush
+	storage       Storage
 	registryMutex sync.Mutex
 	entryTTL      time.Duration
 	done          chan struct{}
 }
 
-// New returns an initialized Auditor
+// New returns an initialized Auditor storing the registry in a JSON file
 func New(runPath string, filename string, ttl time.Duration, health *health.Handle) *RegistryAuditor {
+	return NewWithStorage(NewJSONStorage(filepath.Join(runPath, filename)), ttl, health)
+}
+
+// NewWithStorage returns an initialized Auditor persisting the registry with storage
+func NewWithStorage(storage Storage, ttl time.Duration, health *health.Handle) *RegistryAuditor {
 	return &RegistryAuditor{
-		health:       health,
-		registryPath: filepath.Join(runPath, filename),
-		entryTTL:     ttl,
+		health:   health,
+		storage:  storage,
+		entryTTL: ttl,
 	}
 }
 
@@ -94,6 +97,9 @@ func (a *RegistryAuditor) Stop() {
 	if err := a.flushRegistry(); err != nil {
 		log.Warn(err)
 	}
+	if err := a.storage.Close(); err != nil {
+		log.Warn(err)
+	}
 }
 
 func (a *RegistryAuditor) createChannels() {
@@ -186,18 +192,9 @@ func (a *RegistryAuditor) run() {
 	}
 }
 
-// recoverRegistry rebuilds the registry from the state file found at path
+// recoverRegistry rebuilds the registry from the storage
 func (a *RegistryAuditor) recoverRegistry() map[string]*RegistryEntry {
-	mr, err := ioutil.ReadFile(a.registryPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			log.Debugf("Could not find state file at %q, will start with default offsets", a.registryPath)
-		} else {
-			log.Error(err)
-		}
-		return make(map[string]*RegistryEntry)
-	}
-	r, err := a.unmarshalRegistry(mr)
+	r, err := a.storage.Load()
 	if err != nil {
 		log.Error(err)
 		return make(map[string]*RegistryEntry)
@@ -213,6 +210,7 @@ func (a *RegistryAuditor) cleanupRegistry() {
 	for path, entry := range a.registry {
 		if entry.LastUpdated.Before(expireBefore) {
 			delete(a.registry, path)
+			a.addChange(path)
 		}
 	}
 }
@@ -232,6 +230,16 @@ func (a *RegistryAuditor) updateRegistry(identifier string, offset string, taili
 		Offset:      offset,
 		TailingMode: tailingMode,
 	}
+	a.addChange(identifier)
+}
+
+// addChange records that the entry matching identifier needs to be persisted,
+// the registry mutex must be held.
+func (a *RegistryAuditor) addChange(identifier string) {
+	if a.changes == nil {
+		a.changes = make(map[string]struct{})
+	}
+	a.changes[identifier] = struct{}{}
 }
 
 // readOnlyRegistryCopy returns a read only copy of the registry
@@ -245,45 +253,30 @@ func (a *RegistryAuditor) readOnlyRegistryCopy() map[string]RegistryEntry {
 	return r
 }
 
-// flushRegistry writes on disk the registry at the given path
-func (a *RegistryAuditor) flushRegistry() error {
-	r := a.readOnlyRegistryCopy()
-	mr, err := a.marshalRegistry(r)
-	if err != nil {
-		return err
-	}
-	return ioutil.WriteFile(a.registryPath, mr, 0644)
-}
-
-// marshalRegistry marshals a registry
-func (a *RegistryAuditor) marshalRegistry(registry map[string]RegistryEntry) ([]byte, error) {
-	r := JSONRegistry{
-		Version:  registryAPIVersion,
-		Registry: registry,
+// registryChanges returns a read only copy of the registry and the changes since the last call
+func (a *RegistryAuditor) registryChanges() (map[string]RegistryEntry, map[string]struct{}) {
+	a.registryMutex.Lock()
+	defer a.registryMutex.Unlock()
+	r := make(map[string]RegistryEntry)
+	for path, entry := range a.registry {
+		r[path] = *entry
 	}
-	return json.Marshal(r)
+	changes := a.changes
+	a.changes = nil
+	return r, changes
 }
 
-// unmarshalRegistry unmarshals a registry
-func (a *RegistryAuditor) unmarshalRegistry(b []byte) (map[string]*RegistryEntry, error) {
-	var r map[string]interface{}
-	err := json.Unmarshal(b, &r)
-	if err != nil {
-		return nil, err
-	}
-	version, exists := r["Version"].(float64)
-	if !exists {
-		return nil, fmt.Errorf("registry retrieved from disk must have a version number")
-	}
-	// ensure backward compatibility
-	switch int(version) {
-	case 2:
-		return unmarshalRegistryV2(b)
-	case 1:
-		return unmarshalRegistryV1(b)
-	case 0:
-		return unmarshalRegistryV0(b)
-	default:
-		return nil, fmt.Errorf("invalid registry version number")
+// flushRegistry persists the registry with the storage
+func (a *RegistryAuditor) flushRegistry() error {
+	r, changes := a.registryChanges()
+	if err := a.storage.Commit(r, changes); err != nil {
+		// keep track of the changes to persist them on the next flush
+		a.registryMutex.Lock()
+		for identifier := range changes {
+			a.addChange(identifier)
+		}
+		a.registryMutex.Unlock()
+		return err
 	}
+	return nil
 }
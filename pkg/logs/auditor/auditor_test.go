@@ -42,8 +42,7 @@ func (suite *AuditorTestSuite) SetupTest() {
 	_, err = os.Create(suite.testPath)
 	suite.Nil(err)
 
-	suite.a = New("", DefaultRegistryFilename, time.Hour, health.RegisterLiveness("fake"))
-	suite.a.registryPath = suite.testPath
+	suite.a = NewWithStorage(NewJSONStorage(suite.testPath), time.Hour, health.RegisterLiveness("fake"))
 	suite.source = config.NewLogSource("", &config.LogsConfig{Path: testpath})
 }
 
@@ -123,6 +122,20 @@ func (suite *AuditorTestSuite) TestAuditorCleansupRegistry() {
 	suite.Equal("43", suite.a.registry[otherpath].Offset)
 }
 
+func (suite *AuditorTestSuite) TestAuditorTracksRegistryChanges() {
+	suite.a.registry = make(map[string]*RegistryEntry)
+	suite.a.updateRegistry(suite.source.Config.Path, "42", "end")
+	suite.a.updateRegistry("", "43", "end")
+	suite.Equal(map[string]struct{}{suite.source.Config.Path: {}}, suite.a.changes)
+
+	suite.Nil(suite.a.flushRegistry())
+	suite.Nil(suite.a.changes)
+
+	suite.a.registry[suite.source.Config.Path].LastUpdated = time.Date(2006, time.January, 12, 1, 1, 1, 1, time.UTC)
+	suite.a.cleanupRegistry()
+	suite.Equal(map[string]struct{}{suite.source.Config.Path: {}}, suite.a.changes)
+}
+
 func TestScannerTestSuite(t *testing.T) {
 	suite.Run(t, new(AuditorTestSuite))
 }
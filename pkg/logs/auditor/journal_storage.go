@@ -0,0 +1,247 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package auditor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// journalSuffix is appended to the snapshot path to get the path of the journal.
+const journalSuffix = ".journal"
+
+// minCompactionRecords is the minimum number of records in the journal to trigger a compaction.
+const minCompactionRecords = 1000
+
+// journalRecord is a line of the journal, a record without entry removes the identifier from the registry.
+type journalRecord struct {
+	Identifier string
+	Entry      *RegistryEntry `json:",omitempty"`
+}
+
+// JournalStorage persists the registry in a snapshot file and an append-only journal.
+// Each commit only appends the changed entries to the journal and fsyncs it,
+// the journal is compacted into a new snapshot once it gets bigger than the registry.
+// The snapshot uses the same format as the JSON storage so both storages can be swapped.
+type JournalStorage struct {
+	snapshotPath string
+	journalPath  string
+	journal      *os.File
+	// records is the number of records appended to the journal since the last compaction
+	records int
+}
+
+// NewJournalStorage returns a new JournalStorage using path for the snapshot.
+func NewJournalStorage(path string) *JournalStorage {
+	return &JournalStorage{
+		snapshotPath: path,
+		journalPath:  path + journalSuffix,
+	}
+}
+
+// Load rebuilds the registry from the snapshot and replays the journal,
+// a record partially written before a crash is dropped.
+func (s *JournalStorage) Load() (map[string]*RegistryEntry, error) {
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+	registry, err := readRegistry(s.snapshotPath)
+	if err != nil {
+		log.Warnf("Could not read the registry snapshot at %q: %v", s.snapshotPath, err)
+		registry = make(map[string]*RegistryEntry)
+	}
+	content, err := ioutil.ReadFile(s.journalPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	size, records := replayJournal(content, registry)
+	if size < len(content) {
+		log.Warnf("Dropped %d bytes of incomplete records from the registry journal %q", len(content)-size, s.journalPath)
+	}
+	journal, err := os.OpenFile(s.journalPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	// drop the incomplete records so that new records are not appended to them
+	if err := journal.Truncate(int64(size)); err != nil {
+		journal.Close()
+		return nil, err
+	}
+	if _, err := journal.Seek(int64(size), 0); err != nil {
+		journal.Close()
+		return nil, err
+	}
+	s.journal = journal
+	s.records = records
+	return registry, nil
+}
+
+// Commit appends the changed entries to the journal and compacts it when needed.
+func (s *JournalStorage) Commit(registry map[string]RegistryEntry, changes map[string]struct{}) error {
+	if s.journal == nil {
+		return fmt.Errorf("registry journal %q is not open", s.journalPath)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	var buffer bytes.Buffer
+	for identifier := range changes {
+		record := journalRecord{Identifier: identifier}
+		if entry, exists := registry[identifier]; exists {
+			record.Entry = &entry
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buffer.Write(line)
+		buffer.WriteByte('\n')
+	}
+	if _, err := s.journal.Write(buffer.Bytes()); err != nil {
+		return err
+	}
+	if err := s.journal.Sync(); err != nil {
+		return err
+	}
+	s.records += len(changes)
+	if s.records >= minCompactionRecords && s.records > 2*len(registry) {
+		return s.compact(registry)
+	}
+	return nil
+}
+
+// Close compacts the journal into the snapshot and closes the journal.
+func (s *JournalStorage) Close() error {
+	if s.journal == nil {
+		return nil
+	}
+	defer func() {
+		s.journal.Close()
+		s.journal = nil
+	}()
+	if s.records == 0 {
+		return nil
+	}
+	registry, err := s.loadJournaled()
+	if err != nil {
+		return err
+	}
+	return s.compact(registry)
+}
+
+// loadJournaled returns the registry persisted in the snapshot and the journal.
+func (s *JournalStorage) loadJournaled() (map[string]RegistryEntry, error) {
+	snapshot, err := readRegistry(s.snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(s.journalPath)
+	if err != nil {
+		return nil, err
+	}
+	replayJournal(content, snapshot)
+	registry := make(map[string]RegistryEntry, len(snapshot))
+	for identifier, entry := range snapshot {
+		registry[identifier] = *entry
+	}
+	return registry, nil
+}
+
+// compact atomically writes the registry in a new snapshot and truncates the journal.
+// If the agent crashes in between, replaying the journal on the new snapshot
+// leads to the same registry.
+func (s *JournalStorage) compact(registry map[string]RegistryEntry) error {
+	mr, err := marshalRegistry(registry)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomically(s.snapshotPath, mr, 0644); err != nil {
+		return err
+	}
+	if err := s.journal.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.journal.Seek(0, 0); err != nil {
+		return err
+	}
+	s.records = 0
+	return s.journal.Sync()
+}
+
+// replayJournal applies the records of the journal to the registry,
+// returns the size of the valid content and the number of records applied.
+func replayJournal(content []byte, registry map[string]*RegistryEntry) (int, int) {
+	size, records := 0, 0
+	for {
+		end := bytes.IndexByte(content[size:], '\n')
+		if end < 0 {
+			// the last record has not been fully written
+			return size, records
+		}
+		var record journalRecord
+		if err := json.Unmarshal(content[size:size+end], &record); err != nil {
+			return size, records
+		}
+		if record.Entry != nil {
+			registry[record.Identifier] = record.Entry
+		} else {
+			delete(registry, record.Identifier)
+		}
+		size += end + 1
+		records++
+	}
+}
+
+// writeFileAtomically writes data to a temporary file synced on disk before renaming it to path,
+// readers either get the previous content or the new one.
+func writeFileAtomically(path string, data []byte, perm os.FileMode) error {
+	dir, filename := filepath.Split(path)
+	tmp, err := ioutil.TempFile(dir, filename+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes a rename in dir durable, this is a best effort as
+// directories can't be synced on every platform.
+func syncDir(dir string) {
+	if dir == "" {
+		dir = "."
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync() //nolint:errcheck
+	d.Close()
+}
@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package auditor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJournalStorage(t *testing.T) (*JournalStorage, string) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, DefaultRegistryFilename)
+	return NewJournalStorage(path), path
+}
+
+func TestJournalStorageCommitAndLoad(t *testing.T) {
+	storage, _ := newTestJournalStorage(t)
+	registry, err := storage.Load()
+	require.NoError(t, err)
+	assert.Empty(t, registry)
+
+	now := time.Now().UTC()
+	entries := map[string]RegistryEntry{
+		"foo": {LastUpdated: now, Offset: "1", TailingMode: "end"},
+		"bar": {LastUpdated: now, Offset: "2", TailingMode: "beginning"},
+	}
+	require.NoError(t, storage.Commit(entries, map[string]struct{}{"foo": {}, "bar": {}}))
+
+	entries["foo"] = RegistryEntry{LastUpdated: now, Offset: "3", TailingMode: "end"}
+	delete(entries, "bar")
+	require.NoError(t, storage.Commit(entries, map[string]struct{}{"foo": {}, "bar": {}}))
+
+	// load without closing the storage as it happens after a crash
+	registry, err = NewJournalStorage(storage.snapshotPath).Load()
+	require.NoError(t, err)
+	assert.Len(t, registry, 1)
+	assert.Equal(t, "3", registry["foo"].Offset)
+}
+
+func TestJournalStorageDropsIncompleteRecords(t *testing.T) {
+	storage, _ := newTestJournalStorage(t)
+	_, err := storage.Load()
+	require.NoError(t, err)
+	entries := map[string]RegistryEntry{"foo": {Offset: "1"}}
+	require.NoError(t, storage.Commit(entries, map[string]struct{}{"foo": {}}))
+
+	// simulate a crash in the middle of a write
+	_, err = storage.journal.WriteString(`{"Identifier":"foo","Entry":{"Off`)
+	require.NoError(t, err)
+
+	storage = NewJournalStorage(storage.snapshotPath)
+	registry, err := storage.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "1", registry["foo"].Offset)
+
+	// new records must not be appended to the incomplete one
+	entries["foo"] = RegistryEntry{Offset: "2"}
+	require.NoError(t, storage.Commit(entries, map[string]struct{}{"foo": {}}))
+	registry, err = NewJournalStorage(storage.snapshotPath).Load()
+	require.NoError(t, err)
+	assert.Equal(t, "2", registry["foo"].Offset)
+}
+
+func TestJournalStorageCompactsJournal(t *testing.T) {
+	storage, path := newTestJournalStorage(t)
+	_, err := storage.Load()
+	require.NoError(t, err)
+
+	entries := map[string]RegistryEntry{"foo": {Offset: "0"}}
+	for i := 1; i <= minCompactionRecords; i++ {
+		entries["foo"] = RegistryEntry{Offset: fmt.Sprintf("%d", i)}
+		require.NoError(t, storage.Commit(entries, map[string]struct{}{"foo": {}}))
+	}
+	assert.Equal(t, 0, storage.records)
+
+	info, err := os.Stat(storage.journalPath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+
+	// the snapshot can be read by the JSON storage
+	registry, err := NewJSONStorage(path).Load()
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%d", minCompactionRecords), registry["foo"].Offset)
+}
+
+func TestJournalStorageCompactsOnClose(t *testing.T) {
+	storage, path := newTestJournalStorage(t)
+	_, err := storage.Load()
+	require.NoError(t, err)
+
+	entries := map[string]RegistryEntry{"foo": {Offset: "1"}, "bar": {Offset: "2"}}
+	require.NoError(t, storage.Commit(entries, map[string]struct{}{"foo": {}, "bar": {}}))
+	require.NoError(t, storage.Close())
+
+	registry, err := NewJSONStorage(path).Load()
+	require.NoError(t, err)
+	assert.Len(t, registry, 2)
+	assert.Equal(t, "1", registry["foo"].Offset)
+	assert.Equal(t, "2", registry["bar"].Offset)
+}
+
+func TestJournalStorageLoadsJSONRegistry(t *testing.T) {
+	storage, path := newTestJournalStorage(t)
+	require.NoError(t, NewJSONStorage(path).Commit(map[string]RegistryEntry{"foo": {Offset: "42"}}, nil))
+
+	registry, err := storage.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "42", registry["foo"].Offset)
+}
@@ -0,0 +1,126 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package auditor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Storage types supported by the auditor.
+const (
+	JSONStorageType    = "json"
+	JournalStorageType = "journal"
+)
+
+// Storage persists the registry of the auditor.
+type Storage interface {
+	// Load returns the registry persisted on disk.
+	Load() (map[string]*RegistryEntry, error)
+	// Commit persists the registry, changes holds the identifiers
+	// that have been updated or removed since the last successful commit.
+	Commit(registry map[string]RegistryEntry, changes map[string]struct{}) error
+	// Close releases the resources held by the storage.
+	Close() error
+}
+
+// NewStorage returns the storage matching storageType persisting the registry
+// in the file at runPath/filename, falls back on the JSON storage for unknown types.
+func NewStorage(storageType string, runPath string, filename string) Storage {
+	path := filepath.Join(runPath, filename)
+	switch storageType {
+	case JSONStorageType, "":
+		return NewJSONStorage(path)
+	case JournalStorageType:
+		return NewJournalStorage(path)
+	default:
+		log.Warnf("Unknown auditor storage %q, the registry will be stored in a JSON file", storageType)
+		return NewJSONStorage(path)
+	}
+}
+
+// JSONStorage rewrites the whole registry in a JSON file on each commit.
+type JSONStorage struct {
+	path string
+}
+
+// NewJSONStorage returns a new JSONStorage writing the registry at path.
+func NewJSONStorage(path string) *JSONStorage {
+	return &JSONStorage{
+		path: path,
+	}
+}
+
+// Load rebuilds the registry from the JSON file.
+func (s *JSONStorage) Load() (map[string]*RegistryEntry, error) {
+	return readRegistry(s.path)
+}
+
+// Commit writes the whole registry in the JSON file.
+func (s *JSONStorage) Commit(registry map[string]RegistryEntry, changes map[string]struct{}) error {
+	mr, err := marshalRegistry(registry)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, mr, 0644)
+}
+
+// Close does nothing.
+func (s *JSONStorage) Close() error {
+	return nil
+}
+
+// readRegistry rebuilds the registry from the JSON file found at path,
+// returns an empty registry if the file does not exist.
+func readRegistry(path string) (map[string]*RegistryEntry, error) {
+	mr, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Debugf("Could not find state file at %q, will start with default offsets", path)
+			return make(map[string]*RegistryEntry), nil
+		}
+		return nil, err
+	}
+	return unmarshalRegistry(mr)
+}
+
+// marshalRegistry marshals a registry
+func marshalRegistry(registry map[string]RegistryEntry) ([]byte, error) {
+	r := JSONRegistry{
+		Version:  registryAPIVersion,
+		Registry: registry,
+	}
+	return json.Marshal(r)
+}
+
+// unmarshalRegistry unmarshals a registry
+func unmarshalRegistry(b []byte) (map[string]*RegistryEntry, error) {
+	var r map[string]interface{}
+	err := json.Unmarshal(b, &r)
+	if err != nil {
+		return nil, err
+	}
+	version, exists := r["Version"].(float64)
+	if !exists {
+		return nil, fmt.Errorf("registry retrieved from disk must have a version number")
+	}
+	// ensure backward compatibility
+	switch int(version) {
+	case 2:
+		return unmarshalRegistryV2(b)
+	case 1:
+		return unmarshalRegistryV1(b)
+	case 0:
+		return unmarshalRegistryV0(b)
+	default:
+		return nil, fmt.Errorf("invalid registry version number")
+	}
+}
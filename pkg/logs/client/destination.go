@@ -5,8 +5,17 @@
 
 package client
 
+import "github.com/DataDog/datadog-agent/pkg/logs/message"
+
 // Destination sends a payload to a specific endpoint over a given network protocol.
 type Destination interface {
 	Send(payload []byte) error
 	SendAsync(payload []byte)
 }
+
+// MessagesDestination sends the messages of the payloads, encoded in its own format,
+// to a specific endpoint.
+type MessagesDestination interface {
+	SendMessages(messages []*message.Message) error
+	SendMessagesAsync(messages []*message.Message)
+}
@@ -9,6 +9,9 @@ package client
 type Destinations struct {
 	Main        Destination
 	Additionals []Destination
+	// MessagesAdditionals are additional destinations encoding the messages themselves,
+	// on a best effort basis: their payloads are not retried and not acknowledged.
+	MessagesAdditionals []MessagesDestination
 }
 
 // NewDestinations returns a new destinations composite.
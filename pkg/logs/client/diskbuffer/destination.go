@@ -0,0 +1,216 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package diskbuffer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	coreConfig "github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/backoff"
+	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var (
+	tlmStored      = telemetry.NewCounter("logs_disk_buffer", "stored", []string{"path"}, "Payloads stored on disk")
+	tlmDropped     = telemetry.NewCounter("logs_disk_buffer", "dropped", []string{"path"}, "Payloads dropped because the disk buffer is full")
+	tlmSizeInBytes = telemetry.NewGauge("logs_disk_buffer", "size_in_bytes", []string{"path"}, "Disk space used by the disk buffer")
+)
+
+// Destination wraps a destination to store on disk the payloads that can't be sent
+// because of a retryable error, instead of retrying them forever in memory.
+// The stored payloads are sent in the background, in order, once the destination recovers,
+// new payloads are stored as well until then to preserve the order.
+type Destination struct {
+	destination         client.Destination
+	queue               *diskQueue
+	path                string
+	destinationsContext *client.DestinationsContext
+	once                sync.Once
+	payloadChan         chan []byte
+	notify              chan struct{}
+	backoff             backoff.Policy
+	nbStoreErrors       int
+}
+
+// NewDestination returns a new Destination storing payloads in path,
+// using at most maxSizeInBytes and leaving at least 1-maxDiskRatio of the disk free.
+func NewDestination(destination client.Destination, path string, maxSizeInBytes int64, maxDiskRatio float64, dropPolicy string, destinationsContext *client.DestinationsContext) (*Destination, error) {
+	return newDestination(destination, path, maxSizeInBytes, maxDiskRatio, dropPolicy, filesystem.NewDisk(), destinationsContext)
+}
+
+func newDestination(destination client.Destination, path string, maxSizeInBytes int64, maxDiskRatio float64, dropPolicy string, disk diskUsageRetriever, destinationsContext *client.DestinationsContext) (*Destination, error) {
+	if dropPolicy != DropOldest && dropPolicy != DropNewest {
+		log.Warnf("Unknown drop policy %q for the logs buffer, using %s", dropPolicy, DropOldest)
+		dropPolicy = DropOldest
+	}
+	queue, err := newDiskQueue(path, maxSizeInBytes, maxDiskRatio, dropPolicy, disk)
+	if err != nil {
+		return nil, err
+	}
+	if count := queue.len(); count > 0 {
+		log.Infof("Reloaded %d payloads from the logs buffer %s", count, path)
+	}
+	return &Destination{
+		destination:         destination,
+		queue:               queue,
+		path:                path,
+		destinationsContext: destinationsContext,
+		notify:              make(chan struct{}, 1),
+		backoff: backoff.NewPolicy(
+			coreConfig.DefaultLogsSenderBackoffFactor,
+			coreConfig.DefaultLogsSenderBackoffBase,
+			coreConfig.DefaultLogsSenderBackoffMax,
+			coreConfig.DefaultLogsSenderBackoffRecoveryInterval,
+			false,
+		),
+	}, nil
+}
+
+// Send sends the payload, or stores it on disk if the destination is not available.
+// The error returned is only retryable when the payload could not be stored, a payload
+// which does not fit in the buffer is dropped.
+func (d *Destination) Send(payload []byte) error {
+	d.start()
+	if d.queue.len() > 0 {
+		// older payloads are waiting to be sent
+		return d.store(payload, nil)
+	}
+	err := d.destination.Send(payload)
+	if _, ok := err.(*client.RetryableError); ok {
+		if err := d.store(payload, err); err != nil {
+			// the payload will be retried by the caller
+			return err
+		}
+		metrics.DestinationErrors.Add(1)
+		metrics.TlmDestinationErrors.Inc()
+		return nil
+	}
+	return err
+}
+
+// SendAsync sends a payload in background.
+func (d *Destination) SendAsync(payload []byte) {
+	d.start()
+	d.payloadChan <- payload
+}
+
+// start starts sending in background the async payloads and the stored payloads.
+func (d *Destination) start() {
+	d.once.Do(func() {
+		ctx := d.destinationsContext.Context()
+		d.payloadChan = make(chan []byte, config.ChanSize)
+		go d.sendInBackground(ctx)
+		go d.sendStoredPayloads(ctx)
+		// send the payloads stored by a previous run
+		d.notifyStored()
+	})
+}
+
+// store writes the payload on disk. The payload is dropped if the buffer is full, and the
+// error returned is not retryable. If it can't be written because of another error, sendErr
+// is returned so that the payload is retried in memory, or a retryable error after backing off.
+func (d *Destination) store(payload []byte, sendErr error) error {
+	dropped, err := d.queue.push(payload)
+	if err == errBufferFull {
+		dropped++
+	}
+	if dropped > 0 {
+		tlmDropped.Add(float64(dropped), d.path)
+	}
+	if err == errBufferFull {
+		log.Warnf("Dropped a payload, the logs buffer %s is full", d.path)
+		return err
+	}
+	if err != nil {
+		log.Warnf("Could not store a payload in the logs buffer %s: %v", d.path, err)
+		if sendErr != nil {
+			return sendErr
+		}
+		// the caller retries right away
+		d.nbStoreErrors = d.backoff.IncError(d.nbStoreErrors)
+		select {
+		case <-time.After(d.backoff.GetBackoffDuration(d.nbStoreErrors)):
+		case <-d.destinationsContext.Context().Done():
+		}
+		return client.NewRetryableError(err)
+	}
+	d.nbStoreErrors = d.backoff.DecError(d.nbStoreErrors)
+	tlmStored.Inc(d.path)
+	tlmSizeInBytes.Set(float64(d.queue.sizeInBytes()), d.path)
+	d.notifyStored()
+	return nil
+}
+
+// notifyStored wakes up the background sender of the stored payloads.
+func (d *Destination) notifyStored() {
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+}
+
+// sendInBackground sends the async payloads.
+func (d *Destination) sendInBackground(ctx context.Context) {
+	for {
+		select {
+		case payload := <-d.payloadChan:
+			if err := d.Send(payload); err != nil {
+				log.Debugf("Could not send payload: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sendStoredPayloads sends the stored payloads in order, retrying forever with an
+// exponential backoff unless the error is not retryable. Payloads left when the context
+// is cancelled are sent by the next run.
+func (d *Destination) sendStoredPayloads(ctx context.Context) {
+	nbErrors := 0
+	for {
+		select {
+		case <-d.notify:
+		case <-ctx.Done():
+			return
+		}
+		for d.queue.len() > 0 {
+			payload, err := d.queue.peek()
+			if err != nil {
+				log.Warnf("Could not read a payload from the logs buffer %s: %v", d.path, err)
+				continue
+			}
+			err = d.destination.Send(payload)
+			if ctx.Err() != nil {
+				return
+			}
+			if _, ok := err.(*client.RetryableError); ok {
+				metrics.DestinationErrors.Add(1)
+				metrics.TlmDestinationErrors.Inc()
+				nbErrors = d.backoff.IncError(nbErrors)
+				select {
+				case <-time.After(d.backoff.GetBackoffDuration(nbErrors)):
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			nbErrors = d.backoff.DecError(nbErrors)
+			if err != nil {
+				log.Warnf("Could not send a payload from the logs buffer %s: %v", d.path, err)
+			}
+			d.queue.pop()
+			tlmSizeInBytes.Set(float64(d.queue.sizeInBytes()), d.path)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package diskbuffer
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/util/backoff"
+)
+
+// destinationMock fails with a retryable error while it is down.
+type destinationMock struct {
+	mu       sync.Mutex
+	down     bool
+	attempts int
+	payloads []string
+}
+
+func (d *destinationMock) Send(payload []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.attempts++
+	if d.down {
+		// avoid spinning while retrying
+		time.Sleep(time.Millisecond)
+		return client.NewRetryableError(errors.New("unavailable"))
+	}
+	d.payloads = append(d.payloads, string(payload))
+	return nil
+}
+
+func (d *destinationMock) SendAsync(payload []byte) {
+	d.Send(payload) //nolint:errcheck
+}
+
+func (d *destinationMock) setDown(down bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.down = down
+}
+
+func (d *destinationMock) sendAttempts() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.attempts
+}
+
+func (d *destinationMock) sent() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.payloads...)
+}
+
+func newTestDestination(t *testing.T, inner client.Destination, path string) (*Destination, *client.DestinationsContext) {
+	ctx := client.NewDestinationsContext()
+	ctx.Start()
+	t.Cleanup(ctx.Stop)
+	destination, err := newDestination(inner, path, 100, 1, DropOldest, newTestDisk(10000), ctx)
+	require.NoError(t, err)
+	return destination, ctx
+}
+
+func TestDestinationSendsWhenAvailable(t *testing.T) {
+	inner := &destinationMock{}
+	destination, _ := newTestDestination(t, inner, t.TempDir())
+
+	assert.NoError(t, destination.Send([]byte("a")))
+	assert.Equal(t, []string{"a"}, inner.sent())
+	assert.Equal(t, 0, destination.queue.len())
+}
+
+func TestDestinationStoresAndSendsInOrder(t *testing.T) {
+	inner := &destinationMock{down: true}
+	destination, _ := newTestDestination(t, inner, t.TempDir())
+
+	assert.NoError(t, destination.Send([]byte("a")))
+	assert.NoError(t, destination.Send([]byte("b")))
+	assert.Empty(t, inner.sent())
+
+	inner.setDown(false)
+	assert.NoError(t, destination.Send([]byte("c")))
+	assert.Eventually(t, func() bool { return destination.queue.len() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"a", "b", "c"}, inner.sent())
+}
+
+func TestDestinationSendsPayloadsStoredByPreviousRun(t *testing.T) {
+	path := t.TempDir()
+	inner := &destinationMock{down: true}
+	destination, ctx := newTestDestination(t, inner, path)
+	assert.NoError(t, destination.Send([]byte("a")))
+	ctx.Stop()
+
+	inner = &destinationMock{}
+	destination, _ = newTestDestination(t, inner, path)
+	assert.Equal(t, 1, destination.queue.len())
+	assert.NoError(t, destination.Send([]byte("b")))
+	assert.Eventually(t, func() bool { return destination.queue.len() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"a", "b"}, inner.sent())
+}
+
+func TestDestinationDropsPayloadsWhenFull(t *testing.T) {
+	inner := &destinationMock{down: true}
+	ctx := client.NewDestinationsContext()
+	ctx.Start()
+	defer ctx.Stop()
+	destination, err := newDestination(inner, t.TempDir(), 2, 1, DropNewest, newTestDisk(10000), ctx)
+	require.NoError(t, err)
+
+	assert.NoError(t, destination.Send([]byte("aa")))
+	// the payload is dropped instead of being retried by the caller
+	err = destination.Send([]byte("bb"))
+	assert.Equal(t, errBufferFull, err)
+	assert.Equal(t, 1, destination.queue.len())
+}
+
+func TestDestinationBacksOffWhenSendingStoredPayloads(t *testing.T) {
+	inner := &destinationMock{down: true}
+	destination, _ := newTestDestination(t, inner, t.TempDir())
+	destination.backoff = backoff.NewPolicy(2, 0.05, 0.1, 2, false)
+
+	assert.NoError(t, destination.Send([]byte("a")))
+	time.Sleep(20 * time.Millisecond)
+	// one attempt by Send and one by the background sender, which then waits
+	assert.LessOrEqual(t, inner.sendAttempts(), 2)
+
+	inner.setDown(false)
+	assert.Eventually(t, func() bool { return destination.queue.len() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"a"}, inner.sent())
+}
@@ -0,0 +1,245 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package diskbuffer
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Drop policies applied when the buffer is full.
+const (
+	// DropOldest removes the oldest payloads to make room for the new ones.
+	DropOldest = "drop_oldest"
+	// DropNewest does not store the new payloads, they are dropped.
+	DropNewest = "drop_newest"
+)
+
+const payloadFileExtension = ".payload"
+const tmpFileExtension = ".tmp"
+
+// errBufferFull is returned when a payload can't be stored because of the size limits.
+var errBufferFull = errors.New("disk buffer is full")
+
+type diskUsageRetriever interface {
+	GetUsage(path string) (*filesystem.DiskUsage, error)
+}
+
+// diskQueue stores payloads on disk, one file per payload, and returns them in the order they were pushed.
+// File names hold a sequence number so that the order is kept across restarts.
+type diskQueue struct {
+	path               string
+	maxSizeInBytes     int64
+	maxDiskRatio       float64
+	dropPolicy         string
+	disk               diskUsageRetriever
+	mu                 sync.Mutex
+	filenames          []string
+	currentSizeInBytes int64
+	nextSequence       uint64
+}
+
+// newDiskQueue returns a new queue storing payloads in path and reloads the payloads already there.
+func newDiskQueue(path string, maxSizeInBytes int64, maxDiskRatio float64, dropPolicy string, disk diskUsageRetriever) (*diskQueue, error) {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+	q := &diskQueue{
+		path:           path,
+		maxSizeInBytes: maxSizeInBytes,
+		maxDiskRatio:   maxDiskRatio,
+		dropPolicy:     dropPolicy,
+		disk:           disk,
+	}
+	if err := q.reloadExistingFiles(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// len returns the number of payloads in the queue.
+func (q *diskQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.filenames)
+}
+
+// sizeInBytes returns the disk space used by the queue.
+func (q *diskQueue) sizeInBytes() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.currentSizeInBytes
+}
+
+// push stores the payload on disk, returns the number of stored payloads dropped to make room for it
+// and errBufferFull if the payload does not fit in the queue.
+func (q *diskQueue) push(payload []byte) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	size := int64(len(payload))
+	dropped, err := q.makeRoomFor(size)
+	if err != nil {
+		return dropped, err
+	}
+
+	filename := filepath.Join(q.path, fmt.Sprintf("%020d%s", q.nextSequence, payloadFileExtension))
+	if err := writeFile(filename, payload); err != nil {
+		return dropped, err
+	}
+	q.nextSequence++
+	q.filenames = append(q.filenames, filename)
+	q.currentSizeInBytes += size
+	return dropped, nil
+}
+
+// peek returns the oldest payload of the queue or nil if the queue is empty,
+// a payload that can't be read is removed from the queue.
+func (q *diskQueue) peek() ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.filenames) == 0 {
+		return nil, nil
+	}
+	payload, err := ioutil.ReadFile(q.filenames[0])
+	if err != nil {
+		q.removeOldest()
+		return nil, err
+	}
+	return payload, nil
+}
+
+// pop removes the oldest payload of the queue.
+func (q *diskQueue) pop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.filenames) > 0 {
+		q.removeOldest()
+	}
+}
+
+// makeRoomFor applies the drop policy until a payload of the given size fits in the queue.
+func (q *diskQueue) makeRoomFor(size int64) (int, error) {
+	if size > q.maxSizeInBytes {
+		log.Warnf("The payload is too big for the logs buffer. Current:%v Maximum:%v", size, q.maxSizeInBytes)
+		return 0, errBufferFull
+	}
+	maxStorageInBytes, err := q.computeAvailableSpace()
+	if err != nil {
+		return 0, err
+	}
+	if q.dropPolicy == DropNewest {
+		if q.currentSizeInBytes+size > maxStorageInBytes {
+			return 0, errBufferFull
+		}
+		return 0, nil
+	}
+	dropped := 0
+	for len(q.filenames) > 0 && q.currentSizeInBytes+size > maxStorageInBytes {
+		log.Warnf("Maximum disk space for the logs buffer is reached. Removing %s", q.filenames[0])
+		q.removeOldest()
+		dropped++
+	}
+	if q.currentSizeInBytes+size > maxStorageInBytes {
+		return dropped, errBufferFull
+	}
+	return dropped, nil
+}
+
+// computeAvailableSpace returns the maximum size of the queue given the disk usage.
+func (q *diskQueue) computeAvailableSpace() (int64, error) {
+	usage, err := q.disk.GetUsage(q.path)
+	if err != nil {
+		return 0, err
+	}
+	diskReserved := float64(usage.Total) * (1 - q.maxDiskRatio)
+	availableDiskUsage := int64(usage.Available) - int64(math.Ceil(diskReserved))
+	maxStorageInBytes := q.currentSizeInBytes + availableDiskUsage
+	if q.maxSizeInBytes < maxStorageInBytes {
+		return q.maxSizeInBytes, nil
+	}
+	return maxStorageInBytes, nil
+}
+
+// removeOldest removes the oldest file of the queue, the lock must be held.
+func (q *diskQueue) removeOldest() {
+	filename := q.filenames[0]
+	q.filenames = q.filenames[1:]
+	if info, err := os.Stat(filename); err == nil {
+		q.currentSizeInBytes -= info.Size()
+	}
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Could not remove %s from the logs buffer: %v", filename, err)
+	}
+}
+
+// reloadExistingFiles adds the payloads written by a previous run to the queue
+// and removes the files that were not completely written.
+func (q *diskQueue) reloadExistingFiles() error {
+	entries, err := ioutil.ReadDir(q.path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		name := entry.Name()
+		switch filepath.Ext(name) {
+		case tmpFileExtension:
+			os.Remove(filepath.Join(q.path, name))
+		case payloadFileExtension:
+			sequence, err := strconv.ParseUint(strings.TrimSuffix(name, payloadFileExtension), 10, 64)
+			if err != nil {
+				continue
+			}
+			if sequence >= q.nextSequence {
+				q.nextSequence = sequence + 1
+			}
+			q.filenames = append(q.filenames, filepath.Join(q.path, name))
+			q.currentSizeInBytes += entry.Size()
+		}
+	}
+	// file names are zero-padded so the lexical order is the order of the sequence
+	sort.Strings(q.filenames)
+	return nil
+}
+
+// writeFile writes the payload to a temporary file synced on disk and renames it,
+// so that a crash never leaves a partially written payload in the queue.
+func writeFile(filename string, payload []byte) error {
+	tmpFilename := filename + tmpFileExtension
+	file, err := os.OpenFile(tmpFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(payload); err != nil {
+		file.Close()
+		os.Remove(tmpFilename)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpFilename)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpFilename)
+		return err
+	}
+	return os.Rename(tmpFilename, filename)
+}
@@ -0,0 +1,128 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package diskbuffer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
+)
+
+type diskUsageRetrieverMock struct {
+	diskUsage *filesystem.DiskUsage
+}
+
+func (m diskUsageRetrieverMock) GetUsage(_ string) (*filesystem.DiskUsage, error) {
+	return m.diskUsage, nil
+}
+
+func newTestDisk(available uint64) diskUsageRetrieverMock {
+	return diskUsageRetrieverMock{diskUsage: &filesystem.DiskUsage{Total: 10000, Available: available}}
+}
+
+func newTestQueue(t *testing.T, path string, maxSizeInBytes int64, dropPolicy string) *diskQueue {
+	queue, err := newDiskQueue(path, maxSizeInBytes, 1, dropPolicy, newTestDisk(10000))
+	require.NoError(t, err)
+	return queue
+}
+
+func pushPayloads(t *testing.T, queue *diskQueue, payloads ...string) {
+	for _, payload := range payloads {
+		_, err := queue.push([]byte(payload))
+		require.NoError(t, err)
+	}
+}
+
+func popPayload(t *testing.T, queue *diskQueue) string {
+	payload, err := queue.peek()
+	require.NoError(t, err)
+	queue.pop()
+	return string(payload)
+}
+
+func TestDiskQueuePushAndPop(t *testing.T) {
+	queue := newTestQueue(t, t.TempDir(), 100, DropOldest)
+	pushPayloads(t, queue, "a", "bb", "ccc")
+	assert.Equal(t, 3, queue.len())
+	assert.Equal(t, int64(6), queue.sizeInBytes())
+
+	assert.Equal(t, "a", popPayload(t, queue))
+	assert.Equal(t, "bb", popPayload(t, queue))
+	assert.Equal(t, "ccc", popPayload(t, queue))
+	assert.Equal(t, 0, queue.len())
+	assert.Equal(t, int64(0), queue.sizeInBytes())
+
+	payload, err := queue.peek()
+	assert.NoError(t, err)
+	assert.Nil(t, payload)
+}
+
+func TestDiskQueueReloadsExistingFiles(t *testing.T) {
+	path := t.TempDir()
+	queue := newTestQueue(t, path, 100, DropOldest)
+	pushPayloads(t, queue, "a", "b")
+
+	// a payload not fully written before a crash
+	require.NoError(t, ioutil.WriteFile(filepath.Join(path, "00000000000000000002.payload.tmp"), []byte("c"), 0600))
+
+	queue = newTestQueue(t, path, 100, DropOldest)
+	assert.Equal(t, 2, queue.len())
+	pushPayloads(t, queue, "d")
+	assert.Equal(t, "a", popPayload(t, queue))
+	assert.Equal(t, "b", popPayload(t, queue))
+	assert.Equal(t, "d", popPayload(t, queue))
+
+	_, err := os.Stat(filepath.Join(path, "00000000000000000002.payload.tmp"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDiskQueueDropOldest(t *testing.T) {
+	queue := newTestQueue(t, t.TempDir(), 5, DropOldest)
+	pushPayloads(t, queue, "aa", "bb")
+
+	dropped, err := queue.push([]byte("cc"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, "bb", popPayload(t, queue))
+	assert.Equal(t, "cc", popPayload(t, queue))
+}
+
+func TestDiskQueueDropNewest(t *testing.T) {
+	queue := newTestQueue(t, t.TempDir(), 5, DropNewest)
+	pushPayloads(t, queue, "aa", "bb")
+
+	dropped, err := queue.push([]byte("cc"))
+	assert.Equal(t, errBufferFull, err)
+	assert.Equal(t, 0, dropped)
+	assert.Equal(t, "aa", popPayload(t, queue))
+	assert.Equal(t, "bb", popPayload(t, queue))
+	assert.Equal(t, 0, queue.len())
+}
+
+func TestDiskQueueRespectsMaxDiskRatio(t *testing.T) {
+	// 80% of the disk can be used and 90% is already used
+	queue, err := newDiskQueue(t.TempDir(), 100, 0.8, DropOldest, newTestDisk(1000))
+	require.NoError(t, err)
+
+	_, err = queue.push([]byte("a"))
+	assert.Equal(t, errBufferFull, err)
+	assert.Equal(t, 0, queue.len())
+}
+
+func TestDiskQueueDropsTooBigPayloads(t *testing.T) {
+	queue := newTestQueue(t, t.TempDir(), 5, DropOldest)
+	pushPayloads(t, queue, "aa")
+
+	_, err := queue.push([]byte("bbbbbb"))
+	assert.Equal(t, errBufferFull, err)
+	assert.Equal(t, 1, queue.len())
+}
@@ -0,0 +1,175 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	httputils "github.com/DataDog/datadog-agent/pkg/util/http"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// ProtobufContentType is the content type of the OTLP/HTTP protobuf payloads.
+const ProtobufContentType = "application/x-protobuf"
+
+// logsPath is the default path of the OTLP/HTTP logs receivers.
+const logsPath = "/v1/logs"
+
+// OTLP errors.
+var (
+	errClient = errors.New("client error")
+	errServer = errors.New("server error")
+	tlmSend   = telemetry.NewCounter("logs_client_otlp_destination", "send", []string{"error"}, "Payloads sent")
+)
+
+// Destination sends batches of logs to an OTLP/HTTP receiver. It is meant to dual-ship logs on a
+// best effort basis, as an additional destination: the batches sent in background are not retried
+// and are not acknowledged to the auditor.
+type Destination struct {
+	url                 string
+	headers             map[string]string
+	client              *http.Client
+	destinationsContext *client.DestinationsContext
+	once                sync.Once
+	messagesChan        chan []*message.Message
+}
+
+// NewDestination returns a new Destination sending logs to the OTLP/HTTP receiver at the endpoint URL,
+// the `/v1/logs` path is used when the URL does not define any.
+func NewDestination(endpoint string, headers map[string]string, destinationsContext *client.DestinationsContext) *Destination {
+	return newDestination(endpoint, headers, destinationsContext, time.Second*10)
+}
+
+func newDestination(endpoint string, headers map[string]string, destinationsContext *client.DestinationsContext, timeout time.Duration) *Destination {
+	return &Destination{
+		url:     buildURL(endpoint),
+		headers: headers,
+		client: &http.Client{
+			Timeout: timeout,
+			// reusing core agent HTTP transport to benefit from proxy settings.
+			Transport: httputils.CreateHTTPTransport(),
+		},
+		destinationsContext: destinationsContext,
+	}
+}
+
+// SendMessages sends a batch of logs to the OTLP/HTTP receiver,
+// the error returned can be retryable and it is the responsibility of the callee to retry.
+func (d *Destination) SendMessages(messages []*message.Message) (err error) {
+	defer func() {
+		tlmSend.Inc(errorToTag(err))
+	}()
+
+	encodedPayload, err := encode(messages)
+	if err != nil {
+		// the payload will never be sent, do not retry
+		return err
+	}
+
+	ctx := d.destinationsContext.Context()
+	req, err := http.NewRequest("POST", d.url, bytes.NewReader(encodedPayload))
+	if err != nil {
+		return err
+	}
+	for key, value := range d.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", ProtobufContentType)
+	req = req.WithContext(ctx)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			return ctx.Err()
+		}
+		// most likely a network or a connect error, the callee should retry.
+		return client.NewRetryableError(err)
+	}
+
+	defer resp.Body.Close()
+	response, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		log.Warnf("failed to post OTLP payload. code=%d url=%s response=%s", resp.StatusCode, d.url, string(response))
+	}
+	switch {
+	case resp.StatusCode == 429 || resp.StatusCode == 502 || resp.StatusCode == 503 || resp.StatusCode == 504:
+		// these are the retryable status codes of the OTLP/HTTP specification
+		return client.NewRetryableError(errServer)
+	case resp.StatusCode >= 400:
+		return errClient
+	default:
+		return nil
+	}
+}
+
+// SendMessagesAsync sends a batch of logs in background, once.
+func (d *Destination) SendMessagesAsync(messages []*message.Message) {
+	d.once.Do(func() {
+		messagesChan := make(chan []*message.Message, config.ChanSize)
+		d.sendInBackground(messagesChan)
+		d.messagesChan = messagesChan
+	})
+	d.messagesChan <- messages
+}
+
+// sendInBackground sends all batches from messagesChan in background.
+func (d *Destination) sendInBackground(messagesChan chan []*message.Message) {
+	ctx := d.destinationsContext.Context()
+	go func() {
+		for {
+			select {
+			case messages := <-messagesChan:
+				if err := d.SendMessages(messages); err != nil {
+					log.Debugf("Could not send logs to the OTLP/HTTP receiver %s: %v", d.url, err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func errorToTag(err error) string {
+	if err == nil {
+		return "none"
+	} else if _, ok := err.(*client.RetryableError); ok {
+		return "retryable"
+	} else {
+		return "non-retryable"
+	}
+}
+
+// buildURL returns the URL of the endpoint, using plain HTTP when no scheme is set
+// and the default logs path when no path is set.
+func buildURL(endpoint string) string {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		// let the request fail and report the invalid URL
+		return endpoint
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = logsPath
+	}
+	return u.String()
+}
@@ -0,0 +1,161 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package otlp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// testMessages returns a batch of logs from two services.
+func testMessages() []*message.Message {
+	web := config.NewLogSource("web", &config.LogsConfig{Service: "web", Source: "nginx", Tags: []string{"env:prod"}})
+	db := config.NewLogSource("db", &config.LogsConfig{Service: "db", Source: "postgres"})
+	newMessage := func(content string, status string, source *config.LogSource, ts int64) *message.Message {
+		msg := message.NewMessageWithSource([]byte(`{"message":"`+content+`"}`), status, source, 0)
+		msg.RedactedContent = []byte(content)
+		msg.Timestamp = time.Unix(0, ts*int64(time.Millisecond))
+		return msg
+	}
+	hello := newMessage("hello", message.StatusError, web, 1600000000000)
+	hello.Attributes = map[string]interface{}{"user": "bob", "ddsource": "overridden"}
+	return []*message.Message{
+		hello,
+		newMessage("world", message.StatusInfo, web, 1600000000001),
+		newMessage("other", "unknown", db, 1600000000002),
+	}
+}
+
+type receiverTest struct {
+	server      *httptest.Server
+	destCtx     *client.DestinationsContext
+	destination *Destination
+	requests    chan *http.Request
+	logs        chan pdata.Logs
+}
+
+func newReceiverTest(t *testing.T, statusCode int) *receiverTest {
+	requests := make(chan *http.Request, 1)
+	logs := make(chan pdata.Logs, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		received, err := otlp.NewProtobufLogsUnmarshaler().UnmarshalLogs(body)
+		require.NoError(t, err)
+		w.WriteHeader(statusCode)
+		requests <- r
+		logs <- received
+	}))
+	destCtx := client.NewDestinationsContext()
+	destCtx.Start()
+	return &receiverTest{
+		server:      server,
+		destCtx:     destCtx,
+		destination: NewDestination(server.URL, map[string]string{"X-Scope-OrgID": "test"}, destCtx),
+		requests:    requests,
+		logs:        logs,
+	}
+}
+
+func (r *receiverTest) stop() {
+	r.destCtx.Stop()
+	r.server.Close()
+}
+
+func TestBuildURL(t *testing.T) {
+	assert.Equal(t, "http://localhost:4318/v1/logs", buildURL("localhost:4318"))
+	assert.Equal(t, "https://collector/v1/logs", buildURL("https://collector/"))
+	assert.Equal(t, "https://collector/custom/logs", buildURL("https://collector/custom/logs"))
+}
+
+func TestDestinationSend(t *testing.T) {
+	receiver := newReceiverTest(t, 200)
+	defer receiver.stop()
+
+	messages := testMessages()
+	assert.Nil(t, receiver.destination.SendMessages(messages))
+
+	request := <-receiver.requests
+	assert.Equal(t, "/v1/logs", request.URL.Path)
+	assert.Equal(t, ProtobufContentType, request.Header.Get("Content-Type"))
+	assert.Equal(t, "test", request.Header.Get("X-Scope-OrgID"))
+
+	logs := <-receiver.logs
+	assert.Equal(t, 3, logs.LogRecordCount())
+	require.Equal(t, 2, logs.ResourceLogs().Len())
+
+	resource := logs.ResourceLogs().At(0)
+	hostname, _ := resource.Resource().Attributes().Get(hostNameAttribute)
+	assert.Equal(t, messages[0].GetHostname(), hostname.StringVal())
+	service, _ := resource.Resource().Attributes().Get(serviceNameAttribute)
+	assert.Equal(t, "web", service.StringVal())
+
+	records := resource.InstrumentationLibraryLogs().At(0).Logs()
+	require.Equal(t, 2, records.Len())
+	record := records.At(0)
+	assert.Equal(t, "hello", record.Body().StringVal())
+	assert.Equal(t, "error", record.SeverityText())
+	assert.Equal(t, pdata.SeverityNumberERROR, record.SeverityNumber())
+	assert.Equal(t, time.Unix(1600000000, 0).UnixNano(), int64(record.Timestamp()))
+	// the attributes can not override the metadata of the message
+	source, _ := record.Attributes().Get("ddsource")
+	assert.Equal(t, "nginx", source.StringVal())
+	tags, _ := record.Attributes().Get("ddtags")
+	assert.Equal(t, "env:prod", tags.StringVal())
+	user, _ := record.Attributes().Get("user")
+	assert.Equal(t, "bob", user.StringVal())
+	_, found := records.At(1).Attributes().Get("user")
+	assert.False(t, found)
+
+	other := logs.ResourceLogs().At(1).InstrumentationLibraryLogs().At(0).Logs().At(0)
+	assert.Equal(t, "other", other.Body().StringVal())
+	assert.Equal(t, pdata.SeverityNumberINFO, other.SeverityNumber())
+}
+
+func TestDestinationSendErrors(t *testing.T) {
+	receiver := newReceiverTest(t, 503)
+	err := receiver.destination.SendMessages(testMessages())
+	assert.IsType(t, &client.RetryableError{}, err)
+	receiver.stop()
+
+	receiver = newReceiverTest(t, 400)
+	err = receiver.destination.SendMessages(testMessages())
+	assert.Equal(t, errClient, err)
+	receiver.stop()
+}
+
+func TestDestinationSendInvalidUTF8(t *testing.T) {
+	receiver := newReceiverTest(t, 200)
+	defer receiver.stop()
+
+	msg := message.NewMessage(nil, nil, message.StatusInfo, 0)
+	msg.RedactedContent = []byte("a\xffb")
+	assert.Nil(t, receiver.destination.SendMessages([]*message.Message{msg}))
+	<-receiver.requests
+	record := (<-receiver.logs).ResourceLogs().At(0).InstrumentationLibraryLogs().At(0).Logs().At(0)
+	assert.Equal(t, "a\uFFFDb", record.Body().StringVal())
+}
+
+func TestDestinationSendMessagesAsync(t *testing.T) {
+	receiver := newReceiverTest(t, 200)
+	defer receiver.stop()
+
+	receiver.destination.SendMessagesAsync(testMessages())
+	<-receiver.requests
+	assert.Equal(t, 3, (<-receiver.logs).LogRecordCount())
+}
@@ -0,0 +1,126 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package otlp
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+const (
+	instrumentationLibraryName = "datadog-agent"
+	hostNameAttribute          = "host.name"
+	serviceNameAttribute       = "service.name"
+	sourceAttribute            = "ddsource"
+	tagsAttribute              = "ddtags"
+)
+
+var logsMarshaler = otlp.NewProtobufLogsMarshaler()
+
+// statusSeverityMapping maps the log statuses to the OTLP severity numbers.
+var statusSeverityMapping = map[string]pdata.SeverityNumber{
+	message.StatusEmergency: pdata.SeverityNumberFATAL4,
+	message.StatusAlert:     pdata.SeverityNumberFATAL2,
+	message.StatusCritical:  pdata.SeverityNumberFATAL,
+	message.StatusError:     pdata.SeverityNumberERROR,
+	message.StatusWarning:   pdata.SeverityNumberWARN,
+	message.StatusNotice:    pdata.SeverityNumberINFO2,
+	message.StatusInfo:      pdata.SeverityNumberINFO,
+	message.StatusDebug:     pdata.SeverityNumberDEBUG,
+}
+
+// resourceKey identifies the resource emitting a log.
+type resourceKey struct {
+	hostname string
+	service  string
+}
+
+// encode transforms a batch of logs into an OTLP LogsData protobuf payload.
+func encode(messages []*message.Message) ([]byte, error) {
+	return logsMarshaler.MarshalLogs(toLogs(messages))
+}
+
+// toLogs transforms a batch of logs into OTLP logs grouped by host and service.
+func toLogs(messages []*message.Message) pdata.Logs {
+	logs := pdata.NewLogs()
+	records := make(map[resourceKey]pdata.LogSlice)
+	for _, msg := range messages {
+		key := resourceKey{hostname: msg.GetHostname()}
+		if msg.Origin != nil {
+			key.service = msg.Origin.Service()
+		}
+		slice, exists := records[key]
+		if !exists {
+			resourceLogs := logs.ResourceLogs().AppendEmpty()
+			attributes := resourceLogs.Resource().Attributes()
+			if key.hostname != "" {
+				attributes.InsertString(hostNameAttribute, key.hostname)
+			}
+			if key.service != "" {
+				attributes.InsertString(serviceNameAttribute, key.service)
+			}
+			libraryLogs := resourceLogs.InstrumentationLibraryLogs().AppendEmpty()
+			libraryLogs.InstrumentationLibrary().SetName(instrumentationLibraryName)
+			slice = libraryLogs.Logs()
+			records[key] = slice
+		}
+		fillRecord(slice.AppendEmpty(), msg)
+	}
+	return logs
+}
+
+// fillRecord fills the log record with the content and the metadata of the message,
+// the metadata which are not part of the OTLP log data model are added as attributes.
+func fillRecord(record pdata.LogRecord, msg *message.Message) {
+	record.Body().SetStringVal(strings.ToValidUTF8(string(msg.RedactedContent), string(unicode.ReplacementChar)))
+
+	status := msg.GetStatus()
+	record.SetSeverityText(status)
+	if severity, exists := statusSeverityMapping[status]; exists {
+		record.SetSeverityNumber(severity)
+	} else {
+		record.SetSeverityNumber(pdata.SeverityNumberINFO)
+	}
+
+	switch {
+	case !msg.Timestamp.IsZero():
+		record.SetTimestamp(pdata.NewTimestampFromTime(msg.Timestamp))
+	case msg.IngestionTimestamp != 0:
+		record.SetTimestamp(pdata.NewTimestampFromTime(time.Unix(0, msg.IngestionTimestamp)))
+	}
+
+	attributes := record.Attributes()
+	for key, value := range msg.Attributes {
+		switch v := value.(type) {
+		case string:
+			attributes.InsertString(key, v)
+		case bool:
+			attributes.InsertBool(key, v)
+		case float64:
+			attributes.InsertDouble(key, v)
+		case nil:
+			continue
+		default:
+			// nested objects and arrays are kept in their JSON representation
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				continue
+			}
+			attributes.InsertString(key, string(encoded))
+		}
+	}
+	if msg.Origin != nil {
+		attributes.UpsertString(sourceAttribute, msg.Origin.Source())
+		attributes.UpsertString(tagsAttribute, msg.Origin.TagsToString())
+	}
+}
@@ -256,7 +256,18 @@ func BuildHTTPEndpointsWithConfig(logsConfig *LogsConfigKeys, endpointPrefix str
 	batchMaxSize := logsConfig.batchMaxSize()
 	batchMaxContentSize := logsConfig.batchMaxContentSize()
 
-	return NewEndpointsWithBatchSettings(main, additionals, false, true, batchWait, batchMaxConcurrentSend, batchMaxSize, batchMaxContentSize), nil
+	endpoints := NewEndpointsWithBatchSettings(main, additionals, false, true, batchWait, batchMaxConcurrentSend, batchMaxSize, batchMaxContentSize)
+	if otlpHTTPEndpoint := logsConfig.otlpHTTPEndpoint(); otlpHTTPEndpoint != "" {
+		endpoints.OTLPHTTPEndpoint = otlpHTTPEndpoint
+		endpoints.OTLPHTTPHeaders = logsConfig.otlpHTTPHeaders()
+	}
+	if diskBufferMaxSize := logsConfig.diskBufferMaxSizeInBytes(); diskBufferMaxSize > 0 {
+		endpoints.DiskBufferPath = logsConfig.diskBufferPath()
+		endpoints.DiskBufferMaxSizeInBytes = diskBufferMaxSize
+		endpoints.DiskBufferMaxDiskRatio = logsConfig.diskBufferMaxDiskRatio()
+		endpoints.DiskBufferDropPolicy = logsConfig.diskBufferDropPolicy()
+	}
+	return endpoints, nil
 }
 
 // parseAddress returns the host and the port of the address.
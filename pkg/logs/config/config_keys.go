@@ -7,6 +7,8 @@ package config
 
 import (
 	"encoding/json"
+	"path/filepath"
+	"strings"
 	"time"
 
 	coreConfig "github.com/DataDog/datadog-agent/pkg/config"
@@ -137,6 +139,34 @@ func (l *LogsConfigKeys) getAdditionalEndpoints() []Endpoint {
 	return endpoints
 }
 
+func (l *LogsConfigKeys) otlpHTTPEndpoint() string {
+	return l.getConfig().GetString(l.getConfigKey("otlp_http_endpoint"))
+}
+
+func (l *LogsConfigKeys) otlpHTTPHeaders() map[string]string {
+	return l.getConfig().GetStringMapString(l.getConfigKey("otlp_http_headers"))
+}
+
+func (l *LogsConfigKeys) diskBufferMaxSizeInBytes() int64 {
+	return l.getConfig().GetInt64(l.getConfigKey("disk_buffer_max_size_in_bytes"))
+}
+
+func (l *LogsConfigKeys) diskBufferPath() string {
+	if path := l.getConfig().GetString(l.getConfigKey("disk_buffer_path")); path != "" {
+		return path
+	}
+	// use a directory per prefix so that the products sending logs do not share their buffers
+	return filepath.Join(l.getConfig().GetString("logs_config.run_path"), "logs_buffer", strings.TrimSuffix(l.prefix, "."))
+}
+
+func (l *LogsConfigKeys) diskBufferMaxDiskRatio() float64 {
+	return l.getConfig().GetFloat64(l.getConfigKey("disk_buffer_max_disk_ratio"))
+}
+
+func (l *LogsConfigKeys) diskBufferDropPolicy() string {
+	return l.getConfig().GetString(l.getConfigKey("disk_buffer_drop_policy"))
+}
+
 func (l *LogsConfigKeys) expectedTagsDuration() time.Duration {
 	return l.getConfig().GetDuration(l.getConfigKey("expected_tags_duration"))
 }
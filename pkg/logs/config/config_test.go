@@ -203,6 +203,17 @@ func (suite *ConfigTestSuite) TestMultipleHttpEndpointsEnvVar() {
 	suite.Equal(expectedEndpoints, endpoints)
 }
 
+func (suite *ConfigTestSuite) TestOTLPHTTPEndpoint() {
+	suite.config.Set("api_key", "123")
+	suite.config.Set("logs_config.otlp_http_endpoint", "http://collector:4318")
+	suite.config.Set("logs_config.otlp_http_headers", map[string]string{"x-scope-orgid": "test"})
+
+	endpoints, err := BuildHTTPEndpoints("test-track", "test-proto", "test-source")
+	suite.Nil(err)
+	suite.Equal("http://collector:4318", endpoints.OTLPHTTPEndpoint)
+	suite.Equal(map[string]string{"x-scope-orgid": "test"}, endpoints.OTLPHTTPHeaders)
+}
+
 func (suite *ConfigTestSuite) TestMultipleTCPEndpointsEnvVar() {
 	suite.config.Set("api_key", "123")
 	suite.config.Set("logs_config.logs_dd_url", "agent-http-intake.logs.datadoghq.com:443")
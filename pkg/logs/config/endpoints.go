@@ -64,6 +64,15 @@ type Endpoints struct {
 	BatchMaxConcurrentSend int
 	BatchMaxSize           int
 	BatchMaxContentSize    int
+	// OTLPHTTPEndpoint is the URL of an OTLP/HTTP receiver to dual-ship logs to on a best effort basis,
+	// only used over HTTP
+	OTLPHTTPEndpoint string
+	OTLPHTTPHeaders  map[string]string
+	// DiskBufferPath is the directory where payloads that can't be sent are stored, only used over HTTP
+	DiskBufferPath           string
+	DiskBufferMaxSizeInBytes int64
+	DiskBufferMaxDiskRatio   float64
+	DiskBufferDropPolicy     string
 }
 
 // NewEndpoints returns a new endpoints composite with default batching settings
@@ -21,6 +21,9 @@ const (
 	SnmpTrapsType     = "snmp_traps"
 	StringChannelType = "string_channel"
 
+	// SyslogFormat for network sources receiving syslog messages
+	SyslogFormat string = "syslog"
+
 	// UTF16BE for UTF-16 Big endian encoding
 	UTF16BE string = "utf-16-be"
 	// UTF16LE for UTF-16 Little Endian encoding
@@ -34,6 +37,7 @@ type LogsConfig struct {
 
 	Port        int    // Network
 	IdleTimeout string `mapstructure:"idle_timeout" json:"idle_timeout"` // Network
+	Format      string `mapstructure:"format" json:"format"`             // Network
 	Path        string // File, Journald
 
 	Encoding     string   `mapstructure:"encoding" json:"encoding"`             // File
@@ -64,9 +68,12 @@ type LogsConfig struct {
 	Tags            []string
 	ProcessingRules []*ProcessingRule `mapstructure:"log_processing_rules" json:"log_processing_rules"`
 
-	AutoMultiLine               bool    `mapstructure:"auto_multi_line_detection" json:"auto_multi_line_detection"`
-	AutoMultiLineSampleSize     int     `mapstructure:"auto_multi_line_sample_size" json:"auto_multi_line_sample_size"`
-	AutoMultiLineMatchThreshold float64 `mapstructure:"auto_multi_line_match_threshold" json:"auto_multi_line_match_threshold"`
+	AutoMultiLine                    bool    `mapstructure:"auto_multi_line_detection" json:"auto_multi_line_detection"`
+	AutoMultiLineSampleSize          int     `mapstructure:"auto_multi_line_sample_size" json:"auto_multi_line_sample_size"`
+	AutoMultiLineMatchThreshold      float64 `mapstructure:"auto_multi_line_match_threshold" json:"auto_multi_line_match_threshold"`
+	AutoMultiLineStackTraceThreshold float64 `mapstructure:"auto_multi_line_stack_trace_threshold" json:"auto_multi_line_stack_trace_threshold"`
+	AutoMultiLineMatchTimeout        int     `mapstructure:"auto_multi_line_match_timeout" json:"auto_multi_line_match_timeout"`
+	AutoMultiLineMaxLines            int     `mapstructure:"auto_multi_line_max_lines" json:"auto_multi_line_max_lines"`
 }
 
 // TailingMode type
@@ -130,6 +137,8 @@ func (c *LogsConfig) Validate() error {
 		return fmt.Errorf("tcp source must have a port")
 	case c.Type == UDPType && c.Port == 0:
 		return fmt.Errorf("udp source must have a port")
+	case (c.Type == TCPType || c.Type == UDPType) && c.Format != "" && c.Format != SyslogFormat:
+		return fmt.Errorf("format %s is not supported for %s source", c.Format, c.Type)
 	}
 	err := ValidateProcessingRules(c.ProcessingRules)
 	if err != nil {
@@ -8,6 +8,7 @@ package config
 import (
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 // Processing rule types
@@ -16,15 +17,26 @@ const (
 	IncludeAtMatch = "include_at_match"
 	MaskSequences  = "mask_sequences"
 	MultiLine      = "multi_line"
+	ExtractFields  = "extract_fields"
+	RemapAttribute = "remap_attribute"
+	Sample         = "sample"
+	RateLimit      = "rate_limit"
 )
 
-// ProcessingRule defines an exclusion or a masking rule to
+// ProcessingRule defines an exclusion, a masking or an extraction rule to
 // be applied on log lines
 type ProcessingRule struct {
 	Type               string
 	Name               string
 	ReplacePlaceholder string `mapstructure:"replace_placeholder" json:"replace_placeholder"`
 	Pattern            string
+	// SourceKey and TargetKey are dot-separated attribute paths used by remap rules
+	SourceKey string `mapstructure:"source_key" json:"source_key"`
+	TargetKey string `mapstructure:"target_key" json:"target_key"`
+	// SampleRate is the ratio of matching logs kept by sampling rules
+	SampleRate float64 `mapstructure:"sample_rate" json:"sample_rate"`
+	// MaxLinesPerSecond is the number of matching logs kept per second and per source by rate limiting rules
+	MaxLinesPerSecond int `mapstructure:"max_lines_per_second" json:"max_lines_per_second"`
 	// TODO: should be moved out
 	Regex       *regexp.Regexp
 	Placeholder []byte
@@ -35,6 +47,10 @@ type ProcessingRule struct {
 // - a valid name
 // - a valid type
 // - a valid pattern that compiles
+// Extraction rules must also define at least one named capture group and
+// remap rules must define a source and a target key instead of a pattern.
+// Sampling and rate limiting rules apply to all logs when no pattern is provided and
+// must define a sample rate in (0, 1] or a strictly positive number of lines per second.
 func ValidateProcessingRules(rules []*ProcessingRule) error {
 	for _, rule := range rules {
 		if rule.Name == "" {
@@ -42,34 +58,82 @@ func ValidateProcessingRules(rules []*ProcessingRule) error {
 		}
 
 		switch rule.Type {
-		case ExcludeAtMatch, IncludeAtMatch, MaskSequences, MultiLine:
+		case ExcludeAtMatch, IncludeAtMatch, MaskSequences, MultiLine, ExtractFields:
 			break
+		case RemapAttribute:
+			if rule.SourceKey == "" || rule.TargetKey == "" {
+				return fmt.Errorf("source_key and target_key must be set for processing rule `%s`", rule.Name)
+			}
+			if hasEmptySegment(rule.SourceKey) || hasEmptySegment(rule.TargetKey) {
+				return fmt.Errorf("source_key and target_key can not contain empty keys for processing rule `%s`", rule.Name)
+			}
+			if rule.SourceKey == rule.TargetKey || strings.HasPrefix(rule.TargetKey, rule.SourceKey+".") {
+				return fmt.Errorf("target_key can not be equal to or nested in source_key for processing rule `%s`", rule.Name)
+			}
+			continue
+		case Sample:
+			// a missing sample_rate is rejected rather than dropping all the matching logs,
+			// which is what exclude_at_match is for
+			if rule.SampleRate <= 0 || rule.SampleRate > 1 {
+				return fmt.Errorf("sample_rate must be greater than 0 and at most 1 for processing rule `%s`", rule.Name)
+			}
+		case RateLimit:
+			if rule.MaxLinesPerSecond <= 0 {
+				return fmt.Errorf("max_lines_per_second must be strictly positive for processing rule `%s`", rule.Name)
+			}
 		case "":
 			return fmt.Errorf("type must be set for processing rule `%s`", rule.Name)
 		default:
 			return fmt.Errorf("type %s is not supported for processing rule `%s`", rule.Type, rule.Name)
 		}
 
-		if rule.Pattern == "" {
+		if rule.Pattern == "" && rule.Type != Sample && rule.Type != RateLimit {
 			return fmt.Errorf("no pattern provided for processing rule: %s", rule.Name)
 		}
-		_, err := regexp.Compile(rule.Pattern)
+		re, err := regexp.Compile(rule.Pattern)
 		if err != nil {
 			return fmt.Errorf("invalid pattern %s for processing rule: %s", rule.Pattern, rule.Name)
 		}
+		if rule.Type == ExtractFields && !hasNamedGroup(re) {
+			return fmt.Errorf("pattern %s must contain at least one named capture group for processing rule: %s", rule.Pattern, rule.Name)
+		}
 	}
 	return nil
 }
 
+// hasEmptySegment returns true if the dot-separated attribute path contains an empty key,
+// such as "a..b", ".a" or "a.".
+func hasEmptySegment(path string) bool {
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNamedGroup returns true if the regular expression defines at least one named capture group.
+func hasNamedGroup(re *regexp.Regexp) bool {
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // CompileProcessingRules compiles all processing rule regular expressions.
 func CompileProcessingRules(rules []*ProcessingRule) error {
 	for _, rule := range rules {
+		if rule.Type == RemapAttribute {
+			continue
+		}
 		re, err := regexp.Compile(rule.Pattern)
 		if err != nil {
 			return err
 		}
 		switch rule.Type {
-		case ExcludeAtMatch, IncludeAtMatch:
+		case ExcludeAtMatch, IncludeAtMatch, ExtractFields, Sample, RateLimit:
 			rule.Regex = re
 		case MaskSequences:
 			rule.Regex = re
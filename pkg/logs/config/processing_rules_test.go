@@ -33,3 +33,52 @@ func TestCompileShouldFailWithInvalidRules(t *testing.T) {
 		assert.Nil(t, rule.Regex)
 	}
 }
+
+func TestValidateShouldSucceedWithValidExtractionRules(t *testing.T) {
+	rules := []*ProcessingRule{
+		{Name: "extract", Type: ExtractFields, Pattern: "user=(?P<user>\\w+)"},
+		{Name: "remap", Type: RemapAttribute, SourceKey: "user", TargetKey: "usr.name"},
+	}
+	assert.Nil(t, ValidateProcessingRules(rules))
+	assert.Nil(t, CompileProcessingRules(rules))
+	assert.NotNil(t, rules[0].Regex)
+	assert.Nil(t, rules[1].Regex)
+}
+
+func TestValidateShouldFailWithInvalidExtractionRules(t *testing.T) {
+	invalidRules := []*ProcessingRule{
+		{Name: "extract", Type: ExtractFields, Pattern: "user=(\\w+)"},
+		{Name: "remap", Type: RemapAttribute, SourceKey: "user"},
+		{Name: "remap", Type: RemapAttribute, SourceKey: "user", TargetKey: "user"},
+		{Name: "remap", Type: RemapAttribute, SourceKey: "user", TargetKey: "user.name"},
+		{Name: "remap", Type: RemapAttribute, SourceKey: "usr..name", TargetKey: "user"},
+		{Name: "remap", Type: RemapAttribute, SourceKey: ".usr", TargetKey: "user"},
+		{Name: "remap", Type: RemapAttribute, SourceKey: "usr", TargetKey: "user."},
+	}
+
+	for _, rule := range invalidRules {
+		assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{rule}))
+	}
+}
+
+func TestValidateSamplingRules(t *testing.T) {
+	validRules := []*ProcessingRule{
+		{Name: "sample", Type: Sample, SampleRate: 0.1, Pattern: "DEBUG"},
+		{Name: "sample", Type: Sample, SampleRate: 0.5},
+		{Name: "limit", Type: RateLimit, MaxLinesPerSecond: 100},
+	}
+	assert.Nil(t, ValidateProcessingRules(validRules))
+	assert.Nil(t, CompileProcessingRules(validRules))
+	assert.True(t, validRules[1].Regex.MatchString("any log"))
+
+	invalidRules := []*ProcessingRule{
+		{Name: "sample", Type: Sample, SampleRate: 1.5},
+		{Name: "sample", Type: Sample, SampleRate: -1},
+		{Name: "sample", Type: Sample, SampleRate: 0},
+		{Name: "sample", Type: Sample, Pattern: "DEBUG"},
+		{Name: "limit", Type: RateLimit},
+	}
+	for _, rule := range invalidRules {
+		assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{rule}))
+	}
+}
@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"math/rand"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// Sampler applies the sampling and rate limiting rules on the logs of a source,
+// its state is shared by all the pipelines processing the logs of the source.
+type Sampler struct {
+	source      *LogSource
+	lock        sync.Mutex
+	limiters    map[*ProcessingRule]*rate.Limiter
+	sampledOut  *CountInfo
+	rateLimited *CountInfo
+}
+
+// NewSampler returns a new sampler reporting the dropped logs on the status of the source.
+func NewSampler(source *LogSource) *Sampler {
+	return &Sampler{
+		source:      source,
+		limiters:    make(map[*ProcessingRule]*rate.Limiter),
+		sampledOut:  NewCountInfo("Sampled Out"),
+		rateLimited: NewCountInfo("Rate Limited"),
+	}
+}
+
+// Keep returns false if the log must be dropped by the sampling or rate limiting rule.
+func (s *Sampler) Keep(rule *ProcessingRule) bool {
+	switch rule.Type {
+	case Sample:
+		if rand.Float64() < rule.SampleRate {
+			return true
+		}
+		s.drop(s.sampledOut)
+	case RateLimit:
+		if s.limiter(rule).Allow() {
+			return true
+		}
+		s.drop(s.rateLimited)
+	default:
+		return true
+	}
+	return false
+}
+
+// limiter returns the rate limiter of the rule, a limiter allows a burst of one second of logs.
+func (s *Sampler) limiter(rule *ProcessingRule) *rate.Limiter {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	limiter, exists := s.limiters[rule]
+	if !exists {
+		limiter = rate.NewLimiter(rate.Limit(rule.MaxLinesPerSecond), rule.MaxLinesPerSecond)
+		s.limiters[rule] = limiter
+	}
+	return limiter
+}
+
+// drop increments the counter and registers it on the source status the first time a log is dropped.
+func (s *Sampler) drop(count *CountInfo) {
+	if s.source.GetInfo(count.InfoKey()) == nil {
+		s.source.RegisterInfo(count)
+	}
+	count.Add(1)
+}
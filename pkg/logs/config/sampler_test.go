@@ -0,0 +1,46 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSamplerSample(t *testing.T) {
+	source := NewLogSource("", &LogsConfig{})
+
+	keepAll := &ProcessingRule{Type: Sample, SampleRate: 1}
+	dropAll := &ProcessingRule{Type: Sample, SampleRate: 0}
+	for i := 0; i < 10; i++ {
+		assert.True(t, source.Sampler.Keep(keepAll))
+		assert.False(t, source.Sampler.Keep(dropAll))
+	}
+	assert.Equal(t, map[string][]string{"Sampled Out": {"10"}}, source.GetInfoStatus())
+}
+
+func TestSamplerRateLimit(t *testing.T) {
+	source := NewLogSource("", &LogsConfig{})
+	other := NewLogSource("", &LogsConfig{})
+
+	rule := &ProcessingRule{Type: RateLimit, MaxLinesPerSecond: 3}
+	for i := 0; i < 3; i++ {
+		assert.True(t, source.Sampler.Keep(rule))
+	}
+	assert.False(t, source.Sampler.Keep(rule))
+	assert.Equal(t, map[string][]string{"Rate Limited": {"1"}}, source.GetInfoStatus())
+
+	// the limit applies per source
+	assert.True(t, other.Sampler.Keep(rule))
+	assert.Empty(t, other.GetInfoStatus())
+}
+
+func TestSamplerIgnoresOtherRules(t *testing.T) {
+	source := NewLogSource("", &LogsConfig{})
+	assert.True(t, source.Sampler.Keep(&ProcessingRule{Type: ExcludeAtMatch}))
+	assert.Empty(t, source.GetInfoStatus())
+}
@@ -49,11 +49,13 @@ type LogSource struct {
 	// the duration between when a message is decoded by the tailer/listener/decoder and when the message is handled by a sender
 	LatencyStats     *util.StatsTracker
 	hiddenFromStatus bool
+	// Sampler applies the sampling and rate limiting processing rules on the logs of this source
+	Sampler *Sampler
 }
 
 // NewLogSource creates a new log source.
 func NewLogSource(name string, config *LogsConfig) *LogSource {
-	return &LogSource{
+	source := &LogSource{
 		Name:             name,
 		Config:           config,
 		Status:           NewLogStatus(),
@@ -65,6 +67,8 @@ func NewLogSource(name string, config *LogsConfig) *LogSource {
 		LatencyStats:     util.NewStatsTracker(time.Hour*24, time.Hour),
 		hiddenFromStatus: false,
 	}
+	source.Sampler = NewSampler(source)
+	return source
 }
 
 // AddInput registers an input as being handled by this source.
@@ -6,6 +6,7 @@
 package decoder
 
 import (
+	"fmt"
 	"regexp"
 	"sort"
 	"sync"
@@ -15,6 +16,9 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
+// autoMultiLineInfoKey is the key of the status info reporting the result of the detection.
+const autoMultiLineInfoKey = "Auto multi-line detection"
+
 type scoredPattern struct {
 	score  int
 	regexp *regexp.Regexp
@@ -42,30 +46,38 @@ func (d *DetectedPattern) Get() *regexp.Regexp {
 
 // AutoMultilineHandler can attempts to detect a known/commob pattern (a timestamp) in the logs
 // and will switch to a MultiLine handler if one is detected and the thresholds are met.
+// When no pattern is detected but the logs contain stack traces, it switches to a MultiLine handler
+// aggregating the stack traces with the line preceding them.
 type AutoMultilineHandler struct {
-	multiLineHandler  *MultiLineHandler
-	singleLineHandler *SingleLineHandler
-	inputChan         chan *Message
-	outputChan        chan *Message
-	isRunning         bool
-	linesToAssess     int
-	linesTested       int
-	lineLimit         int
-	matchThreshold    float64
-	scoredMatches     []*scoredPattern
-	processsingFunc   func(message *Message)
-	flushTimeout      time.Duration
-	source            *config.LogSource
-	timeoutTimer      *time.Timer
-	detectedPattern   *DetectedPattern
+	multiLineHandler    *MultiLineHandler
+	singleLineHandler   *SingleLineHandler
+	inputChan           chan *Message
+	outputChan          chan *Message
+	isRunning           bool
+	linesToAssess       int
+	linesTested         int
+	lineLimit           int
+	maxLines            int
+	matchThreshold      float64
+	stackTraceThreshold float64
+	scoredMatches       []*scoredPattern
+	processsingFunc     func(message *Message)
+	flushTimeout        time.Duration
+	source              *config.LogSource
+	timeoutTimer        *time.Timer
+	detectedPattern     *DetectedPattern
+	stackTraces         *stackTraceDetector
+	stackTraceLines     int
 }
 
 // NewAutoMultilineHandler returns a new AutoMultilineHandler.
 func NewAutoMultilineHandler(outputChan chan *Message,
 	lineLimit, linesToAssess int,
 	matchThreshold float64,
+	stackTraceThreshold float64,
 	matchTimeout time.Duration,
 	flushTimeout time.Duration,
+	maxLines int,
 	source *config.LogSource,
 	additionalPatterns []*regexp.Regexp,
 	detectedPattern *DetectedPattern,
@@ -82,17 +94,20 @@ func NewAutoMultilineHandler(outputChan chan *Message,
 		}
 	}
 	h := &AutoMultilineHandler{
-		inputChan:       make(chan *Message),
-		outputChan:      outputChan,
-		isRunning:       true,
-		lineLimit:       lineLimit,
-		matchThreshold:  matchThreshold,
-		scoredMatches:   scoredMatches,
-		linesToAssess:   linesToAssess,
-		flushTimeout:    flushTimeout,
-		source:          source,
-		timeoutTimer:    time.NewTimer(matchTimeout),
-		detectedPattern: detectedPattern,
+		inputChan:           make(chan *Message),
+		outputChan:          outputChan,
+		isRunning:           true,
+		lineLimit:           lineLimit,
+		maxLines:            maxLines,
+		matchThreshold:      matchThreshold,
+		stackTraceThreshold: stackTraceThreshold,
+		scoredMatches:       scoredMatches,
+		linesToAssess:       linesToAssess,
+		flushTimeout:        flushTimeout,
+		source:              source,
+		timeoutTimer:        time.NewTimer(matchTimeout),
+		detectedPattern:     detectedPattern,
+		stackTraces:         &stackTraceDetector{},
 	}
 
 	h.singleLineHandler = NewSingleLineHandler(outputChan, lineLimit)
@@ -132,7 +147,12 @@ func (h *AutoMultilineHandler) run() {
 }
 
 func (h *AutoMultilineHandler) processAndTry(message *Message) {
-	// Process message before anything else
+	// Stack frames are detected with their indentation which is trimmed by the single line handler
+	if h.stackTraces.isContinuation(message.Content) {
+		h.stackTraceLines++
+	}
+
+	// Send the message as a single line until a pattern is detected
 	h.singleLineHandler.process(message)
 
 	for i, scoredPattern := range h.scoredMatches {
@@ -168,27 +188,46 @@ func (h *AutoMultilineHandler) processAndTry(message *Message) {
 
 		if matchRatio >= h.matchThreshold {
 			log.Debugf("Pattern %v matched %d lines with a ratio of %f", topMatch.regexp.String(), topMatch.score, matchRatio)
+			h.reportDetection(fmt.Sprintf("Pattern %v detected", topMatch.regexp.String()))
 			h.detectedPattern.Set(topMatch.regexp)
-			h.switchToMultilineHandler(topMatch.regexp)
+			h.switchToMultilineHandler(newMultiLineHandler(h.inputChan, h.outputChan, topMatch.regexp, h.flushTimeout, h.lineLimit))
+		} else if stackTraceRatio := float64(h.stackTraceLines) / float64(h.linesTested); h.stackTraceLines > 0 && stackTraceRatio >= h.stackTraceThreshold {
+			log.Debugf("No pattern met the line match threshold during multiline autosensing but %d lines of stack traces were found with a ratio of %f - using stack trace handler", h.stackTraceLines, stackTraceRatio)
+			h.reportDetection("No pattern detected, aggregating stack traces")
+			h.switchToMultilineHandler(newStackTraceHandler(h.inputChan, h.outputChan, h.flushTimeout, h.lineLimit))
 		} else {
 			log.Debug("No pattern met the line match threshold during multiline autosensing - using single line handler")
+			h.reportDetection("No pattern detected, using single line handling")
 			// Stay with the single line handler and no longer attempt to detect multiline matches.
 			h.processsingFunc = h.singleLineHandler.process
 		}
 	}
 }
 
-func (h *AutoMultilineHandler) switchToMultilineHandler(r *regexp.Regexp) {
+func (h *AutoMultilineHandler) switchToMultilineHandler(multiLineHandler *MultiLineHandler) {
 	h.isRunning = false
 	h.singleLineHandler = nil
 
-	// Build and start a multiline-handler
-	h.multiLineHandler = newMultiLineHandler(h.inputChan, h.outputChan, r, h.flushTimeout, h.lineLimit)
+	// Start the multiline-handler
+	h.multiLineHandler = multiLineHandler
+	h.multiLineHandler.maxLines = h.maxLines
+	h.multiLineHandler.registerInfo(h.source)
 	h.multiLineHandler.Start()
 
 	// At this point control is handed over to the multiline handler and the AutoMultilineHandler read loop has stopped.
 }
 
+// reportDetection surfaces the result of the detection on the status page,
+// the result of all the handlers of the source is reported.
+func (h *AutoMultilineHandler) reportDetection(result string) {
+	info, ok := h.source.GetInfo(autoMultiLineInfoKey).(*config.MappedInfo)
+	if !ok {
+		info = config.NewMappedInfo(autoMultiLineInfoKey)
+		h.source.RegisterInfo(info)
+	}
+	info.SetMessage(result, result)
+}
+
 // Originally referenced from https://github.com/egnyte/ax/blob/master/pkg/heuristic/timestamp.go
 // All line matching rules must only match the beginning of a line, so when adding new expressions
 // make sure to prepend it with `^`
@@ -110,13 +110,7 @@ func NewDecoderWithEndLineMatcher(source *config.LogSource, parser parser.Parser
 			// Since a single source can have multiple file tailers - each with their own decoder instance,
 			// Make sure we keep track of the multiline match count info from all of the decoders so the
 			// status page displays it correctly.
-			if existingInfo, ok := source.GetInfo(lh.countInfo.InfoKey()).(*config.CountInfo); ok {
-				// override the new decoders info to the instance we are already using
-				lh.countInfo = existingInfo
-			} else {
-				// this is the first decoder we have seen for this source - use it's count info
-				source.RegisterInfo(lh.countInfo)
-			}
+			lh.registerInfo(source)
 			lineHandler = lh
 		}
 	}
@@ -130,7 +124,10 @@ func NewDecoderWithEndLineMatcher(source *config.LogSource, parser parser.Parser
 				// Save the pattern again for the next rotation
 				detectedPattern.Set(multiLinePattern)
 
-				lineHandler = NewMultiLineHandler(outputChan, multiLinePattern, config.AggregationTimeout(), lineLimit)
+				lh := NewMultiLineHandler(outputChan, multiLinePattern, config.AggregationTimeout(), lineLimit)
+				lh.maxLines = autoMultiLineMaxLines(source)
+				lh.registerInfo(source)
+				lineHandler = lh
 			} else {
 				lineHandler = buildAutoMultilineHandlerFromConfig(outputChan, lineLimit, source, detectedPattern)
 			}
@@ -157,6 +154,10 @@ func buildAutoMultilineHandlerFromConfig(outputChan chan *Message, lineLimit int
 	if matchThreshold == 0 {
 		matchThreshold = dd_conf.Datadog.GetFloat64("logs_config.auto_multi_line_default_match_threshold")
 	}
+	stackTraceThreshold := source.Config.AutoMultiLineStackTraceThreshold
+	if stackTraceThreshold == 0 {
+		stackTraceThreshold = dd_conf.Datadog.GetFloat64("logs_config.auto_multi_line_default_stack_trace_threshold")
+	}
 	additionalPatterns := dd_conf.Datadog.GetStringSlice("logs_config.auto_multi_line_extra_patterns")
 	additionalPatternsCompiled := []*regexp.Regexp{}
 
@@ -169,18 +170,32 @@ func buildAutoMultilineHandlerFromConfig(outputChan chan *Message, lineLimit int
 		additionalPatternsCompiled = append(additionalPatternsCompiled, compiled)
 	}
 
-	matchTimeout := time.Second * dd_conf.Datadog.GetDuration("logs_config.auto_multi_line_default_match_timeout")
+	matchTimeout := time.Second * time.Duration(source.Config.AutoMultiLineMatchTimeout)
+	if matchTimeout <= 0 {
+		matchTimeout = time.Second * dd_conf.Datadog.GetDuration("logs_config.auto_multi_line_default_match_timeout")
+	}
 	return NewAutoMultilineHandler(outputChan,
 		lineLimit,
 		linesToSample,
 		matchThreshold,
+		stackTraceThreshold,
 		matchTimeout,
 		config.AggregationTimeout(),
+		autoMultiLineMaxLines(source),
 		source,
 		additionalPatternsCompiled,
 		detectedPattern)
 }
 
+// autoMultiLineMaxLines returns the maximum number of lines of a message aggregated by auto multi line detection,
+// 0 means no limit.
+func autoMultiLineMaxLines(source *config.LogSource) int {
+	if source.Config.AutoMultiLineMaxLines > 0 {
+		return source.Config.AutoMultiLineMaxLines
+	}
+	return dd_conf.Datadog.GetInt("logs_config.auto_multi_line_default_max_lines")
+}
+
 // New returns an initialized Decoder
 func New(InputChan chan *Input, OutputChan chan *Message, lineParser LineParser, contentLenLimit int, matcher EndLineMatcher, detectedPattern *DetectedPattern) *Decoder {
 	var lineBuffer bytes.Buffer
@@ -232,7 +232,7 @@ func TestAutoMultiLineHandlerStaysSingleLineMode(t *testing.T) {
 	outputChan := make(chan *Message, 10)
 	source := config.NewLogSource("config", &config.LogsConfig{})
 	detectedPattern := &DetectedPattern{}
-	h := NewAutoMultilineHandler(outputChan, 100, 5, 1.0, 10*time.Millisecond, 10*time.Millisecond, source, []*regexp.Regexp{}, detectedPattern)
+	h := NewAutoMultilineHandler(outputChan, 100, 5, 1.0, 0.05, 10*time.Millisecond, 10*time.Millisecond, 0, source, []*regexp.Regexp{}, detectedPattern)
 	h.Start()
 
 	for i := 0; i < 6; i++ {
@@ -249,7 +249,7 @@ func TestAutoMultiLineHandlerSwitchesToMultiLineMode(t *testing.T) {
 	outputChan := make(chan *Message, 10)
 	source := config.NewLogSource("config", &config.LogsConfig{})
 	detectedPattern := &DetectedPattern{}
-	h := NewAutoMultilineHandler(outputChan, 100, 5, 1.0, 10*time.Millisecond, 10*time.Millisecond, source, []*regexp.Regexp{}, detectedPattern)
+	h := NewAutoMultilineHandler(outputChan, 100, 5, 1.0, 0.05, 10*time.Millisecond, 10*time.Millisecond, 0, source, []*regexp.Regexp{}, detectedPattern)
 	h.Start()
 
 	for i := 0; i < 6; i++ {
@@ -265,7 +265,7 @@ func TestAutoMultiLineHandlerHandelsMessage(t *testing.T) {
 
 	outputChan := make(chan *Message, 10)
 	source := config.NewLogSource("config", &config.LogsConfig{})
-	h := NewAutoMultilineHandler(outputChan, 500, 1, 1.0, 10*time.Millisecond, 10*time.Millisecond, source, []*regexp.Regexp{}, &DetectedPattern{})
+	h := NewAutoMultilineHandler(outputChan, 500, 1, 1.0, 0.05, 10*time.Millisecond, 10*time.Millisecond, 0, source, []*regexp.Regexp{}, &DetectedPattern{})
 	h.Start()
 
 	h.Handle(getDummyMessageWithLF("Jul 12, 2021 12:55:15 PM test message 1"))
@@ -285,7 +285,7 @@ func TestAutoMultiLineHandlerHandelsMessageConflictingPatterns(t *testing.T) {
 
 	outputChan := make(chan *Message, 10)
 	source := config.NewLogSource("config", &config.LogsConfig{})
-	h := NewAutoMultilineHandler(outputChan, 500, 4, 0.75, 10*time.Millisecond, 10*time.Millisecond, source, []*regexp.Regexp{}, &DetectedPattern{})
+	h := NewAutoMultilineHandler(outputChan, 500, 4, 0.75, 0.05, 10*time.Millisecond, 10*time.Millisecond, 0, source, []*regexp.Regexp{}, &DetectedPattern{})
 	h.Start()
 
 	// we will match both patterns, but one will win with a threshold of 0.75
@@ -312,7 +312,7 @@ func TestAutoMultiLineHandlerHandelsMessageConflictingPatternsNoWinner(t *testin
 
 	outputChan := make(chan *Message, 10)
 	source := config.NewLogSource("config", &config.LogsConfig{})
-	h := NewAutoMultilineHandler(outputChan, 500, 4, 0.75, 10*time.Millisecond, 10*time.Millisecond, source, []*regexp.Regexp{}, &DetectedPattern{})
+	h := NewAutoMultilineHandler(outputChan, 500, 4, 0.75, 0.05, 10*time.Millisecond, 10*time.Millisecond, 0, source, []*regexp.Regexp{}, &DetectedPattern{})
 	h.Start()
 
 	// we will match both patterns, but neither will win because it doesn't meet the threshold
@@ -332,3 +332,85 @@ func TestAutoMultiLineHandlerHandelsMessageConflictingPatternsNoWinner(t *testin
 
 	assert.Equal(t, "Jul 12, 2021 12:55:15 PM test message 2", string(output.Content))
 }
+
+func TestAutoMultiLineHandlerSwitchesToStackTraceMode(t *testing.T) {
+	outputChan := make(chan *Message, 10)
+	source := config.NewLogSource("config", &config.LogsConfig{})
+	h := NewAutoMultilineHandler(outputChan, 500, 3, 1.0, 0.05, 10*time.Millisecond, 10*time.Millisecond, 0, source, []*regexp.Regexp{}, &DetectedPattern{})
+	h.Start()
+
+	h.Handle(getDummyMessageWithLF("request failed"))
+	h.Handle(getDummyMessageWithLF("java.lang.Exception: boom"))
+	h.Handle(getDummyMessageWithLF("\tat Main.funcd(Main.java:62)"))
+	for i := 0; i < 3; i++ {
+		<-outputChan
+	}
+
+	h.Handle(getDummyMessageWithLF("java.lang.Exception: boom"))
+	h.Handle(getDummyMessageWithLF("\tat Main.funcd(Main.java:62)"))
+	h.Handle(getDummyMessageWithLF("Caused by: java.lang.NullPointerException"))
+	h.Handle(getDummyMessageWithLF("\t... 2 more"))
+	h.Handle(getDummyMessageWithLF("next message"))
+	output := <-outputChan
+
+	assert.Equal(t, "java.lang.Exception: boom\\n\tat Main.funcd(Main.java:62)\\nCaused by: java.lang.NullPointerException\\n\t... 2 more", string(output.Content))
+	assert.Equal(t, []string{"No pattern detected, aggregating stack traces"}, source.GetInfo(autoMultiLineInfoKey).Info())
+	assert.Equal(t, []string{"3"}, source.GetInfo("MultiLine aggregated lines").Info())
+}
+
+func TestAutoMultiLineHandlerStaysInSingleLineModeBelowStackTraceThreshold(t *testing.T) {
+	outputChan := make(chan *Message, 30)
+	source := config.NewLogSource("config", &config.LogsConfig{})
+	h := NewAutoMultilineHandler(outputChan, 500, 20, 1.0, 0.1, 10*time.Millisecond, 10*time.Millisecond, 0, source, []*regexp.Regexp{}, &DetectedPattern{})
+	h.Start()
+
+	// a single indented line in the sample does not make the source a stack trace source
+	h.Handle(getDummyMessageWithLF("\tindented message"))
+	for i := 0; i < 19; i++ {
+		h.Handle(getDummyMessageWithLF("test message"))
+	}
+	for i := 0; i < 20; i++ {
+		<-outputChan
+	}
+
+	h.Handle(getDummyMessageWithLF("java.lang.Exception: boom"))
+	h.Handle(getDummyMessageWithLF("\tat Main.funcd(Main.java:62)"))
+	output := <-outputChan
+
+	assert.NotNil(t, h.singleLineHandler)
+	assert.Nil(t, h.multiLineHandler)
+	assert.Equal(t, "java.lang.Exception: boom", string(output.Content))
+	assert.Equal(t, []string{"No pattern detected, using single line handling"}, source.GetInfo(autoMultiLineInfoKey).Info())
+}
+
+func TestAutoMultiLineHandlerReportsDetectedPattern(t *testing.T) {
+	outputChan := make(chan *Message, 10)
+	source := config.NewLogSource("config", &config.LogsConfig{})
+	h := NewAutoMultilineHandler(outputChan, 500, 1, 1.0, 0.05, 10*time.Millisecond, 10*time.Millisecond, 0, source, []*regexp.Regexp{}, &DetectedPattern{})
+	h.Start()
+
+	h.Handle(getDummyMessageWithLF("Jul 12, 2021 12:55:15 PM test message 1"))
+	<-outputChan
+
+	info := source.GetInfo(autoMultiLineInfoKey).Info()
+	assert.Len(t, info, 1)
+	assert.Contains(t, info[0], "detected")
+	assert.NotNil(t, source.GetInfo("MultiLine matches"))
+}
+
+func TestMultiLineHandlerMaxLines(t *testing.T) {
+	outputChan := make(chan *Message, 10)
+	h := NewMultiLineHandler(outputChan, regexp.MustCompile(`^\[`), 10*time.Millisecond, 500)
+	h.maxLines = 2
+	h.Start()
+
+	h.Handle(getDummyMessageWithLF("[1] first"))
+	h.Handle(getDummyMessageWithLF("second"))
+	h.Handle(getDummyMessageWithLF("third"))
+	h.Handle(getDummyMessageWithLF("[2] fourth"))
+
+	assert.Equal(t, "[1] first\\nsecond", string((<-outputChan).Content))
+	assert.Equal(t, "third", string((<-outputChan).Content))
+	assert.Equal(t, "[2] fourth", string((<-outputChan).Content))
+	h.Stop()
+}
@@ -15,18 +15,24 @@ import (
 
 // MultiLineHandler makes sure that multiple lines from a same content
 // are properly put together.
+// A new content either starts with a line matching newContentRe or,
+// when there is no pattern, with a line that does not continue a stack trace.
 type MultiLineHandler struct {
-	inputChan      chan *Message
-	outputChan     chan *Message
-	newContentRe   *regexp.Regexp
-	buffer         *bytes.Buffer
-	flushTimeout   time.Duration
-	lineLimit      int
-	shouldTruncate bool
-	linesLen       int
-	status         string
-	timestamp      string
-	countInfo      *config.CountInfo
+	inputChan           chan *Message
+	outputChan          chan *Message
+	newContentRe        *regexp.Regexp
+	stackTraces         *stackTraceDetector
+	buffer              *bytes.Buffer
+	flushTimeout        time.Duration
+	lineLimit           int
+	maxLines            int
+	shouldTruncate      bool
+	linesLen            int
+	linesCount          int
+	status              string
+	timestamp           string
+	countInfo           *config.CountInfo
+	linesAggregatedInfo *config.CountInfo
 }
 
 // NewMultiLineHandler returns a new MultiLineHandler.
@@ -36,16 +42,49 @@ func NewMultiLineHandler(outputChan chan *Message, newContentRe *regexp.Regexp,
 
 func newMultiLineHandler(inputChan chan *Message, outputChan chan *Message, newContentRe *regexp.Regexp, flushTimeout time.Duration, lineLimit int) *MultiLineHandler {
 	return &MultiLineHandler{
-		inputChan:    inputChan,
-		outputChan:   outputChan,
-		newContentRe: newContentRe,
-		buffer:       bytes.NewBuffer(nil),
-		flushTimeout: flushTimeout,
-		lineLimit:    lineLimit,
-		countInfo:    config.NewCountInfo("MultiLine matches"),
+		inputChan:           inputChan,
+		outputChan:          outputChan,
+		newContentRe:        newContentRe,
+		buffer:              bytes.NewBuffer(nil),
+		flushTimeout:        flushTimeout,
+		lineLimit:           lineLimit,
+		countInfo:           config.NewCountInfo("MultiLine matches"),
+		linesAggregatedInfo: config.NewCountInfo("MultiLine aggregated lines"),
 	}
 }
 
+// newStackTraceHandler returns a new MultiLineHandler aggregating the stack traces with the line preceding them.
+func newStackTraceHandler(inputChan chan *Message, outputChan chan *Message, flushTimeout time.Duration, lineLimit int) *MultiLineHandler {
+	h := newMultiLineHandler(inputChan, outputChan, nil, flushTimeout, lineLimit)
+	h.stackTraces = &stackTraceDetector{}
+	return h
+}
+
+// registerInfo registers the count infos of the handler on the source, the infos
+// are shared by all the handlers of the source so that the status page displays the totals.
+func (h *MultiLineHandler) registerInfo(source *config.LogSource) {
+	h.countInfo = registerCountInfo(source, h.countInfo)
+	h.linesAggregatedInfo = registerCountInfo(source, h.linesAggregatedInfo)
+}
+
+// registerCountInfo registers info on the source unless the source already has one with the same key,
+// returns the info registered on the source.
+func registerCountInfo(source *config.LogSource, info *config.CountInfo) *config.CountInfo {
+	if existingInfo, ok := source.GetInfo(info.InfoKey()).(*config.CountInfo); ok {
+		return existingInfo
+	}
+	source.RegisterInfo(info)
+	return info
+}
+
+// isNewContent returns true if the line is the first line of a new content.
+func (h *MultiLineHandler) isNewContent(content []byte) bool {
+	if h.newContentRe != nil {
+		return h.newContentRe.Match(content)
+	}
+	return !h.stackTraces.isContinuation(content)
+}
+
 // Handle forward lines to lineChan to process them.
 func (h *MultiLineHandler) Handle(input *Message) {
 	h.inputChan <- input
@@ -106,7 +145,7 @@ func (h *MultiLineHandler) run() {
 // so that the agent restarts tailing from the right place.
 func (h *MultiLineHandler) process(message *Message) {
 
-	if h.newContentRe.Match(message.Content) {
+	if h.isNewContent(message.Content) {
 		h.countInfo.Add(1)
 		// the current line is part of a new message,
 		// send the buffer
@@ -126,7 +165,9 @@ func (h *MultiLineHandler) process(message *Message) {
 		// the buffer already contains some data which means that
 		// the current line is not the first line of the message
 		h.buffer.Write(escapedLineFeed)
+		h.linesAggregatedInfo.Add(1)
 	}
+	h.linesCount++
 
 	if isTruncated {
 		// the previous line has been truncated because it was too long,
@@ -143,6 +184,9 @@ func (h *MultiLineHandler) process(message *Message) {
 		h.buffer.Write(truncatedFlag)
 		h.sendBuffer()
 		h.shouldTruncate = true
+	} else if h.maxLines > 0 && h.linesCount >= h.maxLines {
+		// the multiline message has too many lines, the next lines are sent as a new message
+		h.sendBuffer()
 	}
 }
 
@@ -152,6 +196,7 @@ func (h *MultiLineHandler) sendBuffer() {
 	defer func() {
 		h.buffer.Reset()
 		h.linesLen = 0
+		h.linesCount = 0
 		h.shouldTruncate = false
 	}()
 
@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package decoder
+
+import (
+	"regexp"
+)
+
+var (
+	// indented lines are stack frames for most languages:
+	// Java and .NET `	at Main.main(Main.java:5)`, Python `  File "main.py", line 5`, Go `	/go/main.go:5 +0x1d`
+	indentedLineRe = regexp.MustCompile(`^[ \t]+\S`)
+	// Java chained exceptions and .NET inner exceptions
+	chainedExceptionRe = regexp.MustCompile(`^(Caused by|Suppressed): |^\.\.\. \d+ (more|common frames omitted)|^--- End of |^ ?---> `)
+	// Python tracebacks, the exception follows the stack frames
+	pythonTracebackRe = regexp.MustCompile(`^Traceback \(most recent call last\):`)
+	pythonChainedRe   = regexp.MustCompile(`^(During handling of the above exception|The above exception was the direct cause)`)
+	// Go panics, goroutines are separated by blank lines
+	goPanicRe     = regexp.MustCompile(`^(panic: |fatal error: )`)
+	goGoroutineRe = regexp.MustCompile(`^goroutine \d+ \[[^\]]+\]:`)
+	goFunctionRe  = regexp.MustCompile(`^(\S+\(.*\)|created by \S+.*)$`)
+)
+
+// stackTraceDetector detects the lines that continue the previous line as part of
+// a Java, Python, Go or .NET stack trace. It tracks the state of the current
+// stack trace, so the lines must be submitted in order.
+type stackTraceDetector struct {
+	inStackTrace      bool
+	inPythonTraceback bool
+	inGoPanic         bool
+}
+
+// isContinuation returns true if the line is part of the stack trace of the previous lines.
+func (d *stackTraceDetector) isContinuation(content []byte) bool {
+	if len(content) == 0 {
+		// blank lines separate the goroutines of a panic and the chained Python exceptions
+		return d.inStackTrace || d.inGoPanic
+	}
+	d.inStackTrace = d.detect(content)
+	return d.inStackTrace
+}
+
+func (d *stackTraceDetector) detect(content []byte) bool {
+	switch {
+	case indentedLineRe.Match(content), chainedExceptionRe.Match(content):
+		return true
+	case pythonTracebackRe.Match(content), pythonChainedRe.Match(content):
+		d.inPythonTraceback = true
+		return true
+	case d.inPythonTraceback:
+		// the exception ends the traceback
+		d.inPythonTraceback = false
+		return true
+	case goGoroutineRe.Match(content):
+		d.inGoPanic = true
+		return true
+	case d.inGoPanic && goFunctionRe.Match(content):
+		return true
+	case goPanicRe.Match(content):
+		// the panic starts a new message
+		d.inGoPanic = true
+		return false
+	}
+	d.inGoPanic = false
+	return false
+}
@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package decoder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// aggregate groups the lines in messages the same way the stack trace handler does.
+func aggregate(lines string) []string {
+	detector := &stackTraceDetector{}
+	var messages []string
+	for _, line := range strings.Split(lines, "\n") {
+		if detector.isContinuation([]byte(line)) && len(messages) > 0 {
+			messages[len(messages)-1] += "\n" + line
+			continue
+		}
+		messages = append(messages, line)
+	}
+	return messages
+}
+
+func TestStackTraceDetectorJava(t *testing.T) {
+	trace := `Exception in thread "main" java.lang.IllegalStateException: boom
+	at com.example.Main.run(Main.java:12)
+	at com.example.Main.main(Main.java:5)
+Caused by: java.lang.NullPointerException
+	at com.example.Service.call(Service.java:42)
+	... 2 more`
+	assert.Equal(t, []string{"starting", trace, "done"}, aggregate("starting\n"+trace+"\ndone"))
+}
+
+func TestStackTraceDetectorPython(t *testing.T) {
+	trace := `ERROR:root:request failed
+Traceback (most recent call last):
+  File "main.py", line 3, in <module>
+    run()
+  File "main.py", line 2, in run
+    raise KeyError("x")
+KeyError: 'x'
+
+During handling of the above exception, another exception occurred:
+
+Traceback (most recent call last):
+  File "main.py", line 5, in <module>
+    raise ValueError("y")
+ValueError: y`
+	assert.Equal(t, []string{trace, "INFO:root:next request"}, aggregate(trace+"\nINFO:root:next request"))
+}
+
+func TestStackTraceDetectorGo(t *testing.T) {
+	trace := `panic: runtime error: index out of range [5] with length 3
+
+goroutine 1 [running]:
+main.main()
+	/tmp/main.go:8 +0x1d
+
+goroutine 6 [chan receive]:
+main.worker(0xc000010000)
+	/tmp/main.go:20 +0x45
+created by main.main
+	/tmp/main.go:6 +0x2b`
+	assert.Equal(t, []string{"starting", trace, "exit status 2"}, aggregate("starting\n"+trace+"\nexit status 2"))
+}
+
+func TestStackTraceDetectorDotNet(t *testing.T) {
+	trace := `System.InvalidOperationException: outer ---> System.ArgumentException: inner
+   at App.Service.Validate(String value) in C:\app\Service.cs:line 17
+   --- End of inner exception stack trace ---
+   at App.Program.Main(String[] args) in C:\app\Program.cs:line 9`
+	assert.Equal(t, []string{"starting", trace, "done"}, aggregate("starting\n"+trace+"\ndone"))
+}
+
+func TestStackTraceDetectorSingleLines(t *testing.T) {
+	lines := "first line\nsecond line\n\nthird line"
+	assert.Equal(t, []string{"first line", "second line", "", "third line"}, aggregate(lines))
+}
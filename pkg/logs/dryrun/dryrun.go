@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package dryrun runs sample logs through the decoding and processing steps of a logs config
+// to show what would be sent, without sending anything.
+package dryrun
+
+import (
+	"bytes"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/decoder"
+	"github.com/DataDog/datadog-agent/pkg/logs/input/file"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/processor"
+)
+
+// Result is the outcome of the processing of a log of the sample.
+type Result struct {
+	// Content is the log decoded from the sample, multi-line logs are aggregated.
+	Content string
+	// Payload is the JSON payload that would be sent, empty if the log is dropped.
+	Payload string
+	// DropRule is the name of the processing rule dropping the log, if any.
+	DropRule string
+	// RedactingRules are the names of the processing rules modifying the content of the log.
+	RedactingRules []string
+}
+
+// IsDropped returns true if the log would not be sent.
+func (r *Result) IsDropped() bool {
+	return r.DropRule != ""
+}
+
+// Run decodes the sample as a file tailed with the logs config would be, then applies the global
+// and the logs config processing rules and encodes the remaining logs in JSON.
+func Run(logsConfig *config.LogsConfig, globalProcessingRules []*config.ProcessingRule, sample []byte) ([]*Result, error) {
+	if err := logsConfig.Validate(); err != nil {
+		return nil, err
+	}
+	source := config.NewLogSource(logsConfig.Name, logsConfig)
+	p := processor.New(nil, nil, globalProcessingRules, processor.JSONEncoder, nil)
+
+	// The decoder only outputs the lines that end with a line feed
+	if len(sample) > 0 && !bytes.HasSuffix(sample, []byte("\n")) {
+		sample = append(sample, '\n')
+	}
+	d := file.NewDecoderFromSource(source)
+	d.Start()
+	go func() {
+		d.InputChan <- decoder.NewInput(sample)
+		d.Stop()
+	}()
+
+	var results []*Result
+	var err error
+	for output := range d.OutputChan {
+		// Empty lines are not sent by the tailers
+		if len(output.Content) == 0 || err != nil {
+			continue
+		}
+		result := &Result{Content: string(output.Content)}
+		msg := message.NewMessage(output.Content, message.NewOrigin(source), output.Status, output.IngestionTimestamp)
+
+		var dropRule *config.ProcessingRule
+		var redactingRules []*config.ProcessingRule
+		var payload []byte
+		dropRule, redactingRules, payload, err = p.DryRun(msg)
+		if dropRule != nil {
+			result.DropRule = dropRule.Name
+		}
+		for _, rule := range redactingRules {
+			result.RedactingRules = append(result.RedactingRules, rule.Name)
+		}
+		result.Payload = string(payload)
+		results = append(results, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,73 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package dryrun
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+const sample = `2021-07-08 05:08:19,214 GET /health
+2021-07-08 05:08:20,214 login failed with password=hunter2 for bob
+java.lang.Exception: boom
+	at Main.main(Main.java:5)
+
+2021-07-08 05:08:21,214 done`
+
+func TestRun(t *testing.T) {
+	logsConfig := &config.LogsConfig{
+		Type:    config.FileType,
+		Path:    "/var/log/app.log",
+		Service: "app",
+		Source:  "java",
+		ProcessingRules: []*config.ProcessingRule{
+			{Type: config.MultiLine, Name: "new_log_start_with_date", Pattern: `\d{4}-\d{2}-\d{2}`},
+			{Type: config.MaskSequences, Name: "mask_passwords", Pattern: `password=\S+`, ReplacePlaceholder: "password=[masked]"},
+		},
+	}
+	globalRules := []*config.ProcessingRule{
+		{Type: config.ExcludeAtMatch, Name: "exclude_healthchecks", Pattern: "/health"},
+	}
+	require.NoError(t, config.CompileProcessingRules(globalRules))
+
+	results, err := Run(logsConfig, globalRules, []byte(sample))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.True(t, results[0].IsDropped())
+	assert.Equal(t, "exclude_healthchecks", results[0].DropRule)
+	assert.Empty(t, results[0].Payload)
+
+	assert.False(t, results[1].IsDropped())
+	assert.Equal(t, "2021-07-08 05:08:20,214 login failed with password=hunter2 for bob\\njava.lang.Exception: boom\\n\tat Main.main(Main.java:5)\\n", results[1].Content)
+	assert.Equal(t, []string{"mask_passwords"}, results[1].RedactingRules)
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(results[1].Payload), &payload))
+	assert.Equal(t, "2021-07-08 05:08:20,214 login failed with password=[masked] for bob\\njava.lang.Exception: boom\\n\tat Main.main(Main.java:5)\\n", payload["message"])
+	assert.Equal(t, "app", payload["service"])
+	assert.Equal(t, "java", payload["ddsource"])
+
+	assert.False(t, results[2].IsDropped())
+	assert.Empty(t, results[2].RedactingRules)
+	assert.Equal(t, "2021-07-08 05:08:21,214 done", results[2].Content)
+}
+
+func TestRunInvalidConfig(t *testing.T) {
+	logsConfig := &config.LogsConfig{
+		Type: config.FileType,
+		Path: "/var/log/app.log",
+		ProcessingRules: []*config.ProcessingRule{
+			{Type: config.MaskSequences, Name: "invalid", Pattern: "("},
+		},
+	}
+	_, err := Run(logsConfig, nil, []byte(sample))
+	assert.Error(t, err)
+}
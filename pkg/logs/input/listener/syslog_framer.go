@@ -0,0 +1,153 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listener
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// maxSyslogFrameSize is the maximum size of a syslog message,
+// bigger messages are truncated.
+const maxSyslogFrameSize = 256 * 1000
+
+// maxOctetCountLen is the maximum number of digits of the length prefix of an octet-counted frame.
+const maxOctetCountLen = 6
+
+// syslogFramer splits a stream of data into syslog messages as defined by RFC 6587,
+// either using octet counting, `MSG-LEN SP SYSLOG-MSG`, or non-transparent framing
+// where messages are terminated by a line feed.
+type syslogFramer struct {
+	buffer        []byte
+	octetCounting bool
+	// datagram reports whether each chunk of data is a whole message, as over UDP
+	datagram     bool
+	maxFrameSize int
+	// discard is the number of bytes left to drop from a truncated octet-counted frame
+	discard int
+}
+
+// newSyslogFramer returns a new framer, octet counting is only detected when enabled.
+func newSyslogFramer(octetCounting bool, maxFrameSize int) *syslogFramer {
+	return &syslogFramer{
+		octetCounting: octetCounting,
+		maxFrameSize:  maxFrameSize,
+	}
+}
+
+// newSyslogDatagramFramer returns a new framer returning each chunk of data as a single frame,
+// line feeds included.
+func newSyslogDatagramFramer(maxFrameSize int) *syslogFramer {
+	return &syslogFramer{
+		datagram:     true,
+		maxFrameSize: maxFrameSize,
+	}
+}
+
+// frames appends data to the buffered content and returns the complete frames.
+func (f *syslogFramer) frames(data []byte) [][]byte {
+	if f.datagram {
+		frame := bytes.TrimRight(data, "\r\n")
+		if len(frame) > f.maxFrameSize {
+			frame = frame[:f.maxFrameSize]
+		}
+		if len(frame) == 0 {
+			return nil
+		}
+		return [][]byte{f.copyFrame(frame)}
+	}
+	if f.discard > 0 {
+		n := min(f.discard, len(data))
+		f.discard -= n
+		data = data[n:]
+	}
+	f.buffer = append(f.buffer, data...)
+
+	var frames [][]byte
+	for len(f.buffer) > 0 {
+		frame, complete := f.next()
+		if !complete {
+			break
+		}
+		if len(frame) > 0 {
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+// flush returns the buffered content as a frame.
+func (f *syslogFramer) flush() []byte {
+	frame := bytes.TrimRight(f.buffer, "\r\n")
+	f.buffer = nil
+	if len(frame) > f.maxFrameSize {
+		frame = frame[:f.maxFrameSize]
+	}
+	return frame
+}
+
+// next extracts the next frame of the buffer, returns false if the frame is not complete yet.
+func (f *syslogFramer) next() ([]byte, bool) {
+	if f.octetCounting && f.buffer[0] >= '1' && f.buffer[0] <= '9' {
+		if frame, complete, ok := f.nextOctetCounted(); ok {
+			return frame, complete
+		}
+	}
+	end := bytes.IndexByte(f.buffer, '\n')
+	if end < 0 || end > f.maxFrameSize {
+		if len(f.buffer) < f.maxFrameSize {
+			return nil, false
+		}
+		// the message is too big, send it in several frames
+		frame := f.copyFrame(f.buffer[:f.maxFrameSize])
+		f.buffer = f.buffer[f.maxFrameSize:]
+		return frame, true
+	}
+	frame := f.copyFrame(bytes.TrimRight(f.buffer[:end], "\r"))
+	f.buffer = f.buffer[end+1:]
+	return frame, true
+}
+
+// nextOctetCounted extracts the next octet-counted frame of the buffer,
+// returns false if the buffer does not start with a valid length prefix.
+func (f *syslogFramer) nextOctetCounted() ([]byte, bool, bool) {
+	space := bytes.IndexByte(f.buffer[:min(len(f.buffer), maxOctetCountLen+1)], ' ')
+	if space < 0 {
+		if len(f.buffer) > maxOctetCountLen {
+			return nil, false, false
+		}
+		// wait for the end of the length prefix
+		return nil, false, true
+	}
+	length, err := strconv.Atoi(string(f.buffer[:space]))
+	if err != nil {
+		return nil, false, false
+	}
+	start := space + 1
+	if length > f.maxFrameSize {
+		if len(f.buffer) < start+f.maxFrameSize {
+			return nil, false, true
+		}
+		frame := f.copyFrame(f.buffer[start : start+f.maxFrameSize])
+		f.discard = length - f.maxFrameSize
+		f.buffer = f.buffer[start+f.maxFrameSize:]
+		n := min(f.discard, len(f.buffer))
+		f.discard -= n
+		f.buffer = f.buffer[n:]
+		return frame, true, true
+	}
+	if len(f.buffer) < start+length {
+		return nil, false, true
+	}
+	frame := f.copyFrame(bytes.TrimRight(f.buffer[start:start+length], "\r\n"))
+	f.buffer = f.buffer[start+length:]
+	return frame, true, true
+}
+
+// copyFrame copies the frame so that it does not share the buffer memory.
+func (f *syslogFramer) copyFrame(frame []byte) []byte {
+	return append([]byte(nil), frame...)
+}
@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listener
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func toStrings(frames [][]byte) []string {
+	var result []string
+	for _, frame := range frames {
+		result = append(result, string(frame))
+	}
+	return result
+}
+
+func TestSyslogFramerNonTransparent(t *testing.T) {
+	framer := newSyslogFramer(true, 100)
+
+	assert.Equal(t, []string{"<13>1 - - - - - - foo", "<13>1 - - - - - - bar"}, toStrings(framer.frames([]byte("<13>1 - - - - - - foo\r\n<13>1 - - - - - - bar\n<13>1 - - -"))))
+	assert.Equal(t, []string{"<13>1 - - - - - - baz"}, toStrings(framer.frames([]byte(" - - - baz\n"))))
+	assert.Empty(t, framer.frames([]byte("<13>incomplete")))
+	assert.Equal(t, "<13>incomplete", string(framer.flush()))
+}
+
+func TestSyslogFramerOctetCounting(t *testing.T) {
+	framer := newSyslogFramer(true, 100)
+
+	assert.Equal(t, []string{"<13>1 - - - - - - a\nb"}, toStrings(framer.frames([]byte("21 <13>1 - - - - - - a\nb20 <13>1 - - - - - - "))))
+	assert.Equal(t, []string{"<13>1 - - - - - - cd", "<13>1 - - - - - - e"}, toStrings(framer.frames([]byte("cd19 <13>1 - - - - - - e"))))
+
+	// the octet count is split between reads
+	assert.Empty(t, framer.frames([]byte("1")))
+	assert.Equal(t, []string{"<13>1 - - - - - - f"}, toStrings(framer.frames([]byte("9 <13>1 - - - - - - f"))))
+
+	// mixing both framings
+	assert.Equal(t, []string{"<13>line", "<13>1 - - - - - - g"}, toStrings(framer.frames([]byte("<13>line\n19 <13>1 - - - - - - g"))))
+}
+
+func TestSyslogFramerOctetCountingDisabled(t *testing.T) {
+	framer := newSyslogFramer(false, 100)
+	assert.Equal(t, []string{"5 hello"}, toStrings(framer.frames([]byte("5 hello\n"))))
+}
+
+func TestSyslogFramerTruncatesBigFrames(t *testing.T) {
+	framer := newSyslogFramer(true, 5)
+
+	assert.Equal(t, []string{"abcde"}, toStrings(framer.frames([]byte("12 abcdefg"))))
+	assert.Equal(t, []string{"<1>ok"}, toStrings(framer.frames([]byte("hijkl5 <1>ok"))))
+
+	assert.Equal(t, []string{"abcde", "fgh"}, toStrings(framer.frames([]byte("abcdefgh\n"))))
+}
+
+func TestSyslogDatagramFramer(t *testing.T) {
+	framer := newSyslogDatagramFramer(100)
+	assert.Equal(t, []string{"<14>1 - host app - - - first\nsecond"}, toStrings(framer.frames([]byte("<14>1 - host app - - - first\nsecond\n"))))
+	assert.Equal(t, []string{"5 hello"}, toStrings(framer.frames([]byte("5 hello"))))
+	assert.Empty(t, framer.frames([]byte("\n")))
+
+	framer = newSyslogDatagramFramer(5)
+	assert.Equal(t, []string{"abcde"}, toStrings(framer.frames([]byte("abcdefgh\n"))))
+	assert.Equal(t, []string{"ij"}, toStrings(framer.frames([]byte("ij\n"))))
+}
@@ -0,0 +1,246 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listener
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// syslogNilValue is the RFC 5424 value of an empty header field.
+const syslogNilValue = "-"
+
+// syslogBOM is the UTF-8 byte order mark that can prefix RFC 5424 messages.
+var syslogBOM = []byte{0xEF, 0xBB, 0xBF}
+
+// syslogSeverityStatuses maps the syslog severities to the log statuses.
+var syslogSeverityStatuses = []string{
+	message.StatusEmergency,
+	message.StatusAlert,
+	message.StatusCritical,
+	message.StatusError,
+	message.StatusWarning,
+	message.StatusNotice,
+	message.StatusInfo,
+	message.StatusDebug,
+}
+
+// syslogMessage holds the fields of a syslog message.
+type syslogMessage struct {
+	facility       int
+	severity       int
+	version        int
+	timestamp      time.Time
+	hostname       string
+	appName        string
+	procID         string
+	msgID          string
+	structuredData map[string]map[string]string
+	content        []byte
+}
+
+// status returns the log status matching the severity of the message.
+func (m *syslogMessage) status() string {
+	return syslogSeverityStatuses[m.severity]
+}
+
+// attributes returns the header fields of the message as log attributes.
+func (m *syslogMessage) attributes() map[string]interface{} {
+	attributes := map[string]interface{}{
+		"facility": m.facility,
+		"severity": m.severity,
+	}
+	if m.version > 0 {
+		attributes["version"] = m.version
+	}
+	for key, value := range map[string]string{
+		"hostname": m.hostname,
+		"appname":  m.appName,
+		"procid":   m.procID,
+		"msgid":    m.msgID,
+	} {
+		if value != "" {
+			attributes[key] = value
+		}
+	}
+	if len(m.structuredData) > 0 {
+		attributes["structured_data"] = m.structuredData
+	}
+	return map[string]interface{}{"syslog": attributes}
+}
+
+// parseSyslog parses an RFC 5424 or an RFC 3164 message,
+// returns false if the frame does not start with a valid priority.
+func parseSyslog(frame []byte) (*syslogMessage, bool) {
+	pri, rest, ok := parsePriority(frame)
+	if !ok {
+		return nil, false
+	}
+	msg := &syslogMessage{
+		facility: pri / 8,
+		severity: pri % 8,
+	}
+	// RFC 5424 messages define a version right after the priority
+	if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' && rest[1] == ' ' {
+		msg.version = int(rest[0] - '0')
+		parseRFC5424(msg, rest[2:])
+	} else {
+		parseRFC3164(msg, rest)
+	}
+	return msg, true
+}
+
+// parsePriority parses the `<PRI>` prefix of a message.
+func parsePriority(frame []byte) (int, []byte, bool) {
+	if len(frame) < 3 || frame[0] != '<' {
+		return 0, nil, false
+	}
+	end := bytes.IndexByte(frame[:min(len(frame), 5)], '>')
+	if end < 2 {
+		return 0, nil, false
+	}
+	pri, err := strconv.Atoi(string(frame[1:end]))
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, nil, false
+	}
+	return pri, frame[end+1:], true
+}
+
+// parseRFC5424 parses `TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]`.
+func parseRFC5424(msg *syslogMessage, rest []byte) {
+	var fields [5]string
+	for i := range fields {
+		fields[i], rest = nextField(rest)
+	}
+	if timestamp, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+		msg.timestamp = timestamp.UTC()
+	}
+	msg.hostname = nilToEmpty(fields[1])
+	msg.appName = nilToEmpty(fields[2])
+	msg.procID = nilToEmpty(fields[3])
+	msg.msgID = nilToEmpty(fields[4])
+	msg.structuredData, rest = parseStructuredData(rest)
+	if len(rest) > 0 && rest[0] == ' ' {
+		rest = rest[1:]
+	}
+	msg.content = bytes.TrimPrefix(rest, syslogBOM)
+}
+
+// parseRFC3164 parses `TIMESTAMP HOSTNAME TAG[PID]: MSG`, falling back to the raw content
+// for the parts that can not be parsed.
+func parseRFC3164(msg *syslogMessage, rest []byte) {
+	// the timestamp has the `Mmm dd hh:mm:ss` format and does not define any year nor timezone
+	const timestampLen = len(time.Stamp)
+	if len(rest) > timestampLen && rest[timestampLen] == ' ' {
+		if _, err := time.Parse(time.Stamp, string(rest[:timestampLen])); err == nil {
+			rest = rest[timestampLen+1:]
+			msg.hostname, rest = nextField(rest)
+		}
+	}
+	// the tag is made of alphanumeric characters and is terminated by `[PID]:` or `:`
+	if end := bytes.IndexByte(rest, ':'); end > 0 && bytes.IndexByte(rest[:end], ' ') < 0 {
+		tag := rest[:end]
+		if open := bytes.IndexByte(tag, '['); open > 0 && tag[len(tag)-1] == ']' {
+			msg.procID = string(tag[open+1 : len(tag)-1])
+			tag = tag[:open]
+		}
+		msg.appName = string(tag)
+		rest = bytes.TrimPrefix(rest[end+1:], []byte(" "))
+	}
+	msg.content = rest
+}
+
+// parseStructuredData parses the `[SD-ID PARAM="VALUE" ...]` elements of an RFC 5424 message.
+func parseStructuredData(rest []byte) (map[string]map[string]string, []byte) {
+	if len(rest) > 0 && rest[0] == '-' {
+		return nil, rest[1:]
+	}
+	var data map[string]map[string]string
+	for len(rest) > 0 && rest[0] == '[' {
+		end := structuredDataElementEnd(rest)
+		if end < 0 {
+			break
+		}
+		id, params := parseStructuredDataElement(rest[1:end])
+		if data == nil {
+			data = make(map[string]map[string]string)
+		}
+		data[id] = params
+		rest = rest[end+1:]
+	}
+	return data, rest
+}
+
+// structuredDataElementEnd returns the index of the `]` closing the element, ignoring escaped characters.
+func structuredDataElementEnd(rest []byte) int {
+	inValue := false
+	for i := 1; i < len(rest); i++ {
+		switch rest[i] {
+		case '\\':
+			i++
+		case '"':
+			inValue = !inValue
+		case ']':
+			if !inValue {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseStructuredDataElement parses `SD-ID PARAM="VALUE" ...`.
+func parseStructuredDataElement(element []byte) (string, map[string]string) {
+	id, rest := nextField(element)
+	params := make(map[string]string)
+	for len(rest) > 0 {
+		eq := bytes.IndexByte(rest, '=')
+		if eq < 0 || eq+1 >= len(rest) || rest[eq+1] != '"' {
+			break
+		}
+		name := string(bytes.TrimSpace(rest[:eq]))
+		var value []byte
+		i := eq + 2
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+			}
+			value = append(value, rest[i])
+		}
+		params[name] = string(value)
+		if i+1 >= len(rest) {
+			break
+		}
+		rest = rest[i+1:]
+	}
+	return id, params
+}
+
+// nextField returns the content until the next space and the content after it.
+func nextField(rest []byte) (string, []byte) {
+	end := bytes.IndexByte(rest, ' ')
+	if end < 0 {
+		return string(rest), nil
+	}
+	return string(rest[:end]), rest[end+1:]
+}
+
+func nilToEmpty(value string) string {
+	if value == syslogNilValue {
+		return ""
+	}
+	return value
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
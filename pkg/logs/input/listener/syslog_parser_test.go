@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listener
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func TestParseSyslogRFC5424(t *testing.T) {
+	frame := []byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high \"q\""] ` + "\xEF\xBB\xBF" + `An application event log entry...`)
+	msg, ok := parseSyslog(frame)
+	require.True(t, ok)
+
+	assert.Equal(t, 20, msg.facility)
+	assert.Equal(t, 5, msg.severity)
+	assert.Equal(t, message.StatusNotice, msg.status())
+	assert.Equal(t, 1, msg.version)
+	assert.Equal(t, time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC), msg.timestamp)
+	assert.Equal(t, "mymachine.example.com", msg.hostname)
+	assert.Equal(t, "evntslog", msg.appName)
+	assert.Equal(t, "1234", msg.procID)
+	assert.Equal(t, "ID47", msg.msgID)
+	assert.Equal(t, map[string]map[string]string{
+		"exampleSDID@32473":     {"iut": "3", "eventSource": "Application", "eventID": "1011"},
+		"examplePriority@32473": {"class": `high "q"`},
+	}, msg.structuredData)
+	assert.Equal(t, "An application event log entry...", string(msg.content))
+}
+
+func TestParseSyslogRFC5424NilValues(t *testing.T) {
+	msg, ok := parseSyslog([]byte(`<34>1 - - su - - - 'su root' failed`))
+	require.True(t, ok)
+
+	assert.Equal(t, message.StatusCritical, msg.status())
+	assert.True(t, msg.timestamp.IsZero())
+	assert.Equal(t, "", msg.hostname)
+	assert.Equal(t, "su", msg.appName)
+	assert.Equal(t, "", msg.procID)
+	assert.Nil(t, msg.structuredData)
+	assert.Equal(t, "'su root' failed", string(msg.content))
+	assert.Equal(t, map[string]interface{}{
+		"syslog": map[string]interface{}{"facility": 4, "severity": 2, "version": 1, "appname": "su"},
+	}, msg.attributes())
+}
+
+func TestParseSyslogRFC3164(t *testing.T) {
+	msg, ok := parseSyslog([]byte(`<34>Oct 11 22:14:15 mymachine su[42]: 'su root' failed for lonvick on /dev/pts/8`))
+	require.True(t, ok)
+
+	assert.Equal(t, 4, msg.facility)
+	assert.Equal(t, 2, msg.severity)
+	assert.Equal(t, 0, msg.version)
+	assert.Equal(t, "mymachine", msg.hostname)
+	assert.Equal(t, "su", msg.appName)
+	assert.Equal(t, "42", msg.procID)
+	assert.Equal(t, "'su root' failed for lonvick on /dev/pts/8", string(msg.content))
+
+	msg, ok = parseSyslog([]byte(`<13>Oct  1 02:04:05 host a message: without tag`))
+	require.True(t, ok)
+	assert.Equal(t, "host", msg.hostname)
+	assert.Equal(t, "", msg.appName)
+	assert.Equal(t, "a message: without tag", string(msg.content))
+
+	msg, ok = parseSyslog([]byte(`<13>no header at all`))
+	require.True(t, ok)
+	assert.Equal(t, message.StatusNotice, msg.status())
+	assert.Equal(t, "no header at all", string(msg.content))
+}
+
+func TestParseSyslogInvalidPriority(t *testing.T) {
+	for _, frame := range []string{"", "hello", "<>1 - - - - - -", "<192>1 - - - - - -", "<abc>hello", "<1234567"} {
+		_, ok := parseSyslog([]byte(frame))
+		assert.False(t, ok, frame)
+	}
+}
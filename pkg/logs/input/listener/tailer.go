@@ -8,6 +8,7 @@ package listener
 import (
 	"io"
 	"net"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
@@ -24,6 +25,7 @@ type Tailer struct {
 	outputChan chan *message.Message
 	read       func(*Tailer) ([]byte, error)
 	decoder    *decoder.Decoder
+	framer     *syslogFramer
 	stop       chan struct{}
 	done       chan struct{}
 }
@@ -41,8 +43,40 @@ func NewTailer(source *config.LogSource, conn net.Conn, outputChan chan *message
 	}
 }
 
+// NewSyslogTailer returns a new Tailer parsing syslog messages,
+// octet-counted framing is supported when octetCounting is true.
+func NewSyslogTailer(source *config.LogSource, conn net.Conn, outputChan chan *message.Message, read func(*Tailer) ([]byte, error), octetCounting bool) *Tailer {
+	return &Tailer{
+		source:     source,
+		conn:       conn,
+		outputChan: outputChan,
+		read:       read,
+		framer:     newSyslogFramer(octetCounting, maxSyslogFrameSize),
+		stop:       make(chan struct{}, 1),
+		done:       make(chan struct{}, 1),
+	}
+}
+
+// NewSyslogDatagramTailer returns a new Tailer parsing syslog messages,
+// each read holding a single message which can contain line feeds.
+func NewSyslogDatagramTailer(source *config.LogSource, conn net.Conn, outputChan chan *message.Message, read func(*Tailer) ([]byte, error)) *Tailer {
+	return &Tailer{
+		source:     source,
+		conn:       conn,
+		outputChan: outputChan,
+		read:       read,
+		framer:     newSyslogDatagramFramer(maxSyslogFrameSize),
+		stop:       make(chan struct{}, 1),
+		done:       make(chan struct{}, 1),
+	}
+}
+
 // Start prepares the tailer to read and decode data from the connection
 func (t *Tailer) Start() {
+	if t.framer != nil {
+		go t.readFramesForever()
+		return
+	}
 	go t.forwardMessages()
 	t.decoder.Start()
 	go t.readForever()
@@ -95,3 +129,52 @@ func (t *Tailer) readForever() {
 		}
 	}
 }
+
+// readFramesForever reads the data from conn and forwards the syslog messages,
+// syslog messages are not split in lines by a decoder as they can contain line feeds.
+func (t *Tailer) readFramesForever() {
+	defer func() {
+		t.conn.Close()
+		if frame := t.framer.flush(); len(frame) > 0 {
+			t.forwardSyslogMessage(frame)
+		}
+		t.done <- struct{}{}
+	}()
+	for {
+		select {
+		case <-t.stop:
+			// stop reading data from the connection
+			return
+		default:
+			data, err := t.read(t)
+			if err != nil && err == io.EOF {
+				// connection has been closed client-side, stop from reading new data
+				return
+			}
+			if err != nil {
+				// an error occurred, stop from reading new data
+				log.Warnf("Couldn't read message from connection: %v", err)
+				return
+			}
+			t.source.BytesRead.Add(int64(len(data)))
+			for _, frame := range t.framer.frames(data) {
+				t.forwardSyslogMessage(frame)
+			}
+		}
+	}
+}
+
+// forwardSyslogMessage parses the syslog message and forwards it to the output channel,
+// frames that are not valid syslog messages are forwarded as is.
+func (t *Tailer) forwardSyslogMessage(frame []byte) {
+	ingestionTimestamp := time.Now().UnixNano()
+	syslogMsg, ok := parseSyslog(frame)
+	if !ok {
+		t.outputChan <- message.NewMessageWithSource(frame, message.StatusInfo, t.source, ingestionTimestamp)
+		return
+	}
+	msg := message.NewMessageWithSource(syslogMsg.content, syslogMsg.status(), t.source, ingestionTimestamp)
+	msg.Timestamp = syslogMsg.timestamp
+	msg.Attributes = syslogMsg.attributes()
+	t.outputChan <- msg
+}
@@ -141,7 +141,12 @@ func (l *TCPListener) read(tailer *Tailer) ([]byte, error) {
 func (l *TCPListener) startTailer(conn net.Conn) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	tailer := NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read)
+	var tailer *Tailer
+	if l.source.Config.Format == config.SyslogFormat {
+		tailer = NewSyslogTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read, true)
+	} else {
+		tailer = NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read)
+	}
 	l.tailers = append(l.tailers, tailer)
 	tailer.Start()
 }
@@ -65,3 +65,28 @@ func TestTCPDoesNotTruncateMessagesThatAreBiggerThanTheReadBufferSize(t *testing
 
 	listener.Stop()
 }
+
+func TestTCPShouldReceivesSyslogMessages(t *testing.T) {
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewTCPListener(pp, config.NewLogSource("", &config.LogsConfig{Port: tcpTestPort, Format: config.SyslogFormat}), 9000)
+	listener.Start()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("%s", listener.listener.Addr()))
+	assert.Nil(t, err)
+
+	var msg *message.Message
+
+	fmt.Fprintf(conn, "<11>Oct 11 22:14:15 mymachine su: 'su root' failed\n")
+	msg = <-msgChan
+	assert.Equal(t, "'su root' failed", string(msg.Content))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+
+	// octet-counted messages can contain line feeds
+	fmt.Fprintf(conn, "35 <14>1 - host app - - - first\nsecond")
+	msg = <-msgChan
+	assert.Equal(t, "first\nsecond", string(msg.Content))
+	assert.Equal(t, message.StatusInfo, msg.GetStatus())
+
+	listener.Stop()
+}
@@ -67,7 +67,12 @@ func (l *UDPListener) startNewTailer() error {
 	if err != nil {
 		return err
 	}
-	l.tailer = NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read)
+	if l.source.Config.Format == config.SyslogFormat {
+		// each datagram holds a single message, which is not split on line feeds
+		l.tailer = NewSyslogDatagramTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read)
+	} else {
+		l.tailer = NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read)
+	}
 	l.tailer.Start()
 	return nil
 }
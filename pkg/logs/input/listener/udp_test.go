@@ -37,3 +37,28 @@ func TestUDPShouldReceiveMessage(t *testing.T) {
 
 	listener.Stop()
 }
+
+func TestUDPShouldReceiveSyslogMessages(t *testing.T) {
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewUDPListener(pp, config.NewLogSource("", &config.LogsConfig{Port: udpTestPort, Format: config.SyslogFormat}), 9000)
+	listener.Start()
+
+	conn, err := net.Dial("udp", fmt.Sprintf("%s", listener.tailer.conn.LocalAddr()))
+	assert.Nil(t, err)
+
+	var msg *message.Message
+
+	fmt.Fprintf(conn, "<11>Oct 11 22:14:15 mymachine su: 'su root' failed")
+	msg = <-msgChan
+	assert.Equal(t, "'su root' failed", string(msg.Content))
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+
+	// a datagram holds a single message, which can contain line feeds
+	fmt.Fprintf(conn, "<14>1 - host app - - - first\n  second\n")
+	msg = <-msgChan
+	assert.Equal(t, "first\n  second", string(msg.Content))
+	assert.Equal(t, message.StatusInfo, msg.GetStatus())
+
+	listener.Stop()
+}
@@ -25,6 +25,11 @@ type Message struct {
 	// Optional.
 	// Used in the Serverless Agent
 	Lambda *Lambda
+	// Optional. Structured attributes extracted from the content by the processing rules
+	Attributes map[string]interface{}
+	// Optional. Content of the message once redacted by the processing rules, before it is encoded.
+	// Used by the destinations encoding the messages themselves.
+	RedactedContent []byte
 }
 
 // Lambda is a struct storing information about the Lambda function and function execution.
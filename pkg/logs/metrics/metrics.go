@@ -24,6 +24,16 @@ var (
 	// TlmLogsProcessed is the total number of processed logs.
 	TlmLogsProcessed = telemetry.NewCounter("logs", "processed",
 		nil, "Total number of processed logs")
+	// LogsSampledOut is the total number of logs dropped by sampling rules.
+	LogsSampledOut = expvar.Int{}
+	// TlmLogsSampledOut is the total number of logs dropped by sampling rules.
+	TlmLogsSampledOut = telemetry.NewCounter("logs", "sampled_out",
+		nil, "Total number of logs dropped by sampling rules")
+	// LogsRateLimited is the total number of logs dropped by rate limiting rules.
+	LogsRateLimited = expvar.Int{}
+	// TlmLogsRateLimited is the total number of logs dropped by rate limiting rules.
+	TlmLogsRateLimited = telemetry.NewCounter("logs", "rate_limited",
+		nil, "Total number of logs dropped by rate limiting rules")
 
 	// LogsSent is the total number of sent logs.
 	LogsSent = expvar.Int{}
@@ -64,6 +74,8 @@ func init() {
 	LogsExpvars = expvar.NewMap("logs-agent")
 	LogsExpvars.Set("LogsDecoded", &LogsDecoded)
 	LogsExpvars.Set("LogsProcessed", &LogsProcessed)
+	LogsExpvars.Set("LogsSampledOut", &LogsSampledOut)
+	LogsExpvars.Set("LogsRateLimited", &LogsRateLimited)
 	LogsExpvars.Set("LogsSent", &LogsSent)
 	LogsExpvars.Set("DestinationErrors", &DestinationErrors)
 	LogsExpvars.Set("DestinationLogsDropped", &DestinationLogsDropped)
@@ -7,15 +7,20 @@ package pipeline
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/diskbuffer"
 	"github.com/DataDog/datadog-agent/pkg/logs/client/http"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/otlp"
 	"github.com/DataDog/datadog-agent/pkg/logs/client/tcp"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/diagnostic"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
 	"github.com/DataDog/datadog-agent/pkg/logs/processor"
 	"github.com/DataDog/datadog-agent/pkg/logs/sender"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
 // Pipeline processes and sends messages to the backend
@@ -29,12 +34,17 @@ type Pipeline struct {
 func NewPipeline(outputChan chan *message.Message, processingRules []*config.ProcessingRule, endpoints *config.Endpoints, destinationsContext *client.DestinationsContext, diagnosticMessageReceiver diagnostic.MessageReceiver, serverless bool, pipelineID int) *Pipeline {
 	var destinations *client.Destinations
 	if endpoints.UseHTTP {
-		main := http.NewDestination(endpoints.Main, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend)
+		main := withDiskBuffer(http.NewDestination(endpoints.Main, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend), "main", endpoints, destinationsContext, pipelineID)
 		additionals := []client.Destination{}
-		for _, endpoint := range endpoints.Additionals {
-			additionals = append(additionals, http.NewDestination(endpoint, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend))
+		for i, endpoint := range endpoints.Additionals {
+			destination := http.NewDestination(endpoint, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend)
+			additionals = append(additionals, withDiskBuffer(destination, fmt.Sprintf("additional_%d", i), endpoints, destinationsContext, pipelineID))
 		}
 		destinations = client.NewDestinations(main, additionals)
+		if useOTLP(endpoints, serverless) {
+			// logs are dual-shipped on a best effort basis, without retries nor acknowledgments
+			destinations.MessagesAdditionals = []client.MessagesDestination{otlp.NewDestination(endpoints.OTLPHTTPEndpoint, endpoints.OTLPHTTPHeaders, destinationsContext)}
+		}
 	} else {
 		main := tcp.NewDestination(endpoints.Main, endpoints.UseProto, destinationsContext)
 		additionals := []client.Destination{}
@@ -64,6 +74,10 @@ func NewPipeline(outputChan chan *message.Message, processingRules []*config.Pro
 	} else {
 		encoder = processor.RawEncoder
 	}
+	if useOTLP(endpoints, serverless) {
+		// the OTLP destination encodes the redacted content of the messages
+		encoder = processor.WithRedactedContent(encoder)
+	}
 
 	inputChan := make(chan *message.Message, config.ChanSize)
 	processor := processor.New(inputChan, senderChan, processingRules, encoder, diagnosticMessageReceiver)
@@ -75,6 +89,26 @@ func NewPipeline(outputChan chan *message.Message, processingRules []*config.Pro
 	}
 }
 
+// useOTLP reports whether the logs are also sent to an OTLP/HTTP receiver.
+func useOTLP(endpoints *config.Endpoints, serverless bool) bool {
+	return endpoints.UseHTTP && endpoints.OTLPHTTPEndpoint != "" && !serverless
+}
+
+// withDiskBuffer wraps the destination to store on disk the payloads that can't be sent when the disk buffer is enabled,
+// each destination of each pipeline uses its own directory.
+func withDiskBuffer(destination client.Destination, name string, endpoints *config.Endpoints, destinationsContext *client.DestinationsContext, pipelineID int) client.Destination {
+	if endpoints.DiskBufferMaxSizeInBytes <= 0 {
+		return destination
+	}
+	path := filepath.Join(endpoints.DiskBufferPath, fmt.Sprintf("pipeline_%d", pipelineID), name)
+	buffered, err := diskbuffer.NewDestination(destination, path, endpoints.DiskBufferMaxSizeInBytes, endpoints.DiskBufferMaxDiskRatio, endpoints.DiskBufferDropPolicy, destinationsContext)
+	if err != nil {
+		log.Errorf("Could not create the logs buffer at %s, payloads will be retried in memory: %v", path, err)
+		return destination
+	}
+	return buffered
+}
+
 // Start launches the pipeline
 func (p *Pipeline) Start() {
 	p.sender.Start()
@@ -0,0 +1,233 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// extractFields adds the named capture groups of the rule matching content to the message attributes.
+func extractFields(msg *message.Message, rule *config.ProcessingRule, content []byte) {
+	match := rule.Regex.FindSubmatchIndex(content)
+	if match == nil {
+		return
+	}
+	for i, name := range rule.Regex.SubexpNames() {
+		if name == "" || match[2*i] < 0 {
+			continue
+		}
+		if msg.Attributes == nil {
+			msg.Attributes = make(map[string]interface{})
+		}
+		msg.Attributes[name] = string(content[match[2*i]:match[2*i+1]])
+	}
+}
+
+// remapAttribute moves the value at the source key of the rule to its target key,
+// both in the message attributes and, when the content is a JSON object, in the content.
+// It returns the content, updated if a key was moved.
+func remapAttribute(msg *message.Message, rule *config.ProcessingRule, content []byte) []byte {
+	from, to := strings.Split(rule.SourceKey, "."), strings.Split(rule.TargetKey, ".")
+	if msg.Attributes != nil {
+		moveKey(msg.Attributes, from, to)
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return content
+	}
+	if remapped, moved := moveJSONKey(trimmed, from, to); moved {
+		return remapped
+	}
+	return content
+}
+
+// moveKey moves the value found at path from to path to, creating the intermediate
+// objects if needed, and returns true if a value was moved.
+func moveKey(object map[string]interface{}, from, to []string) bool {
+	parent := lookupParent(object, from, false)
+	if parent == nil {
+		return false
+	}
+	value, found := parent[from[len(from)-1]]
+	if !found {
+		return false
+	}
+	target := lookupParent(object, to, true)
+	if target == nil {
+		// an intermediate key of the target path is not an object
+		return false
+	}
+	delete(parent, from[len(from)-1])
+	target[to[len(to)-1]] = value
+	return true
+}
+
+// lookupParent returns the object holding the last key of path,
+// creating the missing intermediate objects when create is true.
+func lookupParent(object map[string]interface{}, path []string, create bool) map[string]interface{} {
+	current := object
+	for _, key := range path[:len(path)-1] {
+		next, found := current[key]
+		if !found {
+			if !create {
+				return nil
+			}
+			child := make(map[string]interface{})
+			current[key] = child
+			current = child
+			continue
+		}
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = child
+	}
+	return current
+}
+
+// jsonField is a field of a JSON object, its value is left as is.
+type jsonField struct {
+	key   string
+	value json.RawMessage
+}
+
+// moveJSONKey moves the value found at path from to path to in the given JSON object, creating
+// the intermediate objects if needed. The order of the keys and the values which are not on the
+// paths are kept as is, the moved key is added last. It returns false if no value was moved.
+func moveJSONKey(content []byte, from, to []string) ([]byte, bool) {
+	fields, ok := parseJSONObject(content)
+	if !ok {
+		return nil, false
+	}
+	fields, value, ok := removeJSONField(fields, from)
+	if !ok {
+		return nil, false
+	}
+	if fields, ok = setJSONField(fields, to, value); !ok {
+		// an intermediate key of the target path is not an object
+		return nil, false
+	}
+	return encodeJSONObject(fields), true
+}
+
+// removeJSONField removes the field at path and returns its value.
+func removeJSONField(fields []jsonField, path []string) ([]jsonField, json.RawMessage, bool) {
+	i := indexJSONField(fields, path[0])
+	if i < 0 {
+		return nil, nil, false
+	}
+	if len(path) == 1 {
+		value := fields[i].value
+		return append(fields[:i:i], fields[i+1:]...), value, true
+	}
+	child, ok := parseJSONObject(fields[i].value)
+	if !ok {
+		return nil, nil, false
+	}
+	child, value, ok := removeJSONField(child, path[1:])
+	if !ok {
+		return nil, nil, false
+	}
+	fields[i].value = encodeJSONObject(child)
+	return fields, value, true
+}
+
+// setJSONField sets the value of the field at path, creating the missing intermediate objects.
+func setJSONField(fields []jsonField, path []string, value json.RawMessage) ([]jsonField, bool) {
+	i := indexJSONField(fields, path[0])
+	if len(path) == 1 {
+		if i < 0 {
+			return append(fields, jsonField{key: path[0], value: value}), true
+		}
+		fields[i].value = value
+		return fields, true
+	}
+	var child []jsonField
+	if i >= 0 {
+		var ok bool
+		if child, ok = parseJSONObject(fields[i].value); !ok {
+			return nil, false
+		}
+	}
+	child, ok := setJSONField(child, path[1:], value)
+	if !ok {
+		return nil, false
+	}
+	if i < 0 {
+		return append(fields, jsonField{key: path[0], value: encodeJSONObject(child)}), true
+	}
+	fields[i].value = encodeJSONObject(child)
+	return fields, true
+}
+
+func indexJSONField(fields []jsonField, key string) int {
+	for i, field := range fields {
+		if field.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseJSONObject returns the fields of a JSON object, in order, without decoding their values.
+func parseJSONObject(data []byte) ([]jsonField, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, false
+	}
+	var fields []jsonField
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, false
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, false
+		}
+		fields = append(fields, jsonField{key: key, value: value})
+	}
+	if token, err := decoder.Token(); err != nil || token != json.Delim('}') {
+		return nil, false
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		// the content is not a single JSON object
+		return nil, false
+	}
+	return fields, true
+}
+
+// encodeJSONObject returns the JSON object made of fields, the keys are not HTML escaped.
+func encodeJSONObject(fields []jsonField) []byte {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	buf.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		// the encoder can't fail on a string, it adds a newline which is removed
+		_ = encoder.Encode(field.key)
+		buf.Truncate(buf.Len() - 1)
+		buf.WriteByte(':')
+		buf.Write(field.value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
@@ -17,6 +17,23 @@ type Encoder interface {
 	Encode(msg *message.Message, redactedMsg []byte) ([]byte, error)
 }
 
+// WithRedactedContent returns an encoder keeping the redacted content on the messages it encodes,
+// for the destinations encoding the messages themselves.
+func WithRedactedContent(encoder Encoder) Encoder {
+	return &redactedContentEncoder{encoder: encoder}
+}
+
+// redactedContentEncoder sets the redacted content of the messages before encoding them.
+type redactedContentEncoder struct {
+	encoder Encoder
+}
+
+// Encode encodes the message with the wrapped encoder.
+func (e *redactedContentEncoder) Encode(msg *message.Message, redactedMsg []byte) ([]byte, error) {
+	msg.RedactedContent = redactedMsg
+	return e.encoder.Encode(msg, redactedMsg)
+}
+
 // toValidUtf8 ensures all characters are UTF-8.
 func toValidUtf8(msg []byte) string {
 	if utf8.Valid(msg) {
@@ -221,6 +221,46 @@ func TestJsonEncoder(t *testing.T) {
 	assert.NotEmpty(t, log.Timestamp)
 }
 
+func TestJsonEncoderWithAttributes(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{Service: "Service"})
+	msg := newMessage([]byte("message"), source, message.StatusInfo)
+	msg.Attributes = map[string]interface{}{
+		"user":    "bob",
+		"http":    map[string]interface{}{"status_code": "200"},
+		"service": "override",
+	}
+
+	jsonMessage, err := JSONEncoder.Encode(msg, []byte("redacted"))
+	assert.Nil(t, err)
+
+	var log map[string]interface{}
+	err = json.Unmarshal(jsonMessage, &log)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "redacted", log["message"])
+	assert.Equal(t, "Service", log["service"])
+	assert.Equal(t, "bob", log["user"])
+	assert.Equal(t, map[string]interface{}{"status_code": "200"}, log["http"])
+}
+
+func TestWithRedactedContent(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{})
+	msg := newMessage([]byte("message"), source, message.StatusInfo)
+
+	encoded, err := WithRedactedContent(JSONEncoder).Encode(msg, []byte("redacted"))
+	assert.Nil(t, err)
+	assert.Equal(t, "redacted", string(msg.RedactedContent))
+
+	expected, err := JSONEncoder.Encode(msg, []byte("redacted"))
+	assert.Nil(t, err)
+	var got, want map[string]interface{}
+	assert.Nil(t, json.Unmarshal(encoded, &got))
+	assert.Nil(t, json.Unmarshal(expected, &want))
+	delete(got, "timestamp")
+	delete(want, "timestamp")
+	assert.Equal(t, want, got)
+}
+
 func TestEncoderToValidUTF8(t *testing.T) {
 	assert.Equal(t, "a�z", toValidUtf8([]byte("a\xfez")))
 	assert.Equal(t, "a��z", toValidUtf8([]byte("a\xc0\xafz")))
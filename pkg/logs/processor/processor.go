@@ -6,6 +6,7 @@
 package processor
 
 import (
+	"bytes"
 	"context"
 	"sync"
 
@@ -104,23 +105,75 @@ func (p *Processor) processMessage(msg *message.Message) {
 }
 
 // applyRedactingRules returns given a message if we should process it or not,
-// and a copy of the message with some fields redacted, depending on config
+// and a copy of the message with some fields redacted, depending on config.
+// Extraction and remapping rules update the attributes of the message.
 func (p *Processor) applyRedactingRules(msg *message.Message) (bool, []byte) {
+	dropRule, content := p.applyRules(msg, nil)
+	return dropRule == nil, content
+}
+
+// applyRules applies the processing rules to the message and returns the rule dropping it if any,
+// and the redacted content. When set, onRedact is called with the rules modifying the content.
+func (p *Processor) applyRules(msg *message.Message, onRedact func(rule *config.ProcessingRule)) (*config.ProcessingRule, []byte) {
 	content := msg.Content
 	rules := append(p.processingRules, msg.Origin.LogSource.Config.ProcessingRules...)
 	for _, rule := range rules {
+		redacted := content
 		switch rule.Type {
 		case config.ExcludeAtMatch:
 			if rule.Regex.Match(content) {
-				return false, nil
+				return rule, nil
 			}
 		case config.IncludeAtMatch:
 			if !rule.Regex.Match(content) {
-				return false, nil
+				return rule, nil
 			}
 		case config.MaskSequences:
-			content = rule.Regex.ReplaceAll(content, rule.Placeholder)
+			redacted = rule.Regex.ReplaceAll(content, rule.Placeholder)
+		case config.ExtractFields:
+			extractFields(msg, rule, content)
+		case config.RemapAttribute:
+			redacted = remapAttribute(msg, rule, content)
+		case config.Sample, config.RateLimit:
+			if !p.keep(msg, rule, content) {
+				return rule, nil
+			}
 		}
+		if onRedact != nil && !bytes.Equal(redacted, content) {
+			onRedact(rule)
+		}
+		content = redacted
+	}
+	return nil, content
+}
+
+// DryRun applies the processing rules to the message and encodes it without sending it,
+// it returns the rule dropping the message if any, the rules modifying its content and the encoded message.
+func (p *Processor) DryRun(msg *message.Message) (*config.ProcessingRule, []*config.ProcessingRule, []byte, error) {
+	var redactingRules []*config.ProcessingRule
+	dropRule, redactedMsg := p.applyRules(msg, func(rule *config.ProcessingRule) {
+		redactingRules = append(redactingRules, rule)
+	})
+	if dropRule != nil {
+		return dropRule, redactingRules, nil, nil
+	}
+	content, err := p.encoder.Encode(msg, redactedMsg)
+	return nil, redactingRules, content, err
+}
+
+// keep returns false if the message matches the sampling or rate limiting rule
+// and is dropped by the sampler of its source.
+func (p *Processor) keep(msg *message.Message, rule *config.ProcessingRule, content []byte) bool {
+	sampler := msg.Origin.LogSource.Sampler
+	if sampler == nil || !rule.Regex.Match(content) || sampler.Keep(rule) {
+		return true
+	}
+	if rule.Type == config.Sample {
+		metrics.LogsSampledOut.Add(1)
+		metrics.TlmLogsSampledOut.Inc()
+	} else {
+		metrics.LogsRateLimited.Add(1)
+		metrics.TlmLogsRateLimited.Inc()
 	}
-	return true, content
+	return false
 }
@@ -128,6 +128,105 @@ func TestMask(t *testing.T) {
 	assert.Equal(t, []byte("New data added to data_values= on prod"), redactedMessage)
 }
 
+func TestExtractFields(t *testing.T) {
+	p := &Processor{}
+
+	source := newSource("extract_fields", "", `user=(?P<user>\w+) status=(?P<status_code>\d+)(?: took (?P<duration>\d+)ms)?`)
+	msg := newMessage([]byte("request done user=bob status=200"), &source, "")
+	shouldProcess, redactedMessage := p.applyRedactingRules(msg)
+	assert.Equal(t, true, shouldProcess)
+	assert.Equal(t, []byte("request done user=bob status=200"), redactedMessage)
+	assert.Equal(t, map[string]interface{}{"user": "bob", "status_code": "200"}, msg.Attributes)
+
+	msg = newMessage([]byte("hello world"), &source, "")
+	shouldProcess, _ = p.applyRedactingRules(msg)
+	assert.Equal(t, true, shouldProcess)
+	assert.Nil(t, msg.Attributes)
+}
+
+func TestExtractFieldsAfterMask(t *testing.T) {
+	mRule := newProcessingRule("mask_sequences", "[masked]", `secret=\w+`)
+	eRule := newProcessingRule("extract_fields", "", `secret=(?P<secret>\S+)`)
+	p := &Processor{processingRules: []*config.ProcessingRule{mRule, eRule}}
+
+	source := config.LogSource{Config: &config.LogsConfig{}}
+	msg := newMessage([]byte("login secret=foo"), &source, "")
+	_, redactedMessage := p.applyRedactingRules(msg)
+	assert.Equal(t, []byte("login [masked]"), redactedMessage)
+	assert.Nil(t, msg.Attributes)
+}
+
+func TestRemapAttribute(t *testing.T) {
+	eRule := newProcessingRule("extract_fields", "", `usr=(?P<usr>\w+)`)
+	rRule := &config.ProcessingRule{Type: config.RemapAttribute, Name: "test", SourceKey: "usr", TargetKey: "user.name"}
+	p := &Processor{processingRules: []*config.ProcessingRule{eRule}}
+
+	source := config.LogSource{Config: &config.LogsConfig{ProcessingRules: []*config.ProcessingRule{rRule}}}
+
+	msg := newMessage([]byte("login usr=bob"), &source, "")
+	shouldProcess, redactedMessage := p.applyRedactingRules(msg)
+	assert.Equal(t, true, shouldProcess)
+	assert.Equal(t, []byte("login usr=bob"), redactedMessage)
+	assert.Equal(t, map[string]interface{}{"user": map[string]interface{}{"name": "bob"}}, msg.Attributes)
+
+	msg = newMessage([]byte(`{"usr":"alice","count":12345678901234567890}`), &source, "")
+	shouldProcess, redactedMessage = p.applyRedactingRules(msg)
+	assert.Equal(t, true, shouldProcess)
+	assert.Equal(t, []byte(`{"count":12345678901234567890,"user":{"name":"alice"}}`), redactedMessage)
+
+	rRule.SourceKey, rRule.TargetKey = "http.code", "status_code"
+	msg = newMessage([]byte(`{"http":{"code":404,"url":"/"}}`), &source, "")
+	_, redactedMessage = p.applyRedactingRules(msg)
+	assert.Equal(t, []byte(`{"http":{"url":"/"},"status_code":404}`), redactedMessage)
+
+	// the order of the keys and the other values are kept as is
+	rRule.SourceKey, rRule.TargetKey = "http.code", "http.status_code"
+	msg = newMessage([]byte(` {"url":"/a?b=<c>&d","http":{"code":404, "method":"GET"},"count":1.0} `), &source, "")
+	_, redactedMessage = p.applyRedactingRules(msg)
+	assert.Equal(t, []byte(`{"url":"/a?b=<c>&d","http":{"method":"GET","status_code":404},"count":1.0}`), redactedMessage)
+
+	msg = newMessage([]byte(`{"http":"GET"}`), &source, "")
+	_, redactedMessage = p.applyRedactingRules(msg)
+	assert.Equal(t, []byte(`{"http":"GET"}`), redactedMessage)
+
+	msg = newMessage([]byte(`{not json`), &source, "")
+	_, redactedMessage = p.applyRedactingRules(msg)
+	assert.Equal(t, []byte(`{not json`), redactedMessage)
+}
+
+func TestSampling(t *testing.T) {
+	p := &Processor{}
+
+	rule := newProcessingRule("sample", "", "DEBUG")
+	source := config.NewLogSource("", &config.LogsConfig{ProcessingRules: []*config.ProcessingRule{rule}})
+
+	shouldProcess, _ := p.applyRedactingRules(newMessage([]byte("DEBUG hello"), source, ""))
+	assert.Equal(t, false, shouldProcess)
+
+	shouldProcess, redactedMessage := p.applyRedactingRules(newMessage([]byte("INFO hello"), source, ""))
+	assert.Equal(t, true, shouldProcess)
+	assert.Equal(t, []byte("INFO hello"), redactedMessage)
+
+	rule.SampleRate = 1
+	shouldProcess, _ = p.applyRedactingRules(newMessage([]byte("DEBUG hello"), source, ""))
+	assert.Equal(t, true, shouldProcess)
+}
+
+func TestRateLimit(t *testing.T) {
+	rule := newProcessingRule("rate_limit", "", "")
+	rule.MaxLinesPerSecond = 2
+	p := &Processor{processingRules: []*config.ProcessingRule{rule}}
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+	var kept int
+	for i := 0; i < 5; i++ {
+		if shouldProcess, _ := p.applyRedactingRules(newMessage([]byte("hello"), source, "")); shouldProcess {
+			kept++
+		}
+	}
+	assert.Equal(t, 2, kept)
+}
+
 func TestTruncate(t *testing.T) {
 	p := &Processor{}
 
@@ -138,6 +237,28 @@ func TestTruncate(t *testing.T) {
 	assert.Equal(t, []byte("hello"), redactedMessage)
 }
 
+func TestDryRun(t *testing.T) {
+	maskRule := newProcessingRule("mask_sequences", "[masked]", "secret")
+	maskRule.Name = "mask_secrets"
+	unusedMaskRule := newProcessingRule("mask_sequences", "[masked]", "password")
+	unusedMaskRule.Name = "mask_passwords"
+	excludeRule := newProcessingRule("exclude_at_match", "", "health")
+	excludeRule.Name = "exclude_healthchecks"
+	p := &Processor{processingRules: []*config.ProcessingRule{maskRule, unusedMaskRule, excludeRule}, encoder: RawEncoder}
+	source := config.NewLogSource("", &config.LogsConfig{})
+
+	dropRule, redactingRules, content, err := p.DryRun(newMessage([]byte("my secret"), source, ""))
+	assert.NoError(t, err)
+	assert.Nil(t, dropRule)
+	assert.Equal(t, []*config.ProcessingRule{maskRule}, redactingRules)
+	assert.Contains(t, string(content), "my [masked]")
+
+	dropRule, _, content, err = p.DryRun(newMessage([]byte("GET /health"), source, ""))
+	assert.NoError(t, err)
+	assert.Equal(t, excludeRule, dropRule)
+	assert.Nil(t, content)
+}
+
 func newProcessingRule(ruleType, replacePlaceholder, pattern string) *config.ProcessingRule {
 	return &config.ProcessingRule{
 		Type:               ruleType,
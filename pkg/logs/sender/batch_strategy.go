@@ -83,7 +83,7 @@ func (s *batchStrategy) Flush(ctx context.Context) {
 	}
 }
 
-func (s *batchStrategy) syncFlush(inputChan chan *message.Message, outputChan chan *message.Message, send func([]byte) error) {
+func (s *batchStrategy) syncFlush(inputChan chan *message.Message, outputChan chan *message.Message, send func([]*message.Message, []byte) error) {
 	defer func() {
 		s.flushBuffer(outputChan, send)
 		s.pendingSends.Wait()
@@ -102,7 +102,7 @@ func (s *batchStrategy) syncFlush(inputChan chan *message.Message, outputChan ch
 }
 
 // Send accumulates messages to a buffer and sends them when the buffer is full or outdated.
-func (s *batchStrategy) Send(inputChan chan *message.Message, outputChan chan *message.Message, send func([]byte) error) {
+func (s *batchStrategy) Send(inputChan chan *message.Message, outputChan chan *message.Message, send func([]*message.Message, []byte) error) {
 	flushTicker := s.clock.Ticker(s.batchWait)
 	defer func() {
 		s.flushBuffer(outputChan, send)
@@ -136,7 +136,7 @@ func (s *batchStrategy) Send(inputChan chan *message.Message, outputChan chan *m
 	}
 }
 
-func (s *batchStrategy) processMessage(m *message.Message, outputChan chan *message.Message, send func([]byte) error) {
+func (s *batchStrategy) processMessage(m *message.Message, outputChan chan *message.Message, send func([]*message.Message, []byte) error) {
 	if m.Origin != nil {
 		m.Origin.LogSource.LatencyStats.Add(m.GetLatency())
 	}
@@ -156,7 +156,7 @@ func (s *batchStrategy) processMessage(m *message.Message, outputChan chan *mess
 
 // flushBuffer sends all the messages that are stored in the buffer and forwards them
 // to the next stage of the pipeline.
-func (s *batchStrategy) flushBuffer(outputChan chan *message.Message, send func([]byte) error) {
+func (s *batchStrategy) flushBuffer(outputChan chan *message.Message, send func([]*message.Message, []byte) error) {
 	if s.buffer.IsEmpty() {
 		return
 	}
@@ -176,8 +176,8 @@ func (s *batchStrategy) flushBuffer(outputChan chan *message.Message, send func(
 	}()
 }
 
-func (s *batchStrategy) sendMessages(messages []*message.Message, outputChan chan *message.Message, send func([]byte) error) {
-	err := send(s.serializer.Serialize(messages))
+func (s *batchStrategy) sendMessages(messages []*message.Message, outputChan chan *message.Message, send func([]*message.Message, []byte) error) {
+	err := send(messages, s.serializer.Serialize(messages))
 	if err != nil {
 		if shouldStopSending(err) {
 			return
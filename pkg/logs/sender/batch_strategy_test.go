@@ -21,7 +21,7 @@ func TestBatchStrategySendsPayloadWhenBufferIsFull(t *testing.T) {
 	output := make(chan *message.Message)
 
 	var content []byte
-	success := func(payload []byte) error {
+	success := func(messages []*message.Message, payload []byte) error {
 		assert.Equal(t, content, payload)
 		return nil
 	}
@@ -53,7 +53,7 @@ func TestBatchStrategySendsPayloadWhenBufferIsOutdated(t *testing.T) {
 	timerInterval := 100 * time.Millisecond
 
 	// payload sends are blocked until we've confirmed that the we buffer the correct number of pending payloads
-	send := func(payload []byte) error {
+	send := func(messages []*message.Message, payload []byte) error {
 		return nil
 	}
 
@@ -89,7 +89,7 @@ func TestBatchStrategySendsPayloadWhenClosingInput(t *testing.T) {
 	output := make(chan *message.Message)
 
 	var content []byte
-	success := func(payload []byte) error {
+	success := func(messages []*message.Message, payload []byte) error {
 		assert.Equal(t, content, payload)
 		return nil
 	}
@@ -119,7 +119,7 @@ func TestBatchStrategyShouldNotBlockWhenForceStopping(t *testing.T) {
 	output := make(chan *message.Message)
 
 	var content []byte
-	success := func(payload []byte) error {
+	success := func(messages []*message.Message, payload []byte) error {
 		return context.Canceled
 	}
 
@@ -137,7 +137,7 @@ func TestBatchStrategyShouldNotBlockWhenStoppingGracefully(t *testing.T) {
 	output := make(chan *message.Message)
 
 	var content []byte
-	success := func(payload []byte) error {
+	success := func(messages []*message.Message, payload []byte) error {
 		return nil
 	}
 
@@ -157,7 +157,7 @@ func TestBatchStrategyConcurrentSends(t *testing.T) {
 	waitChan := make(chan bool)
 
 	// payload sends are blocked until we've confirmed that the we buffer the correct number of pending payloads
-	stuckSend := func(payload []byte) error {
+	stuckSend := func(messages []*message.Message, payload []byte) error {
 		<-waitChan
 		return nil
 	}
@@ -206,7 +206,7 @@ func TestBatchStrategySynchronousFlush(t *testing.T) {
 	input := make(chan *message.Message)
 	// output needs to be buffered so the flush has somewhere to write to without blocking
 	output := make(chan *message.Message, 3)
-	send := func(payload []byte) error {
+	send := func(messages []*message.Message, payload []byte) error {
 		return nil
 	}
 
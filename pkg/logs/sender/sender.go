@@ -16,7 +16,7 @@ import (
 // Strategy should contain all logic to send logs to a remote destination
 // and forward them the next stage of the pipeline.
 type Strategy interface {
-	Send(inputChan chan *message.Message, outputChan chan *message.Message, send func([]byte) error)
+	Send(inputChan chan *message.Message, outputChan chan *message.Message, send func([]*message.Message, []byte) error)
 	Flush(ctx context.Context)
 }
 
@@ -64,10 +64,10 @@ func (s *Sender) run() {
 	s.strategy.Send(s.inputChan, s.outputChan, s.send)
 }
 
-// send sends a payload to multiple destinations,
+// send sends a payload, serialized from the messages, to multiple destinations,
 // it will forever retry for the main destination unless the error is not retryable
 // and only try once for additionnal destinations.
-func (s *Sender) send(payload []byte) error {
+func (s *Sender) send(messages []*message.Message, payload []byte) error {
 	for {
 		err := s.destinations.Main.Send(payload)
 		if err != nil {
@@ -89,6 +89,10 @@ func (s *Sender) send(payload []byte) error {
 		destination.SendAsync(payload)
 	}
 
+	for _, destination := range s.destinations.MessagesAdditionals {
+		destination.SendMessagesAsync(messages)
+	}
+
 	return nil
 }
 
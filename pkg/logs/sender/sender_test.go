@@ -6,11 +6,15 @@
 package sender
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/diskbuffer"
 	"github.com/DataDog/datadog-agent/pkg/logs/client/mock"
 	"github.com/DataDog/datadog-agent/pkg/logs/client/tcp"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
@@ -87,3 +91,76 @@ func TestSenderNotBlockedByAdditional(t *testing.T) {
 	sender.Stop()
 	destinationsCtx.Stop()
 }
+
+// unavailableDestination always fails with a retryable error.
+type unavailableDestination struct{}
+
+func (unavailableDestination) Send(payload []byte) error {
+	return client.NewRetryableError(errors.New("unavailable"))
+}
+
+func (unavailableDestination) SendAsync(payload []byte) {}
+
+func TestSenderReturnsWhenDiskBufferIsFull(t *testing.T) {
+	destinationsCtx := client.NewDestinationsContext()
+	destinationsCtx.Start()
+	defer destinationsCtx.Stop()
+
+	destination, err := diskbuffer.NewDestination(unavailableDestination{}, t.TempDir(), 2, 1, diskbuffer.DropNewest, destinationsCtx)
+	require.NoError(t, err)
+	sender := NewSender(nil, nil, client.NewDestinations(destination, nil), StreamStrategy)
+
+	// fill the buffer
+	assert.NoError(t, sender.send(nil, []byte("aa")))
+
+	done := make(chan error, 1)
+	go func() { done <- sender.send(nil, []byte("bb")) }()
+	select {
+	case err := <-done:
+		// the payload is dropped instead of being retried forever
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "send did not return while the disk buffer is full")
+	}
+}
+
+// messagesDestinationMock records the batches of messages it receives.
+type messagesDestinationMock struct {
+	batches chan []*message.Message
+}
+
+func (d *messagesDestinationMock) SendMessages(messages []*message.Message) error {
+	d.batches <- messages
+	return nil
+}
+
+func (d *messagesDestinationMock) SendMessagesAsync(messages []*message.Message) {
+	d.SendMessages(messages) //nolint:errcheck
+}
+
+func TestSenderSendsMessagesToMessagesDestinations(t *testing.T) {
+	l := mock.NewMockLogsIntake(t)
+	defer l.Close()
+
+	source := config.NewLogSource("", &config.LogsConfig{})
+
+	input := make(chan *message.Message, 1)
+	output := make(chan *message.Message, 1)
+
+	destinationsCtx := client.NewDestinationsContext()
+	destinationsCtx.Start()
+	defer destinationsCtx.Stop()
+
+	destinations := client.NewDestinations(tcp.AddrToDestination(l.Addr(), destinationsCtx), nil)
+	messagesDestination := &messagesDestinationMock{batches: make(chan []*message.Message, 1)}
+	destinations.MessagesAdditionals = []client.MessagesDestination{messagesDestination}
+
+	sender := NewSender(input, output, destinations, StreamStrategy)
+	sender.Start()
+	defer sender.Stop()
+
+	expectedMessage := newMessage([]byte("fake line"), source, "")
+	input <- expectedMessage
+	assert.Equal(t, expectedMessage, <-output)
+	assert.Equal(t, []*message.Message{expectedMessage}, <-messagesDestination.batches)
+}
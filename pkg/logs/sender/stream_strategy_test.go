@@ -19,7 +19,7 @@ func TestStreamStrategy(t *testing.T) {
 	output := make(chan *message.Message)
 
 	var content []byte
-	success := func(payload []byte) error {
+	success := func(messages []*message.Message, payload []byte) error {
 		assert.Equal(t, content, payload)
 		return nil
 	}
@@ -44,7 +44,7 @@ func TestStreamStrategyShouldNotBlockWhenForceStopping(t *testing.T) {
 	output := make(chan *message.Message)
 
 	var content []byte
-	success := func(payload []byte) error {
+	success := func(messages []*message.Message, payload []byte) error {
 		return context.Canceled
 	}
 
@@ -62,7 +62,7 @@ func TestStreamStrategyShouldNotBlockWhenStoppingGracefully(t *testing.T) {
 	output := make(chan *message.Message)
 
 	var content []byte
-	success := func(payload []byte) error {
+	success := func(messages []*message.Message, payload []byte) error {
 		return nil
 	}
 
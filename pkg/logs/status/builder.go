@@ -67,6 +67,9 @@ func (b *Builder) getEndpoints() []string {
 	for _, additional := range b.endpoints.Additionals {
 		result = append(result, b.formatEndpoint(additional, "Additional: "))
 	}
+	if b.endpoints.UseHTTP && b.endpoints.OTLPHTTPEndpoint != "" {
+		result = append(result, fmt.Sprintf("Additional: Sending logs in OTLP/HTTP to %s (best effort)", b.endpoints.OTLPHTTPEndpoint))
+	}
 	return result
 }
 
@@ -180,6 +183,9 @@ func (b *Builder) toDictionary(c *config.LogsConfig) map[string]interface{} {
 	switch c.Type {
 	case config.TCPType, config.UDPType:
 		dictionary["Port"] = c.Port
+		if c.Format != "" {
+			dictionary["Format"] = c.Format
+		}
 	case config.FileType:
 		dictionary["Path"] = c.Path
 		dictionary["TailingMode"] = c.TailingMode
@@ -207,6 +213,8 @@ func (b *Builder) toDictionary(c *config.LogsConfig) map[string]interface{} {
 func (b *Builder) getMetricsStatus() map[string]int64 {
 	var metrics = make(map[string]int64, 2)
 	metrics["LogsProcessed"] = b.logsExpVars.Get("LogsProcessed").(*expvar.Int).Value()
+	metrics["LogsSampledOut"] = b.logsExpVars.Get("LogsSampledOut").(*expvar.Int).Value()
+	metrics["LogsRateLimited"] = b.logsExpVars.Get("LogsRateLimited").(*expvar.Int).Value()
 	metrics["LogsSent"] = b.logsExpVars.Get("LogsSent").(*expvar.Int).Value()
 	metrics["BytesSent"] = b.logsExpVars.Get("BytesSent").(*expvar.Int).Value()
 	metrics["EncodedBytesSent"] = b.logsExpVars.Get("EncodedBytesSent").(*expvar.Int).Value()
@@ -42,6 +42,20 @@ func (a APIMetricType) MarshalText() ([]byte, error) {
 	return []byte(str), nil
 }
 
+// protobufType returns the value of the MetricType enum of the v2 series intake payload
+func (a APIMetricType) protobufType() int32 {
+	switch a {
+	case APICountType:
+		return 1
+	case APIRateType:
+		return 2
+	case APIGaugeType:
+		return 3
+	default:
+		return 0
+	}
+}
+
 // UnmarshalText is a custom unmarshaller for APIMetricType (used for testing)
 func (a *APIMetricType) UnmarshalText(buf []byte) error {
 	switch string(buf) {
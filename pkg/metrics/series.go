@@ -16,9 +16,11 @@ import (
 	"strings"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/richardartoul/molecule"
 
 	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
 	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
+	"github.com/DataDog/datadog-agent/pkg/serializer/stream"
 	"github.com/DataDog/datadog-agent/pkg/telemetry"
 )
 
@@ -200,9 +202,190 @@ func (series Series) SplitPayload(times int) ([]marshaler.AbstractMarshaler, err
 	return payloads, nil
 }
 
-// MarshalSplitCompress not implemented
+// MarshalSplitCompress uses the stream compressor to marshal and compress series payloads for the v2 series
+// intake. If a compressed payload is larger than the max, a new payload will be generated. This method returns
+// a slice of compressed protobuf marshaled MetricPayload objects. Like for SketchSeriesList, the MetricPayload
+// is not marshaled at once: each series is marshaled individually, packed with the appropriate protobuf
+// metadata, and compressed in stream. Series too big to fit in a payload are dropped.
 func (series Series) MarshalSplitCompress(bufferContext *marshaler.BufferContext) ([]*[]byte, error) {
-	return nil, fmt.Errorf("Series MarshalSplitCompress is not implemented")
+	var err error
+	var compressor *stream.Compressor
+	buf := bufferContext.PrecompressionBuf
+	ps := molecule.NewProtoStream(buf)
+	payloads := []*[]byte{}
+
+	// constants for the protobuf data we will be writing, taken from the MetricPayload message of
+	// https://github.com/DataDog/agent-payload/blob/master/proto/metrics/agent_payload.proto
+	// Unused fields are commented out
+	const payloadSeries = 1
+	const serieResources = 1
+	const serieMetric = 2
+	const serieTags = 3
+	const seriePoints = 4
+	const serieType = 5
+	// const serieUnit = 6
+	const serieSourceTypeName = 7
+	const serieInterval = 8
+	const resourceType = 1
+	const resourceName = 2
+	const pointValue = 1
+	const pointTimestamp = 2
+
+	// Prepare to write the next payload
+	startPayload := func() error {
+		var err error
+
+		bufferContext.CompressorInput.Reset()
+		bufferContext.CompressorOutput.Reset()
+
+		compressor, err = stream.NewCompressor(bufferContext.CompressorInput, bufferContext.CompressorOutput, []byte{}, []byte{}, []byte{}, bufferContext.Codec)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	finishPayload := func() error {
+		var payload []byte
+		payload, err = compressor.Close()
+		if err != nil {
+			return err
+		}
+
+		payloads = append(payloads, &payload)
+
+		return nil
+	}
+
+	writeResource := func(ps *molecule.ProtoStream, typ, name string) error {
+		return ps.Embedded(serieResources, func(ps *molecule.ProtoStream) error {
+			err := ps.String(resourceType, typ)
+			if err != nil {
+				return err
+			}
+			return ps.String(resourceName, name)
+		})
+	}
+
+	// start things off
+	err = startPayload()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, serie := range series {
+		populateDeviceField(serie)
+
+		buf.Reset()
+		err = ps.Embedded(payloadSeries, func(ps *molecule.ProtoStream) error {
+			var err error
+
+			err = writeResource(ps, "host", serie.Host)
+			if err != nil {
+				return err
+			}
+
+			if serie.Device != "" {
+				err = writeResource(ps, "device", serie.Device)
+				if err != nil {
+					return err
+				}
+			}
+
+			err = ps.String(serieMetric, serie.Name)
+			if err != nil {
+				return err
+			}
+
+			for _, tag := range serie.Tags {
+				err = ps.String(serieTags, tag)
+				if err != nil {
+					return err
+				}
+			}
+
+			for _, p := range serie.Points {
+				err = ps.Embedded(seriePoints, func(ps *molecule.ProtoStream) error {
+					err := ps.Double(pointValue, p.Value)
+					if err != nil {
+						return err
+					}
+					return ps.Int64(pointTimestamp, int64(p.Ts))
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			err = ps.Int32(serieType, serie.MType.protobufType())
+			if err != nil {
+				return err
+			}
+
+			err = ps.String(serieSourceTypeName, serie.SourceTypeName)
+			if err != nil {
+				return err
+			}
+
+			return ps.Int64(serieInterval, serie.Interval)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Compress the protobuf metadata and the marshaled serie
+		err = compressor.AddItem(buf.Bytes())
+		switch err {
+		case stream.ErrPayloadFull:
+			seriesExpvar.Add("PayloadFull", 1)
+			tlmSeries.Inc("payload_full")
+
+			// Since the compression buffer is full - flush it and start a new one
+			err = finishPayload()
+			if err != nil {
+				return nil, err
+			}
+
+			err = startPayload()
+			if err != nil {
+				return nil, err
+			}
+
+			// Add it to the new compression buffer
+			err = compressor.AddItem(buf.Bytes())
+			if err == stream.ErrItemTooBig {
+				// Item was too big, drop it
+				seriesExpvar.Add("ItemTooBig", 1)
+				tlmSeries.Inc("item_too_big")
+				continue
+			}
+			if err != nil {
+				// Unexpected error bail out
+				seriesExpvar.Add("UnexpectedItemDrops", 1)
+				tlmSeries.Inc("unexpected_item_drops")
+				return nil, err
+			}
+		case stream.ErrItemTooBig:
+			// Item was too big, drop it
+			seriesExpvar.Add("ItemTooBig", 1)
+			tlmSeries.Inc("item_too_big")
+		case nil:
+			continue
+		default:
+			// Unexpected error bail out
+			seriesExpvar.Add("UnexpectedItemDrops", 1)
+			tlmSeries.Inc("unexpected_item_drops")
+			return nil, err
+		}
+	}
+
+	err = finishPayload()
+	if err != nil {
+		return nil, err
+	}
+
+	return payloads, nil
 }
 
 // UnmarshalJSON is a custom unmarshaller for Point (used for testing)
@@ -13,14 +13,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"testing"
 
 	jsoniter "github.com/json-iterator/go"
 
+	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/forwarder"
+	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
 	"github.com/DataDog/datadog-agent/pkg/serializer/stream"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
 )
 
 func TestPopulateDeviceField(t *testing.T) {
@@ -401,6 +405,205 @@ func TestPayloadsSeries(t *testing.T) {
 	require.Equal(t, originalLength, newLength)
 }
 
+func TestMarshalSplitCompressSeries(t *testing.T) {
+	series := Series{
+		{
+			Points: []Point{
+				{Ts: 12345, Value: 21.21},
+				{Ts: 67890, Value: 0},
+			},
+			MType:          APIGaugeType,
+			Name:           "test.metrics",
+			Interval:       1,
+			Host:           "localHost",
+			Tags:           []string{"tag1", "device:/dev/sda1", "tag2:yes"},
+			SourceTypeName: "System",
+		},
+		{
+			Points:   []Point{{Ts: 12345, Value: 3}},
+			MType:    APIRateType,
+			Name:     "test.rate",
+			Interval: 10,
+			Tags:     []string{},
+		},
+	}
+
+	payloads, err := series.MarshalSplitCompress(marshaler.DefaultBufferContext())
+	require.NoError(t, err)
+	require.Len(t, payloads, 1)
+
+	decoded := decodeSeriesPayload(t, *payloads[0])
+	assert.Equal(t, []decodedSerie{
+		{
+			Resources:      []string{"host:localHost", "device:/dev/sda1"},
+			Metric:         "test.metrics",
+			Tags:           []string{"tag1", "tag2:yes"},
+			Points:         []Point{{Ts: 12345, Value: 21.21}, {Ts: 67890, Value: 0}},
+			Type:           3,
+			SourceTypeName: "System",
+			Interval:       1,
+		},
+		{
+			Resources: []string{"host:"},
+			Metric:    "test.rate",
+			Points:    []Point{{Ts: 12345, Value: 3}},
+			Type:      2,
+			Interval:  10,
+		},
+	}, decoded)
+}
+
+func TestMarshalSplitCompressSeriesEmpty(t *testing.T) {
+	payloads, err := Series{}.MarshalSplitCompress(marshaler.DefaultBufferContext())
+	require.NoError(t, err)
+	require.Len(t, payloads, 1)
+
+	payload, err := decompressPayload(*payloads[0])
+	require.NoError(t, err)
+	assert.Empty(t, payload)
+}
+
+func TestMarshalSplitCompressSeriesSplit(t *testing.T) {
+	oldSetting := config.Datadog.Get("serializer_max_uncompressed_payload_size")
+	defer config.Datadog.Set("serializer_max_uncompressed_payload_size", oldSetting)
+	config.Datadog.Set("serializer_max_uncompressed_payload_size", 500)
+
+	series := Series{}
+	for i := 0; i < 100; i++ {
+		series = append(series, &Serie{
+			Points:   []Point{{Ts: 12345, Value: float64(i)}},
+			MType:    APICountType,
+			Name:     fmt.Sprintf("test.metrics%d", i),
+			Interval: 10,
+			Host:     "localHost",
+			Tags:     []string{"tag1", "tag2:yes"},
+		})
+	}
+
+	payloads, err := series.MarshalSplitCompress(marshaler.DefaultBufferContext())
+	require.NoError(t, err)
+	assert.Greater(t, len(payloads), 1)
+
+	var names []string
+	for _, payload := range payloads {
+		for _, serie := range decodeSeriesPayload(t, *payload) {
+			names = append(names, serie.Metric)
+		}
+	}
+	require.Len(t, names, len(series))
+	for i, serie := range series {
+		assert.Equal(t, serie.Name, names[i])
+	}
+}
+
+func TestMarshalSplitCompressSeriesItemTooBigIsDropped(t *testing.T) {
+	oldSetting := config.Datadog.Get("serializer_max_uncompressed_payload_size")
+	defer config.Datadog.Set("serializer_max_uncompressed_payload_size", oldSetting)
+	config.Datadog.Set("serializer_max_uncompressed_payload_size", 100)
+
+	bigSerie := &Serie{Name: "big", Host: "localHost"}
+	for i := 0; i < 20; i++ {
+		bigSerie.Points = append(bigSerie.Points, Point{Ts: float64(i), Value: float64(i)})
+	}
+	series := Series{bigSerie, {Name: "small"}}
+
+	payloads, err := series.MarshalSplitCompress(marshaler.DefaultBufferContext())
+	require.NoError(t, err)
+
+	var names []string
+	for _, payload := range payloads {
+		for _, serie := range decodeSeriesPayload(t, *payload) {
+			names = append(names, serie.Metric)
+		}
+	}
+	assert.Equal(t, []string{"small"}, names)
+}
+
+// decodedSerie is a MetricPayload.MetricSeries of the v2 series intake, the resources
+// being formatted as `type:name`
+type decodedSerie struct {
+	Resources      []string
+	Metric         string
+	Tags           []string
+	Points         []Point
+	Type           int32
+	SourceTypeName string
+	Interval       int64
+}
+
+// decodeSeriesPayload decodes a compressed payload created by Series.MarshalSplitCompress
+func decodeSeriesPayload(t *testing.T, compressedPayload []byte) []decodedSerie {
+	payload, err := decompressPayload(compressedPayload)
+	require.NoError(t, err)
+
+	var series []decodedSerie
+	forEachField(t, payload, func(num protowire.Number, v uint64, b []byte) {
+		require.Equal(t, protowire.Number(1), num)
+		var serie decodedSerie
+		forEachField(t, b, func(num protowire.Number, v uint64, b []byte) {
+			switch num {
+			case 1:
+				var resource [2]string
+				forEachField(t, b, func(num protowire.Number, v uint64, b []byte) {
+					resource[num-1] = string(b)
+				})
+				serie.Resources = append(serie.Resources, resource[0]+":"+resource[1])
+			case 2:
+				serie.Metric = string(b)
+			case 3:
+				serie.Tags = append(serie.Tags, string(b))
+			case 4:
+				var point Point
+				forEachField(t, b, func(num protowire.Number, v uint64, b []byte) {
+					switch num {
+					case 1:
+						point.Value = math.Float64frombits(v)
+					case 2:
+						point.Ts = float64(int64(v))
+					}
+				})
+				serie.Points = append(serie.Points, point)
+			case 5:
+				serie.Type = int32(v)
+			case 7:
+				serie.SourceTypeName = string(b)
+			case 8:
+				serie.Interval = int64(v)
+			default:
+				require.FailNow(t, "unexpected field", "field %d", num)
+			}
+		})
+		series = append(series, serie)
+	})
+	return series
+}
+
+func forEachField(t *testing.T, b []byte, f func(num protowire.Number, v uint64, b []byte)) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.True(t, n > 0, "invalid tag")
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			f(num, v, nil)
+		case protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(b)
+			f(num, v, nil)
+		case protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			f(num, 0, v)
+		default:
+			require.FailNow(t, "unexpected wire type", "wire type %d", typ)
+		}
+		require.True(t, n >= 0, "invalid value")
+		b = b[n:]
+	}
+}
+
 var result forwarder.Payloads
 
 func BenchmarkPayloadsSeries(b *testing.B) {
@@ -0,0 +1,105 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package serializer
+
+import (
+	"net/http"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// payloadCodec is the codec compressing the payloads sent to an endpoint, along with the
+// extra headers of the compressed payloads
+type payloadCodec struct {
+	codec                compression.Codec
+	jsonExtraHeaders     http.Header
+	protobufExtraHeaders http.Header
+}
+
+// codecConfig is the configuration of the codec of an endpoint in `serializer_compression_per_endpoint`
+type codecConfig struct {
+	Kind  string `mapstructure:"kind"`
+	Level int    `mapstructure:"level"`
+}
+
+// newPayloadCodec returns the payloadCodec of the given kind and level. A level without
+// kind applies to the algorithm and format selected at build time.
+func newPayloadCodec(kind string, level int) (payloadCodec, error) {
+	codec, err := compression.NewCodec(kind, level)
+	if err != nil {
+		return payloadCodec{}, err
+	}
+	if codec == compression.DefaultCodec {
+		return payloadCodec{
+			codec:                codec,
+			jsonExtraHeaders:     jsonExtraHeadersWithCompression,
+			protobufExtraHeaders: protobufExtraHeadersWithCompression,
+		}, nil
+	}
+	return payloadCodec{
+		codec:                codec,
+		jsonExtraHeaders:     withContentEncoding(jsonExtraHeaders, codec.ContentEncoding()),
+		protobufExtraHeaders: withContentEncoding(protobufExtraHeaders, codec.ContentEncoding()),
+	}, nil
+}
+
+func withContentEncoding(extraHeaders http.Header, contentEncoding string) http.Header {
+	h := extraHeaders.Clone()
+	if contentEncoding != "" {
+		h.Set("Content-Encoding", contentEncoding)
+	}
+	return h
+}
+
+// newPayloadCodecsFromConfig returns the codec of the payloads and the codecs of the endpoints
+// overriding it, by endpoint name. An invalid codec configuration is replaced by the default one.
+func newPayloadCodecsFromConfig() (payloadCodec, map[string]payloadCodec) {
+	kind := config.Datadog.GetString("serializer_compression_kind")
+	defaultCodec, err := newPayloadCodec(kind, config.Datadog.GetInt("serializer_compression_level"))
+	if err != nil {
+		log.Errorf("Invalid serializer compression, using %s instead: %s", compression.DefaultCodec.Kind(), err)
+		defaultCodec, _ = newPayloadCodec("", 0)
+	} else {
+		warnIfZstd(kind, "all the endpoints")
+	}
+
+	var endpointConfigs map[string]codecConfig
+	if err := config.Datadog.UnmarshalKey("serializer_compression_per_endpoint", &endpointConfigs); err != nil {
+		log.Errorf("Could not parse serializer_compression_per_endpoint: %s", err)
+	}
+
+	endpointCodecs := make(map[string]payloadCodec, len(endpointConfigs))
+	for endpoint, c := range endpointConfigs {
+		codec, err := newPayloadCodec(c.Kind, c.Level)
+		if err != nil {
+			log.Errorf("Invalid serializer compression for the %s endpoint, using %s instead: %s", endpoint, defaultCodec.codec.Kind(), err)
+			continue
+		}
+		log.Infof("Compressing the payloads sent to the %s endpoint with %s", endpoint, codec.codec.Kind())
+		warnIfZstd(c.Kind, "the "+endpoint+" endpoint")
+		endpointCodecs[endpoint] = codec
+	}
+	return defaultCodec, endpointCodecs
+}
+
+// warnIfZstd warns that the payloads sent to the endpoints could be rejected when the configured
+// kind is zstd: it compresses them in the v1 zstd format, which the intake may not accept.
+func warnIfZstd(kind string, endpoints string) {
+	if kind == compression.ZstdKind {
+		log.Warnf("The payloads sent to %s are compressed in the v1 zstd format, which the Datadog intake may not accept: they could be rejected", endpoints)
+	}
+}
+
+// codecFor returns the codec compressing the payloads sent to endpoint
+func (s *Serializer) codecFor(endpoint transaction.Endpoint) payloadCodec {
+	if codec, ok := s.endpointCodecs[endpoint.Name]; ok {
+		return codec
+	}
+	return s.defaultCodec
+}
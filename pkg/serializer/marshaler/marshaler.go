@@ -9,6 +9,8 @@ import (
 	"bytes"
 
 	jsoniter "github.com/json-iterator/go"
+
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 )
 
 // Marshaler is an interface for metrics that are able to serialize themselves to JSON and protobuf
@@ -57,13 +59,21 @@ type BufferContext struct {
 	CompressorInput   *bytes.Buffer
 	CompressorOutput  *bytes.Buffer
 	PrecompressionBuf *bytes.Buffer
+	// Codec is the codec used to compress the payloads
+	Codec compression.Codec
 }
 
 // DefaultBufferContext initialize the default compression buffers
 func DefaultBufferContext() *BufferContext {
+	return NewBufferContext(compression.DefaultCodec)
+}
+
+// NewBufferContext initialize the compression buffers of payloads compressed with codec
+func NewBufferContext(codec compression.Codec) *BufferContext {
 	return &BufferContext{
-		bytes.NewBuffer(make([]byte, 0, 1024)),
-		bytes.NewBuffer(make([]byte, 0, 1024)),
-		bytes.NewBuffer(make([]byte, 0, 1024)),
+		CompressorInput:   bytes.NewBuffer(make([]byte, 0, 1024)),
+		CompressorOutput:  bytes.NewBuffer(make([]byte, 0, 1024)),
+		PrecompressionBuf: bytes.NewBuffer(make([]byte, 0, 1024)),
+		Codec:             codec,
 	}
 }
@@ -0,0 +1,354 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package remotewrite exports the metrics flushed by the aggregator to a
+// Prometheus remote write endpoint, in addition to the Datadog intake.
+package remotewrite
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+	"github.com/DataDog/datadog-agent/pkg/version"
+)
+
+const (
+	// maximum number of time series sent in a single request
+	maxTimeSeriesPerRequest = 5000
+
+	metricNameLabel = "__name__"
+	quantileLabel   = "quantile"
+	hostLabel       = "host"
+	deviceLabel     = "device"
+)
+
+// quantiles reported for each sketch, 0 and 1 are the minimum and the maximum
+var quantiles = []float64{0, 0.5, 0.75, 0.95, 0.99, 1}
+
+var (
+	exporterExpvars        = expvar.NewMap("remote_write_exporter")
+	exporterRequests       = expvar.Int{}
+	exporterRequestErrors  = expvar.Int{}
+	exporterDroppedRequest = expvar.Int{}
+	exporterTimeSeries     = expvar.Int{}
+)
+
+func init() {
+	exporterExpvars.Set("Requests", &exporterRequests)
+	exporterExpvars.Set("RequestErrors", &exporterRequestErrors)
+	exporterExpvars.Set("DroppedRequests", &exporterDroppedRequest)
+	exporterExpvars.Set("TimeSeries", &exporterTimeSeries)
+}
+
+// Exporter converts the series and sketches flushed by the aggregator to Prometheus remote
+// write requests and sends them to a remote write URL, in its own goroutine. The requests
+// are dropped when the queue is full or when they fail, they are not retried.
+// The series are exported as gauges: the counts are the number of occurrences over the flush
+// interval and the rates the number of occurrences per second, not cumulative counters. The
+// help of their metric family names their Datadog type. The sketches are exported as summaries.
+type Exporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	requests chan []byte
+	stopChan chan struct{}
+	stopped  chan struct{}
+}
+
+// NewExporter returns a started Exporter sending the remote write requests to url, with
+// the given extra headers. At most queueSize requests are waiting to be sent.
+func NewExporter(url string, headers map[string]string, timeout time.Duration, queueSize int) *Exporter {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	e := &Exporter{
+		url:      url,
+		headers:  headers,
+		client:   &http.Client{Timeout: timeout},
+		requests: make(chan []byte, queueSize),
+		stopChan: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// NewExporterFromConfig returns a started Exporter configured by the metrics_remote_write settings,
+// or nil if metrics_remote_write_url is not set.
+func NewExporterFromConfig() *Exporter {
+	url := config.Datadog.GetString("metrics_remote_write_url")
+	if url == "" {
+		return nil
+	}
+	log.Infof("Exporting the metrics to the Prometheus remote write URL %s", url)
+	return NewExporter(
+		url,
+		config.Datadog.GetStringMapString("metrics_remote_write_headers"),
+		time.Duration(config.Datadog.GetInt("metrics_remote_write_timeout"))*time.Second,
+		config.Datadog.GetInt("metrics_remote_write_queue_size"),
+	)
+}
+
+// Stop stops the Exporter, the requests waiting to be sent are dropped.
+func (e *Exporter) Stop() {
+	close(e.stopChan)
+	<-e.stopped
+}
+
+// SendSeries queues the remote write requests of the series.
+func (e *Exporter) SendSeries(series metrics.Series) {
+	result := make([]timeSeries, 0, len(series))
+	for _, serie := range series {
+		if len(serie.Points) == 0 {
+			continue
+		}
+		samples := make([]sample, 0, len(serie.Points))
+		for _, p := range serie.Points {
+			samples = append(samples, sample{value: p.Value, timestamp: toMillis(p.Ts)})
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i].timestamp < samples[j].timestamp })
+
+		tags := serie.Tags
+		if serie.Device != "" {
+			tags = append(tags[:len(tags):len(tags)], deviceLabel+":"+serie.Device)
+		}
+		result = append(result, timeSeries{
+			labels:   makeLabels(serie.Name, serie.Host, tags, ""),
+			samples:  samples,
+			metadata: seriesMetadata(serie),
+		})
+	}
+	e.queue(result)
+}
+
+// seriesMetadata returns the metadata of the metric family of a series.
+func seriesMetadata(serie *metrics.Serie) *metricMetadata {
+	help := "Datadog gauge"
+	switch serie.MType {
+	case metrics.APICountType:
+		help = "Datadog count: the number of occurrences over the flush interval"
+	case metrics.APIRateType:
+		help = "Datadog rate: the number of occurrences per second"
+	}
+	return &metricMetadata{family: sanitizeMetricName(serie.Name), mtype: metricTypeGauge, help: help}
+}
+
+// SendSketches queues the remote write requests of the sketches, each sketch is converted
+// to a Prometheus summary: its quantiles, sum and count.
+func (e *Exporter) SendSketches(sketches metrics.SketchSeriesList) {
+	var result []timeSeries
+	for _, sketch := range sketches {
+		if len(sketch.Points) == 0 {
+			continue
+		}
+		points := make([]metrics.SketchPoint, len(sketch.Points))
+		copy(points, sketch.Points)
+		sort.Slice(points, func(i, j int) bool { return points[i].Ts < points[j].Ts })
+		metadata := &metricMetadata{family: sanitizeMetricName(sketch.Name), mtype: metricTypeSummary, help: "Datadog distribution"}
+
+		for _, q := range quantiles {
+			samples := make([]sample, 0, len(points))
+			for _, p := range points {
+				samples = append(samples, sample{value: sketchQuantile(p.Sketch, q), timestamp: p.Ts * 1000})
+			}
+			result = append(result, timeSeries{
+				labels:   makeLabels(sketch.Name, sketch.Host, sketch.Tags, strconv.FormatFloat(q, 'g', -1, 64)),
+				samples:  samples,
+				metadata: metadata,
+			})
+		}
+
+		sums := make([]sample, 0, len(points))
+		counts := make([]sample, 0, len(points))
+		for _, p := range points {
+			sums = append(sums, sample{value: p.Sketch.Basic.Sum, timestamp: p.Ts * 1000})
+			counts = append(counts, sample{value: float64(p.Sketch.Basic.Cnt), timestamp: p.Ts * 1000})
+		}
+		result = append(result,
+			timeSeries{labels: makeLabels(sketch.Name+"_sum", sketch.Host, sketch.Tags, ""), samples: sums, metadata: metadata},
+			timeSeries{labels: makeLabels(sketch.Name+"_count", sketch.Host, sketch.Tags, ""), samples: counts, metadata: metadata},
+		)
+	}
+	e.queue(result)
+}
+
+func sketchQuantile(sketch *quantile.Sketch, q float64) float64 {
+	switch q {
+	case 0:
+		return sketch.Basic.Min
+	case 1:
+		return sketch.Basic.Max
+	}
+	return sketch.Quantile(quantile.Default(), q)
+}
+
+// queue splits the time series in remote write requests and queues them, a request is
+// dropped if the queue is full. The requests are only encoded when the queue has room for them.
+func (e *Exporter) queue(series []timeSeries) {
+	for len(series) > 0 {
+		n := len(series)
+		if n > maxTimeSeriesPerRequest {
+			n = maxTimeSeriesPerRequest
+		}
+		if len(e.requests) < cap(e.requests) {
+			select {
+			case e.requests <- snappy.Encode(nil, marshalWriteRequest(series[:n])):
+				exporterTimeSeries.Add(int64(n))
+				series = series[n:]
+				continue
+			default:
+			}
+		}
+		exporterDroppedRequest.Add(1)
+		log.Warnf("The Prometheus remote write queue is full, dropping a request of %d time series", n)
+		series = series[n:]
+	}
+}
+
+func (e *Exporter) run() {
+	defer close(e.stopped)
+	for {
+		select {
+		case <-e.stopChan:
+			return
+		case request := <-e.requests:
+			exporterRequests.Add(1)
+			if err := e.send(request); err != nil {
+				exporterRequestErrors.Add(1)
+				log.Errorf("Could not send the metrics to the Prometheus remote write URL: %v", err)
+			}
+		}
+	}
+}
+
+func (e *Exporter) send(request []byte) error {
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(request))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "datadog-agent/"+version.AgentVersion)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected response %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+// makeLabels returns the labels of a time series sorted by name. The tags become labels, the
+// values of the tags having the same key are joined with commas, the tags without value are
+// set to "true".
+func makeLabels(name, host string, tags []string, q string) []label {
+	values := make(map[string][]string, len(tags)+2)
+	for _, tag := range tags {
+		key, value := tag, "true"
+		if i := strings.IndexByte(tag, ':'); i >= 0 {
+			key, value = tag[:i], tag[i+1:]
+		}
+		key = sanitizeLabelName(key)
+		values[key] = append(values[key], value)
+	}
+	if host != "" {
+		values[hostLabel] = append(values[hostLabel], host)
+	}
+	if q != "" {
+		values[quantileLabel] = []string{q}
+	}
+
+	labels := make([]label, 0, len(values)+1)
+	labels = append(labels, label{name: metricNameLabel, value: sanitizeMetricName(name)})
+	for key, vals := range values {
+		sort.Strings(vals)
+		labels = append(labels, label{name: key, value: strings.Join(uniq(vals), ",")})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return labels
+}
+
+// sanitizeMetricName replaces the characters not allowed in a Prometheus metric name by underscores.
+func sanitizeMetricName(name string) string {
+	return sanitize(name, func(i int, r rune) bool {
+		return r == '_' || r == ':' || isLetter(r) || (i > 0 && isDigit(r))
+	})
+}
+
+// sanitizeLabelName replaces the characters not allowed in a Prometheus label name by underscores,
+// the names reserved by Prometheus are prefixed with "tag".
+func sanitizeLabelName(name string) string {
+	name = sanitize(name, func(i int, r rune) bool {
+		return r == '_' || isLetter(r) || (i > 0 && isDigit(r))
+	})
+	if strings.HasPrefix(name, "__") {
+		name = "tag" + name
+	}
+	return name
+}
+
+func sanitize(s string, valid func(i int, r rune) bool) string {
+	if s == "" {
+		return "_"
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i, r := range s {
+		if valid(i, r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// uniq removes the duplicates of a sorted slice in place.
+func uniq(s []string) []string {
+	j := 0
+	for i := 1; i < len(s); i++ {
+		if s[i] != s[j] {
+			j++
+			s[j] = s[i]
+		}
+	}
+	return s[:j+1]
+}
+
+func toMillis(ts float64) int64 {
+	return int64(ts * 1000)
+}
@@ -0,0 +1,259 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build test
+
+package remotewrite
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+)
+
+// writeRequest is a decoded remote write request.
+type writeRequest struct {
+	series   []timeSeries
+	metadata []metricMetadata
+}
+
+// startServer returns a remote write server sending the decoded requests to the returned channel.
+func startServer(t *testing.T, status int) (*httptest.Server, chan writeRequest) {
+	requests := make(chan writeRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "0.1.0", r.Header.Get("X-Prometheus-Remote-Write-Version"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		request, err := snappy.Decode(nil, body)
+		require.NoError(t, err)
+		requests <- unmarshalWriteRequest(t, request)
+		w.WriteHeader(status)
+	}))
+	return server, requests
+}
+
+func receive(t *testing.T, requests chan writeRequest) writeRequest {
+	select {
+	case request := <-requests:
+		return request
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timeout waiting for the remote write request")
+	}
+	return writeRequest{}
+}
+
+func TestExporterSendSeries(t *testing.T) {
+	server, requests := startServer(t, http.StatusNoContent)
+	defer server.Close()
+	e := NewExporter(server.URL, map[string]string{"Authorization": "Bearer token"}, time.Second, 10)
+	defer e.Stop()
+
+	e.SendSeries(metrics.Series{
+		{
+			Name:   "http.requests",
+			Points: []metrics.Point{{Ts: 1600000010, Value: 2}, {Ts: 1600000000, Value: 1}},
+			Tags:   []string{"env:prod", "role:db", "role:cache", "canary", "__name__:x"},
+			Host:   "my-host",
+			Device: "sda",
+			MType:  metrics.APICountType,
+		},
+		{Name: "empty"},
+		{
+			Name:   "9lives",
+			Points: []metrics.Point{{Ts: 1600000000.5, Value: 3}},
+			MType:  metrics.APIGaugeType,
+		},
+	})
+
+	countMetadata := &metricMetadata{"http_requests", metricTypeGauge, "Datadog count: the number of occurrences over the flush interval"}
+	gaugeMetadata := &metricMetadata{"_lives", metricTypeGauge, "Datadog gauge"}
+	request := receive(t, requests)
+	assert.Equal(t, []timeSeries{
+		{
+			labels: []label{
+				{"__name__", "http_requests"},
+				{"canary", "true"},
+				{"device", "sda"},
+				{"env", "prod"},
+				{"host", "my-host"},
+				{"role", "cache,db"},
+				{"tag__name__", "x"},
+			},
+			samples:  []sample{{1, 1600000000000}, {2, 1600000010000}},
+			metadata: countMetadata,
+		},
+		{
+			labels:   []label{{"__name__", "_lives"}},
+			samples:  []sample{{3, 1600000000500}},
+			metadata: gaugeMetadata,
+		},
+	}, request.series)
+	assert.Equal(t, []metricMetadata{*countMetadata, *gaugeMetadata}, request.metadata)
+	assert.Equal(t, "Datadog rate: the number of occurrences per second", seriesMetadata(&metrics.Serie{MType: metrics.APIRateType}).help)
+}
+
+func TestExporterSendSketches(t *testing.T) {
+	server, requests := startServer(t, http.StatusOK)
+	defer server.Close()
+	e := NewExporter(server.URL, map[string]string{"Authorization": "Bearer token"}, time.Second, 10)
+	defer e.Stop()
+
+	sketch := &quantile.Sketch{}
+	sketch.Insert(quantile.Default(), 1, 2, 3, 4)
+	e.SendSketches(metrics.SketchSeriesList{{
+		Name:   "request.latency",
+		Tags:   []string{"env:prod"},
+		Points: []metrics.SketchPoint{{Sketch: sketch, Ts: 1600000000}},
+	}})
+
+	metadata := &metricMetadata{"request_latency", metricTypeSummary, "Datadog distribution"}
+	request := receive(t, requests)
+	// the quantile, sum and count series share the metadata of their metric family
+	assert.Equal(t, []metricMetadata{*metadata}, request.metadata)
+	series := request.series
+	require.Len(t, series, len(quantiles)+2)
+	for i, q := range []string{"0", "0.5", "0.75", "0.95", "0.99", "1"} {
+		assert.Equal(t, []label{{"__name__", "request_latency"}, {"env", "prod"}, {"quantile", q}}, series[i].labels)
+		require.Len(t, series[i].samples, 1)
+		assert.Equal(t, sketchQuantile(sketch, quantiles[i]), series[i].samples[0].value)
+		assert.Equal(t, metadata, series[i].metadata)
+	}
+	assert.Equal(t, 1.0, series[0].samples[0].value)
+	assert.Equal(t, 4.0, series[5].samples[0].value)
+	assert.Equal(t, timeSeries{
+		labels:   []label{{"__name__", "request_latency_sum"}, {"env", "prod"}},
+		samples:  []sample{{10, 1600000000000}},
+		metadata: metadata,
+	}, series[6])
+	assert.Equal(t, timeSeries{
+		labels:   []label{{"__name__", "request_latency_count"}, {"env", "prod"}},
+		samples:  []sample{{4, 1600000000000}},
+		metadata: metadata,
+	}, series[7])
+}
+
+func TestExporterRequestErrors(t *testing.T) {
+	server, requests := startServer(t, http.StatusBadRequest)
+	defer server.Close()
+	e := NewExporter(server.URL, map[string]string{"Authorization": "Bearer token"}, time.Second, 10)
+	defer e.Stop()
+
+	errors := exporterRequestErrors.Value()
+	e.SendSeries(metrics.Series{{Name: "my.metric", Points: []metrics.Point{{Ts: 1, Value: 1}}}})
+	receive(t, requests)
+	assert.Eventually(t, func() bool { return exporterRequestErrors.Value() == errors+1 }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestExporterQueueFull(t *testing.T) {
+	// the exporter is stopped so that the requests are not consumed
+	e := NewExporter("http://localhost", nil, time.Second, 1)
+	e.Stop()
+
+	dropped := exporterDroppedRequest.Value()
+	series := make([]timeSeries, maxTimeSeriesPerRequest+1)
+	e.queue(series)
+	assert.Len(t, e.requests, 1)
+	assert.Equal(t, dropped+1, exporterDroppedRequest.Value())
+
+	// the queue is full, the requests are dropped without being encoded
+	queued := <-e.requests
+	e.requests <- queued
+	e.queue(series)
+	assert.Len(t, e.requests, 1)
+	assert.Equal(t, queued, <-e.requests)
+	assert.Equal(t, dropped+3, exporterDroppedRequest.Value())
+}
+
+// unmarshalWriteRequest decodes a remote write request encoded by marshalWriteRequest, the
+// metadata of the series is set from the metric family of their name, or its prefix.
+func unmarshalWriteRequest(t *testing.T, b []byte) writeRequest {
+	var request writeRequest
+	consume(t, b, func(num protowire.Number, _ protowire.Type, ts []byte) {
+		if num == writeRequestMetadataField {
+			var m metricMetadata
+			consume(t, ts, func(num protowire.Number, _ protowire.Type, v []byte) {
+				switch num {
+				case metadataTypeField:
+					mtype, _ := protowire.ConsumeVarint(v)
+					m.mtype = metricType(mtype)
+				case metadataFamilyNameField:
+					m.family = string(v)
+				case metadataHelpField:
+					m.help = string(v)
+				}
+			})
+			request.metadata = append(request.metadata, m)
+			return
+		}
+		var s timeSeries
+		consume(t, ts, func(num protowire.Number, _ protowire.Type, value []byte) {
+			if num == timeSeriesLabelsField {
+				var l label
+				consume(t, value, func(num protowire.Number, _ protowire.Type, v []byte) {
+					if num == labelNameField {
+						l.name = string(v)
+					} else {
+						l.value = string(v)
+					}
+				})
+				s.labels = append(s.labels, l)
+				return
+			}
+			var smp sample
+			consume(t, value, func(num protowire.Number, _ protowire.Type, v []byte) {
+				if num == sampleValueField {
+					bits, _ := protowire.ConsumeFixed64(v)
+					smp.value = math.Float64frombits(bits)
+				} else {
+					ts, _ := protowire.ConsumeVarint(v)
+					smp.timestamp = int64(ts)
+				}
+			})
+			s.samples = append(s.samples, smp)
+		})
+		request.series = append(request.series, s)
+	})
+	for i, s := range request.series {
+		for j, m := range request.metadata {
+			if name := s.labels[0].value; name == m.family || name == m.family+"_sum" || name == m.family+"_count" {
+				request.series[i].metadata = &request.metadata[j]
+				break
+			}
+		}
+	}
+	return request
+}
+
+// consume calls f with the number, type and raw value of each field of a message, the
+// values of the length-delimited fields are their content.
+func consume(t *testing.T, b []byte, f func(protowire.Number, protowire.Type, []byte)) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.True(t, n >= 0)
+		b = b[n:]
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		require.True(t, n >= 0)
+		value := b[:n]
+		if typ == protowire.BytesType {
+			value, _ = protowire.ConsumeBytes(value)
+		}
+		f(num, typ, value)
+		b = b[n:]
+	}
+}
@@ -0,0 +1,118 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package remotewrite
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The Prometheus remote write messages are encoded by hand, they only use the
+// following fields, see https://github.com/prometheus/prometheus/blob/main/prompb/remote.proto
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; repeated MetricMetadata metadata = 3; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+//	message MetricMetadata { MetricType type = 1; string metric_family_name = 2; string help = 4; }
+const (
+	writeRequestTimeSeriesField = 1
+	writeRequestMetadataField   = 3
+	timeSeriesLabelsField       = 1
+	timeSeriesSamplesField      = 2
+	labelNameField              = 1
+	labelValueField             = 2
+	sampleValueField            = 1
+	sampleTimestampField        = 2
+	metadataTypeField           = 1
+	metadataFamilyNameField     = 2
+	metadataHelpField           = 4
+)
+
+// metricType is the MetricType enum of the metric metadata.
+type metricType uint64
+
+const (
+	metricTypeGauge   metricType = 2
+	metricTypeSummary metricType = 5
+)
+
+// metricMetadata describes the metric family of time series.
+type metricMetadata struct {
+	family string
+	mtype  metricType
+	help   string
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	value float64
+	// timestamp in milliseconds
+	timestamp int64
+}
+
+type timeSeries struct {
+	// labels sorted by name, including the __name__ label
+	labels  []label
+	samples []sample
+	// metadata of the metric family of the time series, nil if it is unknown
+	metadata *metricMetadata
+}
+
+// marshalWriteRequest encodes the time series as a Prometheus remote write request, along
+// with the metadata of their metric families.
+func marshalWriteRequest(series []timeSeries) []byte {
+	var request, ts, buf []byte
+	for _, s := range series {
+		ts = ts[:0]
+		for _, l := range s.labels {
+			buf = buf[:0]
+			buf = protowire.AppendTag(buf, labelNameField, protowire.BytesType)
+			buf = protowire.AppendString(buf, l.name)
+			buf = protowire.AppendTag(buf, labelValueField, protowire.BytesType)
+			buf = protowire.AppendString(buf, l.value)
+			ts = protowire.AppendTag(ts, timeSeriesLabelsField, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, buf)
+		}
+		for _, smp := range s.samples {
+			buf = buf[:0]
+			buf = protowire.AppendTag(buf, sampleValueField, protowire.Fixed64Type)
+			buf = protowire.AppendFixed64(buf, math.Float64bits(smp.value))
+			buf = protowire.AppendTag(buf, sampleTimestampField, protowire.VarintType)
+			buf = protowire.AppendVarint(buf, uint64(smp.timestamp))
+			ts = protowire.AppendTag(ts, timeSeriesSamplesField, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, buf)
+		}
+		request = protowire.AppendTag(request, writeRequestTimeSeriesField, protowire.BytesType)
+		request = protowire.AppendBytes(request, ts)
+	}
+	families := make(map[string]struct{})
+	for _, s := range series {
+		m := s.metadata
+		if m == nil {
+			continue
+		}
+		if _, ok := families[m.family]; ok {
+			continue
+		}
+		families[m.family] = struct{}{}
+		buf = buf[:0]
+		buf = protowire.AppendTag(buf, metadataTypeField, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, uint64(m.mtype))
+		buf = protowire.AppendTag(buf, metadataFamilyNameField, protowire.BytesType)
+		buf = protowire.AppendString(buf, m.family)
+		buf = protowire.AppendTag(buf, metadataHelpField, protowire.BytesType)
+		buf = protowire.AppendString(buf, m.help)
+		request = protowire.AppendTag(request, writeRequestMetadataField, protowire.BytesType)
+		request = protowire.AppendBytes(request, buf)
+	}
+	return request
+}
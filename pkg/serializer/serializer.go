@@ -12,12 +12,16 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/forwarder"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/endpoints"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
 	"github.com/DataDog/datadog-agent/pkg/process/util/api/headers"
 	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
+	"github.com/DataDog/datadog-agent/pkg/serializer/remotewrite"
 	"github.com/DataDog/datadog-agent/pkg/serializer/split"
 	"github.com/DataDog/datadog-agent/pkg/serializer/stream"
 	"github.com/DataDog/datadog-agent/pkg/util/compression"
@@ -108,6 +112,20 @@ type Serializer struct {
 
 	seriesJSONPayloadBuilder *stream.JSONPayloadBuilder
 
+	// seriesBufferContext holds the compression buffers reused by every
+	// series protobuf payload sent to the v2 series intake.
+	seriesBufferContext      *marshaler.BufferContext
+	seriesBufferContextMutex sync.Mutex
+
+	// defaultCodec compresses the payloads, unless the endpoint they are
+	// sent to has its own codec in endpointCodecs.
+	defaultCodec   payloadCodec
+	endpointCodecs map[string]payloadCodec
+
+	// remoteWriteExporter also exports the series and sketches to a Prometheus
+	// remote write URL, nil if it is not configured.
+	remoteWriteExporter *remotewrite.Exporter
+
 	// Those variables allow users to blacklist any kind of payload
 	// from being sent by the agent. This was introduced for
 	// environment where, for example, events or serviceChecks
@@ -127,10 +145,14 @@ type Serializer struct {
 
 // NewSerializer returns a new Serializer initialized
 func NewSerializer(forwarder forwarder.Forwarder, orchestratorForwarder forwarder.Forwarder) *Serializer {
+	defaultCodec, endpointCodecs := newPayloadCodecsFromConfig()
 	s := &Serializer{
 		Forwarder:                     forwarder,
 		orchestratorForwarder:         orchestratorForwarder,
 		seriesJSONPayloadBuilder:      stream.NewJSONPayloadBuilder(config.Datadog.GetBool("enable_json_stream_shared_compressor_buffers")),
+		defaultCodec:                  defaultCodec,
+		endpointCodecs:                endpointCodecs,
+		remoteWriteExporter:           remotewrite.NewExporterFromConfig(),
 		enableEvents:                  config.Datadog.GetBool("enable_payloads.events"),
 		enableSeries:                  config.Datadog.GetBool("enable_payloads.series"),
 		enableServiceChecks:           config.Datadog.GetBool("enable_payloads.service_checks"),
@@ -142,6 +164,8 @@ func NewSerializer(forwarder forwarder.Forwarder, orchestratorForwarder forwarde
 		enableSketchProtobufStream:    stream.Available && config.Datadog.GetBool("enable_sketch_stream_payload_serialization"),
 	}
 
+	s.seriesBufferContext = marshaler.NewBufferContext(s.codecFor(endpoints.SeriesEndpoint).codec)
+
 	if !s.enableEvents {
 		log.Warn("event payloads are disabled: all events will be dropped")
 	}
@@ -161,37 +185,44 @@ func NewSerializer(forwarder forwarder.Forwarder, orchestratorForwarder forwarde
 	return s
 }
 
-func (s Serializer) serializePayload(payload marshaler.Marshaler, compress bool, useV1API bool) (forwarder.Payloads, http.Header, error) {
+// Stop stops the components started by the Serializer.
+func (s *Serializer) Stop() {
+	if s.remoteWriteExporter != nil {
+		s.remoteWriteExporter.Stop()
+	}
+}
+
+func (s *Serializer) serializePayload(payload marshaler.Marshaler, compress bool, useV1API bool, codec payloadCodec) (forwarder.Payloads, http.Header, error) {
 	if useV1API {
-		return s.serializePayloadJSON(payload, compress)
+		return s.serializePayloadJSON(payload, compress, codec)
 	}
-	return s.serializePayloadProto(payload, compress)
+	return s.serializePayloadProto(payload, compress, codec)
 }
 
-func (s Serializer) serializePayloadJSON(payload marshaler.JSONMarshaler, compress bool) (forwarder.Payloads, http.Header, error) {
+func (s *Serializer) serializePayloadJSON(payload marshaler.JSONMarshaler, compress bool, codec payloadCodec) (forwarder.Payloads, http.Header, error) {
 	var extraHeaders http.Header
 
 	if compress {
-		extraHeaders = jsonExtraHeadersWithCompression
+		extraHeaders = codec.jsonExtraHeaders
 	} else {
 		extraHeaders = jsonExtraHeaders
 	}
 
-	return s.serializePayloadInternal(payload, compress, extraHeaders, split.JSONMarshalFct)
+	return s.serializePayloadInternal(payload, compress, extraHeaders, split.JSONMarshalFct, codec)
 }
 
-func (s Serializer) serializePayloadProto(payload marshaler.ProtoMarshaler, compress bool) (forwarder.Payloads, http.Header, error) {
+func (s *Serializer) serializePayloadProto(payload marshaler.ProtoMarshaler, compress bool, codec payloadCodec) (forwarder.Payloads, http.Header, error) {
 	var extraHeaders http.Header
 	if compress {
-		extraHeaders = protobufExtraHeadersWithCompression
+		extraHeaders = codec.protobufExtraHeaders
 	} else {
 		extraHeaders = protobufExtraHeaders
 	}
-	return s.serializePayloadInternal(payload, compress, extraHeaders, split.ProtoMarshalFct)
+	return s.serializePayloadInternal(payload, compress, extraHeaders, split.ProtoMarshalFct, codec)
 }
 
-func (s Serializer) serializePayloadInternal(payload marshaler.AbstractMarshaler, compress bool, extraHeaders http.Header, marshalFct split.MarshalFct) (forwarder.Payloads, http.Header, error) {
-	payloads, err := split.Payloads(payload, compress, marshalFct)
+func (s *Serializer) serializePayloadInternal(payload marshaler.AbstractMarshaler, compress bool, extraHeaders http.Header, marshalFct split.MarshalFct, codec payloadCodec) (forwarder.Payloads, http.Header, error) {
+	payloads, err := split.PayloadsWithCodec(payload, compress, marshalFct, codec.codec)
 
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not split payload into small enough chunks: %s", err)
@@ -200,9 +231,9 @@ func (s Serializer) serializePayloadInternal(payload marshaler.AbstractMarshaler
 	return payloads, extraHeaders, nil
 }
 
-func (s Serializer) serializeStreamablePayload(payload marshaler.StreamJSONMarshaler, policy stream.OnErrItemTooBigPolicy) (forwarder.Payloads, http.Header, error) {
-	payloads, err := s.seriesJSONPayloadBuilder.BuildWithOnErrItemTooBigPolicy(payload, policy)
-	return payloads, jsonExtraHeadersWithCompression, err
+func (s *Serializer) serializeStreamablePayload(payload marshaler.StreamJSONMarshaler, policy stream.OnErrItemTooBigPolicy, codec payloadCodec) (forwarder.Payloads, http.Header, error) {
+	payloads, err := s.seriesJSONPayloadBuilder.BuildWithOnErrItemTooBigPolicy(payload, policy, codec.codec)
+	return payloads, codec.jsonExtraHeaders, err
 }
 
 // As events are gathered by SourceType, the serialization logic is more complex than for the other serializations.
@@ -214,10 +245,10 @@ func (s Serializer) serializeStreamablePayload(payload marshaler.StreamJSONMarsh
 // for performance reasons.
 //
 // If none of the previous methods work, we fallback to the old serialization method (Serializer.serializePayload).
-func (s Serializer) serializeEventsStreamJSONMarshalerPayload(
-	eventsStreamJSONMarshaler EventsStreamJSONMarshaler, useV1API bool) (forwarder.Payloads, http.Header, error) {
+func (s *Serializer) serializeEventsStreamJSONMarshalerPayload(
+	eventsStreamJSONMarshaler EventsStreamJSONMarshaler, useV1API bool, codec payloadCodec) (forwarder.Payloads, http.Header, error) {
 	marshaler := eventsStreamJSONMarshaler.CreateSingleMarshaler()
-	eventPayloads, extraHeaders, err := s.serializeStreamablePayload(marshaler, stream.FailOnErrItemTooBig)
+	eventPayloads, extraHeaders, err := s.serializeStreamablePayload(marshaler, stream.FailOnErrItemTooBig, codec)
 
 	if err == stream.ErrItemTooBig {
 		expvarsSendEventsErrItemTooBigs.Add(1)
@@ -225,12 +256,12 @@ func (s Serializer) serializeEventsStreamJSONMarshalerPayload(
 		// Do not use CreateMarshalersBySourceType when there are too many source types (Performance issue).
 		if marshaler.Len() > maxItemCountForCreateMarshalersBySourceType {
 			expvarsSendEventsErrItemTooBigsFallback.Add(1)
-			eventPayloads, extraHeaders, err = s.serializePayload(eventsStreamJSONMarshaler, true, useV1API)
+			eventPayloads, extraHeaders, err = s.serializePayload(eventsStreamJSONMarshaler, true, useV1API, codec)
 		} else {
 			eventPayloads = nil
 			for _, v := range eventsStreamJSONMarshaler.CreateMarshalersBySourceType() {
 				var eventPayloadsForSourceType forwarder.Payloads
-				eventPayloadsForSourceType, extraHeaders, err = s.serializeStreamablePayload(v, stream.DropItemOnErrItemTooBig)
+				eventPayloadsForSourceType, extraHeaders, err = s.serializeStreamablePayload(v, stream.DropItemOnErrItemTooBig, codec)
 				if err != nil {
 					return nil, nil, err
 				}
@@ -253,10 +284,15 @@ func (s *Serializer) SendEvents(e EventsStreamJSONMarshaler) error {
 	var extraHeaders http.Header
 	var err error
 
+	codec := s.codecFor(endpoints.EventsEndpoint)
+	if useV1API {
+		codec = s.codecFor(endpoints.V1IntakeEndpoint)
+	}
+
 	if useV1API && s.enableEventsJSONStream {
-		eventPayloads, extraHeaders, err = s.serializeEventsStreamJSONMarshalerPayload(e, useV1API)
+		eventPayloads, extraHeaders, err = s.serializeEventsStreamJSONMarshalerPayload(e, useV1API, codec)
 	} else {
-		eventPayloads, extraHeaders, err = s.serializePayload(e, true, useV1API)
+		eventPayloads, extraHeaders, err = s.serializePayload(e, true, useV1API, codec)
 	}
 	if err != nil {
 		return fmt.Errorf("dropping event payload: %s", err)
@@ -281,10 +317,15 @@ func (s *Serializer) SendServiceChecks(sc marshaler.StreamJSONMarshaler) error {
 	var extraHeaders http.Header
 	var err error
 
+	codec := s.codecFor(endpoints.ServiceChecksEndpoint)
+	if useV1API {
+		codec = s.codecFor(endpoints.V1CheckRunsEndpoint)
+	}
+
 	if useV1API && s.enableServiceChecksJSONStream {
-		serviceCheckPayloads, extraHeaders, err = s.serializeStreamablePayload(sc, stream.DropItemOnErrItemTooBig)
+		serviceCheckPayloads, extraHeaders, err = s.serializeStreamablePayload(sc, stream.DropItemOnErrItemTooBig, codec)
 	} else {
-		serviceCheckPayloads, extraHeaders, err = s.serializePayloadJSON(sc, true)
+		serviceCheckPayloads, extraHeaders, err = s.serializePayloadJSON(sc, true, codec)
 	}
 	if err != nil {
 		return fmt.Errorf("dropping service check payload: %s", err)
@@ -298,21 +339,36 @@ func (s *Serializer) SendServiceChecks(sc marshaler.StreamJSONMarshaler) error {
 
 // SendSeries serializes a list of serviceChecks and sends the payload to the forwarder
 func (s *Serializer) SendSeries(series marshaler.StreamJSONMarshaler) error {
+	if s.remoteWriteExporter != nil {
+		if series, ok := series.(metrics.Series); ok {
+			s.remoteWriteExporter.SendSeries(series)
+		}
+	}
+
 	if !s.enableSeries {
 		log.Debug("series payloads are disabled: dropping it")
 		return nil
 	}
 
-	const useV1API = true // v2 intake for series is not yet implemented
+	if config.Datadog.GetBool("use_v2_api.series") && stream.Available {
+		s.seriesBufferContextMutex.Lock()
+		payloads, err := series.MarshalSplitCompress(s.seriesBufferContext)
+		s.seriesBufferContextMutex.Unlock()
+		if err == nil {
+			return s.Forwarder.SubmitSeries(payloads, s.codecFor(endpoints.SeriesEndpoint).protobufExtraHeaders)
+		}
+		log.Warnf("Error: %v trying to stream compress series for the v2 intake - falling back to the v1 intake", err)
+	}
 
 	var seriesPayloads forwarder.Payloads
 	var extraHeaders http.Header
 	var err error
 
-	if useV1API && s.enableJSONStream {
-		seriesPayloads, extraHeaders, err = s.serializeStreamablePayload(series, stream.DropItemOnErrItemTooBig)
+	codec := s.codecFor(endpoints.V1SeriesEndpoint)
+	if s.enableJSONStream {
+		seriesPayloads, extraHeaders, err = s.serializeStreamablePayload(series, stream.DropItemOnErrItemTooBig, codec)
 	} else {
-		seriesPayloads, extraHeaders, err = s.serializePayloadJSON(series, true)
+		seriesPayloads, extraHeaders, err = s.serializePayloadJSON(series, true, codec)
 	}
 
 	if err != nil {
@@ -324,22 +380,29 @@ func (s *Serializer) SendSeries(series marshaler.StreamJSONMarshaler) error {
 
 // SendSketch serializes a list of SketSeriesList and sends the payload to the forwarder
 func (s *Serializer) SendSketch(sketches marshaler.Marshaler) error {
+	if s.remoteWriteExporter != nil {
+		if sketches, ok := sketches.(metrics.SketchSeriesList); ok {
+			s.remoteWriteExporter.SendSketches(sketches)
+		}
+	}
+
 	if !s.enableSketches {
 		log.Debug("sketches payloads are disabled: dropping it")
 		return nil
 	}
 
+	codec := s.codecFor(endpoints.SketchSeriesEndpoint)
 	if s.enableSketchProtobufStream {
-		payloads, err := sketches.MarshalSplitCompress(marshaler.DefaultBufferContext())
+		payloads, err := sketches.MarshalSplitCompress(marshaler.NewBufferContext(codec.codec))
 		if err == nil {
-			return s.Forwarder.SubmitSketchSeries(payloads, protobufExtraHeadersWithCompression)
+			return s.Forwarder.SubmitSketchSeries(payloads, codec.protobufExtraHeaders)
 		}
 		log.Warnf("Error: %v trying to stream compress SketchSeriesList - falling back to split/compress method", err)
 	}
 
 	compress := true
 	useV1API := false // Sketches only have a v2 endpoint
-	splitSketches, extraHeaders, err := s.serializePayload(sketches, compress, useV1API)
+	splitSketches, extraHeaders, err := s.serializePayload(sketches, compress, useV1API, codec)
 	if err != nil {
 		return fmt.Errorf("dropping sketch payload: %s", err)
 	}
@@ -349,21 +412,21 @@ func (s *Serializer) SendSketch(sketches marshaler.Marshaler) error {
 
 // SendMetadata serializes a metadata payload and sends it to the forwarder
 func (s *Serializer) SendMetadata(m marshaler.JSONMarshaler) error {
-	return s.sendMetadata(m, s.Forwarder.SubmitMetadata)
+	return s.sendMetadata(m, s.Forwarder.SubmitMetadata, s.codecFor(endpoints.MetadataEndpoint))
 }
 
 // SendHostMetadata serializes a metadata payload and sends it to the forwarder
 func (s *Serializer) SendHostMetadata(m marshaler.JSONMarshaler) error {
-	return s.sendMetadata(m, s.Forwarder.SubmitHostMetadata)
+	return s.sendMetadata(m, s.Forwarder.SubmitHostMetadata, s.codecFor(endpoints.V1IntakeEndpoint))
 }
 
 // SendAgentchecksMetadata serializes a metadata payload and sends it to the forwarder
 func (s *Serializer) SendAgentchecksMetadata(m marshaler.JSONMarshaler) error {
-	return s.sendMetadata(m, s.Forwarder.SubmitAgentChecksMetadata)
+	return s.sendMetadata(m, s.Forwarder.SubmitAgentChecksMetadata, s.codecFor(endpoints.V1IntakeEndpoint))
 }
 
-func (s *Serializer) sendMetadata(m marshaler.JSONMarshaler, submit func(payload forwarder.Payloads, extra http.Header) error) error {
-	mustSplit, compressedPayload, payload, err := split.CheckSizeAndSerialize(m, true, split.JSONMarshalFct)
+func (s *Serializer) sendMetadata(m marshaler.JSONMarshaler, submit func(payload forwarder.Payloads, extra http.Header) error, codec payloadCodec) error {
+	mustSplit, compressedPayload, payload, err := split.CheckSizeAndSerializeWithCodec(m, true, split.JSONMarshalFct, codec.codec)
 	if err != nil {
 		return fmt.Errorf("could not determine size of metadata payload: %s", err)
 	}
@@ -374,7 +437,7 @@ func (s *Serializer) sendMetadata(m marshaler.JSONMarshaler, submit func(payload
 		return fmt.Errorf("metadata payload was too big to send (%d bytes compressed, %d bytes uncompressed), metadata payloads cannot be split", len(compressedPayload), len(payload))
 	}
 
-	if err := submit(forwarder.Payloads{&compressedPayload}, jsonExtraHeadersWithCompression); err != nil {
+	if err := submit(forwarder.Payloads{&compressedPayload}, codec.jsonExtraHeaders); err != nil {
 		return err
 	}
 
@@ -394,11 +457,12 @@ func (s *Serializer) SendProcessesMetadata(data interface{}) error {
 	if err != nil {
 		return fmt.Errorf("could not serialize processes metadata payload: %s", err)
 	}
-	compressedPayload, err := compression.Compress(nil, payload)
+	codec := s.codecFor(endpoints.V1IntakeEndpoint)
+	compressedPayload, err := codec.codec.Compress(nil, payload)
 	if err != nil {
 		return fmt.Errorf("could not compress processes metadata payload: %s", err)
 	}
-	if err := s.Forwarder.SubmitV1Intake(forwarder.Payloads{&compressedPayload}, jsonExtraHeadersWithCompression); err != nil {
+	if err := s.Forwarder.SubmitV1Intake(forwarder.Payloads{&compressedPayload}, codec.jsonExtraHeaders); err != nil {
 		return err
 	}
 
@@ -10,7 +10,9 @@ package serializer
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/stretchr/testify/assert"
@@ -19,7 +21,9 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/forwarder"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
 	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
+	"github.com/DataDog/datadog-agent/pkg/serializer/stream"
 	"github.com/DataDog/datadog-agent/pkg/util/compression"
 )
 
@@ -135,6 +139,15 @@ func (p *testPayload) WriteItem(stream *jsoniter.Stream, i int) error {
 func (p *testPayload) Len() int                  { return 1 }
 func (p *testPayload) DescribeItem(i int) string { return "description" }
 
+// testStreamErrorPayload can only be serialized to JSON.
+type testStreamErrorPayload struct {
+	testPayload
+}
+
+func (p *testStreamErrorPayload) MarshalSplitCompress(bufferContext *marshaler.BufferContext) ([]*[]byte, error) {
+	return nil, fmt.Errorf("some error")
+}
+
 type testErrorPayload struct{}
 
 func (p *testErrorPayload) MarshalJSON() ([]byte, error) { return nil, fmt.Errorf("some error") }
@@ -311,6 +324,66 @@ func TestSendV1Series(t *testing.T) {
 	require.NotNil(t, err)
 }
 
+func TestSendV2Series(t *testing.T) {
+	if !stream.Available {
+		t.Skip("the v2 series payloads are built by the stream compressor")
+	}
+	config.Datadog.Set("use_v2_api.series", true)
+	defer config.Datadog.Set("use_v2_api.series", nil)
+	config.Datadog.Set("enable_stream_payload_serialization", false)
+	defer config.Datadog.Set("enable_stream_payload_serialization", nil)
+
+	f := &forwarder.MockedForwarder{}
+	payloads, _ := mkPayloads(protobufString, true)
+	f.On("SubmitSeries", payloads, protobufExtraHeadersWithCompression).Return(nil).Times(1)
+
+	s := NewSerializer(f, nil)
+
+	payload := &testPayload{}
+	err := s.SendSeries(payload)
+	require.Nil(t, err)
+	f.AssertExpectations(t)
+
+	// the v1 intake is used when the series cannot be encoded for the v2 intake
+	f.On("SubmitV1Series", jsonPayloads, jsonExtraHeadersWithCompression).Return(nil).Times(1)
+	err = s.SendSeries(&testStreamErrorPayload{})
+	require.Nil(t, err)
+	f.AssertExpectations(t)
+
+	errPayload := &testErrorPayload{}
+	err = s.SendSeries(errPayload)
+	require.NotNil(t, err)
+	f.AssertNumberOfCalls(t, "SubmitV1Series", 1)
+}
+
+func TestSendSeriesRemoteWrite(t *testing.T) {
+	received := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	config.Datadog.Set("metrics_remote_write_url", server.URL)
+	defer config.Datadog.Set("metrics_remote_write_url", "")
+	// the series are exported even if they are not sent to Datadog
+	config.Datadog.Set("enable_payloads.series", false)
+	defer config.Datadog.Set("enable_payloads.series", true)
+
+	f := &forwarder.MockedForwarder{}
+	s := NewSerializer(f, nil)
+	defer s.remoteWriteExporter.Stop()
+
+	err := s.SendSeries(metrics.Series{{Name: "my.metric", Points: []metrics.Point{{Ts: 1600000000, Value: 1}}}})
+	require.Nil(t, err)
+	f.AssertExpectations(t)
+
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timeout waiting for the remote write request")
+	}
+}
+
 func TestSendSketch(t *testing.T) {
 	f := &forwarder.MockedForwarder{}
 	payloads, _ := mkPayloads(protobufString, true)
@@ -371,6 +444,53 @@ func TestSendProcessesMetadata(t *testing.T) {
 	require.NotNil(t, err)
 }
 
+func TestSendWithEndpointCodec(t *testing.T) {
+	config.Datadog.Set("enable_stream_payload_serialization", false)
+	defer config.Datadog.Set("enable_stream_payload_serialization", nil)
+	config.Datadog.Set("serializer_compression_per_endpoint", map[string]interface{}{
+		"series_v1": map[string]interface{}{"kind": "gzip", "level": 1},
+	})
+	defer config.Datadog.Set("serializer_compression_per_endpoint", nil)
+
+	codec, err := compression.NewCodec(compression.GzipKind, 1)
+	require.NoError(t, err)
+	compressed, err := codec.Compress(nil, jsonString)
+	require.NoError(t, err)
+	expectedHeaders := jsonExtraHeaders.Clone()
+	expectedHeaders.Set("Content-Encoding", "gzip")
+
+	f := &forwarder.MockedForwarder{}
+	f.On("SubmitV1Series", forwarder.Payloads{&compressed}, expectedHeaders).Return(nil).Times(1)
+	// the other endpoints keep the default codec
+	f.On("SubmitMetadata", jsonPayloads, jsonExtraHeadersWithCompression).Return(nil).Times(1)
+
+	s := NewSerializer(f, nil)
+
+	require.NoError(t, s.SendSeries(&testPayload{}))
+	require.NoError(t, s.SendMetadata(&testPayload{}))
+	f.AssertExpectations(t)
+}
+
+func TestNewPayloadCodecsFromConfig(t *testing.T) {
+	config.Datadog.Set("serializer_compression_kind", "none")
+	defer config.Datadog.Set("serializer_compression_kind", nil)
+	config.Datadog.Set("serializer_compression_per_endpoint", map[string]interface{}{
+		"intake":      map[string]interface{}{"kind": "lz4"},
+		"sketches_v2": map[string]interface{}{"kind": "zlib", "level": 9},
+	})
+	defer config.Datadog.Set("serializer_compression_per_endpoint", nil)
+
+	defaultCodec, endpointCodecs := newPayloadCodecsFromConfig()
+	assert.Equal(t, compression.NoneKind, defaultCodec.codec.Kind())
+	assert.Equal(t, jsonExtraHeaders, defaultCodec.jsonExtraHeaders)
+	assert.Equal(t, protobufExtraHeaders, defaultCodec.protobufExtraHeaders)
+
+	// invalid codecs are replaced by the default one
+	require.Len(t, endpointCodecs, 1)
+	assert.Equal(t, compression.ZlibKind, endpointCodecs["sketches_v2"].codec.Kind())
+	assert.Equal(t, "deflate", endpointCodecs["sketches_v2"].protobufExtraHeaders.Get("Content-Encoding"))
+}
+
 func TestSendWithDisabledKind(t *testing.T) {
 	mockConfig := config.Mock()
 
@@ -406,6 +526,7 @@ func TestSendWithDisabledKind(t *testing.T) {
 	f.AssertNotCalled(t, "SubmitV1CheckRuns")
 	f.AssertNotCalled(t, "SubmitServiceChecks")
 	f.AssertNotCalled(t, "SubmitV1Series")
+	f.AssertNotCalled(t, "SubmitSeries")
 	f.AssertNotCalled(t, "SubmitSketchSeries")
 
 	// We never disable metadata
@@ -62,7 +62,12 @@ func init() {
 // CheckSizeAndSerialize Check the size of a payload and marshall it (optionally compress it)
 // The dual role makes sense as you will never serialize without checking the size of the payload
 func CheckSizeAndSerialize(m marshaler.AbstractMarshaler, compress bool, marshalFct MarshalFct) (bool, []byte, []byte, error) {
-	compressedPayload, payload, err := serializeMarshaller(m, compress, marshalFct)
+	return CheckSizeAndSerializeWithCodec(m, compress, marshalFct, compression.DefaultCodec)
+}
+
+// CheckSizeAndSerializeWithCodec is CheckSizeAndSerialize compressing the payload with codec
+func CheckSizeAndSerializeWithCodec(m marshaler.AbstractMarshaler, compress bool, marshalFct MarshalFct, codec compression.Codec) (bool, []byte, []byte, error) {
+	compressedPayload, payload, err := serializeMarshaller(m, compress, marshalFct, codec)
 	if err != nil {
 		return false, nil, nil, err
 	}
@@ -74,9 +79,14 @@ func CheckSizeAndSerialize(m marshaler.AbstractMarshaler, compress bool, marshal
 
 // Payloads serializes a metadata payload and sends it to the forwarder
 func Payloads(m marshaler.AbstractMarshaler, compress bool, marshalFct MarshalFct) (forwarder.Payloads, error) {
+	return PayloadsWithCodec(m, compress, marshalFct, compression.DefaultCodec)
+}
+
+// PayloadsWithCodec is Payloads compressing the payloads with codec
+func PayloadsWithCodec(m marshaler.AbstractMarshaler, compress bool, marshalFct MarshalFct, codec compression.Codec) (forwarder.Payloads, error) {
 	marshallers := []marshaler.AbstractMarshaler{m}
 	smallEnoughPayloads := forwarder.Payloads{}
-	tooBig, compressedPayload, _, err := CheckSizeAndSerialize(m, compress, marshalFct)
+	tooBig, compressedPayload, _, err := CheckSizeAndSerializeWithCodec(m, compress, marshalFct, codec)
 	if err != nil {
 		return smallEnoughPayloads, err
 	}
@@ -103,7 +113,7 @@ func Payloads(m marshaler.AbstractMarshaler, compress bool, marshalFct MarshalFc
 		for _, toSplit := range tempSlice {
 			var e error
 			// we have to do this every time to get the proper payload
-			compressedPayload, payload, e := serializeMarshaller(toSplit, compress, marshalFct)
+			compressedPayload, payload, e := serializeMarshaller(toSplit, compress, marshalFct, codec)
 			if e != nil {
 				return smallEnoughPayloads, e
 			}
@@ -125,7 +135,7 @@ func Payloads(m marshaler.AbstractMarshaler, compress bool, marshalFct MarshalFc
 			// after the payload has been split, loop through the chunks
 			for _, chunk := range chunks {
 				// serialize the payload
-				tooBigChunk, compressedPayload, _, err := CheckSizeAndSerialize(chunk, compress, marshalFct)
+				tooBigChunk, compressedPayload, _, err := CheckSizeAndSerializeWithCodec(chunk, compress, marshalFct, codec)
 				if err != nil {
 					log.Debugf("Error serializing a chunk: %s", err)
 					continue
@@ -159,7 +169,7 @@ func Payloads(m marshaler.AbstractMarshaler, compress bool, marshalFct MarshalFc
 }
 
 // serializeMarshaller serializes the marshaller and returns both the compressed and uncompressed payloads
-func serializeMarshaller(m marshaler.AbstractMarshaler, compress bool, marshalFct MarshalFct) ([]byte, []byte, error) {
+func serializeMarshaller(m marshaler.AbstractMarshaler, compress bool, marshalFct MarshalFct, codec compression.Codec) ([]byte, []byte, error) {
 	var payload []byte
 	var compressedPayload []byte
 	var err error
@@ -169,7 +179,7 @@ func serializeMarshaller(m marshaler.AbstractMarshaler, compress bool, marshalFc
 		return nil, nil, err
 	}
 	if compress {
-		compressedPayload, err = compression.Compress(nil, payload)
+		compressedPayload, err = codec.Compress(nil, payload)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -13,6 +13,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	jsoniter "github.com/json-iterator/go"
@@ -20,6 +21,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 )
 
 var (
@@ -104,7 +106,7 @@ func payloadToString(payload []byte) string {
 }
 
 func TestCompressorSimple(t *testing.T) {
-	c, err := NewCompressor(&bytes.Buffer{}, &bytes.Buffer{}, []byte("{["), []byte("]}"), []byte(","))
+	c, err := NewCompressor(&bytes.Buffer{}, &bytes.Buffer{}, []byte("{["), []byte("]}"), []byte(","), compression.DefaultCodec)
 	require.NoError(t, err)
 
 	for i := 0; i < 5; i++ {
@@ -183,3 +185,39 @@ func TestLockedCompressorProducesSamePayloads(t *testing.T) {
 
 	require.Equal(t, payloadToString(*payloads1[0]), payloadToString(*payloads2[0]))
 }
+
+func TestBuildWithCodecs(t *testing.T) {
+	m := &dummyMarshaller{
+		header: "{[",
+		footer: "]}",
+	}
+	for i := 0; i < 50; i++ {
+		m.items = append(m.items, fmt.Sprintf("item-%02d", i))
+	}
+	config.Datadog.SetDefault("serializer_max_payload_size", 120)
+	defer resetDefaults()
+
+	for _, kind := range []string{compression.ZlibKind, compression.GzipKind, compression.ZstdKind, compression.NoneKind} {
+		t.Run(kind, func(t *testing.T) {
+			codec, err := compression.NewCodec(kind, 1)
+			if kind == compression.ZstdKind && err != nil {
+				t.Skip(err)
+			}
+			require.NoError(t, err)
+
+			builder := NewJSONPayloadBuilder(true)
+			payloads, err := builder.BuildWithOnErrItemTooBigPolicy(m, DropItemOnErrItemTooBig, codec)
+			require.NoError(t, err)
+			require.Greater(t, len(payloads), 1)
+
+			var items []string
+			for _, payload := range payloads {
+				decompressed, err := codec.Decompress(nil, *payload)
+				require.NoError(t, err)
+				require.Regexp(t, `^\{\[.*\]\}$`, string(decompressed))
+				items = append(items, strings.Split(string(decompressed[2:len(decompressed)-2]), ",")...)
+			}
+			require.Equal(t, m.items, items)
+		})
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/forwarder"
 	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
 	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
@@ -98,13 +99,14 @@ const (
 
 // Build serializes a metadata payload and sends it to the forwarder
 func (b *JSONPayloadBuilder) Build(m marshaler.StreamJSONMarshaler) (forwarder.Payloads, error) {
-	return b.BuildWithOnErrItemTooBigPolicy(m, DropItemOnErrItemTooBig)
+	return b.BuildWithOnErrItemTooBigPolicy(m, DropItemOnErrItemTooBig, compression.DefaultCodec)
 }
 
-// BuildWithOnErrItemTooBigPolicy serializes a metadata payload and sends it to the forwarder
+// BuildWithOnErrItemTooBigPolicy serializes a metadata payload, compressed with codec, and sends it to the forwarder
 func (b *JSONPayloadBuilder) BuildWithOnErrItemTooBigPolicy(
 	m marshaler.StreamJSONMarshaler,
-	policy OnErrItemTooBigPolicy) (forwarder.Payloads, error) {
+	policy OnErrItemTooBigPolicy,
+	codec compression.Codec) (forwarder.Payloads, error) {
 
 	var input, output *bytes.Buffer
 	if b.shareAndLockBuffers {
@@ -151,7 +153,7 @@ func (b *JSONPayloadBuilder) BuildWithOnErrItemTooBigPolicy(
 		return nil, err
 	}
 
-	compressor, err := NewCompressor(input, output, header.Bytes(), footer.Bytes(), []byte(","))
+	compressor, err := NewCompressor(input, output, header.Bytes(), footer.Bytes(), []byte(","), codec)
 	if err != nil {
 		return nil, err
 	}
@@ -181,7 +183,7 @@ func (b *JSONPayloadBuilder) BuildWithOnErrItemTooBigPolicy(
 			payloads = append(payloads, &payload)
 			input.Reset()
 			output.Reset()
-			compressor, err = NewCompressor(input, output, header.Bytes(), footer.Bytes(), []byte(","))
+			compressor, err = NewCompressor(input, output, header.Bytes(), footer.Bytes(), []byte(","), codec)
 			if err != nil {
 				return nil, err
 			}
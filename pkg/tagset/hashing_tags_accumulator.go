@@ -68,6 +68,21 @@ func (h *HashingTagsAccumulator) SortUniq() {
 	h.Truncate(j + 1)
 }
 
+// Retain removes in place the tags for which keep returns false
+func (h *HashingTagsAccumulator) Retain(keep func(tag string) bool) {
+	j := 0
+	for i := 0; i < len(h.data); i++ {
+		if !keep(h.data[i]) {
+			continue
+		}
+		h.data[j] = h.data[i]
+		h.hash[j] = h.hash[i]
+		j++
+	}
+
+	h.Truncate(j)
+}
+
 // Get returns the internal slice
 func (h *HashingTagsAccumulator) Get() []string {
 	return h.data
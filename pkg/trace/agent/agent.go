@@ -8,6 +8,7 @@ package agent
 import (
 	"context"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -23,6 +24,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
 	"github.com/DataDog/datadog-agent/pkg/trace/stats"
 	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
 	"github.com/DataDog/datadog-agent/pkg/trace/writer"
 	"github.com/DataDog/datadog-agent/pkg/util/fargate"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
@@ -46,6 +48,8 @@ type Agent struct {
 	ErrorsSampler         *sampler.ErrorsSampler
 	RareSampler           *sampler.RareSampler
 	NoPrioritySampler     *sampler.NoPrioritySampler
+	TailSampler           *sampler.TailSampler      // nil when the tail-based sampling is disabled
+	FiltersSubscriber     *config.FiltersSubscriber // nil when the remote filters are disabled
 	EventProcessor        *event.Processor
 	TraceWriter           *writer.TraceWriter
 	StatsWriter           *writer.StatsWriter
@@ -62,6 +66,9 @@ type Agent struct {
 
 	// Used to synchronize on a clean exit
 	ctx context.Context
+
+	// workers is the group of the goroutines processing the payloads of In
+	workers sync.WaitGroup
 }
 
 // NewAgent returns a new Agent object, ready to be started. It takes a context
@@ -88,6 +95,12 @@ func NewAgent(ctx context.Context, conf *config.AgentConfig) *Agent {
 		conf:                  conf,
 		ctx:                   ctx,
 	}
+	if conf.TailSampling != nil && conf.TailSampling.Enabled {
+		agnt.TailSampler = sampler.NewTailSampler(conf.TailSampling, agnt.writeTailSampled)
+	}
+	if features.Has("remote_filters") {
+		agnt.FiltersSubscriber = config.NewFiltersSubscriber(agnt.loadRemoteFilters)
+	}
 	agnt.Receiver = api.NewHTTPReceiver(conf, dynConf, in, agnt)
 	agnt.OTLPReceiver = api.NewOTLPReceiver(in, conf.OTLPReceiver)
 	return agnt
@@ -107,11 +120,23 @@ func (a *Agent) Run() {
 	} {
 		starter.Start()
 	}
+	if a.TailSampler != nil {
+		a.TailSampler.Start()
+		go a.reportTailSampling()
+	}
+	if a.FiltersSubscriber != nil {
+		if err := a.FiltersSubscriber.Start(); err != nil {
+			log.Errorf("Error when subscribing to remote filters: %v", err)
+		} else {
+			go a.reportRemoteFilters()
+		}
+	}
 
 	go a.TraceWriter.Run()
 	go a.StatsWriter.Run()
 
 	for i := 0; i < runtime.NumCPU(); i++ {
+		a.workers.Add(1)
 		go a.work()
 	}
 
@@ -137,6 +162,7 @@ func (a *Agent) FlushSync() {
 }
 
 func (a *Agent) work() {
+	defer a.workers.Done()
 	for {
 		select {
 		case p, ok := <-a.In:
@@ -154,9 +180,16 @@ func (a *Agent) loop() {
 		select {
 		case <-a.ctx.Done():
 			log.Info("Exiting...")
+			// the OTLP receiver is stopped first, as it sends its payloads to the channel
+			// closed by the HTTP receiver
+			a.OTLPReceiver.Stop()
 			if err := a.Receiver.Stop(); err != nil {
 				log.Error(err)
 			}
+			a.stopTailSampler()
+			if a.FiltersSubscriber != nil {
+				a.FiltersSubscriber.Stop()
+			}
 			for _, stopper := range []interface{ Stop() }{
 				a.Concentrator,
 				a.ClientStatsAggregator,
@@ -167,7 +200,6 @@ func (a *Agent) loop() {
 				a.NoPrioritySampler,
 				a.RareSampler,
 				a.EventProcessor,
-				a.OTLPReceiver,
 				a.obfuscator,
 			} {
 				stopper.Stop()
@@ -177,6 +209,16 @@ func (a *Agent) loop() {
 	}
 }
 
+// stopTailSampler stops the tail sampler, once the workers are done with the payloads left in
+// In, which is closed by the receiver: no trace is added to the tail sampler after it stops, and
+// the traces still buffered are decided and written before the writer stops.
+func (a *Agent) stopTailSampler() {
+	a.workers.Wait()
+	if a.TailSampler != nil {
+		a.TailSampler.Stop()
+	}
+}
+
 // Process is the default work unit that receives a trace, transforms it and
 // passes it downstream.
 func (a *Agent) Process(p *api.Payload) {
@@ -375,7 +417,13 @@ func (a *Agent) sample(ts *info.TagStats, pt ProcessedTrace) (events []*pb.Span,
 		return nil, false
 	}
 
-	sampled := a.runSamplers(pt, hasPriority)
+	// traces explicitly kept by the user are always kept
+	sampled := priority >= sampler.PriorityUserKeep || a.runSamplers(pt, hasPriority)
+	if a.TailSampler != nil {
+		// the tail sampler may also keep the trace, once it is complete. The events are
+		// extracted now, whether or not the tail sampler keeps the trace later on.
+		a.TailSampler.Add(pt.Trace, sampled)
+	}
 
 	events, numExtracted := a.EventProcessor.Process(pt.Root, pt.Trace)
 
@@ -416,6 +464,85 @@ func (a *Agent) sampleNoPriorityTrace(pt ProcessedTrace) bool {
 	return a.NoPrioritySampler.Sample(pt.Trace, pt.Root, pt.Env)
 }
 
+// writeTailSampled sends the chunks of the traces kept by the tail sampler to the trace writer.
+// The other samplers dropped them, their roots are marked as kept by the agent.
+func (a *Agent) writeTailSampled(chunks pb.Traces) {
+	ss := new(writer.SampledSpans)
+	for _, t := range chunks {
+		if root := traceutil.GetRoot(t); root != nil {
+			sampler.SetSamplingPriority(root, sampler.PriorityAutoKeep)
+		}
+		ss.Traces = append(ss.Traces, traceutil.APITrace(t))
+		ss.Size += t.Msgsize()
+		ss.SpanCount += int64(len(t))
+		if ss.Size > writer.MaxPayloadSize {
+			a.TraceWriter.In <- ss
+			ss = new(writer.SampledSpans)
+		}
+	}
+	if ss.Size > 0 {
+		a.TraceWriter.In <- ss
+	}
+}
+
+// reportTailSampling periodically updates the info about the tail sampler, until the agent exits.
+func (a *Agent) reportTailSampling() {
+	defer watchdog.LogOnPanic()
+	tick := time.NewTicker(10 * time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			s := a.TailSampler.Stats()
+			info.UpdateTailSampling(info.TailSamplingStats{
+				Enabled:        true,
+				TracesKept:     s.TracesKept,
+				TracesDropped:  s.TracesDropped,
+				TracesEvicted:  s.TracesEvicted,
+				SpansLate:      s.SpansLate,
+				TracesBuffered: s.TracesBuffered,
+				SpansBuffered:  s.SpansBuffered,
+			})
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+// loadRemoteFilters applies the span filtering rules received through remote configuration,
+// in addition to the ones of the agent's configuration.
+func (a *Agent) loadRemoteFilters(f *config.RemoteFilters) {
+	rules := make([]*config.ReplaceRule, 0, len(a.conf.ReplaceTags)+len(f.ReplaceTags))
+	rules = append(rules, a.conf.ReplaceTags...)
+	a.Replacer.UpdateRules(append(rules, f.ReplaceTags...))
+
+	ignore := make([]string, 0, len(a.conf.Ignore["resource"])+len(f.IgnoreResources))
+	ignore = append(ignore, a.conf.Ignore["resource"]...)
+	a.Blacklister.UpdateList(append(ignore, f.IgnoreResources...))
+}
+
+// reportRemoteFilters periodically updates the info about the remote filters, until the agent exits.
+func (a *Agent) reportRemoteFilters() {
+	defer watchdog.LogOnPanic()
+	tick := time.NewTicker(10 * time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			s := a.FiltersSubscriber.Status()
+			info.UpdateRemoteFilters(info.RemoteFiltersStats{
+				Enabled:         true,
+				Version:         s.Version,
+				ReplaceTags:     s.ReplaceTags,
+				IgnoreResources: s.IgnoreResources,
+				LastError:       s.LastError,
+			})
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
 func traceContainsError(trace pb.Trace) bool {
 	for _, span := range trace {
 		if span.Error != 0 {
@@ -1180,3 +1180,172 @@ func TestMergeDuplicates(t *testing.T) {
 	mergeDuplicates(in)
 	assert.Equal(t, expected, in)
 }
+
+func TestLoadRemoteFilters(t *testing.T) {
+	assert := assert.New(t)
+	conf := &config.AgentConfig{
+		ReplaceTags: []*config.ReplaceRule{{Name: "http.url", Pattern: "token=[^&]*", Re: regexp.MustCompile("token=[^&]*"), Repl: "token=?"}},
+		Ignore:      map[string][]string{"resource": {"^/health$"}},
+	}
+	a := Agent{
+		Blacklister: filters.NewBlacklister(conf.Ignore["resource"]),
+		Replacer:    filters.NewReplacer(conf.ReplaceTags),
+		conf:        conf,
+	}
+	replace := func() map[string]string {
+		span := &pb.Span{Meta: map[string]string{"http.url": "/users?token=abc", "user.email": "jane@example.com"}}
+		a.Replacer.Replace(pb.Trace{span})
+		return span.Meta
+	}
+	allows := func(resource string) bool {
+		return a.Blacklister.Allows(&pb.Span{Resource: resource})
+	}
+
+	a.loadRemoteFilters(&config.RemoteFilters{
+		ReplaceTags:     []*config.ReplaceRule{{Name: "user.email", Pattern: "^.*$", Re: regexp.MustCompile("^.*$"), Repl: "?"}},
+		IgnoreResources: []string{"^/internal/"},
+	})
+	assert.Equal(map[string]string{"http.url": "/users?token=?", "user.email": "?"}, replace())
+	assert.False(allows("/health"))
+	assert.False(allows("/internal/metrics"))
+	assert.True(allows("/users"))
+
+	// the rules of the agent's configuration are kept when the remote ones are removed
+	a.loadRemoteFilters(&config.RemoteFilters{})
+	assert.Equal(map[string]string{"http.url": "/users?token=?", "user.email": "jane@example.com"}, replace())
+	assert.False(allows("/health"))
+	assert.True(allows("/internal/metrics"))
+}
+
+func TestSampleWithTailSampler(t *testing.T) {
+	cfg := &config.AgentConfig{}
+	sampledCfg := &config.AgentConfig{ExtraSampleRate: 1}
+	for name, tt := range map[string]struct {
+		priority    sampler.SamplingPriority
+		hasPriority bool
+		hasErrors   bool
+		wantSampled bool
+	}{
+		"user-keep": {
+			priority:    sampler.PriorityUserKeep,
+			hasPriority: true,
+			wantSampled: true,
+		},
+		"error": {
+			hasErrors:   true,
+			wantSampled: true,
+		},
+		"error-prio-unsampled": {
+			hasPriority: true,
+			hasErrors:   true,
+			wantSampled: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var tailKept pb.Traces
+			a := &Agent{
+				NoPrioritySampler: sampler.NewNoPrioritySampler(cfg),
+				ErrorsSampler:     sampler.NewErrorsSampler(sampledCfg),
+				PrioritySampler:   sampler.NewPrioritySampler(cfg, &sampler.DynamicConfig{}),
+				RareSampler:       sampler.NewRareSampler(),
+				EventProcessor:    newEventProcessor(cfg),
+				// no policy matches the traces
+				TailSampler: sampler.NewTailSampler(&config.TailSamplingConfig{
+					Enabled:             true,
+					DecisionWaitSeconds: 10,
+					MaxTraces:           10,
+					MaxSpans:            100,
+					Policies:            []*config.TailSamplingPolicy{{Service: "other", Percentage: 100}},
+				}, func(chunks pb.Traces) { tailKept = append(tailKept, chunks...) }),
+			}
+			a.TailSampler.Start()
+			root := &pb.Span{
+				TraceID:  1,
+				SpanID:   1,
+				Service:  "serv1",
+				Start:    time.Now().UnixNano(),
+				Duration: (100 * time.Millisecond).Nanoseconds(),
+				Metrics:  map[string]float64{},
+			}
+			if tt.hasErrors {
+				root.Error = 1
+			}
+			if tt.hasPriority {
+				sampler.SetSamplingPriority(root, tt.priority)
+			}
+			_, sampled := a.sample(info.NewReceiverStats().GetTagStats(info.Tags{}), ProcessedTrace{Trace: pb.Trace{root}, Root: root})
+			assert.Equal(t, tt.wantSampled, sampled)
+
+			// the trace is also handed to the tail sampler, which doesn't keep it a second time
+			assert.EqualValues(t, 1, a.TailSampler.Stats().TracesBuffered)
+			a.TailSampler.Stop()
+			assert.Empty(t, tailKept)
+		})
+	}
+}
+
+func TestWriteTailSampled(t *testing.T) {
+	a := &Agent{TraceWriter: &writer.TraceWriter{In: make(chan *writer.SampledSpans, 1)}}
+	root := &pb.Span{TraceID: 1, SpanID: 1, Metrics: map[string]float64{sampler.KeySamplingPriority: float64(sampler.PriorityAutoDrop)}}
+	child := &pb.Span{TraceID: 1, SpanID: 2, ParentID: 1}
+	// a chunk flushed separately from its parent
+	late := &pb.Span{TraceID: 1, SpanID: 3, ParentID: 2}
+	a.writeTailSampled(pb.Traces{{root, child}, {late}})
+
+	ss := <-a.TraceWriter.In
+	assert.Len(t, ss.Traces, 2)
+	assert.EqualValues(t, 3, ss.SpanCount)
+	// the roots of the chunks are marked as kept
+	for _, span := range []*pb.Span{root, late} {
+		priority, ok := sampler.GetSamplingPriority(span)
+		assert.True(t, ok)
+		assert.Equal(t, sampler.PriorityAutoKeep, priority)
+	}
+	_, ok := sampler.GetSamplingPriority(child)
+	assert.False(t, ok)
+}
+
+func TestStopTailSamplerAfterWorkers(t *testing.T) {
+	cfg := config.New()
+	cfg.Endpoints[0].APIKey = "test"
+	cfg.TailSampling = &config.TailSamplingConfig{
+		Enabled:             true,
+		DecisionWaitSeconds: 10,
+		MaxTraces:           1000,
+		MaxSpans:            1000,
+		// no policy matches the traces
+		Policies: []*config.TailSamplingPolicy{{Service: "other", Percentage: 100}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agnt := NewAgent(ctx, cfg)
+	agnt.TailSampler.Start()
+
+	const traceCount = 200
+	for i := 0; i < traceCount; i++ {
+		agnt.In <- &api.Payload{
+			Traces: pb.Traces{{{
+				TraceID:  uint64(i + 1),
+				SpanID:   1,
+				Service:  "serv1",
+				Name:     "request",
+				Start:    time.Now().UnixNano(),
+				Duration: (100 * time.Millisecond).Nanoseconds(),
+			}}},
+			Source:              info.NewReceiverStats().GetTagStats(info.Tags{}),
+			ClientComputedStats: true,
+		}
+	}
+	for i := 0; i < 4; i++ {
+		agnt.workers.Add(1)
+		go agnt.work()
+	}
+	// the receiver closes In when it stops
+	close(agnt.In)
+	agnt.stopTailSampler()
+
+	// every trace was added before the tail sampler stopped, and decided when it stopped
+	stats := agnt.TailSampler.Stats()
+	assert.EqualValues(t, traceCount, stats.TracesDropped)
+	assert.Zero(t, stats.TracesBuffered)
+}
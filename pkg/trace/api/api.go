@@ -7,6 +7,7 @@ package api
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"expvar"
@@ -120,7 +121,7 @@ func (r *HTTPReceiver) buildMux() *http.ServeMux {
 	hash, infoHandler := r.makeInfoHandler()
 	r.attachDebugHandlers(mux)
 	for _, e := range endpoints {
-		if e.IsEnabled != nil && !e.IsEnabled() {
+		if e.IsEnabled != nil && !e.IsEnabled(r.conf) {
 			continue
 		}
 		mux.Handle(e.Pattern, replyWithVersion(hash, e.Handler(r)))
@@ -550,7 +551,7 @@ func (r *HTTPReceiver) handleTraces(v Version, w http.ResponseWriter, req *http.
 	atomic.AddInt64(&ts.PayloadAccepted, 1)
 
 	cid := req.Header.Get(headerContainerID)
-	payload := &Payload{
+	r.sendPayload(&Payload{
 		Source:                 ts,
 		Traces:                 traces,
 		ContainerID:            cid,
@@ -558,8 +559,72 @@ func (r *HTTPReceiver) handleTraces(v Version, w http.ResponseWriter, req *http.
 		ClientComputedTopLevel: req.Header.Get(headerComputedTopLevel) != "",
 		ClientComputedStats:    req.Header.Get(headerComputedStats) != "",
 		ClientDroppedP0s:       droppedTracesFromHeader(req.Header, ts),
+	})
+}
+
+// handleConvertedTraces returns a handler for the traces sent in a non Datadog format, such as Zipkin
+// or Jaeger, which are decoded from the request body and converted to Datadog traces by decode. The
+// spans skipped by decode are counted as dropped.
+func (r *HTTPReceiver) handleConvertedTraces(decode func(mediaType string, body []byte) (pb.Traces, int64, error)) func(Version, http.ResponseWriter, *http.Request) {
+	return func(v Version, w http.ResponseWriter, req *http.Request) {
+		ts := r.tagStats(v, req.Header)
+		start := time.Now()
+		body, err := r.readBody(req)
+		var (
+			traces  pb.Traces
+			skipped int64
+		)
+		if err == nil {
+			traces, skipped, err = decode(getMediaType(req), body)
+		}
+		tags := append(ts.AsTags(), fmt.Sprintf("success:%v", err == nil))
+		metrics.Histogram("datadog.trace_agent.receiver.serve_traces_ms", float64(time.Since(start))/float64(time.Millisecond), tags, 1)
+		if err != nil {
+			httpDecodingError(err, []string{"handler:traces", fmt.Sprintf("v:%s", v)}, w)
+			log.Errorf("Cannot decode %s traces payload: %v", v, err)
+			return
+		}
+		if skipped > 0 {
+			// the invalid spans are dropped, the rest of the payload is kept
+			atomic.AddInt64(&ts.SpansDropped, skipped)
+		}
+		if r.rateLimited(int64(len(traces))) {
+			w.WriteHeader(r.rateLimiterResponse)
+			atomic.AddInt64(&ts.PayloadRefused, 1)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+
+		atomic.AddInt64(&ts.TracesReceived, int64(len(traces)))
+		atomic.AddInt64(&ts.TracesBytes, req.Body.(*apiutil.LimitedReader).Count)
+		atomic.AddInt64(&ts.PayloadAccepted, 1)
+
+		cid := req.Header.Get(headerContainerID)
+		r.sendPayload(&Payload{
+			Source:        ts,
+			Traces:        traces,
+			ContainerID:   cid,
+			ContainerTags: getContainerTags(cid),
+		})
 	}
+}
+
+// readBody reads the body of req, decompressing it when it is gzipped.
+func (r *HTTPReceiver) readBody(req *http.Request) ([]byte, error) {
+	var rd io.Reader = req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gzipr, err := gzip.NewReader(rd)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipr.Close()
+		rd = apiutil.NewLimitedReader(ioutil.NopCloser(gzipr), r.conf.MaxRequestBytes)
+	}
+	return ioutil.ReadAll(rd)
+}
 
+// sendPayload sends payload to the out channel, without blocking the caller when it is full.
+func (r *HTTPReceiver) sendPayload(payload *Payload) {
 	select {
 	case r.out <- payload:
 		// ok
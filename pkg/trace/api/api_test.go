@@ -839,6 +839,32 @@ func TestConfigEndpoint(t *testing.T) {
 	}
 }
 
+func TestZipkinAndJaegerEndpointsEnabled(t *testing.T) {
+	for _, path := range []string{"/api/v2/spans", "/api/traces"} {
+		t.Run(path, func(t *testing.T) {
+			post := func(conf *config.AgentConfig) int {
+				rcv := newTestReceiverFromConfig(conf)
+				server := httptest.NewServer(rcv.buildMux())
+				defer server.Close()
+				resp, err := http.Post(server.URL+path, "application/json", strings.NewReader("[]"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				return resp.StatusCode
+			}
+
+			conf := newTestReceiverConfig()
+			assert.Equal(t, http.StatusNotFound, post(conf))
+
+			conf = newTestReceiverConfig()
+			conf.ZipkinReceiverEnabled = true
+			conf.JaegerReceiverEnabled = true
+			assert.NotEqual(t, http.StatusNotFound, post(conf))
+		})
+	}
+}
+
 func TestClientDropP0s(t *testing.T) {
 	conf := newTestReceiverConfig()
 	rcv := newTestReceiverFromConfig(conf)
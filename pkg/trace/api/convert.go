@@ -0,0 +1,122 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// This file holds the helpers shared by the receivers of the spans sent in non Datadog
+// formats (Zipkin, Jaeger), which convert them to Datadog spans.
+
+// spanEvent is an event which happened during a converted span. The events are marshalled
+// in the "events" tag, like the events of the OpenTelemetry spans.
+type spanEvent struct {
+	TimeUnixNano uint64            `json:"time_unix_nano,omitempty"`
+	Name         string            `json:"name,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// marshalSpanEvents marshals events into JSON.
+func marshalSpanEvents(events []spanEvent) string {
+	b, err := json.Marshal(events)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// finishConvertedSpan sets the fields of span which are deduced from its kind and its tags,
+// once they are all set. kind is the lower case span kind: server, client, producer, consumer
+// or internal.
+func finishConvertedSpan(span *pb.Span, kind string) {
+	if r := resourceFromTags(span.Meta); r != "" {
+		span.Resource = r
+	}
+	if _, ok := span.Meta["env"]; !ok {
+		if env := span.Meta["deployment.environment"]; env != "" {
+			span.Meta["env"] = env
+		}
+	}
+	switch kind {
+	case "server":
+		span.Type = "web"
+	case "client":
+		span.Type = "http"
+		db := span.Meta["db.system"]
+		if db == "" {
+			// OpenTracing semantic conventions
+			db = span.Meta["db.type"]
+		}
+		switch db {
+		case "":
+		case "redis", "memcached":
+			span.Type = "cache"
+		default:
+			span.Type = "db"
+		}
+	default:
+		span.Type = "custom"
+	}
+}
+
+// groupByTraceID groups spans in traces, in the order in which the traces first appear.
+func groupByTraceID(spans []*pb.Span) pb.Traces {
+	var traces pb.Traces
+	index := make(map[uint64]int)
+	for _, span := range spans {
+		i, ok := index[span.TraceID]
+		if !ok {
+			i = len(traces)
+			index[span.TraceID] = i
+			traces = append(traces, pb.Trace{})
+		}
+		traces[i] = append(traces[i], span)
+	}
+	return traces
+}
+
+// forEachProtoField calls f for each field of the protobuf message b, with the value of the
+// field in v for the integer and fixed size types or in bytes for the length-delimited types.
+func forEachProtoField(b []byte, f func(num protowire.Number, typ protowire.Type, v uint64, bytes []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var (
+			v     uint64
+			bytes []byte
+		)
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v32 uint32
+			v32, n = protowire.ConsumeFixed32(b)
+			v = uint64(v32)
+		case protowire.BytesType:
+			bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := f(num, typ, v, bytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
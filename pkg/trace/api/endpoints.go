@@ -8,6 +8,7 @@ package api
 import (
 	"net/http"
 
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/config/features"
 )
 
@@ -25,7 +26,7 @@ type endpoint struct {
 
 	// IsEnabled specifies a function which reports whether this endpoint should be enabled
 	// based on the given config conf.
-	IsEnabled func() bool
+	IsEnabled func(conf *config.AgentConfig) bool
 }
 
 // endpoints specifies the list of endpoints registered for the trace-agent API.
@@ -96,9 +97,23 @@ var endpoints = []endpoint{
 		Pattern: "/debugger/v1/input",
 		Handler: func(r *HTTPReceiver) http.Handler { return r.debuggerProxyHandler() },
 	},
+	{
+		Pattern: "/api/v2/spans",
+		Handler: func(r *HTTPReceiver) http.Handler {
+			return r.handleWithVersion(zipkinV2, r.handleConvertedTraces(decodeZipkin))
+		},
+		IsEnabled: func(conf *config.AgentConfig) bool { return conf.ZipkinReceiverEnabled },
+	},
+	{
+		Pattern: "/api/traces",
+		Handler: func(r *HTTPReceiver) http.Handler {
+			return r.handleWithVersion(jaeger, r.handleConvertedTraces(decodeJaeger))
+		},
+		IsEnabled: func(conf *config.AgentConfig) bool { return conf.JaegerReceiverEnabled },
+	},
 	{
 		Pattern:   "/v0.6/config",
 		Handler:   func(r *HTTPReceiver) http.Handler { return http.HandlerFunc(r.handleConfig) },
-		IsEnabled: func() bool { return features.Has("config_endpoint") },
+		IsEnabled: func(_ *config.AgentConfig) bool { return features.Has("config_endpoint") },
 	},
 }
@@ -98,9 +98,10 @@ func TestInfoHandler(t *testing.T) {
 	}
 
 	var testCases = []struct {
-		name                 string
-		expected             string
-		enableConfigEndpoint bool
+		name                  string
+		expected              string
+		enableConfigEndpoint  bool
+		enableZipkinAndJaeger bool
 	}{
 		{
 			name: "default",
@@ -157,8 +158,9 @@ func TestInfoHandler(t *testing.T) {
 }`,
 		},
 		{
-			name:                 "debug",
-			enableConfigEndpoint: true,
+			name:                  "debug",
+			enableConfigEndpoint:  true,
+			enableZipkinAndJaeger: true,
 			expected: `{
 	"version": "0.99.0",
 	"git_commit": "fab047e10",
@@ -173,6 +175,8 @@ func TestInfoHandler(t *testing.T) {
 		"/v0.6/stats",
 		"/appsec/proxy/",
 		"/debugger/v1/input",
+		"/api/v2/spans",
+		"/api/traces",
 		"/v0.6/config"
 	],
 	"feature_flags": [
@@ -215,6 +219,8 @@ func TestInfoHandler(t *testing.T) {
 	}
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
+			conf.ZipkinReceiverEnabled = tt.enableZipkinAndJaeger
+			conf.JaegerReceiverEnabled = tt.enableZipkinAndJaeger
 			rcv := newTestReceiverFromConfig(conf)
 			if tt.enableConfigEndpoint {
 				defer testutil.WithFeatures("config_endpoint")()
@@ -0,0 +1,421 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// jaegerFlagDebug is the bit of the Jaeger span flags which marks the debug spans.
+const jaegerFlagDebug = 0x2
+
+// jaegerBatch holds the spans of a Jaeger batch, decoded from either the Thrift or the
+// protobuf encoding, see https://www.jaegertracing.io/docs/latest/apis/.
+type jaegerBatch struct {
+	process *jaegerProcess
+	spans   []*jaegerSpan
+}
+
+// jaegerProcess describes the traced process which emitted the spans.
+type jaegerProcess struct {
+	serviceName string
+	tags        []jaegerTag
+}
+
+// jaegerSpan is a Jaeger span. The times are in nanoseconds.
+type jaegerSpan struct {
+	traceIDLow    uint64
+	traceIDHigh   uint64
+	spanID        uint64
+	parentSpanID  uint64
+	operationName string
+	references    []jaegerSpanRef
+	flags         int32
+	start         int64
+	duration      int64
+	tags          []jaegerTag
+	logs          []jaegerLog
+	// process overrides the process of the batch when set.
+	process *jaegerProcess
+}
+
+// jaegerSpanRef is a causal reference of a span to another span.
+type jaegerSpanRef struct {
+	traceIDLow  uint64
+	spanID      uint64
+	followsFrom bool
+}
+
+// jaegerLog is a timed event of a span.
+type jaegerLog struct {
+	timestamp int64
+	fields    []jaegerTag
+}
+
+// jaegerTag is a typed key-value pair. str holds the string representation of all
+// the values, and num the value of the numeric ones.
+type jaegerTag struct {
+	key     string
+	str     string
+	num     float64
+	numeric bool
+}
+
+// decodeJaeger decodes the Jaeger batch of body and converts its spans to Datadog traces. The spans
+// which can not be converted are skipped, it returns their number.
+func decodeJaeger(mediaType string, body []byte) (pb.Traces, int64, error) {
+	var (
+		batch *jaegerBatch
+		err   error
+	)
+	switch mediaType {
+	case "application/x-thrift", "application/vnd.apache.thrift.binary":
+		batch, err = decodeJaegerThrift(body)
+	case "application/x-protobuf":
+		batch, err = decodeJaegerProto(body)
+	default:
+		return nil, 0, fmt.Errorf("unsupported media type %q", mediaType)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	var skipped int64
+	converted := make([]*pb.Span, 0, len(batch.spans))
+	for _, s := range batch.spans {
+		process := batch.process
+		if s.process != nil {
+			process = s.process
+		}
+		span, err := convertJaegerSpan(process, s)
+		if err != nil {
+			log.Debugf("Skipping invalid Jaeger span: %v", err)
+			skipped++
+			continue
+		}
+		converted = append(converted, span)
+	}
+	return groupByTraceID(converted), skipped, nil
+}
+
+// convertJaegerSpan converts the Jaeger span in, emitted by process, to a Datadog span.
+func convertJaegerSpan(process *jaegerProcess, in *jaegerSpan) (*pb.Span, error) {
+	if in.traceIDLow == 0 && in.traceIDHigh == 0 || in.spanID == 0 {
+		return nil, fmt.Errorf("span %q has no trace or span ID", in.operationName)
+	}
+	span := &pb.Span{
+		TraceID:  in.traceIDLow,
+		SpanID:   in.spanID,
+		ParentID: jaegerParentID(in),
+		Start:    in.start,
+		Duration: in.duration,
+		Resource: in.operationName,
+		Meta:     make(map[string]string, len(in.tags)),
+		Metrics: map[string]float64{
+			// auto-keep all incoming traces; it was already chosen as a keeper on
+			// the client side.
+			sampler.KeySamplingPriority: float64(sampler.PriorityAutoKeep),
+		},
+	}
+	if in.flags&jaegerFlagDebug != 0 {
+		span.Metrics[sampler.KeySamplingPriority] = float64(sampler.PriorityUserKeep)
+	}
+	kind := "internal"
+	for _, t := range in.tags {
+		switch t.key {
+		case "span.kind":
+			kind = strings.ToLower(t.str)
+		case "error":
+			// OpenTracing semantic conventions
+			if t.str == "true" {
+				span.Error = 1
+			}
+			continue
+		}
+		setJaegerTag(span, t)
+	}
+	span.Meta["span.kind"] = kind
+	span.Name = "jaeger." + kind
+	if process != nil {
+		span.Service = process.serviceName
+		for _, t := range process.tags {
+			if _, ok := span.Meta[t.key]; ok {
+				continue
+			}
+			if _, ok := span.Metrics[t.key]; ok {
+				continue
+			}
+			setJaegerTag(span, t)
+		}
+	}
+	if len(in.logs) > 0 {
+		events := make([]spanEvent, 0, len(in.logs))
+		for _, l := range in.logs {
+			events = append(events, jaegerLogEvent(span, l))
+		}
+		span.Meta["events"] = marshalSpanEvents(events)
+	}
+	finishConvertedSpan(span, kind)
+	return span, nil
+}
+
+// jaegerParentID returns the ID of the parent of span: the parent span ID when set, or else
+// the span of the first reference in the same trace, preferring the CHILD_OF references.
+func jaegerParentID(span *jaegerSpan) uint64 {
+	if span.parentSpanID != 0 {
+		return span.parentSpanID
+	}
+	var parentID uint64
+	for _, ref := range span.references {
+		if ref.traceIDLow != span.traceIDLow {
+			continue
+		}
+		if !ref.followsFrom {
+			return ref.spanID
+		}
+		if parentID == 0 {
+			parentID = ref.spanID
+		}
+	}
+	return parentID
+}
+
+// setJaegerTag sets the tag t on span, as a metric when it is numeric.
+func setJaegerTag(span *pb.Span, t jaegerTag) {
+	if t.numeric {
+		span.Metrics[t.key] = t.num
+		return
+	}
+	span.Meta[t.key] = t.str
+}
+
+// jaegerLogEvent returns the event of the log l of span. The fields of the error logs are
+// reported in the error tags of span when it is an error.
+func jaegerLogEvent(span *pb.Span, l jaegerLog) spanEvent {
+	e := spanEvent{
+		TimeUnixNano: uint64(l.timestamp),
+		Name:         "log",
+		Attributes:   make(map[string]string, len(l.fields)),
+	}
+	for _, f := range l.fields {
+		e.Attributes[f.key] = f.str
+	}
+	if name, ok := e.Attributes["event"]; ok {
+		e.Name = name
+		delete(e.Attributes, "event")
+	}
+	if e.Name != "error" || span.Error == 0 {
+		return e
+	}
+	// OpenTracing semantic conventions for the logs of errors
+	if msg, ok := e.Attributes["message"]; ok {
+		span.Meta["error.msg"] = msg
+	} else if obj, ok := e.Attributes["error.object"]; ok {
+		span.Meta["error.msg"] = obj
+	}
+	if typ, ok := e.Attributes["error.kind"]; ok {
+		span.Meta["error.type"] = typ
+	}
+	if stack, ok := e.Attributes["stack"]; ok {
+		span.Meta["error.stack"] = stack
+	}
+	return e
+}
+
+// jaegerStringTag returns a tag holding the string s.
+func jaegerStringTag(key, s string) jaegerTag {
+	return jaegerTag{key: key, str: s}
+}
+
+// jaegerBoolTag returns a tag holding the boolean b.
+func jaegerBoolTag(key string, b bool) jaegerTag {
+	return jaegerTag{key: key, str: strconv.FormatBool(b)}
+}
+
+// jaegerLongTag returns a tag holding the integer v.
+func jaegerLongTag(key string, v int64) jaegerTag {
+	return jaegerTag{key: key, str: strconv.FormatInt(v, 10), num: float64(v), numeric: true}
+}
+
+// jaegerDoubleTag returns a tag holding the float v.
+func jaegerDoubleTag(key string, v float64) jaegerTag {
+	return jaegerTag{key: key, str: strconv.FormatFloat(v, 'f', -1, 64), num: v, numeric: true}
+}
+
+// jaegerBinaryTag returns a tag holding the binary value b, base64 encoded.
+func jaegerBinaryTag(key string, b []byte) jaegerTag {
+	return jaegerTag{key: key, str: base64.StdEncoding.EncodeToString(b)}
+}
+
+// decodeJaegerProto decodes the jaeger.api_v2.Batch message b.
+func decodeJaegerProto(b []byte) (*jaegerBatch, error) {
+	var batch jaegerBatch
+	err := forEachProtoField(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			var span *jaegerSpan
+			if span, err = decodeJaegerProtoSpan(b); err == nil {
+				batch.spans = append(batch.spans, span)
+			}
+		case 2:
+			batch.process, err = decodeJaegerProtoProcess(b)
+		}
+		return err
+	})
+	return &batch, err
+}
+
+func decodeJaegerProtoSpan(b []byte) (*jaegerSpan, error) {
+	var span jaegerSpan
+	err := forEachProtoField(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			span.traceIDHigh, span.traceIDLow = jaegerProtoTraceID(b)
+		case 2:
+			span.spanID = byteArrayToUint64(b)
+		case 3:
+			span.operationName = string(b)
+		case 4:
+			var ref jaegerSpanRef
+			err = forEachProtoField(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+				switch num {
+				case 1:
+					_, ref.traceIDLow = jaegerProtoTraceID(b)
+				case 2:
+					ref.spanID = byteArrayToUint64(b)
+				case 3:
+					ref.followsFrom = v == 1
+				}
+				return nil
+			})
+			span.references = append(span.references, ref)
+		case 5:
+			span.flags = int32(v)
+		case 6:
+			span.start, err = decodeProtoTimestamp(b)
+		case 7:
+			span.duration, err = decodeProtoTimestamp(b)
+		case 8:
+			var tag jaegerTag
+			if tag, err = decodeJaegerProtoKeyValue(b); err == nil {
+				span.tags = append(span.tags, tag)
+			}
+		case 9:
+			var l jaegerLog
+			err = forEachProtoField(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+				var err error
+				switch num {
+				case 1:
+					l.timestamp, err = decodeProtoTimestamp(b)
+				case 2:
+					var tag jaegerTag
+					if tag, err = decodeJaegerProtoKeyValue(b); err == nil {
+						l.fields = append(l.fields, tag)
+					}
+				}
+				return err
+			})
+			span.logs = append(span.logs, l)
+		case 10:
+			span.process, err = decodeJaegerProtoProcess(b)
+		}
+		return err
+	})
+	return &span, err
+}
+
+func decodeJaegerProtoProcess(b []byte) (*jaegerProcess, error) {
+	var p jaegerProcess
+	err := forEachProtoField(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			p.serviceName = string(b)
+		case 2:
+			var tag jaegerTag
+			if tag, err = decodeJaegerProtoKeyValue(b); err == nil {
+				p.tags = append(p.tags, tag)
+			}
+		}
+		return err
+	})
+	return &p, err
+}
+
+func decodeJaegerProtoKeyValue(b []byte) (jaegerTag, error) {
+	var (
+		key    string
+		vType  uint64
+		str    string
+		varint uint64
+		double uint64
+		binary []byte
+	)
+	err := forEachProtoField(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			key = string(b)
+		case 2:
+			vType = v
+		case 3:
+			str = string(b)
+		case 4, 5:
+			varint = v
+		case 6:
+			double = v
+		case 7:
+			binary = b
+		}
+		return nil
+	})
+	switch vType {
+	case 1:
+		return jaegerBoolTag(key, varint != 0), err
+	case 2:
+		return jaegerLongTag(key, int64(varint)), err
+	case 3:
+		return jaegerDoubleTag(key, math.Float64frombits(double)), err
+	case 4:
+		return jaegerBinaryTag(key, binary), err
+	default:
+		return jaegerStringTag(key, str), err
+	}
+}
+
+// jaegerProtoTraceID returns the high and low 64 bits of the 128-bit trace ID b.
+func jaegerProtoTraceID(b []byte) (high, low uint64) {
+	if len(b) == 16 {
+		high = byteArrayToUint64(b[:8])
+	}
+	return high, byteArrayToUint64(b)
+}
+
+// decodeProtoTimestamp decodes the google.protobuf.Timestamp or google.protobuf.Duration
+// message b, which share their layout, in nanoseconds.
+func decodeProtoTimestamp(b []byte) (int64, error) {
+	var seconds, nanos int64
+	err := forEachProtoField(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			seconds = int64(v)
+		case 2:
+			nanos = int64(int32(v))
+		}
+		return nil
+	})
+	return seconds*1e9 + nanos, err
+}
@@ -0,0 +1,393 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// thriftWriter writes the values of the Thrift binary protocol, to forge the test payloads.
+type thriftWriter struct {
+	bytes.Buffer
+}
+
+func (w *thriftWriter) field(typ byte, id int16) {
+	w.WriteByte(typ)
+	binary.Write(w, binary.BigEndian, id)
+}
+
+func (w *thriftWriter) stop() { w.WriteByte(thriftStop) }
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(thriftI32, id)
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(thriftI64, id)
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *thriftWriter) str(id int16, s string) {
+	w.field(thriftString, id)
+	binary.Write(w, binary.BigEndian, int32(len(s)))
+	w.WriteString(s)
+}
+
+func (w *thriftWriter) list(id int16, elemType byte, size int) {
+	w.field(thriftList, id)
+	w.WriteByte(elemType)
+	binary.Write(w, binary.BigEndian, int32(size))
+}
+
+func (w *thriftWriter) stringTag(key, value string) {
+	w.str(1, key)
+	w.i32(2, 0)
+	w.str(3, value)
+	w.stop()
+}
+
+func (w *thriftWriter) boolTag(key string, value bool) {
+	w.str(1, key)
+	w.i32(2, 2)
+	w.field(thriftBool, 5)
+	if value {
+		w.WriteByte(1)
+	} else {
+		w.WriteByte(0)
+	}
+	w.stop()
+}
+
+func (w *thriftWriter) longTag(key string, value int64) {
+	w.str(1, key)
+	w.i32(2, 3)
+	w.i64(6, value)
+	w.stop()
+}
+
+func (w *thriftWriter) doubleTag(key string, value float64) {
+	w.str(1, key)
+	w.i32(2, 1)
+	w.field(thriftDouble, 4)
+	binary.Write(w, binary.BigEndian, math.Float64bits(value))
+	w.stop()
+}
+
+// jaegerThriftTestPayload returns a Batch of a server span, and a client span in error
+// which references it.
+func jaegerThriftTestPayload() []byte {
+	var w thriftWriter
+	// Batch.process
+	w.field(thriftStruct, 1)
+	w.str(1, "frontend")
+	w.list(2, thriftStruct, 2)
+	w.stringTag("hostname", "host-1")
+	w.stringTag("deployment.environment", "staging")
+	w.stop()
+	// Batch.spans
+	w.list(2, thriftStruct, 2)
+
+	w.i64(1, 0x10)
+	w.i64(2, 0x20)
+	w.i64(3, 0x1)
+	w.i64(4, 0)
+	w.str(5, "HTTP GET /dispatch")
+	w.i32(7, 1)
+	w.i64(8, 1000)
+	w.i64(9, 50)
+	w.list(10, thriftStruct, 3)
+	w.stringTag("span.kind", "server")
+	w.stringTag("http.method", "GET")
+	w.longTag("http.status_code", 200)
+	w.field(thriftMap, 42) // unknown fields are skipped
+	w.WriteByte(thriftString)
+	w.WriteByte(thriftI32)
+	binary.Write(&w, binary.BigEndian, int32(1))
+	binary.Write(&w, binary.BigEndian, int32(1))
+	w.WriteString("a")
+	binary.Write(&w, binary.BigEndian, int32(1))
+	w.stop()
+
+	w.i64(1, 0x10)
+	w.i64(2, 0x20)
+	w.i64(3, 0x2)
+	w.i64(4, 0)
+	w.str(5, "redis GET")
+	w.list(6, thriftStruct, 1)
+	w.i32(1, 0) // CHILD_OF
+	w.i64(2, 0x10)
+	w.i64(3, 0x20)
+	w.i64(4, 0x1)
+	w.stop()
+	w.i32(7, 3) // sampled and debug
+	w.i64(8, 1010)
+	w.i64(9, 20)
+	w.list(10, thriftStruct, 4)
+	w.stringTag("span.kind", "client")
+	w.stringTag("db.type", "redis")
+	w.boolTag("error", true)
+	w.doubleTag("retry.ratio", 0.5)
+	w.list(11, thriftStruct, 1)
+	w.i64(1, 1020)
+	w.list(2, thriftStruct, 3)
+	w.stringTag("event", "error")
+	w.stringTag("error.kind", "timeout")
+	w.stringTag("message", "redis timeout")
+	w.stop()
+	w.stop()
+
+	w.stop()
+	return w.Bytes()
+}
+
+func TestDecodeJaegerThrift(t *testing.T) {
+	assert := assert.New(t)
+	traces, _, err := decodeJaeger("application/x-thrift", jaegerThriftTestPayload())
+	assert.NoError(err)
+	assert.Equal(pb.Traces{{
+		{
+			Service:  "frontend",
+			Name:     "jaeger.server",
+			Resource: "GET",
+			TraceID:  0x10,
+			SpanID:   0x1,
+			Start:    1000000,
+			Duration: 50000,
+			Type:     "web",
+			Meta: map[string]string{
+				"span.kind":              "server",
+				"http.method":            "GET",
+				"hostname":               "host-1",
+				"deployment.environment": "staging",
+				"env":                    "staging",
+			},
+			Metrics: map[string]float64{
+				sampler.KeySamplingPriority: float64(sampler.PriorityAutoKeep),
+				"http.status_code":          200,
+			},
+		},
+		{
+			Service:  "frontend",
+			Name:     "jaeger.client",
+			Resource: "redis GET",
+			TraceID:  0x10,
+			SpanID:   0x2,
+			ParentID: 0x1,
+			Start:    1010000,
+			Duration: 20000,
+			Error:    1,
+			Type:     "cache",
+			Meta: map[string]string{
+				"span.kind":              "client",
+				"db.type":                "redis",
+				"hostname":               "host-1",
+				"deployment.environment": "staging",
+				"env":                    "staging",
+				"error.msg":              "redis timeout",
+				"error.type":             "timeout",
+				"events":                 `[{"time_unix_nano":1020000,"name":"error","attributes":{"error.kind":"timeout","message":"redis timeout"}}]`,
+			},
+			Metrics: map[string]float64{
+				sampler.KeySamplingPriority: float64(sampler.PriorityUserKeep),
+				"retry.ratio":               0.5,
+			},
+		},
+	}}, traces)
+}
+
+func TestDecodeJaegerThriftInvalid(t *testing.T) {
+	payload := jaegerThriftTestPayload()
+	for name, b := range map[string][]byte{
+		"truncated": payload[:len(payload)-10],
+		"list-size": {thriftList, 0, 2, thriftStruct, 0x7f, 0xff, 0xff, 0xff},
+		"type":      {99, 0, 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := decodeJaeger("application/vnd.apache.thrift.binary", b)
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("depth", func(t *testing.T) {
+		var w thriftWriter
+		for i := 0; i < 2*thriftMaxDepth; i++ {
+			w.field(thriftStruct, 1)
+		}
+		for i := 0; i <= 2*thriftMaxDepth; i++ {
+			w.stop()
+		}
+		_, _, err := decodeJaeger("application/x-thrift", w.Bytes())
+		assert.Error(t, err)
+	})
+
+	t.Run("media-type", func(t *testing.T) {
+		_, _, err := decodeJaeger("application/json", payload)
+		assert.Error(t, err)
+	})
+}
+
+func TestDecodeJaegerProto(t *testing.T) {
+	assert := assert.New(t)
+
+	keyValue := func(key string, vType uint64, appendValue func([]byte) []byte) []byte {
+		var b []byte
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, key)
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, vType)
+		return appendValue(b)
+	}
+	timestamp := func(seconds, nanos uint64) []byte {
+		var b []byte
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, seconds)
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		return protowire.AppendVarint(b, nanos)
+	}
+
+	var process []byte
+	process = protowire.AppendTag(process, 1, protowire.BytesType)
+	process = protowire.AppendString(process, "worker")
+
+	var ref []byte
+	ref = protowire.AppendTag(ref, 1, protowire.BytesType)
+	ref = protowire.AppendBytes(ref, []byte{0, 0, 0, 0, 0, 0, 0, 9, 0, 0, 0, 0, 0, 0, 0, 7})
+	ref = protowire.AppendTag(ref, 2, protowire.BytesType)
+	ref = protowire.AppendBytes(ref, []byte{0, 0, 0, 0, 0, 0, 0, 5})
+	ref = protowire.AppendTag(ref, 3, protowire.VarintType)
+	ref = protowire.AppendVarint(ref, 1) // FOLLOWS_FROM
+
+	var span []byte
+	span = protowire.AppendTag(span, 1, protowire.BytesType)
+	span = protowire.AppendBytes(span, []byte{0, 0, 0, 0, 0, 0, 0, 9, 0, 0, 0, 0, 0, 0, 0, 7})
+	span = protowire.AppendTag(span, 2, protowire.BytesType)
+	span = protowire.AppendBytes(span, []byte{0, 0, 0, 0, 0, 0, 0, 6})
+	span = protowire.AppendTag(span, 3, protowire.BytesType)
+	span = protowire.AppendString(span, "process-job")
+	span = protowire.AppendTag(span, 4, protowire.BytesType)
+	span = protowire.AppendBytes(span, ref)
+	span = protowire.AppendTag(span, 6, protowire.BytesType)
+	span = protowire.AppendBytes(span, timestamp(2, 5))
+	span = protowire.AppendTag(span, 7, protowire.BytesType)
+	span = protowire.AppendBytes(span, timestamp(0, 300))
+	span = protowire.AppendTag(span, 8, protowire.BytesType)
+	span = protowire.AppendBytes(span, keyValue("span.kind", 0, func(b []byte) []byte {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		return protowire.AppendString(b, "consumer")
+	}))
+	span = protowire.AppendTag(span, 8, protowire.BytesType)
+	span = protowire.AppendBytes(span, keyValue("attempt", 2, func(b []byte) []byte {
+		b = protowire.AppendTag(b, 5, protowire.VarintType)
+		return protowire.AppendVarint(b, 3)
+	}))
+	span = protowire.AppendTag(span, 8, protowire.BytesType)
+	span = protowire.AppendBytes(span, keyValue("cached", 1, func(b []byte) []byte {
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		return protowire.AppendVarint(b, 1)
+	}))
+	span = protowire.AppendTag(span, 8, protowire.BytesType)
+	span = protowire.AppendBytes(span, keyValue("load", 3, func(b []byte) []byte {
+		b = protowire.AppendTag(b, 6, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(0.25))
+	}))
+
+	var batch []byte
+	batch = protowire.AppendTag(batch, 1, protowire.BytesType)
+	batch = protowire.AppendBytes(batch, span)
+	batch = protowire.AppendTag(batch, 2, protowire.BytesType)
+	batch = protowire.AppendBytes(batch, process)
+
+	traces, _, err := decodeJaeger("application/x-protobuf", batch)
+	assert.NoError(err)
+	assert.Equal(pb.Traces{{{
+		Service:  "worker",
+		Name:     "jaeger.consumer",
+		Resource: "process-job",
+		TraceID:  7,
+		SpanID:   6,
+		ParentID: 5,
+		Start:    2000000005,
+		Duration: 300,
+		Type:     "custom",
+		Meta: map[string]string{
+			"span.kind": "consumer",
+			"cached":    "true",
+		},
+		Metrics: map[string]float64{
+			sampler.KeySamplingPriority: float64(sampler.PriorityAutoKeep),
+			"attempt":                   3,
+			"load":                      0.25,
+		},
+	}}}, traces)
+
+	_, _, err = decodeJaeger("application/x-protobuf", batch[:len(batch)-1])
+	assert.Error(err)
+}
+
+func TestJaegerParentID(t *testing.T) {
+	for name, tt := range map[string]struct {
+		span     jaegerSpan
+		expected uint64
+	}{
+		"parent": {
+			span:     jaegerSpan{traceIDLow: 1, parentSpanID: 2, references: []jaegerSpanRef{{traceIDLow: 1, spanID: 3}}},
+			expected: 2,
+		},
+		"child-of": {
+			span: jaegerSpan{traceIDLow: 1, references: []jaegerSpanRef{
+				{traceIDLow: 1, spanID: 3, followsFrom: true},
+				{traceIDLow: 1, spanID: 4},
+			}},
+			expected: 4,
+		},
+		"follows-from": {
+			span:     jaegerSpan{traceIDLow: 1, references: []jaegerSpanRef{{traceIDLow: 1, spanID: 3, followsFrom: true}}},
+			expected: 3,
+		},
+		"other-trace": {
+			span:     jaegerSpan{traceIDLow: 1, references: []jaegerSpanRef{{traceIDLow: 2, spanID: 3}}},
+			expected: 0,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, jaegerParentID(&tt.span))
+		})
+	}
+}
+
+func TestHandleJaegerTraces(t *testing.T) {
+	assert := assert.New(t)
+	conf := newTestReceiverConfig()
+	receiver := newTestReceiverFromConfig(conf)
+	handler := http.HandlerFunc(receiver.handleWithVersion(jaeger, receiver.handleConvertedTraces(decodeJaeger)))
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/traces", bytes.NewReader(jaegerThriftTestPayload()))
+	req.Header.Set("Content-Type", "application/x-thrift")
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(http.StatusAccepted, rr.Code)
+	p := <-receiver.out
+	assert.Len(p.Traces, 1)
+	assert.Len(p.Traces[0], 2)
+
+	ts := receiver.Stats.GetTagStats(info.Tags{EndpointVersion: "jaeger"})
+	assert.EqualValues(1, ts.TracesReceived)
+	assert.EqualValues(len(jaegerThriftTestPayload()), ts.TracesBytes)
+}
@@ -0,0 +1,329 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The types of the Thrift binary protocol.
+const (
+	thriftStop   byte = 0
+	thriftBool   byte = 2
+	thriftByte   byte = 3
+	thriftDouble byte = 4
+	thriftI16    byte = 6
+	thriftI32    byte = 8
+	thriftI64    byte = 10
+	thriftString byte = 11
+	thriftStruct byte = 12
+	thriftMap    byte = 13
+	thriftSet    byte = 14
+	thriftList   byte = 15
+)
+
+// thriftMaxDepth is the maximum nesting of the skipped Thrift values.
+const thriftMaxDepth = 64
+
+// errThriftTruncated is returned when a Thrift message ends unexpectedly.
+var errThriftTruncated = errors.New("thrift: unexpected end of message")
+
+// thriftReader reads the values of the Thrift binary protocol. The first error is
+// kept in err, after which all the reads return zero values.
+type thriftReader struct {
+	b   []byte
+	err error
+}
+
+func (r *thriftReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.b) {
+		r.err = errThriftTruncated
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *thriftReader) readByte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *thriftReader) readI16() int16 {
+	if b := r.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *thriftReader) readI32() int32 {
+	if b := r.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *thriftReader) readI64() int64 {
+	if b := r.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (r *thriftReader) readDouble() float64 {
+	return math.Float64frombits(uint64(r.readI64()))
+}
+
+func (r *thriftReader) readBinary() []byte {
+	return r.next(int(r.readI32()))
+}
+
+func (r *thriftReader) readString() string {
+	return string(r.readBinary())
+}
+
+// readStruct reads a struct, calling f for each of its fields. f must read the value
+// of the field, or skip it.
+func (r *thriftReader) readStruct(f func(id int16, typ byte)) {
+	for r.err == nil {
+		typ := r.readByte()
+		if typ == thriftStop {
+			return
+		}
+		f(r.readI16(), typ)
+	}
+}
+
+// readList reads a list of elements of type elemType, calling f for each of them. f must
+// read the element. The list is skipped if its elements are not of type elemType.
+func (r *thriftReader) readList(elemType byte, f func()) {
+	typ := r.readByte()
+	size := int(r.readI32())
+	if r.err != nil {
+		return
+	}
+	if size < 0 || size > len(r.b) {
+		// all the elements take at least one byte
+		r.err = fmt.Errorf("thrift: invalid list size %d", size)
+		return
+	}
+	for i := 0; i < size && r.err == nil; i++ {
+		if typ != elemType {
+			r.skip(typ, 0)
+			continue
+		}
+		f()
+	}
+}
+
+// skip skips a value of type typ, nested at depth.
+func (r *thriftReader) skip(typ byte, depth int) {
+	if depth > thriftMaxDepth {
+		r.err = errors.New("thrift: maximum depth exceeded")
+		return
+	}
+	switch typ {
+	case thriftBool, thriftByte:
+		r.next(1)
+	case thriftI16:
+		r.next(2)
+	case thriftI32:
+		r.next(4)
+	case thriftDouble, thriftI64:
+		r.next(8)
+	case thriftString:
+		r.readBinary()
+	case thriftStruct:
+		r.readStruct(func(_ int16, typ byte) { r.skip(typ, depth+1) })
+	case thriftMap:
+		keyType, valueType := r.readByte(), r.readByte()
+		size := int(r.readI32())
+		if size < 0 || size > len(r.b) {
+			r.err = fmt.Errorf("thrift: invalid map size %d", size)
+			return
+		}
+		for i := 0; i < size && r.err == nil; i++ {
+			r.skip(keyType, depth+1)
+			r.skip(valueType, depth+1)
+		}
+	case thriftSet, thriftList:
+		elemType := r.readByte()
+		size := int(r.readI32())
+		if size < 0 || size > len(r.b) {
+			r.err = fmt.Errorf("thrift: invalid list size %d", size)
+			return
+		}
+		for i := 0; i < size && r.err == nil; i++ {
+			r.skip(elemType, depth+1)
+		}
+	default:
+		r.err = fmt.Errorf("thrift: unknown type %d", typ)
+	}
+}
+
+// decodeJaegerThrift decodes the jaeger.thrift Batch struct b, in the Thrift binary protocol.
+func decodeJaegerThrift(b []byte) (*jaegerBatch, error) {
+	var batch jaegerBatch
+	r := &thriftReader{b: b}
+	r.readStruct(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftStruct:
+			batch.process = readJaegerThriftProcess(r)
+		case id == 2 && typ == thriftList:
+			r.readList(thriftStruct, func() {
+				batch.spans = append(batch.spans, readJaegerThriftSpan(r))
+			})
+		default:
+			r.skip(typ, 0)
+		}
+	})
+	return &batch, r.err
+}
+
+func readJaegerThriftProcess(r *thriftReader) *jaegerProcess {
+	var p jaegerProcess
+	r.readStruct(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftString:
+			p.serviceName = r.readString()
+		case id == 2 && typ == thriftList:
+			p.tags = readJaegerThriftTags(r)
+		default:
+			r.skip(typ, 0)
+		}
+	})
+	return &p
+}
+
+func readJaegerThriftSpan(r *thriftReader) *jaegerSpan {
+	var span jaegerSpan
+	r.readStruct(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftI64:
+			span.traceIDLow = uint64(r.readI64())
+		case id == 2 && typ == thriftI64:
+			span.traceIDHigh = uint64(r.readI64())
+		case id == 3 && typ == thriftI64:
+			span.spanID = uint64(r.readI64())
+		case id == 4 && typ == thriftI64:
+			span.parentSpanID = uint64(r.readI64())
+		case id == 5 && typ == thriftString:
+			span.operationName = r.readString()
+		case id == 6 && typ == thriftList:
+			r.readList(thriftStruct, func() {
+				span.references = append(span.references, readJaegerThriftSpanRef(r))
+			})
+		case id == 7 && typ == thriftI32:
+			span.flags = r.readI32()
+		case id == 8 && typ == thriftI64:
+			span.start = r.readI64() * 1000
+		case id == 9 && typ == thriftI64:
+			span.duration = r.readI64() * 1000
+		case id == 10 && typ == thriftList:
+			span.tags = readJaegerThriftTags(r)
+		case id == 11 && typ == thriftList:
+			r.readList(thriftStruct, func() {
+				span.logs = append(span.logs, readJaegerThriftLog(r))
+			})
+		default:
+			r.skip(typ, 0)
+		}
+	})
+	return &span
+}
+
+func readJaegerThriftSpanRef(r *thriftReader) jaegerSpanRef {
+	var ref jaegerSpanRef
+	r.readStruct(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftI32:
+			ref.followsFrom = r.readI32() == 1
+		case id == 2 && typ == thriftI64:
+			ref.traceIDLow = uint64(r.readI64())
+		case id == 4 && typ == thriftI64:
+			ref.spanID = uint64(r.readI64())
+		default:
+			r.skip(typ, 0)
+		}
+	})
+	return ref
+}
+
+func readJaegerThriftLog(r *thriftReader) jaegerLog {
+	var l jaegerLog
+	r.readStruct(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftI64:
+			l.timestamp = r.readI64() * 1000
+		case id == 2 && typ == thriftList:
+			l.fields = readJaegerThriftTags(r)
+		default:
+			r.skip(typ, 0)
+		}
+	})
+	return l
+}
+
+func readJaegerThriftTags(r *thriftReader) []jaegerTag {
+	var tags []jaegerTag
+	r.readList(thriftStruct, func() {
+		tags = append(tags, readJaegerThriftTag(r))
+	})
+	return tags
+}
+
+func readJaegerThriftTag(r *thriftReader) jaegerTag {
+	var (
+		key    string
+		vType  int32
+		str    string
+		double float64
+		b      bool
+		long   int64
+		bin    []byte
+	)
+	r.readStruct(func(id int16, typ byte) {
+		switch {
+		case id == 1 && typ == thriftString:
+			key = r.readString()
+		case id == 2 && typ == thriftI32:
+			vType = r.readI32()
+		case id == 3 && typ == thriftString:
+			str = r.readString()
+		case id == 4 && typ == thriftDouble:
+			double = r.readDouble()
+		case id == 5 && typ == thriftBool:
+			b = r.readByte() != 0
+		case id == 6 && typ == thriftI64:
+			long = r.readI64()
+		case id == 7 && typ == thriftString:
+			bin = r.readBinary()
+		default:
+			r.skip(typ, 0)
+		}
+	})
+	switch vType {
+	case 1:
+		return jaegerDoubleTag(key, double)
+	case 2:
+		return jaegerBoolTag(key, b)
+	case 3:
+		return jaegerLongTag(key, long)
+	case 4:
+		return jaegerBinaryTag(key, bin)
+	default:
+		return jaegerStringTag(key, str)
+	}
+}
@@ -70,3 +70,20 @@ const (
 	v05 Version = "v0.5"
 	v06 Version = "v0.6"
 )
+
+const (
+	// zipkinV2 receives spans in the Zipkin v2 format, at the path used by the Zipkin collectors.
+	//
+	// Content-Type: application/json or application/x-protobuf
+	// Payload: A list of Zipkin spans, as a JSON array or a zipkin.proto3.ListOfSpans message.
+	// Response: 202 Accepted.
+	zipkinV2 Version = "zipkin_v2"
+
+	// jaeger receives spans in the Jaeger format, at the path used by the Jaeger collectors.
+	//
+	// Content-Type: application/x-thrift or application/x-protobuf
+	// Payload: A batch of spans from a single process, as a jaeger.thrift Batch encoded with the
+	// binary protocol or a jaeger.api_v2.Batch message.
+	// Response: 202 Accepted.
+	jaeger Version = "jaeger"
+)
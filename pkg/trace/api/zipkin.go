@@ -0,0 +1,301 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// zipkinSpan is a span in the Zipkin v2 format, see https://zipkin.io/zipkin-api/#/default/post_spans.
+// The IDs are hexadecimal strings, as in the JSON encoding.
+type zipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId"`
+	Name           string             `json:"name"`
+	Kind           string             `json:"kind"`
+	Timestamp      uint64             `json:"timestamp"` // microseconds since epoch
+	Duration       uint64             `json:"duration"`  // microseconds
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint"`
+	Annotations    []zipkinAnnotation `json:"annotations"`
+	Tags           map[string]string  `json:"tags"`
+	Debug          bool               `json:"debug"`
+	Shared         bool               `json:"shared"`
+}
+
+// zipkinEndpoint is the network context of a node in the service graph.
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+// zipkinAnnotation is an event which explains latency with a timestamp.
+type zipkinAnnotation struct {
+	Timestamp uint64 `json:"timestamp"` // microseconds since epoch
+	Value     string `json:"value"`
+}
+
+// zipkinSpanKinds maps the Kind enum of zipkin.proto to the kinds of the JSON encoding.
+var zipkinSpanKinds = map[uint64]string{
+	1: "CLIENT",
+	2: "SERVER",
+	3: "PRODUCER",
+	4: "CONSUMER",
+}
+
+// decodeZipkin decodes the Zipkin spans of body and converts them to Datadog traces. The spans
+// which can not be converted are skipped, it returns their number.
+func decodeZipkin(mediaType string, body []byte) (pb.Traces, int64, error) {
+	var (
+		spans []*zipkinSpan
+		err   error
+	)
+	switch mediaType {
+	case "application/x-protobuf":
+		spans, err = decodeZipkinProto(body)
+	default:
+		err = json.Unmarshal(body, &spans)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	var skipped int64
+	converted := make([]*pb.Span, 0, len(spans))
+	for _, s := range spans {
+		span, err := convertZipkinSpan(s)
+		if err != nil {
+			log.Debugf("Skipping invalid Zipkin span: %v", err)
+			skipped++
+			continue
+		}
+		converted = append(converted, span)
+	}
+	return groupByTraceID(converted), skipped, nil
+}
+
+// convertZipkinSpan converts the Zipkin span in to a Datadog span.
+func convertZipkinSpan(in *zipkinSpan) (*pb.Span, error) {
+	traceID, err := parseZipkinID(in.TraceID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace ID %q: %v", in.TraceID, err)
+	}
+	spanID, err := parseZipkinID(in.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid span ID %q: %v", in.ID, err)
+	}
+	parentID, err := parseZipkinID(in.ParentID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parent ID %q: %v", in.ParentID, err)
+	}
+	if traceID == 0 || spanID == 0 {
+		return nil, fmt.Errorf("span %q has no trace or span ID", in.Name)
+	}
+	kind := strings.ToLower(in.Kind)
+	if kind == "" {
+		kind = "internal"
+	}
+	if in.Shared && kind == "server" {
+		// with the B3 single host spans, the server shares the span ID of its client: the server
+		// span is given its own ID and is parented on the client span
+		spanID, parentID = sharedServerSpanID(spanID), spanID
+	}
+	span := &pb.Span{
+		Name:     "zipkin." + kind,
+		TraceID:  traceID,
+		SpanID:   spanID,
+		ParentID: parentID,
+		Start:    int64(in.Timestamp) * 1000,
+		Duration: int64(in.Duration) * 1000,
+		Resource: in.Name,
+		Meta:     make(map[string]string, len(in.Tags)+1),
+		Metrics: map[string]float64{
+			// auto-keep all incoming traces; it was already chosen as a keeper on
+			// the client side.
+			sampler.KeySamplingPriority: float64(sampler.PriorityAutoKeep),
+		},
+	}
+	if in.Debug {
+		span.Metrics[sampler.KeySamplingPriority] = float64(sampler.PriorityUserKeep)
+	}
+	for k, v := range in.Tags {
+		span.Meta[k] = v
+	}
+	span.Meta["span.kind"] = kind
+	if e := in.LocalEndpoint; e != nil {
+		span.Service = e.ServiceName
+	}
+	if e := in.RemoteEndpoint; e != nil {
+		if e.ServiceName != "" {
+			span.Meta["peer.service"] = e.ServiceName
+		}
+		if e.IPv4 != "" {
+			span.Meta["peer.ipv4"] = e.IPv4
+		}
+		if e.IPv6 != "" {
+			span.Meta["peer.ipv6"] = e.IPv6
+		}
+		if e.Port != 0 {
+			span.Meta["peer.port"] = strconv.Itoa(e.Port)
+		}
+	}
+	if len(in.Annotations) > 0 {
+		events := make([]spanEvent, 0, len(in.Annotations))
+		for _, a := range in.Annotations {
+			events = append(events, spanEvent{TimeUnixNano: a.Timestamp * 1000, Name: a.Value})
+		}
+		span.Meta["events"] = marshalSpanEvents(events)
+	}
+	if msg, ok := in.Tags["error"]; ok {
+		// the "error" tag holds the error message, or is empty when it is unknown
+		span.Error = 1
+		delete(span.Meta, "error")
+		if msg != "" && msg != "true" {
+			span.Meta["error.msg"] = msg
+		}
+	}
+	finishConvertedSpan(span, kind)
+	return span, nil
+}
+
+// sharedServerSpanID derives the span ID of a shared server span from the ID it shares with
+// its client span.
+func sharedServerSpanID(id uint64) uint64 {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], id)
+	h := fnv.New64a()
+	h.Write(b[:])
+	derived := h.Sum64()
+	if derived == 0 || derived == id {
+		derived = ^id
+	}
+	return derived
+}
+
+// parseZipkinID parses the hexadecimal ID id, keeping the lower 64 bits of the 128-bit trace IDs.
+func parseZipkinID(id string) (uint64, error) {
+	if id == "" {
+		return 0, nil
+	}
+	if len(id) > 16 {
+		id = id[len(id)-16:]
+	}
+	return strconv.ParseUint(id, 16, 64)
+}
+
+// decodeZipkinProto decodes the zipkin.proto3.ListOfSpans message b.
+func decodeZipkinProto(b []byte) ([]*zipkinSpan, error) {
+	var spans []*zipkinSpan
+	err := forEachProtoField(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		span, err := decodeZipkinProtoSpan(b)
+		if err != nil {
+			return err
+		}
+		spans = append(spans, span)
+		return nil
+	})
+	return spans, err
+}
+
+func decodeZipkinProtoSpan(b []byte) (*zipkinSpan, error) {
+	var span zipkinSpan
+	err := forEachProtoField(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			span.TraceID = hex.EncodeToString(b)
+		case 2:
+			span.ParentID = hex.EncodeToString(b)
+		case 3:
+			span.ID = hex.EncodeToString(b)
+		case 4:
+			span.Kind = zipkinSpanKinds[v]
+		case 5:
+			span.Name = string(b)
+		case 6:
+			span.Timestamp = v
+		case 7:
+			span.Duration = v
+		case 8:
+			span.LocalEndpoint, err = decodeZipkinProtoEndpoint(b)
+		case 9:
+			span.RemoteEndpoint, err = decodeZipkinProtoEndpoint(b)
+		case 10:
+			var a zipkinAnnotation
+			err = forEachProtoField(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+				switch num {
+				case 1:
+					a.Timestamp = v
+				case 2:
+					a.Value = string(b)
+				}
+				return nil
+			})
+			span.Annotations = append(span.Annotations, a)
+		case 11:
+			var key, value string
+			err = forEachProtoField(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+				switch num {
+				case 1:
+					key = string(b)
+				case 2:
+					value = string(b)
+				}
+				return nil
+			})
+			if span.Tags == nil {
+				span.Tags = make(map[string]string)
+			}
+			span.Tags[key] = value
+		case 12:
+			span.Debug = v != 0
+		case 13:
+			span.Shared = v != 0
+		}
+		return err
+	})
+	return &span, err
+}
+
+func decodeZipkinProtoEndpoint(b []byte) (*zipkinEndpoint, error) {
+	var e zipkinEndpoint
+	err := forEachProtoField(b, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			e.ServiceName = string(b)
+		case 2:
+			if len(b) == net.IPv4len {
+				e.IPv4 = net.IP(b).String()
+			}
+		case 3:
+			if len(b) == net.IPv6len {
+				e.IPv6 = net.IP(b).String()
+			}
+		case 4:
+			e.Port = int(int32(v))
+		}
+		return nil
+	})
+	return &e, err
+}
@@ -0,0 +1,276 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/sampler"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const zipkinTestPayload = `[
+  {
+    "traceId": "5af7183fb1d4cf5f5af7183fb1d4cf5f",
+    "id": "352bff9a74ca9ad2",
+    "name": "get /api",
+    "kind": "SERVER",
+    "timestamp": 1556604172355737,
+    "duration": 1431,
+    "localEndpoint": {"serviceName": "backend", "ipv4": "192.168.99.1", "port": 3306},
+    "tags": {"http.method": "GET", "http.route": "/api", "deployment.environment": "prod"}
+  },
+  {
+    "traceId": "5af7183fb1d4cf5f5af7183fb1d4cf5f",
+    "parentId": "352bff9a74ca9ad2",
+    "id": "6b221d5bc9e6496c",
+    "name": "select",
+    "kind": "CLIENT",
+    "timestamp": 1556604172355800,
+    "duration": 1000,
+    "debug": true,
+    "localEndpoint": {"serviceName": "backend"},
+    "remoteEndpoint": {"serviceName": "mysql", "ipv4": "10.0.0.2", "port": 3306},
+    "annotations": [{"timestamp": 1556604172355900, "value": "query sent"}],
+    "tags": {"db.type": "sql", "error": "connection refused"}
+  },
+  {
+    "traceId": "1",
+    "id": "2",
+    "name": "job"
+  }
+]`
+
+func TestDecodeZipkinJSON(t *testing.T) {
+	assert := assert.New(t)
+	traces, skipped, err := decodeZipkin("application/json", []byte(zipkinTestPayload))
+	assert.NoError(err)
+	assert.Zero(skipped)
+	assert.Len(traces, 2)
+	assert.Len(traces[0], 2)
+	assert.Len(traces[1], 1)
+
+	assert.Equal(&pb.Span{
+		Service:  "backend",
+		Name:     "zipkin.server",
+		Resource: "GET /api",
+		TraceID:  0x5af7183fb1d4cf5f,
+		SpanID:   0x352bff9a74ca9ad2,
+		Start:    1556604172355737000,
+		Duration: 1431000,
+		Type:     "web",
+		Meta: map[string]string{
+			"http.method":            "GET",
+			"http.route":             "/api",
+			"deployment.environment": "prod",
+			"env":                    "prod",
+			"span.kind":              "server",
+		},
+		Metrics: map[string]float64{sampler.KeySamplingPriority: float64(sampler.PriorityAutoKeep)},
+	}, traces[0][0])
+
+	assert.Equal(&pb.Span{
+		Service:  "backend",
+		Name:     "zipkin.client",
+		Resource: "select",
+		TraceID:  0x5af7183fb1d4cf5f,
+		SpanID:   0x6b221d5bc9e6496c,
+		ParentID: 0x352bff9a74ca9ad2,
+		Start:    1556604172355800000,
+		Duration: 1000000,
+		Error:    1,
+		Type:     "db",
+		Meta: map[string]string{
+			"db.type":      "sql",
+			"error.msg":    "connection refused",
+			"events":       `[{"time_unix_nano":1556604172355900000,"name":"query sent"}]`,
+			"peer.service": "mysql",
+			"peer.ipv4":    "10.0.0.2",
+			"peer.port":    "3306",
+			"span.kind":    "client",
+		},
+		Metrics: map[string]float64{sampler.KeySamplingPriority: float64(sampler.PriorityUserKeep)},
+	}, traces[0][1])
+
+	assert.Equal("zipkin.internal", traces[1][0].Name)
+	assert.Equal("custom", traces[1][0].Type)
+	assert.Equal("job", traces[1][0].Resource)
+}
+
+func TestDecodeZipkinInvalid(t *testing.T) {
+	_, _, err := decodeZipkin("application/json", []byte(`{"traceId": "1"}`))
+	assert.Error(t, err)
+
+	// the invalid spans are skipped
+	traces, skipped, err := decodeZipkin("application/json", []byte(`[
+		{"traceId": "1"},
+		{"traceId": "1", "id": "zz"},
+		{"traceId": "1", "id": "2", "parentId": "zz"},
+		{"traceId": "1", "id": "3", "name": "job"}
+	]`))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, skipped)
+	assert.Len(t, traces, 1)
+	assert.Len(t, traces[0], 1)
+	assert.EqualValues(t, 3, traces[0][0].SpanID)
+}
+
+func TestDecodeZipkinSharedSpan(t *testing.T) {
+	assert := assert.New(t)
+	traces, _, err := decodeZipkin("application/json", []byte(`[
+		{"traceId": "1", "id": "a", "parentId": "5", "kind": "CLIENT", "name": "get", "localEndpoint": {"serviceName": "frontend"}},
+		{"traceId": "1", "id": "a", "parentId": "5", "kind": "SERVER", "name": "get", "shared": true, "localEndpoint": {"serviceName": "backend"}}
+	]`))
+	assert.NoError(err)
+	assert.Len(traces, 1)
+	assert.Len(traces[0], 2)
+
+	client, server := traces[0][0], traces[0][1]
+	assert.EqualValues(0xa, client.SpanID)
+	assert.EqualValues(5, client.ParentID)
+	// the server span gets its own ID and is the child of the client span
+	assert.Equal(sharedServerSpanID(0xa), server.SpanID)
+	assert.NotEqual(client.SpanID, server.SpanID)
+	assert.NotZero(server.SpanID)
+	assert.Equal(client.SpanID, server.ParentID)
+	assert.Equal("backend", server.Service)
+}
+
+func TestDecodeZipkinProto(t *testing.T) {
+	assert := assert.New(t)
+
+	var endpoint []byte
+	endpoint = protowire.AppendTag(endpoint, 1, protowire.BytesType)
+	endpoint = protowire.AppendString(endpoint, "backend")
+	endpoint = protowire.AppendTag(endpoint, 2, protowire.BytesType)
+	endpoint = protowire.AppendBytes(endpoint, net.ParseIP("10.0.0.1").To4())
+	endpoint = protowire.AppendTag(endpoint, 4, protowire.VarintType)
+	endpoint = protowire.AppendVarint(endpoint, 8080)
+
+	var tag []byte
+	tag = protowire.AppendTag(tag, 1, protowire.BytesType)
+	tag = protowire.AppendString(tag, "messaging.operation")
+	tag = protowire.AppendTag(tag, 2, protowire.BytesType)
+	tag = protowire.AppendString(tag, "process")
+
+	var span []byte
+	span = protowire.AppendTag(span, 1, protowire.BytesType)
+	span = protowire.AppendBytes(span, []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 2})
+	span = protowire.AppendTag(span, 2, protowire.BytesType)
+	span = protowire.AppendBytes(span, []byte{0, 0, 0, 0, 0, 0, 0, 3})
+	span = protowire.AppendTag(span, 3, protowire.BytesType)
+	span = protowire.AppendBytes(span, []byte{0, 0, 0, 0, 0, 0, 0, 4})
+	span = protowire.AppendTag(span, 4, protowire.VarintType)
+	span = protowire.AppendVarint(span, 4) // CONSUMER
+	span = protowire.AppendTag(span, 5, protowire.BytesType)
+	span = protowire.AppendString(span, "consume")
+	span = protowire.AppendTag(span, 6, protowire.Fixed64Type)
+	span = protowire.AppendFixed64(span, 1000)
+	span = protowire.AppendTag(span, 7, protowire.VarintType)
+	span = protowire.AppendVarint(span, 20)
+	span = protowire.AppendTag(span, 8, protowire.BytesType)
+	span = protowire.AppendBytes(span, endpoint)
+	span = protowire.AppendTag(span, 11, protowire.BytesType)
+	span = protowire.AppendBytes(span, tag)
+
+	var list []byte
+	list = protowire.AppendTag(list, 1, protowire.BytesType)
+	list = protowire.AppendBytes(list, span)
+
+	traces, _, err := decodeZipkin("application/x-protobuf", list)
+	assert.NoError(err)
+	assert.Equal(pb.Traces{{{
+		Service:  "backend",
+		Name:     "zipkin.consumer",
+		Resource: "process",
+		TraceID:  2,
+		SpanID:   4,
+		ParentID: 3,
+		Start:    1000000,
+		Duration: 20000,
+		Type:     "custom",
+		Meta: map[string]string{
+			"messaging.operation": "process",
+			"span.kind":           "consumer",
+		},
+		Metrics: map[string]float64{sampler.KeySamplingPriority: float64(sampler.PriorityAutoKeep)},
+	}}}, traces)
+
+	_, _, err = decodeZipkin("application/x-protobuf", list[:len(list)-1])
+	assert.Error(err)
+}
+
+func TestHandleZipkinTraces(t *testing.T) {
+	assert := assert.New(t)
+	conf := newTestReceiverConfig()
+	receiver := newTestReceiverFromConfig(conf)
+	handler := http.HandlerFunc(receiver.handleWithVersion(zipkinV2, receiver.handleConvertedTraces(decodeZipkin)))
+
+	t.Run("json", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v2/spans", bytes.NewReader([]byte(zipkinTestPayload)))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(http.StatusAccepted, rr.Code)
+		p := <-receiver.out
+		assert.Len(p.Traces, 2)
+		assert.Equal("zipkin_v2", p.Source.EndpointVersion)
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		var buf bytes.Buffer
+		gzipw := gzip.NewWriter(&buf)
+		_, err := gzipw.Write([]byte(zipkinTestPayload))
+		assert.NoError(err)
+		assert.NoError(gzipw.Close())
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v2/spans", &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(http.StatusAccepted, rr.Code)
+		p := <-receiver.out
+		assert.Len(p.Traces, 2)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v2/spans", bytes.NewReader([]byte("} invalid json")))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(http.StatusBadRequest, rr.Code)
+		assert.Len(receiver.out, 0)
+	})
+
+	t.Run("invalid-span", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v2/spans", bytes.NewReader([]byte(`[{"traceId": "1", "id": "zz"}, {"traceId": "1", "id": "2"}]`)))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(http.StatusAccepted, rr.Code)
+		p := <-receiver.out
+		assert.Len(p.Traces, 1)
+	})
+
+	ts := receiver.Stats.GetTagStats(info.Tags{EndpointVersion: "zipkin_v2"})
+	assert.EqualValues(5, ts.TracesReceived)
+	assert.EqualValues(3, ts.PayloadAccepted)
+	assert.EqualValues(1, ts.SpansDropped)
+}
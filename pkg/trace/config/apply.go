@@ -74,6 +74,44 @@ type ObfuscationConfig struct {
 	// Memcached holds the configuration for obfuscating the "memcached.command" tag
 	// for spans of type "memcached".
 	Memcached Enablable `mapstructure:"memcached"`
+
+	// SQL holds the obfuscation settings for SQL queries.
+	SQL SQLObfuscationConfig `mapstructure:"sql"`
+
+	// GraphQL holds the configuration for obfuscating the literals of the "graphql.source" tag
+	// and the values of the "graphql.variables.*" tags for spans of type "graphql". Its keep
+	// list names the arguments, input fields and variables whose values are not obfuscated.
+	GraphQL KeepValuesObfuscationConfig `mapstructure:"graphql"`
+
+	// GRPC holds the configuration for obfuscating the request and response metadata tags
+	// for spans of type "grpc" or "rpc". Its keep list names the metadata keys whose values
+	// are not obfuscated.
+	GRPC KeepValuesObfuscationConfig `mapstructure:"grpc"`
+
+	// AWS holds the configuration for obfuscating the "params.*" request parameter tags of
+	// AWS SDK spans. Its keep list names the parameters whose values are not obfuscated.
+	AWS KeepValuesObfuscationConfig `mapstructure:"aws"`
+
+	// Messaging holds the configuration for obfuscating the message tags (keys, bodies,
+	// headers) for spans of type "queue" or "kafka". Its keep list names the message
+	// attributes whose values are not obfuscated.
+	Messaging KeepValuesObfuscationConfig `mapstructure:"messaging"`
+}
+
+// SQLObfuscationConfig holds the configuration settings for SQL obfuscation.
+type SQLObfuscationConfig struct {
+	// DBMS specifies the type of database the queries are sent to when their span doesn't
+	// have a "db.type" or "db.system" tag, such as "postgresql", "mysql" or "mssql". The
+	// queries are tokenized following its dialect. When empty, a generic dialect is used.
+	DBMS string `mapstructure:"dbms"`
+
+	// TableNames specifies whether the names of the tables referenced by queries are added
+	// to their span in the "sql.tables" tag.
+	TableNames bool `mapstructure:"table_names"`
+
+	// CollectCommands specifies whether the commands run by queries (SELECT, UPDATE, ...)
+	// are added to their span in the "sql.commands" tag.
+	CollectCommands bool `mapstructure:"collect_commands"`
 }
 
 // HTTPObfuscationConfig holds the configuration settings for HTTP obfuscation.
@@ -105,6 +143,17 @@ type JSONObfuscationConfig struct {
 	ObfuscateSQLValues []string `mapstructure:"obfuscate_sql_values"`
 }
 
+// KeepValuesObfuscationConfig holds the obfuscation configuration for sensitive
+// data found in span tags, such as request metadata or parameters.
+type KeepValuesObfuscationConfig struct {
+	// Enabled will specify whether obfuscation should be enabled.
+	Enabled bool `mapstructure:"enabled"`
+
+	// KeepValues will specify a set of keys for which their values will
+	// not be obfuscated.
+	KeepValues []string `mapstructure:"keep_values"`
+}
+
 // ReplaceRule specifies a replace rule.
 type ReplaceRule struct {
 	// Name specifies the name of the tag that the replace rule addresses. However,
@@ -138,6 +187,64 @@ type WriterConfig struct {
 	FlushPeriodSeconds float64 `mapstructure:"flush_period_seconds"`
 }
 
+// TailSamplingConfig holds the configuration of the tail-based sampling, which buffers the
+// spans of each trace and decides whether to keep it once it is complete.
+type TailSamplingConfig struct {
+	// Enabled specifies whether the traces matching the policies are kept, in addition to the
+	// ones kept by the sampling of each trace chunk as it arrives.
+	Enabled bool `mapstructure:"enabled"`
+
+	// DecisionWaitSeconds specifies how long the spans of a trace are buffered, from the
+	// reception of its first chunk, before deciding whether to keep it. Fractions are permitted.
+	DecisionWaitSeconds float64 `mapstructure:"decision_wait_seconds"`
+
+	// MaxTraces specifies the maximum number of traces buffered. Above it, the decision is
+	// taken early on the oldest traces.
+	MaxTraces int `mapstructure:"max_traces"`
+
+	// MaxSpans specifies the maximum number of spans buffered. Above it, the decision is
+	// taken early on the oldest traces.
+	MaxSpans int `mapstructure:"max_spans"`
+
+	// Policies specifies the policies of the traces to keep. A trace is kept when it matches
+	// any of them.
+	Policies []*TailSamplingPolicy `mapstructure:"policies"`
+}
+
+// TailSamplingPolicy describes the traces kept by the tail-based sampling. A trace matches
+// the policy when it satisfies all its conditions, the unset ones being ignored.
+type TailSamplingPolicy struct {
+	// Name identifies the policy in the telemetry.
+	Name string `mapstructure:"name"`
+
+	// MinDurationMs matches the traces lasting at least this long, from the start of their
+	// first span to the end of their last one, in milliseconds.
+	MinDurationMs float64 `mapstructure:"min_duration_ms"`
+
+	// Service, Resource, Error, StatusCodes and Tags match the traces having at least one span
+	// which satisfies all of them.
+
+	// Service matches the spans of this service.
+	Service string `mapstructure:"service"`
+
+	// Resource is a regular expression matching the resource of the spans.
+	Resource string `mapstructure:"resource"`
+
+	// Error matches the spans in error.
+	Error bool `mapstructure:"error"`
+
+	// StatusCodes matches the spans whose "http.status_code" tag is one of these codes, or of
+	// these classes of codes, such as "5xx".
+	StatusCodes []string `mapstructure:"status_codes"`
+
+	// Tags matches the spans having all these tags. An empty value matches any value of the tag.
+	Tags map[string]string `mapstructure:"tags"`
+
+	// Percentage specifies the percentage of the matching traces to keep, in (0, 100]. It is
+	// required: a policy without it would keep none of its traces and is rejected.
+	Percentage float64 `mapstructure:"percentage"`
+}
+
 func (c *AgentConfig) applyDatadogConfig() error {
 	if len(c.Endpoints) == 0 {
 		c.Endpoints = []*Endpoint{{}}
@@ -289,6 +396,8 @@ func (c *AgentConfig) applyDatadogConfig() error {
 		GRPCPort:        grpcPort,
 		MaxRequestBytes: c.MaxRequestBytes,
 	}
+	c.ZipkinReceiverEnabled = config.Datadog.GetBool("apm_config.zipkin_receiver.enabled")
+	c.JaegerReceiverEnabled = config.Datadog.GetBool("apm_config.jaeger_receiver.enabled")
 
 	if config.Datadog.IsSet("apm_config.obfuscation") {
 		var o ObfuscationConfig
@@ -301,6 +410,22 @@ func (c *AgentConfig) applyDatadogConfig() error {
 		}
 	}
 
+	if config.Datadog.GetBool("apm_config.tail_sampling.enabled") {
+		ts := &TailSamplingConfig{
+			DecisionWaitSeconds: config.Datadog.GetFloat64("apm_config.tail_sampling.decision_wait_seconds"),
+			MaxTraces:           config.Datadog.GetInt("apm_config.tail_sampling.max_traces"),
+			MaxSpans:            config.Datadog.GetInt("apm_config.tail_sampling.max_spans"),
+		}
+		if err := config.Datadog.UnmarshalKey("apm_config.tail_sampling.policies", &ts.Policies); err != nil {
+			return fmt.Errorf("bad format for apm_config.tail_sampling.policies: %v", err)
+		}
+		if err := validateTailSampling(ts); err != nil {
+			return fmt.Errorf("apm_config.tail_sampling: %v", err)
+		}
+		ts.Enabled = true
+		c.TailSampling = ts
+	}
+
 	if config.Datadog.IsSet("apm_config.filter_tags.require") {
 		tags := config.Datadog.GetStringSlice("apm_config.filter_tags.require")
 		for _, tag := range tags {
@@ -408,6 +533,49 @@ func (c *AgentConfig) applyDatadogConfig() error {
 	return nil
 }
 
+// validateTailSampling validates the configuration ts of the tail-based sampling.
+func validateTailSampling(ts *TailSamplingConfig) error {
+	if ts.DecisionWaitSeconds <= 0 {
+		return fmt.Errorf("decision_wait_seconds must be positive, got %v", ts.DecisionWaitSeconds)
+	}
+	if ts.MaxTraces <= 0 || ts.MaxSpans <= 0 {
+		return fmt.Errorf("max_traces and max_spans must be positive, got %d and %d", ts.MaxTraces, ts.MaxSpans)
+	}
+	if len(ts.Policies) == 0 {
+		return errors.New("at least one policy is required")
+	}
+	for i, p := range ts.Policies {
+		if p.Name == "" {
+			p.Name = "policy_" + strconv.Itoa(i)
+		}
+		if p.Percentage <= 0 || p.Percentage > 100 {
+			return fmt.Errorf("policy %q: percentage must be in (0, 100], got %v", p.Name, p.Percentage)
+		}
+		if _, err := regexp.Compile(p.Resource); err != nil {
+			return fmt.Errorf("policy %q: invalid resource: %v", p.Name, err)
+		}
+		for _, code := range p.StatusCodes {
+			if !validStatusCode(code) {
+				return fmt.Errorf("policy %q: invalid status code %q", p.Name, code)
+			}
+		}
+	}
+	return nil
+}
+
+// validStatusCode reports whether code is an HTTP status code, such as "404", or a class
+// of status codes, such as "4xx".
+func validStatusCode(code string) bool {
+	if len(code) != 3 || code[0] < '1' || code[0] > '5' {
+		return false
+	}
+	if strings.ToLower(code[1:]) == "xx" {
+		return true
+	}
+	_, err := strconv.Atoi(code)
+	return err == nil
+}
+
 // loadDeprecatedValues loads a set of deprecated values which are kept for
 // backwards compatibility with Agent 5. These should eventually be removed.
 // TODO(x): remove them gradually or fully in a future release.
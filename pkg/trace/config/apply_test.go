@@ -59,3 +59,33 @@ func TestSplitTag(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateTailSampling(t *testing.T) {
+	valid := func() *TailSamplingConfig {
+		return &TailSamplingConfig{
+			DecisionWaitSeconds: 10,
+			MaxTraces:           10,
+			MaxSpans:            100,
+			Policies:            []*TailSamplingPolicy{{Name: "errors", Error: true, Percentage: 100}},
+		}
+	}
+	assert.NoError(t, validateTailSampling(valid()))
+
+	for name, update := range map[string]func(*TailSamplingConfig){
+		"decision-wait": func(ts *TailSamplingConfig) { ts.DecisionWaitSeconds = 0 },
+		"max-traces":    func(ts *TailSamplingConfig) { ts.MaxTraces = 0 },
+		"max-spans":     func(ts *TailSamplingConfig) { ts.MaxSpans = -1 },
+		"no-policy":     func(ts *TailSamplingConfig) { ts.Policies = nil },
+		"percentage":    func(ts *TailSamplingConfig) { ts.Policies[0].Percentage = 101 },
+		"no-percentage": func(ts *TailSamplingConfig) { ts.Policies[0].Percentage = 0 },
+		"resource":      func(ts *TailSamplingConfig) { ts.Policies[0].Resource = "(" },
+		"status-class":  func(ts *TailSamplingConfig) { ts.Policies[0].StatusCodes = []string{"6xx"} },
+		"status-code":   func(ts *TailSamplingConfig) { ts.Policies[0].StatusCodes = []string{"50"} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			ts := valid()
+			update(ts)
+			assert.Error(t, validateTailSampling(ts))
+		})
+	}
+}
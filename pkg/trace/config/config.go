@@ -133,6 +133,15 @@ type AgentConfig struct {
 	// OTLPReceiver holds the configuration for OpenTelemetry receiver.
 	OTLPReceiver *OTLP
 
+	// ZipkinReceiverEnabled reports whether the spans sent by Zipkin clients are accepted on /api/v2/spans.
+	ZipkinReceiverEnabled bool
+
+	// JaegerReceiverEnabled reports whether the spans sent by Jaeger clients are accepted on /api/traces.
+	JaegerReceiverEnabled bool
+
+	// TailSampling holds the configuration of the tail-based sampling, or nil when it is disabled.
+	TailSampling *TailSamplingConfig
+
 	// Profiling settings, or nil if profiling is disabled
 	ProfilingSettings *profiling.Settings
 }
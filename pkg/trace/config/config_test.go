@@ -306,6 +306,17 @@ func TestFullYamlConfig(t *testing.T) {
 	assert.True(o.RemoveStackTraces)
 	assert.True(c.Obfuscation.Redis.Enabled)
 	assert.True(c.Obfuscation.Memcached.Enabled)
+	assert.Equal("postgresql", o.SQL.DBMS)
+	assert.True(o.SQL.TableNames)
+	assert.True(o.SQL.CollectCommands)
+	assert.True(o.GraphQL.Enabled)
+	assert.EqualValues([]string{"first"}, o.GraphQL.KeepValues)
+	assert.True(o.GRPC.Enabled)
+	assert.EqualValues([]string{"content-type"}, o.GRPC.KeepValues)
+	assert.True(o.AWS.Enabled)
+	assert.EqualValues([]string{"TableName"}, o.AWS.KeepValues)
+	assert.True(o.Messaging.Enabled)
+	assert.EqualValues([]string{"id"}, o.Messaging.KeepValues)
 }
 
 func TestUndocumentedYamlConfig(t *testing.T) {
@@ -419,3 +430,34 @@ func TestNormalizeEnvFromConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestTailSamplingConfig(t *testing.T) {
+	defer cleanConfig()()
+	assert := assert.New(t)
+
+	c, err := prepareConfig("./testdata/tail_sampling.yaml")
+	assert.NoError(err)
+	assert.NoError(c.applyDatadogConfig())
+
+	ts := c.TailSampling
+	if !assert.NotNil(ts) {
+		return
+	}
+	assert.True(ts.Enabled)
+	assert.Equal(2.5, ts.DecisionWaitSeconds)
+	assert.Equal(500, ts.MaxTraces)
+	assert.Equal(100_000, ts.MaxSpans)
+	assert.Equal([]*TailSamplingPolicy{
+		{Name: "slow", MinDurationMs: 500, Percentage: 100},
+		{
+			Name:        "checkout_errors",
+			Service:     "checkout",
+			Resource:    "^POST /cart",
+			Error:       true,
+			StatusCodes: []string{"5xx", "429"},
+			Tags:        map[string]string{"customer.tier": "gold"},
+			Percentage:  100,
+		},
+		{Name: "policy_2", Percentage: 5},
+	}, ts.Policies)
+}
@@ -0,0 +1,149 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config/remote/service"
+	"github.com/DataDog/datadog-agent/pkg/proto/pbgo"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// RemoteFilters holds the span filtering rules received through remote configuration. They
+// apply in addition to the "apm_config.replace_tags" and "apm_config.ignore_resources" rules.
+type RemoteFilters struct {
+	// ReplaceTags holds the compiled tag replacement rules.
+	ReplaceTags []*ReplaceRule
+	// IgnoreResources holds the expressions matching the resources of the traces to drop.
+	IgnoreResources []string
+}
+
+// remoteFiltersFile is the JSON format of the remote configuration files of the APM_FILTERS
+// product. Its fields follow the format of their counterpart in the agent's configuration.
+type remoteFiltersFile struct {
+	ReplaceTags []struct {
+		Name    string `json:"name"`
+		Pattern string `json:"pattern"`
+		Repl    string `json:"repl"`
+	} `json:"replace_tags"`
+	IgnoreResources []string `json:"ignore_resources"`
+}
+
+// FiltersStatus reports the state of the span filtering rules received through remote configuration.
+type FiltersStatus struct {
+	// Version is the version of the remote configuration in use.
+	Version uint64
+	// ReplaceTags is the number of tag replacement rules in use.
+	ReplaceTags int
+	// IgnoreResources is the number of ignored resources expressions in use.
+	IgnoreResources int
+	// LastUpdate is the time at which the configuration in use was loaded.
+	LastUpdate time.Time
+	// LastError holds the reason why the last configuration received was rejected. It is
+	// empty if it was loaded.
+	LastError string
+}
+
+// FiltersSubscriber subscribes to the span filtering rules pushed through remote configuration.
+// The rules are validated as a whole: a configuration holding an invalid rule is rejected and
+// the rules in use are kept.
+type FiltersSubscriber struct {
+	callback func(*RemoteFilters)
+
+	mu             sync.RWMutex // guards status and stopSubscriber
+	status         FiltersStatus
+	stopSubscriber context.CancelFunc
+}
+
+// NewFiltersSubscriber returns a new FiltersSubscriber which calls callback with the rules of
+// each valid configuration received.
+func NewFiltersSubscriber(callback func(*RemoteFilters)) *FiltersSubscriber {
+	return &FiltersSubscriber{callback: callback}
+}
+
+// Start subscribes to the configuration updates from agent-core.
+func (s *FiltersSubscriber) Start() error {
+	close, err := service.NewGRPCSubscriber(pbgo.Product_APM_FILTERS, s.loadNewConfig)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.stopSubscriber = close
+	s.mu.Unlock()
+	return nil
+}
+
+// Stop listening for new configurations.
+func (s *FiltersSubscriber) Stop() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.stopSubscriber != nil {
+		s.stopSubscriber()
+	}
+}
+
+// Status returns the state of the remote filters.
+func (s *FiltersSubscriber) Status() FiltersStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+func (s *FiltersSubscriber) loadNewConfig(new *pbgo.ConfigResponse) error {
+	version := new.ConfigDelegatedTargetVersion
+	filters, err := parseRemoteFilters(new.TargetFiles)
+	if err != nil {
+		log.Errorf("Rejected remote filters version %d: %v", version, err)
+		s.mu.Lock()
+		s.status.LastError = err.Error()
+		s.mu.Unlock()
+		return err
+	}
+	log.Infof("Loaded remote filters version %d: %d replace rules, %d ignored resources", version, len(filters.ReplaceTags), len(filters.IgnoreResources))
+	s.callback(filters)
+	s.mu.Lock()
+	s.status = FiltersStatus{
+		Version:         version,
+		ReplaceTags:     len(filters.ReplaceTags),
+		IgnoreResources: len(filters.IgnoreResources),
+		LastUpdate:      time.Now(),
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// parseRemoteFilters decodes and validates the rules of the given remote configuration files.
+// If it fails it returns the first error.
+func parseRemoteFilters(files []*pbgo.File) (*RemoteFilters, error) {
+	var filters RemoteFilters
+	for _, f := range files {
+		var rf remoteFiltersFile
+		if err := json.Unmarshal(f.Raw, &rf); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Path, err)
+		}
+		rules := make([]*ReplaceRule, 0, len(rf.ReplaceTags))
+		for _, r := range rf.ReplaceTags {
+			rules = append(rules, &ReplaceRule{Name: r.Name, Pattern: r.Pattern, Repl: r.Repl})
+		}
+		if err := compileReplaceRules(rules); err != nil {
+			return nil, fmt.Errorf("%s: replace_tags: %v", f.Path, err)
+		}
+		for _, expr := range rf.IgnoreResources {
+			if _, err := regexp.Compile(expr); err != nil {
+				return nil, fmt.Errorf("%s: ignore_resources: %v", f.Path, err)
+			}
+		}
+		filters.ReplaceTags = append(filters.ReplaceTags, rules...)
+		filters.IgnoreResources = append(filters.IgnoreResources, rf.IgnoreResources...)
+	}
+	return &filters, nil
+}
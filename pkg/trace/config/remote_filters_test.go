@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/proto/pbgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRemoteFilters(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("valid", func(t *testing.T) {
+		f, err := parseRemoteFilters([]*pbgo.File{
+			{Path: "filters/1", Raw: []byte(`{"replace_tags":[{"name":"*","pattern":"[^@ ]+@[^@ ]+","repl":"?"}],"ignore_resources":["^GET /health$"]}`)},
+			{Path: "filters/2", Raw: []byte(`{"replace_tags":[{"name":"http.url","pattern":"token=[^&]*","repl":"token=?"}]}`)},
+		})
+		assert.NoError(err)
+		assert.Len(f.ReplaceTags, 2)
+		assert.Equal("*", f.ReplaceTags[0].Name)
+		assert.Equal("mail ?", f.ReplaceTags[0].Re.ReplaceAllString("mail jane@example.com", f.ReplaceTags[0].Repl))
+		assert.Equal("http.url", f.ReplaceTags[1].Name)
+		assert.NotNil(f.ReplaceTags[1].Re)
+		assert.Equal([]string{"^GET /health$"}, f.IgnoreResources)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		f, err := parseRemoteFilters(nil)
+		assert.NoError(err)
+		assert.Empty(f.ReplaceTags)
+		assert.Empty(f.IgnoreResources)
+	})
+
+	for name, raw := range map[string]string{
+		"json":         `{"replace_tags":`,
+		"no-name":      `{"replace_tags":[{"pattern":"a","repl":"b"}]}`,
+		"no-pattern":   `{"replace_tags":[{"name":"a","repl":"b"}]}`,
+		"bad-pattern":  `{"replace_tags":[{"name":"a","pattern":"(","repl":"b"}]}`,
+		"bad-resource": `{"ignore_resources":["["]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseRemoteFilters([]*pbgo.File{
+				{Path: "filters/1", Raw: []byte(`{"ignore_resources":["ok"]}`)},
+				{Path: "filters/2", Raw: []byte(raw)},
+			})
+			assert.Error(err)
+			assert.Contains(err.Error(), "filters/2")
+		})
+	}
+}
+
+func TestFiltersSubscriberLoadNewConfig(t *testing.T) {
+	assert := assert.New(t)
+	var got []*RemoteFilters
+	s := NewFiltersSubscriber(func(f *RemoteFilters) { got = append(got, f) })
+
+	err := s.loadNewConfig(&pbgo.ConfigResponse{
+		ConfigDelegatedTargetVersion: 3,
+		TargetFiles: []*pbgo.File{
+			{Path: "filters/1", Raw: []byte(`{"replace_tags":[{"name":"user.email","pattern":".*","repl":"?"}],"ignore_resources":["/health"]}`)},
+		},
+	})
+	assert.NoError(err)
+	assert.Len(got, 1)
+	status := s.Status()
+	assert.EqualValues(3, status.Version)
+	assert.Equal(1, status.ReplaceTags)
+	assert.Equal(1, status.IgnoreResources)
+	assert.False(status.LastUpdate.IsZero())
+	assert.Empty(status.LastError)
+
+	// an invalid configuration is rejected and the rules in use are kept
+	err = s.loadNewConfig(&pbgo.ConfigResponse{
+		ConfigDelegatedTargetVersion: 4,
+		TargetFiles: []*pbgo.File{
+			{Path: "filters/1", Raw: []byte(`{"replace_tags":[{"name":"user.email","pattern":"(","repl":"?"}]}`)},
+		},
+	})
+	assert.Error(err)
+	assert.Len(got, 1)
+	status = s.Status()
+	assert.EqualValues(3, status.Version)
+	assert.Equal(1, status.ReplaceTags)
+	assert.Contains(status.LastError, "filters/1: replace_tags")
+
+	// removing all the files removes all the rules
+	err = s.loadNewConfig(&pbgo.ConfigResponse{ConfigDelegatedTargetVersion: 5})
+	assert.NoError(err)
+	assert.Len(got, 2)
+	assert.Empty(got[1].ReplaceTags)
+	assert.Empty(got[1].IgnoreResources)
+	status = s.Status()
+	assert.EqualValues(5, status.Version)
+	assert.Empty(status.LastError)
+}
@@ -7,20 +7,22 @@ package filters
 
 import (
 	"regexp"
+	"sync"
 
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
 // Blacklister holds a list of regular expressions which will match resources
-// on spans that should be dropped.
+// on spans that should be dropped. It is safe for concurrent use.
 type Blacklister struct {
+	mu   sync.RWMutex // guards list
 	list []*regexp.Regexp
 }
 
 // Allows returns true if the Blacklister permits this span.
 func (f *Blacklister) Allows(span *pb.Span) bool {
-	for _, entry := range f.list {
+	for _, entry := range f.getList() {
 		if entry.MatchString(span.Resource) {
 			return false
 		}
@@ -30,7 +32,7 @@ func (f *Blacklister) Allows(span *pb.Span) bool {
 
 // AllowsStat returns true if the Blacklister permits this stat
 func (f *Blacklister) AllowsStat(stat *pb.ClientGroupedStats) bool {
-	for _, entry := range f.list {
+	for _, entry := range f.getList() {
 		if entry.MatchString(stat.Resource) {
 			return false
 		}
@@ -44,6 +46,21 @@ func NewBlacklister(exprs []string) *Blacklister {
 	return &Blacklister{list: compileRules(exprs)}
 }
 
+// UpdateList replaces the Blacklister's regular expressions with the given ones.
+func (f *Blacklister) UpdateList(exprs []string) {
+	list := compileRules(exprs)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.list = list
+}
+
+// getList returns the Blacklister's current regular expressions.
+func (f *Blacklister) getList() []*regexp.Regexp {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.list
+}
+
 // compileRules compiles as many rules as possible from the list of expressions.
 func compileRules(exprs []string) []*regexp.Regexp {
 	list := make([]*regexp.Regexp, 0, len(exprs))
@@ -56,3 +56,16 @@ func TestCompileRules(t *testing.T) {
 		assert.True(t, filter.AllowsStat(&stat))
 	}
 }
+
+func TestBlacklisterUpdateList(t *testing.T) {
+	filter := NewBlacklister([]string{"/health"})
+	span := testutil.RandomSpan()
+	span.Resource = "/health"
+	assert.False(t, filter.Allows(span))
+
+	filter.UpdateList([]string{"^GET /users/", "[123"})
+	assert.True(t, filter.Allows(span))
+	span.Resource = "GET /users/42"
+	assert.False(t, filter.Allows(span))
+	assert.False(t, filter.AllowsStat(&pb.ClientGroupedStats{Resource: "GET /users/42"}))
+}
@@ -7,14 +7,16 @@ package filters
 
 import (
 	"strconv"
+	"sync"
 
 	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 )
 
 // Replacer is a filter which replaces tag values based on its
-// settings. It keeps all spans.
+// settings. It keeps all spans. It is safe for concurrent use.
 type Replacer struct {
+	mu    sync.RWMutex // guards rules
 	rules []*config.ReplaceRule
 }
 
@@ -23,9 +25,24 @@ func NewReplacer(rules []*config.ReplaceRule) *Replacer {
 	return &Replacer{rules: rules}
 }
 
+// UpdateRules replaces the Replacer's rules with the given ones. The rules
+// must have been compiled.
+func (f *Replacer) UpdateRules(rules []*config.ReplaceRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = rules
+}
+
+// getRules returns the Replacer's current rules.
+func (f *Replacer) getRules() []*config.ReplaceRule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.rules
+}
+
 // Replace replaces all tags matching the Replacer's rules.
-func (f Replacer) Replace(trace pb.Trace) {
-	for _, rule := range f.rules {
+func (f *Replacer) Replace(trace pb.Trace) {
+	for _, rule := range f.getRules() {
 		key, str, re := rule.Name, rule.Repl, rule.Re
 		for _, s := range trace {
 			switch key {
@@ -50,8 +67,8 @@ func (f Replacer) Replace(trace pb.Trace) {
 }
 
 // ReplaceStatsGroup applies the replacer rules to the given stats bucket group.
-func (f Replacer) ReplaceStatsGroup(b *pb.ClientGroupedStats) {
-	for _, rule := range f.rules {
+func (f *Replacer) ReplaceStatsGroup(b *pb.ClientGroupedStats) {
+	for _, rule := range f.getRules() {
 		key, str, re := rule.Name, rule.Repl, rule.Re
 		switch key {
 		case "resource.name":
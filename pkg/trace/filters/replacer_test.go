@@ -121,6 +121,22 @@ func TestReplacer(t *testing.T) {
 	})
 }
 
+func TestReplacerUpdateRules(t *testing.T) {
+	assert := assert.New(t)
+	tr := NewReplacer(parseRulesFromString([][3]string{{"http.url", "token=[^&]*", "token=?"}}))
+
+	span := replaceFilterTestSpan(map[string]string{"http.url": "/x?token=abc", "user.email": "jane@example.com"})
+	tr.Replace(pb.Trace{span})
+	assert.Equal("/x?token=?", span.Meta["http.url"])
+	assert.Equal("jane@example.com", span.Meta["user.email"])
+
+	tr.UpdateRules(parseRulesFromString([][3]string{{"*", "[^@]+@[^@]+", "?"}}))
+	span = replaceFilterTestSpan(map[string]string{"http.url": "/x?token=abc", "user.email": "jane@example.com"})
+	tr.Replace(pb.Trace{span})
+	assert.Equal("/x?token=abc", span.Meta["http.url"])
+	assert.Equal("?", span.Meta["user.email"])
+}
+
 func parseRulesFromString(rules [][3]string) []*config.ReplaceRule {
 	r := make([]*config.ReplaceRule, 0, len(rules))
 	for _, rule := range rules {
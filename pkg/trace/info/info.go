@@ -37,6 +37,8 @@ var (
 	watchdogInfo     watchdog.Info
 	rateByService    map[string]float64
 	rateLimiterStats RateLimiterStats
+	tailSampling     TailSamplingStats
+	remoteFilters    RemoteFiltersStats
 	start            = time.Now()
 	once             sync.Once
 	infoTmpl         *template.Template
@@ -84,6 +86,19 @@ const (
   {{if gt .Status.TraceWriter.Errors 0}}WARNING: Traces API errors (1 min): {{.Status.TraceWriter.Errors}}{{end}}
   Stats: {{.Status.StatsWriter.Payloads}} payloads, {{.Status.StatsWriter.StatsBuckets}} stats buckets, {{.Status.StatsWriter.Bytes}} bytes
   {{if gt .Status.StatsWriter.Errors 0}}WARNING: Stats API errors (1 min): {{.Status.StatsWriter.Errors}}{{end}}
+  {{if .Status.TailSampling.Enabled}}
+  --- Tail sampling ---
+
+  Traces: {{.Status.TailSampling.TracesKept}} kept, {{.Status.TailSampling.TracesDropped}} dropped, {{.Status.TailSampling.TracesEvicted}} decided early
+  Buffered: {{.Status.TailSampling.TracesBuffered}} traces, {{.Status.TailSampling.SpansBuffered}} spans
+  {{if gt .Status.TailSampling.SpansLate 0}}Spans received after the decision on their trace: {{.Status.TailSampling.SpansLate}}{{end}}
+  {{end}}
+  {{if .Status.RemoteFilters.Enabled}}
+  --- Remote filters ---
+
+  Version: {{.Status.RemoteFilters.Version}}, {{.Status.RemoteFilters.ReplaceTags}} replace rules, {{.Status.RemoteFilters.IgnoreResources}} ignored resources
+  {{if .Status.RemoteFilters.LastError}}WARNING: Rejected remote filters: {{.Status.RemoteFilters.LastError}}{{end}}
+  {{end}}
 `
 
 	notRunningTmplSrc = `{{.Banner}}
@@ -188,6 +203,64 @@ func publishRateLimiterStats() interface{} {
 	return rateLimiterStats
 }
 
+// TailSamplingStats contains the stats of the tail-based sampling, since the start of the agent.
+type TailSamplingStats struct {
+	// Enabled specifies whether the tail-based sampling is enabled.
+	Enabled bool
+	// TracesKept is the number of traces kept by a policy.
+	TracesKept int64
+	// TracesDropped is the number of traces which matched no policy.
+	TracesDropped int64
+	// TracesEvicted is the number of traces decided early because the buffer was full.
+	TracesEvicted int64
+	// SpansLate is the number of spans received after the decision on their trace.
+	SpansLate int64
+	// TracesBuffered is the number of traces currently buffered.
+	TracesBuffered int64
+	// SpansBuffered is the number of spans currently buffered.
+	SpansBuffered int64
+}
+
+// UpdateTailSampling updates internal stats about the tail-based sampling.
+func UpdateTailSampling(ts TailSamplingStats) {
+	infoMu.Lock()
+	defer infoMu.Unlock()
+	tailSampling = ts
+}
+
+func publishTailSamplingStats() interface{} {
+	infoMu.RLock()
+	defer infoMu.RUnlock()
+	return tailSampling
+}
+
+// RemoteFiltersStats contains the state of the span filtering rules received through remote configuration.
+type RemoteFiltersStats struct {
+	// Enabled specifies whether the remote filters are enabled.
+	Enabled bool
+	// Version is the version of the remote configuration in use.
+	Version uint64
+	// ReplaceTags is the number of tag replacement rules in use.
+	ReplaceTags int
+	// IgnoreResources is the number of ignored resources expressions in use.
+	IgnoreResources int
+	// LastError holds the reason why the last configuration received was rejected.
+	LastError string
+}
+
+// UpdateRemoteFilters updates internal stats about the remote filters.
+func UpdateRemoteFilters(rs RemoteFiltersStats) {
+	infoMu.Lock()
+	defer infoMu.Unlock()
+	remoteFilters = rs
+}
+
+func publishRemoteFiltersStats() interface{} {
+	infoMu.RLock()
+	defer infoMu.RUnlock()
+	return remoteFilters
+}
+
 func publishUptime() interface{} {
 	return int(time.Since(start) / time.Second)
 }
@@ -219,6 +292,8 @@ func InitInfo(conf *config.AgentConfig) error {
 		expvar.Publish("ratebyservice", expvar.Func(publishRateByService))
 		expvar.Publish("watchdog", expvar.Func(publishWatchdogInfo))
 		expvar.Publish("ratelimiter", expvar.Func(publishRateLimiterStats))
+		expvar.Publish("tail_sampling", expvar.Func(publishTailSamplingStats))
+		expvar.Publish("remote_filters", expvar.Func(publishRemoteFiltersStats))
 
 		// copy the config to ensure we don't expose sensitive data such as API keys
 		c := *conf
@@ -276,6 +351,8 @@ type StatusInfo struct {
 	StatsWriter   StatsWriterInfo    `json:"stats_writer"`
 	Watchdog      watchdog.Info      `json:"watchdog"`
 	RateLimiter   RateLimiterStats   `json:"ratelimiter"`
+	TailSampling  TailSamplingStats  `json:"tail_sampling"`
+	RemoteFilters RemoteFiltersStats `json:"remote_filters"`
 	Config        config.AgentConfig `json:"config"`
 }
 
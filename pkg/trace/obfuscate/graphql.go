@@ -0,0 +1,200 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package obfuscate
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+const (
+	// graphqlSourceTag holds the GraphQL document sent by the client.
+	graphqlSourceTag = "graphql.source"
+	// graphqlVariablesPrefix prefixes the tags holding the values of the document's variables.
+	graphqlVariablesPrefix = "graphql.variables."
+	// nonParsableGraphQL replaces the GraphQL documents which could not be obfuscated.
+	nonParsableGraphQL = "Non-parsable GraphQL query"
+)
+
+var (
+	errGraphQLUnterminatedString = errors.New("unterminated string literal")
+	errGraphQLNewlineInString    = errors.New("newline in string literal")
+)
+
+// graphqlObfuscator obfuscates the literals of GraphQL documents and the values of their variables.
+type graphqlObfuscator struct {
+	keepKeys map[string]bool // the values of these arguments, input fields and variables will not be obfuscated
+}
+
+func newGraphQLObfuscator(cfg *config.KeepValuesObfuscationConfig) *graphqlObfuscator {
+	keepKeys := make(map[string]bool, len(cfg.KeepValues))
+	for _, v := range cfg.KeepValues {
+		keepKeys[v] = true
+	}
+	return &graphqlObfuscator{keepKeys: keepKeys}
+}
+
+// obfuscateGraphQL obfuscates the literals found in the span's resource and "graphql.source" tag,
+// along with the values of its "graphql.variables.*" tags.
+func (o *Obfuscator) obfuscateGraphQL(span *pb.Span) {
+	if o.graphql == nil {
+		return
+	}
+	if span.Resource != "" {
+		span.Resource = o.graphql.obfuscateString(span.Resource)
+	}
+	for k, v := range span.Meta {
+		switch {
+		case k == graphqlSourceTag:
+			span.Meta[k] = o.graphql.obfuscateString(v)
+		case strings.HasPrefix(k, graphqlVariablesPrefix):
+			if v != "" && !o.graphql.keepKeys[k[len(graphqlVariablesPrefix):]] {
+				span.Meta[k] = "?"
+			}
+		}
+	}
+}
+
+// obfuscateString obfuscates the given GraphQL document, replacing it entirely if it can not be parsed.
+func (o *graphqlObfuscator) obfuscateString(doc string) string {
+	out, err := o.obfuscate(doc)
+	if err != nil {
+		return nonParsableGraphQL
+	}
+	return out
+}
+
+// obfuscate replaces with "?" the string and number literals of the given GraphQL document and
+// removes its comments. The literals given to kept arguments, input fields or variable defaults
+// are left untouched. Names (including booleans, null and enum values) and variable references
+// are never obfuscated.
+func (o *graphqlObfuscator) obfuscate(doc string) (string, error) {
+	var (
+		out strings.Builder
+		// name holds the last name scanned, if it was the last token.
+		name string
+		// keeping reports whether the literals being scanned are the value of a kept key.
+		keeping bool
+	)
+	out.Grow(len(doc))
+	literal := func(lit string) {
+		if keeping {
+			out.WriteString(lit)
+		} else {
+			out.WriteByte('?')
+		}
+	}
+	for i := 0; i < len(doc); {
+		c := doc[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			out.WriteByte(c)
+			i++
+			continue
+		case c == '#':
+			// comments run until the end of the line
+			for i < len(doc) && doc[i] != '\n' && doc[i] != '\r' {
+				i++
+			}
+			continue
+		case c == '"':
+			n, err := scanGraphQLString(doc[i:])
+			if err != nil {
+				return "", err
+			}
+			literal(doc[i : i+n])
+			i += n
+		case isDigit(rune(c)) || (c == '-' && i+1 < len(doc) && isDigit(rune(doc[i+1]))):
+			n := scanGraphQLNumber(doc[i:])
+			literal(doc[i : i+n])
+			i += n
+		case isGraphQLNameStart(c):
+			j := i + 1
+			for j < len(doc) && (isGraphQLNameStart(doc[j]) || isDigit(rune(doc[j]))) {
+				j++
+			}
+			name = doc[i:j]
+			out.WriteString(name)
+			i = j
+			continue
+		case c == ':':
+			if name != "" {
+				// the name is an argument, an input field, a variable definition or an
+				// alias; the literals which follow are its value.
+				keeping = o.keepKeys[name]
+			}
+			out.WriteByte(c)
+			i++
+		default:
+			out.WriteByte(c)
+			i++
+		}
+		name = ""
+	}
+	return out.String(), nil
+}
+
+// scanGraphQLString returns the length of the string or block string found at the start of s.
+func scanGraphQLString(s string) (int, error) {
+	if strings.HasPrefix(s, `"""`) {
+		for i := 3; i < len(s); i++ {
+			switch {
+			case strings.HasPrefix(s[i:], `\"""`):
+				i += 3
+			case strings.HasPrefix(s[i:], `"""`):
+				return i + 3, nil
+			}
+		}
+		return 0, errGraphQLUnterminatedString
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\n', '\r':
+			return 0, errGraphQLNewlineInString
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, errGraphQLUnterminatedString
+}
+
+// scanGraphQLNumber returns the length of the integer or float found at the start of s.
+func scanGraphQLNumber(s string) int {
+	i := 0
+	if s[i] == '-' {
+		i++
+	}
+	digits := func() {
+		for i < len(s) && isDigit(rune(s[i])) {
+			i++
+		}
+	}
+	digits()
+	if i+1 < len(s) && s[i] == '.' && isDigit(rune(s[i+1])) {
+		i++
+		digits()
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(rune(s[j])) {
+			i = j
+			digits()
+		}
+	}
+	return i
+}
+
+func isGraphQLNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
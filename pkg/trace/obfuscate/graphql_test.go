@@ -0,0 +1,99 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package obfuscate
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObfuscateGraphQLString(t *testing.T) {
+	o := newGraphQLObfuscator(&config.KeepValuesObfuscationConfig{KeepValues: []string{"first", "lang"}})
+	for _, tt := range []struct {
+		in, out string
+	}{
+		{
+			`{ user(id: 42) { name } }`,
+			`{ user(id: ?) { name } }`,
+		},
+		{
+			`query GetUser($id: ID!) { user(id: $id, email: "jane@example.com") { name } }`,
+			`query GetUser($id: ID!) { user(id: $id, email: ?) { name } }`,
+		},
+		{
+			`{ users(first: 10, after: "Y3Vyc29y", score: -1.5e3, active: true, role: ADMIN) { id } }`,
+			`{ users(first: 10, after: ?, score: ?, active: true, role: ADMIN) { id } }`,
+		},
+		{
+			`mutation { createUser(input: {email: "a@b.c", lang: "en", tags: ["x", "y"]}) { id } }`,
+			`mutation { createUser(input: {email: ?, lang: "en", tags: [?, ?]}) { id } }`,
+		},
+		{
+			`query ($first: Int = 5, $name: String = "bob") { small: picture(size: 64) }`,
+			`query ($first: Int = 5, $name: String = ?) { small: picture(size: ?) }`,
+		},
+		{
+			"{ note(text: \"\"\"multi\nline \\\"\"\" text\"\"\") # secret comment\n { id } }",
+			"{ note(text: ?) \n { id } }",
+		},
+		{
+			`{ user(name: "escaped \" quote") { id2 } }`,
+			`{ user(name: ?) { id2 } }`,
+		},
+	} {
+		out, err := o.obfuscate(tt.in)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.out, out)
+	}
+
+	for _, in := range []string{
+		`{ user(name: "unterminated) { id } }`,
+		"{ user(name: \"new\nline\") { id } }",
+		`{ note(text: """unterminated) { id } }`,
+	} {
+		_, err := o.obfuscate(in)
+		assert.Error(t, err, in)
+		assert.Equal(t, nonParsableGraphQL, o.obfuscateString(in))
+	}
+}
+
+func TestObfuscateGraphQL(t *testing.T) {
+	span := func() *pb.Span {
+		return &pb.Span{
+			Type:     "graphql",
+			Resource: `{ user(id: 42) { name } }`,
+			Meta: map[string]string{
+				"graphql.source":          `query ($id: ID!, $first: Int) { user(id: $id, token: "s3cr3t") { name } }`,
+				"graphql.variables.id":    "42",
+				"graphql.variables.first": "10",
+				"graphql.operation.name":  "GetUser",
+			},
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		s := span()
+		NewObfuscator(nil).Obfuscate(s)
+		assert.Equal(t, span(), s)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		s := span()
+		NewObfuscator(&config.ObfuscationConfig{
+			GraphQL: config.KeepValuesObfuscationConfig{Enabled: true, KeepValues: []string{"first"}},
+		}).Obfuscate(s)
+		assert.Equal(t, `{ user(id: ?) { name } }`, s.Resource)
+		assert.Equal(t, map[string]string{
+			"graphql.source":          `query ($id: ID!, $first: Int) { user(id: $id, token: ?) { name } }`,
+			"graphql.variables.id":    "?",
+			"graphql.variables.first": "10",
+			"graphql.operation.name":  "GetUser",
+		}, s.Meta)
+	})
+}
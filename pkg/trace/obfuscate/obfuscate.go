@@ -22,10 +22,14 @@ import (
 // concurrent use.
 type Obfuscator struct {
 	opts                 *config.ObfuscationConfig
-	es                   *jsonObfuscator // nil if disabled
-	mongo                *jsonObfuscator // nil if disabled
-	sqlExecPlan          *jsonObfuscator // nil if disabled
-	sqlExecPlanNormalize *jsonObfuscator // nil if disabled
+	es                   *jsonObfuscator    // nil if disabled
+	mongo                *jsonObfuscator    // nil if disabled
+	sqlExecPlan          *jsonObfuscator    // nil if disabled
+	sqlExecPlanNormalize *jsonObfuscator    // nil if disabled
+	graphql              *graphqlObfuscator // nil if disabled
+	grpc                 *tagsObfuscator    // nil if disabled
+	aws                  *tagsObfuscator    // nil if disabled
+	messaging            *tagsObfuscator    // nil if disabled
 	// sqlLiteralEscapes reports whether we should treat escape characters literally or as escape characters.
 	// A non-zero value means 'yes'. Different SQL engines behave in different ways and the tokenizer needs
 	// to be generic.
@@ -40,6 +44,17 @@ type Obfuscator struct {
 type SQLOptions struct {
 	// ReplaceDigits causes the obfuscator to replace digits in identifiers and table names with question marks.
 	ReplaceDigits bool `json:"replace_digits"`
+
+	// DBMS identifies the database management system the queries are sent to, so that they are
+	// tokenized following its dialect. It is one of the DBMS* constants; an empty value selects
+	// a generic dialect.
+	DBMS string `json:"dbms"`
+
+	// TableNames causes the obfuscator to collect the names of the tables referenced by the query.
+	TableNames bool `json:"table_names"`
+
+	// CollectCommands causes the obfuscator to collect the commands (SELECT, UPDATE, ...) run by the query.
+	CollectCommands bool `json:"collect_commands"`
 }
 
 // SetSQLLiteralEscapes sets whether or not escape characters should be treated literally by the SQL obfuscator.
@@ -77,6 +92,19 @@ func NewObfuscator(cfg *config.ObfuscationConfig) *Obfuscator {
 	if cfg.SQLExecPlanNormalize.Enabled {
 		o.sqlExecPlanNormalize = newJSONObfuscator(&cfg.SQLExecPlanNormalize, &o)
 	}
+	if cfg.GraphQL.Enabled {
+		o.graphql = newGraphQLObfuscator(&cfg.GraphQL)
+	}
+	if cfg.GRPC.Enabled {
+		// metadata keys are case-insensitive
+		o.grpc = newTagsObfuscator(&cfg.GRPC, true, grpcMetadataPrefixes...)
+	}
+	if cfg.AWS.Enabled {
+		o.aws = newTagsObfuscator(&cfg.AWS, false, awsParamsPrefixes...)
+	}
+	if cfg.Messaging.Enabled {
+		o.messaging = newTagsObfuscator(&cfg.Messaging, false, messagingPrefixes...)
+	}
 	return &o
 }
 
@@ -100,6 +128,16 @@ func (o *Obfuscator) Obfuscate(span *pb.Span) {
 		}
 	case "web", "http":
 		o.obfuscateHTTP(span)
+		// AWS SDK spans are HTTP client spans in most tracers
+		o.obfuscateAWS(span)
+	case "aws":
+		o.obfuscateAWS(span)
+	case "graphql":
+		o.obfuscateGraphQL(span)
+	case "grpc", "rpc":
+		o.obfuscateGRPC(span)
+	case "queue", "kafka":
+		o.obfuscateMessaging(span)
 	case "mongodb":
 		o.obfuscateJSON(span, "mongodb.query", o.mongo)
 	case "elasticsearch":
@@ -111,7 +149,7 @@ func (o *Obfuscator) Obfuscate(span *pb.Span) {
 func (o *Obfuscator) ObfuscateStatsGroup(b *pb.ClientGroupedStats) {
 	switch b.Type {
 	case "sql", "cassandra":
-		oq, err := o.ObfuscateSQLString(b.Resource)
+		oq, err := o.ObfuscateSQLStringWithOptions(b.Resource, o.sqlOptions(o.sqlDBMS(b.DBType)))
 		if err != nil {
 			log.Errorf("Error obfuscating stats group resource %q: %v", b.Resource, err)
 			b.Resource = nonParsableResource
@@ -281,7 +281,7 @@ func TestObfuscateConfig(t *testing.T) {
 // TestSQLObfuscationOptionsDeserializationMethod checks if the use of easyjson results in the same deserialization
 // output as encoding/json.
 func TestSQLObfuscationOptionsDeserializationMethod(t *testing.T) {
-	opts, err := json.Marshal(SQLOptions{ReplaceDigits: true})
+	opts, err := json.Marshal(SQLOptions{ReplaceDigits: true, DBMS: DBMSPostgres, TableNames: true, CollectCommands: true})
 	require.NoError(t, err)
 
 	var in, out SQLOptions
@@ -10,7 +10,6 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-	"unicode"
 	"unicode/utf8"
 
 	"github.com/DataDog/datadog-agent/pkg/trace/config/features"
@@ -22,6 +21,49 @@ import (
 const sqlQueryTag = "sql.query"
 const nonParsableResource = "Non-parsable SQL query"
 
+// DBMS values of SQLOptions, identifying the supported SQL dialects.
+const (
+	DBMSPostgres  = "postgresql"
+	DBMSMySQL     = "mysql"
+	DBMSSQLServer = "mssql"
+)
+
+// sqlDialects maps the database types found in the "db.type" and "db.system" span tags to the
+// DBMS whose dialect their queries follow.
+var sqlDialects = map[string]string{
+	"postgres":   DBMSPostgres,
+	"postgresql": DBMSPostgres,
+	"mysql":      DBMSMySQL,
+	"mariadb":    DBMSMySQL,
+	"mssql":      DBMSSQLServer,
+	"sqlserver":  DBMSSQLServer,
+	"sql-server": DBMSSQLServer,
+}
+
+// sqlCommands holds the keywords of the commands collected by the commandFinderFilter.
+var sqlCommands = map[string]struct{}{
+	"SELECT":   {},
+	"INSERT":   {},
+	"UPDATE":   {},
+	"DELETE":   {},
+	"MERGE":    {},
+	"CREATE":   {},
+	"ALTER":    {},
+	"DROP":     {},
+	"TRUNCATE": {},
+	"GRANT":    {},
+	"REVOKE":   {},
+	"CALL":     {},
+	"EXEC":     {},
+	"EXECUTE":  {},
+	"BEGIN":    {},
+	"COMMIT":   {},
+	"ROLLBACK": {},
+}
+
+// maxCommandLen is the length of the longest keyword in sqlCommands.
+const maxCommandLen = 8
+
 var questionMark = []byte("?")
 
 // tokenFilter is a generic interface that a sqlObfuscator expects. It defines
@@ -72,6 +114,10 @@ func (f *discardFilter) Filter(token, lastToken TokenKind, buffer []byte) (Token
 		if features.Has("keep_sql_alias") {
 			return token, buffer, nil
 		}
+		if token == DollarQuotedFunc {
+			// AS introduces the body of a function rather than an alias
+			return token, append([]byte("AS "), buffer...), nil
+		}
 		return Filtered, nil, nil
 	}
 
@@ -218,25 +264,40 @@ func (o *Obfuscator) ObfuscateSQLString(in string) (*ObfuscatedQuery, error) {
 // to quantize and obfuscate the given input SQL query string. Quantization removes some elements such as comments
 // and aliases and obfuscation attempts to hide sensitive information in strings and numbers by redacting them.
 func (o *Obfuscator) ObfuscateSQLStringWithOptions(in string, opts SQLOptions) (*ObfuscatedQuery, error) {
-	if v, ok := o.queryCache.Get(in); ok {
+	key := sqlCacheKey(in, opts)
+	if v, ok := o.queryCache.Get(key); ok {
 		return v.(*ObfuscatedQuery), nil
 	}
 	oq, err := o.obfuscateSQLString(in, opts)
 	if err != nil {
 		return oq, err
 	}
-	o.queryCache.Set(in, oq, oq.Cost())
+	o.queryCache.Set(key, oq, oq.Cost())
 	return oq, nil
 }
 
+// sqlCacheKey returns the key of the query cache for the query in, obfuscated with opts.
+func sqlCacheKey(in string, opts SQLOptions) string {
+	if opts.DBMS == "" && !opts.TableNames && !opts.CollectCommands {
+		return in
+	}
+	flags := []byte("---|")
+	for i, v := range []bool{opts.ReplaceDigits, opts.TableNames, opts.CollectCommands} {
+		if v {
+			flags[i] = '+'
+		}
+	}
+	return opts.DBMS + string(flags) + in
+}
+
 func (o *Obfuscator) obfuscateSQLString(in string, opts SQLOptions) (*ObfuscatedQuery, error) {
 	lesc := o.SQLLiteralEscapes()
-	tok := NewSQLTokenizer(in, lesc)
+	tok := newSQLTokenizer(in, lesc, opts.DBMS)
 	out, err := attemptObfuscationWithOptions(tok, opts)
 	if err != nil && tok.SeenEscape() {
 		// If the tokenizer failed, but saw an escape character in the process,
 		// try again treating escapes differently
-		tok = NewSQLTokenizer(in, !lesc)
+		tok = newSQLTokenizer(in, !lesc, opts.DBMS)
 		if out, err2 := attemptObfuscationWithOptions(tok, opts); err2 == nil {
 			// If the second attempt succeeded, change the default behavior so that
 			// on the next run we get it right in the first run.
@@ -251,10 +312,8 @@ func (o *Obfuscator) obfuscateSQLString(in string, opts SQLOptions) (*Obfuscated
 // token in a query.
 type tableFinderFilter struct {
 	storeTableNames bool
-	// seen keeps track of unique table names encountered by the filter.
-	seen map[string]struct{}
-	// csv specifies a comma-separated list of tables
-	csv strings.Builder
+	// tables holds the unique table names encountered by the filter.
+	tables csvSet
 }
 
 // Filter implements tokenFilter.
@@ -264,7 +323,7 @@ func (f *tableFinderFilter) Filter(token, lastToken TokenKind, buffer []byte) (T
 		// SELECT ... FROM [tableName]
 		// DELETE FROM [tableName]
 		// ... JOIN [tableName]
-		if r, _ := utf8.DecodeRune(buffer); !unicode.IsLetter(r) {
+		if r, _ := utf8.DecodeRune(buffer); !isLetter(r) && (token != ID || !isIdentifierQuote(r)) {
 			// first character in buffer is not a letter; we might have a nested
 			// query like SELECT * FROM (SELECT ...)
 			break
@@ -274,49 +333,138 @@ func (f *tableFinderFilter) Filter(token, lastToken TokenKind, buffer []byte) (T
 		// UPDATE [tableName]
 		// INSERT INTO [tableName]
 		if f.storeTableNames {
-			f.storeName(string(buffer))
+			f.tables.add(string(buffer))
 		}
 		return TableName, buffer, nil
 	}
 	return token, buffer, nil
 }
 
-// storeName marks the given table name as seen in the internal storage.
-func (f *tableFinderFilter) storeName(name string) {
-	if _, ok := f.seen[name]; ok {
-		return
+// CSV returns a comma-separated list of the tables seen by the filter.
+func (f *tableFinderFilter) CSV() string { return f.tables.String() }
+
+// Reset implements tokenFilter.
+func (f *tableFinderFilter) Reset() { f.tables.reset() }
+
+// commandFinderFilter is a filter which collects the commands (SELECT, UPDATE, ...) run by a query.
+// The keywords are only collected at the start of a statement, or of a common table expression,
+// so that the columns or options named like commands (e.g. ON DELETE) are not collected. SELECT
+// is reserved and only starts queries, it is also collected in subqueries and INSERT ... SELECT.
+type commandFinderFilter struct {
+	// commands holds the unique commands encountered by the filter.
+	commands csvSet
+	// last is the kind of the last token filtered, comments excluded.
+	last TokenKind
+	// depth is the number of parentheses opened by the current statement.
+	depth int
+	// ctes holds the depths of the open common table expressions, and closedCTE is true if the
+	// last token closed one.
+	ctes      []int
+	closedCTE bool
+}
+
+// Filter implements tokenFilter. It expects the tokens as they are scanned.
+func (f *commandFinderFilter) Filter(token, lastToken TokenKind, buffer []byte) (TokenKind, []byte, error) {
+	switch token {
+	case Comment:
+		return token, buffer, nil
+	case ID, Update, Insert:
+		if len(buffer) > maxCommandLen {
+			break
+		}
+		var space [maxCommandLen]byte
+		upper := toUpper(buffer, space[:0])
+		if _, ok := sqlCommands[string(upper)]; ok && (f.atStatementStart() || string(upper) == "SELECT") {
+			f.commands.add(string(upper))
+		}
 	}
-	if f.seen == nil {
-		f.seen = make(map[string]struct{}, 1)
+	f.closedCTE = false
+	switch token {
+	case '(':
+		f.depth++
+		if f.last == As {
+			// WITH name AS (DELETE ... RETURNING *)
+			f.ctes = append(f.ctes, f.depth)
+		}
+	case ')':
+		if n := len(f.ctes); n > 0 && f.ctes[n-1] == f.depth {
+			f.ctes = f.ctes[:n-1]
+			f.closedCTE = true
+		}
+		f.depth--
+	case ';':
+		f.depth, f.ctes = 0, f.ctes[:0]
 	}
-	f.seen[name] = struct{}{}
-	if f.csv.Len() > 0 {
-		f.csv.WriteByte(',')
+	f.last = token
+	return token, buffer, nil
+}
+
+// atStatementStart returns true if the next token starts a statement: it is the first token of
+// the query, the first one after a semicolon, or the first one in or after a common table expression.
+func (f *commandFinderFilter) atStatementStart() bool {
+	switch f.last {
+	case 0, ';':
+		return true
+	case '(':
+		return len(f.ctes) > 0 && f.ctes[len(f.ctes)-1] == f.depth
+	case ')':
+		return f.closedCTE
 	}
-	f.csv.WriteString(name)
+	return false
 }
 
-// CSV returns a comma-separated list of the tables seen by the filter.
-func (f *tableFinderFilter) CSV() string { return f.csv.String() }
+// CSV returns a comma-separated list of the commands seen by the filter.
+func (f *commandFinderFilter) CSV() string { return f.commands.String() }
 
 // Reset implements tokenFilter.
-func (f *tableFinderFilter) Reset() {
-	for k := range f.seen {
-		delete(f.seen, k)
+func (f *commandFinderFilter) Reset() {
+	f.commands.reset()
+	f.last, f.depth, f.ctes, f.closedCTE = 0, 0, f.ctes[:0], false
+}
+
+// csvSet holds a set of unique values as a comma-separated list, in the order they were added.
+type csvSet struct {
+	seen map[string]struct{}
+	csv  strings.Builder
+}
+
+// add adds v to the set, if it isn't already part of it.
+func (s *csvSet) add(v string) {
+	if _, ok := s.seen[v]; ok {
+		return
+	}
+	if s.seen == nil {
+		s.seen = make(map[string]struct{}, 1)
+	}
+	s.seen[v] = struct{}{}
+	if s.csv.Len() > 0 {
+		s.csv.WriteByte(',')
 	}
-	f.csv.Reset()
+	s.csv.WriteString(v)
+}
+
+// String returns the comma-separated list of values.
+func (s *csvSet) String() string { return s.csv.String() }
+
+// reset empties the set.
+func (s *csvSet) reset() {
+	for k := range s.seen {
+		delete(s.seen, k)
+	}
+	s.csv.Reset()
 }
 
 // ObfuscatedQuery specifies information about an obfuscated SQL query.
 type ObfuscatedQuery struct {
-	Query     string // the obfuscated SQL query
-	TablesCSV string // comma-separated list of tables that the query addresses
+	Query       string // the obfuscated SQL query
+	TablesCSV   string // comma-separated list of tables that the query addresses
+	CommandsCSV string // comma-separated list of commands that the query runs
 }
 
 // Cost returns the number of bytes needed to store all the fields
 // of this ObfuscatedQuery.
 func (oq *ObfuscatedQuery) Cost() int64 {
-	return int64(len(oq.Query) + len(oq.TablesCSV))
+	return int64(len(oq.Query) + len(oq.TablesCSV) + len(oq.CommandsCSV))
 }
 
 // attemptObfuscation attempts to obfuscate the SQL query loaded into the tokenizer, using the given set of filters.
@@ -328,7 +476,7 @@ func attemptObfuscation(tokenizer *SQLTokenizer) (*ObfuscatedQuery, error) {
 // set of filters. An optional SQLOptions may be given to change the behavior.
 func attemptObfuscationWithOptions(tokenizer *SQLTokenizer, opts SQLOptions) (*ObfuscatedQuery, error) {
 	var (
-		storeTableNames = features.Has("table_names")
+		storeTableNames = opts.TableNames || features.Has("table_names")
 		out             = bytes.NewBuffer(make([]byte, 0, len(tokenizer.buf)))
 		err             error
 		lastToken       TokenKind
@@ -336,6 +484,7 @@ func attemptObfuscationWithOptions(tokenizer *SQLTokenizer, opts SQLOptions) (*O
 		replace         = replaceFilter{replaceDigits: opts.ReplaceDigits}
 		grouping        groupingFilter
 		tableFinder     = tableFinderFilter{storeTableNames: storeTableNames}
+		commandFinder   commandFinderFilter
 	)
 	// call Scan() function until tokens are available or if a LEX_ERROR is raised. After
 	// retrieving a token, send it to the tokenFilter chains so that the token is discarded
@@ -349,6 +498,13 @@ func attemptObfuscationWithOptions(tokenizer *SQLTokenizer, opts SQLOptions) (*O
 			return nil, fmt.Errorf("%v", tokenizer.Err())
 		}
 
+		if opts.CollectCommands {
+			// the commands are found from the scanned tokens, before the comments, the semicolons
+			// and the aliases are discarded
+			if token, buff, err = commandFinder.Filter(token, lastToken, buff); err != nil {
+				return nil, err
+			}
+		}
 		if token, buff, err = discard.Filter(token, lastToken, buff); err != nil {
 			return nil, err
 		}
@@ -386,16 +542,41 @@ func attemptObfuscationWithOptions(tokenizer *SQLTokenizer, opts SQLOptions) (*O
 		return nil, errors.New("result is empty")
 	}
 	return &ObfuscatedQuery{
-		Query:     out.String(),
-		TablesCSV: tableFinder.CSV(),
+		Query:       out.String(),
+		TablesCSV:   tableFinder.CSV(),
+		CommandsCSV: commandFinder.CSV(),
 	}, nil
 }
 
+// sqlDBMS returns the DBMS whose dialect is followed by the queries sent to a database of type
+// dbType, as found in span tags, or to a database of the configured type if dbType is empty.
+// An empty DBMS selects the generic dialect.
+func (o *Obfuscator) sqlDBMS(dbType string) string {
+	if dbType == "" {
+		dbType = o.opts.SQL.DBMS
+	}
+	return sqlDialects[strings.ToLower(dbType)]
+}
+
+// sqlOptions returns the options used to obfuscate the queries sent to dbms.
+func (o *Obfuscator) sqlOptions(dbms string) SQLOptions {
+	return SQLOptions{
+		ReplaceDigits:   features.Has("quantize_sql_tables") || features.Has("replace_sql_digits"),
+		DBMS:            dbms,
+		TableNames:      o.opts.SQL.TableNames,
+		CollectCommands: o.opts.SQL.CollectCommands,
+	}
+}
+
 func (o *Obfuscator) obfuscateSQL(span *pb.Span) {
 	if span.Resource == "" {
 		return
 	}
-	oq, err := o.ObfuscateSQLString(span.Resource)
+	dbType := span.Meta["db.type"]
+	if dbType == "" {
+		dbType = span.Meta["db.system"]
+	}
+	oq, err := o.ObfuscateSQLStringWithOptions(span.Resource, o.sqlOptions(o.sqlDBMS(dbType)))
 	if err != nil {
 		// we have an error, discard the SQL to avoid polluting user resources.
 		log.Debugf("Error parsing SQL query: %v. Resource: %q", err, span.Resource)
@@ -414,6 +595,9 @@ func (o *Obfuscator) obfuscateSQL(span *pb.Span) {
 	if len(oq.TablesCSV) > 0 {
 		traceutil.SetMeta(span, "sql.tables", oq.TablesCSV)
 	}
+	if len(oq.CommandsCSV) > 0 {
+		traceutil.SetMeta(span, "sql.commands", oq.CommandsCSV)
+	}
 	if span.Meta != nil && span.Meta[sqlQueryTag] != "" {
 		// "sql.query" tag already set by user, do not change it.
 		return
@@ -13,6 +13,7 @@ import (
 	"sync/atomic"
 	"testing"
 
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
 	"github.com/DataDog/datadog-agent/pkg/trace/pb"
 	"github.com/DataDog/datadog-agent/pkg/trace/test/testutil"
 	"github.com/stretchr/testify/assert"
@@ -489,6 +490,186 @@ func TestSQLTableFinderAndReplaceDigits(t *testing.T) {
 	})
 }
 
+func TestSQLDialects(t *testing.T) {
+	for _, tt := range []struct {
+		dbms       string
+		query      string
+		obfuscated string
+		tables     string
+	}{
+		{
+			DBMSPostgres,
+			`SELECT "first name" FROM "public"."users" WHERE "id" = "other_id" AND name = E'O\'Reilly'`,
+			`SELECT "first name" FROM public.users WHERE id = other_id AND name = ?`,
+			"public.users",
+		},
+		{
+			DBMSPostgres,
+			`SELECT data #> '{a,b}' FROM docs WHERE flags # 4 = 0`,
+			`SELECT data #> ? FROM docs WHERE flags # ? = ?`,
+			"docs",
+		},
+		{
+			DBMSPostgres,
+			`CREATE FUNCTION inc(i integer) RETURNS integer AS $body$ BEGIN UPDATE counters SET value = value + i WHERE name = 'hits'; RETURN i + 1; END; $body$ LANGUAGE plpgsql`,
+			`CREATE FUNCTION inc ( i integer ) RETURNS integer AS $body$BEGIN UPDATE counters SET value = value + i WHERE name = ? RETURN i + ? END$body$ LANGUAGE plpgsql`,
+			"",
+		},
+		{
+			DBMSPostgres,
+			`DO $$ BEGIN DELETE FROM sessions WHERE expires < now() - interval '1 day'; END $$`,
+			`DO $$BEGIN DELETE FROM sessions WHERE expires < now ( ) - interval ? END$$`,
+			"",
+		},
+		{
+			DBMSPostgres,
+			`SELECT * FROM users WHERE name = $$secret 'value'$$`,
+			`SELECT * FROM users WHERE name = ?`,
+			"users",
+		},
+		{
+			DBMSMySQL,
+			"SELECT `first name` FROM `shop`.`order items` WHERE name IN (\"alice\", \"bob\") # comment",
+			"SELECT `first name` FROM shop.`order items` WHERE name IN ( ? )",
+			"shop.`order items`",
+		},
+		{
+			DBMSMySQL,
+			"SELECT `a``b` FROM t",
+			"SELECT `a``b` FROM t",
+			"t",
+		},
+		{
+			DBMSSQLServer,
+			`SELECT [first name], [o].* FROM [dbo].[orders] AS [o] JOIN #items ON #items.id = [o].[id] WHERE [o].[note] = N'secret'`,
+			`SELECT [first name], o.* FROM dbo.orders JOIN #items ON #items.id = o.id WHERE o.note = ?`,
+			"dbo.orders,#items",
+		},
+		{
+			DBMSSQLServer,
+			`SELECT "id" FROM [a]]b]`,
+			`SELECT id FROM [a]]b]`,
+			"[a]]b]",
+		},
+	} {
+		t.Run(tt.dbms, func(t *testing.T) {
+			assert := assert.New(t)
+			oq, err := NewObfuscator(nil).ObfuscateSQLStringWithOptions(tt.query, SQLOptions{DBMS: tt.dbms, TableNames: true})
+			if !assert.NoError(err) {
+				return
+			}
+			assert.Equal(tt.obfuscated, oq.Query)
+			assert.Equal(tt.tables, oq.TablesCSV)
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		for dbms, query := range map[string]string{
+			DBMSPostgres:  `SELECT "id FROM users`,
+			DBMSMySQL:     "SELECT `id FROM users",
+			DBMSSQLServer: `SELECT [id FROM users`,
+		} {
+			_, err := NewObfuscator(nil).ObfuscateSQLStringWithOptions(query, SQLOptions{DBMS: dbms})
+			assert.Error(t, err, dbms)
+		}
+	})
+
+	t.Run("generic", func(t *testing.T) {
+		// the generic dialect keeps treating double-quoted strings and '#' as before
+		oq, err := NewObfuscator(nil).ObfuscateSQLString(`SELECT "id" FROM users # comment`)
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT id FROM users`, oq.Query)
+	})
+}
+
+func TestSQLCommands(t *testing.T) {
+	for _, tt := range []struct {
+		query    string
+		commands string
+	}{
+		{"SELECT * FROM users", "SELECT"},
+		{"select * from users; update users set name = 'a'", "SELECT,UPDATE"},
+		{"INSERT INTO archive SELECT * FROM orders WHERE id IN (SELECT id FROM old)", "INSERT,SELECT"},
+		{"BEGIN; DELETE FROM t; COMMIT", "BEGIN,DELETE,COMMIT"},
+		{"CREATE TABLE t (id int)", "CREATE"},
+		{"TRUNCATE TABLE t", "TRUNCATE"},
+		{"EXEC sp_who", "EXEC"},
+		{"SET search_path TO public", ""},
+		{"WITH old AS (DELETE FROM orders RETURNING *) INSERT INTO archive SELECT * FROM old", "DELETE,INSERT,SELECT"},
+		{"/* comment */ DROP TABLE t", "DROP"},
+		// columns, aliases and options named like commands
+		{"SELECT begin, end, call AS grant FROM t", "SELECT"},
+		{"UPDATE t SET drop = 1, commit = 2 WHERE create > 0", "UPDATE"},
+		{"INSERT INTO t (call, revoke, merge) VALUES (1, 2, 3)", "INSERT"},
+		{"CREATE TABLE t (id int REFERENCES p ON DELETE CASCADE ON UPDATE SET NULL)", "CREATE"},
+		{"SELECT * FROM t WHERE id IN (SELECT id FROM p) AND (drop = 1)", "SELECT"},
+	} {
+		t.Run("", func(t *testing.T) {
+			oq, err := NewObfuscator(nil).ObfuscateSQLStringWithOptions(tt.query, SQLOptions{CollectCommands: true})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.commands, oq.CommandsCSV)
+		})
+	}
+
+	t.Run("off", func(t *testing.T) {
+		oq, err := NewObfuscator(nil).ObfuscateSQLString("SELECT * FROM users")
+		assert.NoError(t, err)
+		assert.Empty(t, oq.CommandsCSV)
+	})
+}
+
+func TestSQLSpanDialect(t *testing.T) {
+	o := NewObfuscator(&config.ObfuscationConfig{
+		SQL: config.SQLObfuscationConfig{DBMS: "mysql", TableNames: true, CollectCommands: true},
+	})
+
+	t.Run("db.type", func(t *testing.T) {
+		span := &pb.Span{
+			Resource: `UPDATE "users" SET name = 'bob' WHERE id = 1`,
+			Type:     "sql",
+			Meta:     map[string]string{"db.type": "postgres"},
+		}
+		o.Obfuscate(span)
+		assert.Equal(t, `UPDATE users SET name = ? WHERE id = ?`, span.Resource)
+		assert.Equal(t, "users", span.Meta["sql.tables"])
+		assert.Equal(t, "UPDATE", span.Meta["sql.commands"])
+	})
+
+	t.Run("db.system", func(t *testing.T) {
+		span := &pb.Span{
+			Resource: `SELECT * FROM [dbo].[users]`,
+			Type:     "sql",
+			Meta:     map[string]string{"db.system": "mssql"},
+		}
+		o.Obfuscate(span)
+		assert.Equal(t, `SELECT * FROM dbo.users`, span.Resource)
+		assert.Equal(t, "dbo.users", span.Meta["sql.tables"])
+	})
+
+	t.Run("default", func(t *testing.T) {
+		span := &pb.Span{
+			Resource: `SELECT * FROM users WHERE name = "bob"`,
+			Type:     "sql",
+		}
+		o.Obfuscate(span)
+		assert.Equal(t, `SELECT * FROM users WHERE name = ?`, span.Resource)
+		assert.Equal(t, "SELECT", span.Meta["sql.commands"])
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		defer testutil.WithFeatures("sql_cache")()
+		o := NewObfuscator(nil)
+		defer o.Stop()
+		query := `SELECT "name" FROM users`
+		generic, err := o.ObfuscateSQLString(query)
+		assert.NoError(t, err)
+		mysql, err := o.ObfuscateSQLStringWithOptions(query, SQLOptions{DBMS: DBMSMySQL})
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT name FROM users`, generic.Query)
+		assert.Equal(t, `SELECT ? FROM users`, mysql.Query)
+	})
+}
+
 func TestSQLQuantizer(t *testing.T) {
 	cases := []sqlTestCase{
 		{
@@ -145,14 +145,23 @@ type SQLTokenizer struct {
 
 	literalEscapes bool // indicates we should not treat backslashes as escape characters
 	seenEscape     bool // indicates whether this tokenizer has seen an escape character within a string
+
+	dbms       string // the DBMS whose dialect is followed, one of the DBMS* constants, or empty for a generic dialect
+	beforeBody bool   // indicates whether the last token may precede the body of a Postgres function (AS or DO)
 }
 
 // NewSQLTokenizer creates a new SQLTokenizer for the given SQL string. The literalEscapes argument specifies
 // whether escape characters should be treated literally or as such.
 func NewSQLTokenizer(sql string, literalEscapes bool) *SQLTokenizer {
+	return newSQLTokenizer(sql, literalEscapes, "")
+}
+
+// newSQLTokenizer creates a new SQLTokenizer for the given SQL string, following the dialect of dbms.
+func newSQLTokenizer(sql string, literalEscapes bool, dbms string) *SQLTokenizer {
 	return &SQLTokenizer{
 		buf:            []byte(sql),
 		literalEscapes: literalEscapes,
+		dbms:           dbms,
 	}
 }
 
@@ -163,6 +172,7 @@ func (tkn *SQLTokenizer) Reset(in string) {
 	tkn.buf = []byte(in)
 	tkn.off = 0
 	tkn.err = nil
+	tkn.beforeBody = false
 }
 
 // keywords used to recognize string tokens
@@ -196,12 +206,34 @@ func (tkn *SQLTokenizer) SeenEscape() bool { return tkn.seenEscape }
 // Scan scans the tokenizer for the next token and returns
 // the token type and the token buffer.
 func (tkn *SQLTokenizer) Scan() (TokenKind, []byte) {
+	kind, buff := tkn.scan()
+	if kind != Comment {
+		tkn.beforeBody = kind == As || (kind == ID && bytes.EqualFold(buff, []byte("DO")))
+	}
+	return kind, buff
+}
+
+func (tkn *SQLTokenizer) scan() (TokenKind, []byte) {
 	if tkn.lastChar == 0 {
 		tkn.advance()
 	}
 	tkn.skipBlank()
 
 	switch ch := tkn.lastChar; {
+	case tkn.dbms == DBMSSQLServer && (ch == 'N' || ch == 'n') && tkn.peek() == '\'':
+		// N'...' is a Unicode string constant
+		tkn.advance()
+		tkn.advance()
+		return tkn.scanString('\'', String)
+	case tkn.dbms == DBMSPostgres && (ch == 'E' || ch == 'e') && tkn.peek() == '\'':
+		// E'...' is a string constant with C-style escapes, whatever the escaping of regular strings
+		tkn.advance()
+		tkn.advance()
+		literalEscapes := tkn.literalEscapes
+		tkn.literalEscapes = false
+		kind, buff := tkn.scanString('\'', String)
+		tkn.literalEscapes = literalEscapes
+		return kind, buff
 	case isLeadingLetter(ch):
 		return tkn.scanIdentifier()
 	case isDigit(ch):
@@ -235,7 +267,12 @@ func (tkn *SQLTokenizer) Scan() (TokenKind, []byte) {
 			default:
 				return TokenKind(ch), tkn.bytes()
 			}
-		case '=', ',', ';', '(', ')', '+', '*', '&', '|', '^', '[', ']', '?':
+		case '[':
+			if tkn.dbms == DBMSSQLServer {
+				return tkn.scanQuotedIdentifier('[', ']')
+			}
+			return TokenKind(ch), tkn.bytes()
+		case '=', ',', ';', '(', ')', '+', '*', '&', '|', '^', ']', '?':
 			return TokenKind(ch), tkn.bytes()
 		case '.':
 			if isDigit(tkn.lastChar) {
@@ -266,6 +303,19 @@ func (tkn *SQLTokenizer) Scan() (TokenKind, []byte) {
 				return TokenKind(ch), tkn.bytes()
 			}
 		case '#':
+			switch tkn.dbms {
+			case DBMSSQLServer:
+				if isLetter(tkn.lastChar) {
+					// temporary table (e.g. '#orders' or '##orders')
+					return tkn.scanIdentifier()
+				}
+			case DBMSPostgres:
+				// bitwise XOR operator, or JSON path operator (e.g. '#>' or '#>>')
+				for tkn.lastChar == '>' {
+					tkn.advance()
+				}
+				return TokenKind(ch), tkn.bytes()
+			}
 			tkn.advance()
 			return tkn.scanCommentType1("#")
 		case '<':
@@ -312,8 +362,18 @@ func (tkn *SQLTokenizer) Scan() (TokenKind, []byte) {
 		case '\'':
 			return tkn.scanString(ch, String)
 		case '"':
+			switch tkn.dbms {
+			case DBMSPostgres, DBMSSQLServer:
+				return tkn.scanQuotedIdentifier('"', '"')
+			case DBMSMySQL:
+				// double quotes delimit string constants, unless the ANSI_QUOTES mode is enabled
+				return tkn.scanString(ch, String)
+			}
 			return tkn.scanString(ch, DoubleQuotedString)
 		case '`':
+			if tkn.dbms == DBMSMySQL {
+				return tkn.scanQuotedIdentifier('`', '`')
+			}
 			return tkn.scanLiteralIdentifier('`')
 		case '%':
 			if tkn.lastChar == '(' {
@@ -332,16 +392,20 @@ func (tkn *SQLTokenizer) Scan() (TokenKind, []byte) {
 				// want to cover for this use-case too (e.g. $1$some text$1$).
 				return tkn.scanPreparedStatement('$')
 			}
-			kind, tok := tkn.scanDollarQuotedString()
+			kind, tok, delim := tkn.scanDollarQuotedString()
+			if kind == DollarQuotedString && tkn.dbms == DBMSPostgres && tkn.beforeBody {
+				// the body of a function or of an anonymous code block (e.g. 'AS $$ BEGIN ... END $$')
+				kind = DollarQuotedFunc
+			}
 			if kind == DollarQuotedFunc {
 				// this is considered an embedded query, we should try and
 				// obfuscate it
-				out, err := attemptObfuscation(NewSQLTokenizer(string(tok), tkn.literalEscapes))
+				out, err := attemptObfuscation(newSQLTokenizer(string(tok), tkn.literalEscapes, tkn.dbms))
 				if err != nil {
 					// if we can't obfuscate it, treat it as a regular string
 					return DollarQuotedString, tok
 				}
-				tok = append(append([]byte("$func$"), []byte(out.Query)...), []byte("$func$")...)
+				tok = append(append(append([]byte{}, delim...), []byte(out.Query)...), delim...)
 			}
 			return kind, tok
 		case '{':
@@ -369,6 +433,12 @@ func (tkn *SQLTokenizer) Scan() (TokenKind, []byte) {
 	}
 }
 
+// peek returns the rune following tkn.lastChar, without advancing the tokenizer.
+func (tkn *SQLTokenizer) peek() rune {
+	ch, _ := utf8.DecodeRune(tkn.buf[tkn.off:])
+	return ch
+}
+
 func (tkn *SQLTokenizer) skipBlank() {
 	for unicode.IsSpace(tkn.lastChar) {
 		tkn.advance()
@@ -453,6 +523,61 @@ func (tkn *SQLTokenizer) scanLiteralIdentifier(quote rune) (TokenKind, []byte) {
 	return ID, t
 }
 
+// scanQuotedIdentifier scans an identifier delimited by the open and closing quotes, such as
+// "name" in Postgres, `name` in MySQL or [name] in SQL Server. The closing quote is embedded in
+// the identifier by doubling it. The parts of a qualified name (e.g. [dbo].[users]) are scanned
+// as a single identifier. The quotes of a part are only stripped when it is a plain word, the
+// other parts (e.g. [first name]) keep them to remain unambiguous.
+func (tkn *SQLTokenizer) scanQuotedIdentifier(open, closing rune) (TokenKind, []byte) {
+	buf := bytes.NewBuffer(tkn.buf[:0])
+	quoted := true
+	for {
+		if quoted {
+			start := buf.Len()
+			plain := true
+			for {
+				ch := tkn.lastChar
+				if ch == EndChar {
+					tkn.setErr(`unexpected EOF in quoted identifier, expected "%c"`, closing)
+					return LexError, buf.Bytes()
+				}
+				tkn.advance()
+				if ch == closing {
+					if tkn.lastChar != closing {
+						break
+					}
+					tkn.advance()
+				}
+				plain = plain && isPlainIdentifierChar(ch)
+				buf.WriteRune(ch)
+			}
+			if !plain || buf.Len() == start {
+				// quote the part again, doubling the closing quotes it contains
+				quote := runeBytes(closing)
+				part := bytes.ReplaceAll(buf.Bytes()[start:], quote, bytes.Repeat(quote, 2))
+				buf.Truncate(start)
+				buf.WriteRune(open)
+				buf.Write(part)
+				buf.Write(quote)
+			}
+		} else {
+			for isLetter(tkn.lastChar) || isDigit(tkn.lastChar) || tkn.lastChar == '*' {
+				buf.WriteRune(tkn.lastChar)
+				tkn.advance()
+			}
+		}
+		if tkn.lastChar != '.' {
+			break
+		}
+		buf.WriteByte('.')
+		tkn.advance()
+		if quoted = tkn.lastChar == open; quoted {
+			tkn.advance()
+		}
+	}
+	return ID, buf.Bytes()
+}
+
 func (tkn *SQLTokenizer) scanVariableIdentifier(prefix rune) (TokenKind, []byte) {
 	for tkn.advance(); tkn.lastChar != ')' && tkn.lastChar != EndChar; tkn.advance() {
 	}
@@ -470,12 +595,13 @@ func (tkn *SQLTokenizer) scanFormatParameter(prefix rune) (TokenKind, []byte) {
 	return Variable, tkn.bytes()
 }
 
-// scanDollarQuotedString scans a Postgres dollar-quoted string constant.
+// scanDollarQuotedString scans a Postgres dollar-quoted string constant, returning its
+// contents and its delimiter.
 // See: https://www.postgresql.org/docs/current/sql-syntax-lexical.html#SQL-SYNTAX-DOLLAR-QUOTING
-func (tkn *SQLTokenizer) scanDollarQuotedString() (TokenKind, []byte) {
+func (tkn *SQLTokenizer) scanDollarQuotedString() (TokenKind, []byte, []byte) {
 	kind, tag := tkn.scanString('$', String)
 	if kind == LexError {
-		return kind, tkn.bytes()
+		return kind, tkn.bytes(), nil
 	}
 	var (
 		got int
@@ -493,7 +619,7 @@ func (tkn *SQLTokenizer) scanDollarQuotedString() (TokenKind, []byte) {
 		tkn.advance()
 		if ch == EndChar {
 			tkn.setErr("unexpected EOF in dollar-quoted string")
-			return LexError, buf.Bytes()
+			return LexError, buf.Bytes(), nil
 		}
 		if byte(ch) == delim[got] {
 			got++
@@ -506,7 +632,7 @@ func (tkn *SQLTokenizer) scanDollarQuotedString() (TokenKind, []byte) {
 			_, err := buf.Write(delim[:got])
 			if err != nil {
 				tkn.setErr("error reading dollar-quoted string: %v", err)
-				return LexError, buf.Bytes()
+				return LexError, buf.Bytes(), nil
 			}
 			got = 0
 		}
@@ -515,9 +641,9 @@ func (tkn *SQLTokenizer) scanDollarQuotedString() (TokenKind, []byte) {
 	if features.Has("dollar_quoted_func") && string(delim) == "$func$" {
 		// dolar_quoted_func: treat "$func" delimited dollar-quoted strings
 		// differently and do not obfuscate them as a string
-		return DollarQuotedFunc, buf.Bytes()
+		return DollarQuotedFunc, buf.Bytes(), delim
 	}
-	return DollarQuotedString, buf.Bytes()
+	return DollarQuotedString, buf.Bytes(), delim
 }
 
 func (tkn *SQLTokenizer) scanPreparedStatement(prefix rune) (TokenKind, []byte) {
@@ -752,6 +878,16 @@ func isLetter(ch rune) bool {
 	return isLeadingLetter(ch) || ch == '#'
 }
 
+// isIdentifierQuote returns true if the character opens a quoted identifier kept with its quotes.
+func isIdentifierQuote(ch rune) bool {
+	return ch == '"' || ch == '`' || ch == '['
+}
+
+// isPlainIdentifierChar returns true if the character can be part of an identifier without quotes.
+func isPlainIdentifierChar(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || isDigit(ch) || ch == '_' || ch == '$' || ch == '#'
+}
+
 func digitVal(ch rune) int {
 	switch {
 	case '0' <= ch && ch <= '9':
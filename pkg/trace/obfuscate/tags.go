@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package obfuscate
+
+import (
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+)
+
+var (
+	// grpcMetadataPrefixes prefix the tags holding the metadata of gRPC requests and responses.
+	grpcMetadataPrefixes = []string{"grpc.metadata.", "grpc.request.metadata.", "grpc.response.metadata."}
+
+	// awsParamsPrefixes prefix the tags holding the request parameters of AWS SDK calls.
+	awsParamsPrefixes = []string{"params."}
+
+	// messagingPrefixes prefix the tags holding the attributes of messages, such as their key,
+	// body or headers.
+	messagingPrefixes = []string{"messaging.message.", "messaging.kafka.message.", "kafka.message."}
+)
+
+// awsOperationTag is set on all AWS SDK spans and is used to tell them apart from other HTTP spans.
+const awsOperationTag = "aws.operation"
+
+// tagsObfuscator obfuscates the values of the span tags starting with one of its prefixes.
+type tagsObfuscator struct {
+	prefixes []string
+	keepKeys map[string]bool // the values for these keys (the tag name without its prefix) will not be obfuscated
+	fold     bool            // true if keys are case-insensitive
+}
+
+func newTagsObfuscator(cfg *config.KeepValuesObfuscationConfig, fold bool, prefixes ...string) *tagsObfuscator {
+	keepKeys := make(map[string]bool, len(cfg.KeepValues))
+	for _, v := range cfg.KeepValues {
+		if fold {
+			v = strings.ToLower(v)
+		}
+		keepKeys[v] = true
+	}
+	return &tagsObfuscator{
+		prefixes: prefixes,
+		keepKeys: keepKeys,
+		fold:     fold,
+	}
+}
+
+// obfuscate replaces with "?" the values of the span's tags matching the obfuscator's prefixes,
+// unless their key is kept. If the obfuscator is nil it is considered disabled.
+func (o *tagsObfuscator) obfuscate(span *pb.Span) {
+	if o == nil || span.Meta == nil {
+		return
+	}
+	for k, v := range span.Meta {
+		if v == "" || v == "?" {
+			continue
+		}
+		for _, prefix := range o.prefixes {
+			if !strings.HasPrefix(k, prefix) {
+				continue
+			}
+			key := k[len(prefix):]
+			if o.fold {
+				key = strings.ToLower(key)
+			}
+			if !o.keepKeys[key] {
+				span.Meta[k] = "?"
+			}
+			break
+		}
+	}
+}
+
+// obfuscateGRPC obfuscates the request and response metadata of gRPC spans.
+func (o *Obfuscator) obfuscateGRPC(span *pb.Span) {
+	o.grpc.obfuscate(span)
+}
+
+// obfuscateAWS obfuscates the request parameters of AWS SDK spans. Spans which don't have
+// an "aws.operation" tag are left untouched.
+func (o *Obfuscator) obfuscateAWS(span *pb.Span) {
+	if o.aws == nil || span.Meta[awsOperationTag] == "" {
+		return
+	}
+	o.aws.obfuscate(span)
+}
+
+// obfuscateMessaging obfuscates the message attributes of messaging spans.
+func (o *Obfuscator) obfuscateMessaging(span *pb.Span) {
+	o.messaging.obfuscate(span)
+}
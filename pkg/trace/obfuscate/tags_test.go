@@ -0,0 +1,122 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package obfuscate
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObfuscateTags(t *testing.T) {
+	cfg := &config.ObfuscationConfig{
+		GRPC:      config.KeepValuesObfuscationConfig{Enabled: true, KeepValues: []string{"Content-Type"}},
+		AWS:       config.KeepValuesObfuscationConfig{Enabled: true, KeepValues: []string{"TableName"}},
+		Messaging: config.KeepValuesObfuscationConfig{Enabled: true, KeepValues: []string{"id"}},
+	}
+	for name, tt := range map[string]struct {
+		typ      string
+		in, out  map[string]string
+		disabled bool
+	}{
+		"grpc": {
+			typ: "grpc",
+			in: map[string]string{
+				"grpc.metadata.authorization":          "Bearer abc",
+				"grpc.request.metadata.content-type":   "application/grpc",
+				"grpc.response.metadata.x-customer-id": "1234",
+				"grpc.method.name":                     "GetUser",
+				"grpc.request.metadata.x-empty-header": "",
+				"rpc.service":                          "users.UserService",
+			},
+			out: map[string]string{
+				"grpc.metadata.authorization":          "?",
+				"grpc.request.metadata.content-type":   "application/grpc",
+				"grpc.response.metadata.x-customer-id": "?",
+				"grpc.method.name":                     "GetUser",
+				"grpc.request.metadata.x-empty-header": "",
+				"rpc.service":                          "users.UserService",
+			},
+		},
+		"rpc": {
+			typ: "rpc",
+			in:  map[string]string{"grpc.metadata.x-customer-id": "1234"},
+			out: map[string]string{"grpc.metadata.x-customer-id": "?"},
+		},
+		"aws-http": {
+			typ: "http",
+			in: map[string]string{
+				"aws.operation":    "GetItem",
+				"params.TableName": "users",
+				"params.Key":       `{"id":{"S":"1234"}}`,
+				"http.method":      "POST",
+			},
+			out: map[string]string{
+				"aws.operation":    "GetItem",
+				"params.TableName": "users",
+				"params.Key":       "?",
+				"http.method":      "POST",
+			},
+		},
+		"aws": {
+			typ: "aws",
+			in:  map[string]string{"aws.operation": "SendMessage", "params.MessageBody": "hello"},
+			out: map[string]string{"aws.operation": "SendMessage", "params.MessageBody": "?"},
+		},
+		"aws-not-sdk": {
+			typ: "http",
+			in:  map[string]string{"params.user": "jane"},
+			out: map[string]string{"params.user": "jane"},
+		},
+		"queue": {
+			typ: "queue",
+			in: map[string]string{
+				"messaging.message.id":        "m-1",
+				"messaging.message.body":      `{"email":"jane@example.com"}`,
+				"messaging.kafka.message.key": "customer-1234",
+				"kafka.message.header.trace":  "abc",
+				"messaging.destination.name":  "orders",
+			},
+			out: map[string]string{
+				"messaging.message.id":        "m-1",
+				"messaging.message.body":      "?",
+				"messaging.kafka.message.key": "?",
+				"kafka.message.header.trace":  "?",
+				"messaging.destination.name":  "orders",
+			},
+		},
+		"kafka": {
+			typ: "kafka",
+			in:  map[string]string{"kafka.message.key": "customer-1234"},
+			out: map[string]string{"kafka.message.key": "?"},
+		},
+		"disabled": {
+			typ:      "kafka",
+			in:       map[string]string{"kafka.message.key": "customer-1234"},
+			out:      map[string]string{"kafka.message.key": "customer-1234"},
+			disabled: true,
+		},
+		"other-type": {
+			typ: "custom",
+			in:  map[string]string{"kafka.message.key": "customer-1234", "grpc.metadata.x": "y"},
+			out: map[string]string{"kafka.message.key": "customer-1234", "grpc.metadata.x": "y"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := cfg
+			if tt.disabled {
+				c = nil
+			}
+			o := NewObfuscator(c)
+			defer o.Stop()
+			span := &pb.Span{Type: tt.typ, Meta: tt.in}
+			o.Obfuscate(span)
+			assert.Equal(t, tt.out, span.Meta)
+		})
+	}
+}
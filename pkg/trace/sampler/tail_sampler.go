@@ -0,0 +1,384 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sampler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/metrics"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+	"github.com/DataDog/datadog-agent/pkg/trace/watchdog"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// tailFlushPeriod specifies the frequency at which the TailSampler decides on the traces
+// whose decision window is over.
+const tailFlushPeriod = time.Second
+
+// TailSampler samples the traces once they are complete. The chunks of each trace are
+// buffered for a decision window starting at the reception of its first chunk, after which
+// the trace is kept when it matches any of the configured policies. The chunks received
+// after the decision follow it. The TailSampler runs in addition to the other samplers: the
+// chunks they already kept are only used to decide and are not kept a second time.
+type TailSampler struct {
+	// Variables access through the 'atomic' package must be 64bits aligned.
+	tracesKept    int64
+	tracesDropped int64
+	tracesEvicted int64
+	spansLate     int64
+
+	decisionWait time.Duration
+	maxTraces    int
+	maxSpans     int
+	policies     []*tailPolicy
+	// keep is called with the chunks of the kept traces.
+	keep func(pb.Traces)
+
+	mu sync.Mutex
+	// traces holds the buffered traces by ID, and queue in the order of their deadlines.
+	traces map[uint64]*tailTrace
+	queue  []*tailTrace
+	spans  int
+	// decided holds whether the recently decided traces were kept, to apply the decision
+	// to their late chunks. decidedQueue holds their IDs in order, to bound its size.
+	decided      map[uint64]bool
+	decidedQueue []uint64
+
+	// reported holds the counts of the last report.
+	reported TailSamplerStats
+
+	exit    chan struct{}
+	stopped chan struct{}
+}
+
+// tailTrace holds the buffered chunks of a trace.
+type tailTrace struct {
+	id       uint64
+	deadline time.Time
+	chunks   pb.Traces // all the chunks, to decide on the trace
+	pending  pb.Traces // the chunks which were not already kept, to keep if the trace is
+	spans    int
+}
+
+// TailSamplerStats holds the counts of the traces handled by the TailSampler, since it was created.
+type TailSamplerStats struct {
+	// TracesKept is the number of traces kept by a policy.
+	TracesKept int64
+	// TracesDropped is the number of traces which matched no policy.
+	TracesDropped int64
+	// TracesEvicted is the number of traces decided before the end of their decision window,
+	// because the buffer was full. They are also counted as kept or dropped.
+	TracesEvicted int64
+	// SpansLate is the number of spans received after the decision on their trace.
+	SpansLate int64
+	// TracesBuffered is the number of traces currently buffered.
+	TracesBuffered int64
+	// SpansBuffered is the number of spans currently buffered.
+	SpansBuffered int64
+}
+
+// NewTailSampler returns a TailSampler applying the configuration conf, which calls keep
+// with the chunks of the traces it keeps.
+func NewTailSampler(conf *config.TailSamplingConfig, keep func(pb.Traces)) *TailSampler {
+	s := &TailSampler{
+		decisionWait: time.Duration(conf.DecisionWaitSeconds * float64(time.Second)),
+		maxTraces:    conf.MaxTraces,
+		maxSpans:     conf.MaxSpans,
+		keep:         keep,
+		traces:       make(map[uint64]*tailTrace),
+		decided:      make(map[uint64]bool),
+		exit:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+	for _, p := range conf.Policies {
+		tp, err := newTailPolicy(p)
+		if err != nil {
+			log.Errorf("Ignoring tail sampling policy %q: %v", p.Name, err)
+			continue
+		}
+		s.policies = append(s.policies, tp)
+	}
+	return s
+}
+
+// Start starts deciding on the traces whose decision window is over.
+func (s *TailSampler) Start() {
+	go func() {
+		defer watchdog.LogOnPanic()
+		flushTicker := time.NewTicker(tailFlushPeriod)
+		statsTicker := time.NewTicker(10 * time.Second)
+		defer flushTicker.Stop()
+		defer statsTicker.Stop()
+		for {
+			select {
+			case now := <-flushTicker.C:
+				s.flush(now, false)
+			case <-statsTicker.C:
+				s.report()
+			case <-s.exit:
+				// decide on all the buffered traces before exiting
+				s.flush(time.Now(), true)
+				s.report()
+				close(s.stopped)
+				return
+			}
+		}
+	}()
+}
+
+// Stop decides on all the buffered traces and stops the TailSampler.
+func (s *TailSampler) Stop() {
+	close(s.exit)
+	<-s.stopped
+}
+
+// Add buffers the chunk of a trace until the decision on the trace. kept reports whether
+// the chunk was already kept by another sampler.
+func (s *TailSampler) Add(chunk pb.Trace, kept bool) {
+	s.add(time.Now(), chunk, kept)
+}
+
+func (s *TailSampler) add(now time.Time, chunk pb.Trace, alreadyKept bool) {
+	if len(chunk) == 0 {
+		return
+	}
+	id := chunk[0].TraceID
+	var kept pb.Traces
+	s.mu.Lock()
+	if k, ok := s.decided[id]; ok {
+		s.mu.Unlock()
+		atomic.AddInt64(&s.spansLate, int64(len(chunk)))
+		if k && !alreadyKept {
+			s.keep(pb.Traces{chunk})
+		}
+		return
+	}
+	t, ok := s.traces[id]
+	if !ok {
+		t = &tailTrace{id: id, deadline: now.Add(s.decisionWait)}
+		s.traces[id] = t
+		s.queue = append(s.queue, t)
+	}
+	t.chunks = append(t.chunks, chunk)
+	if !alreadyKept {
+		t.pending = append(t.pending, chunk)
+	}
+	t.spans += len(chunk)
+	s.spans += len(chunk)
+	for len(s.queue) > 0 && (len(s.traces) > s.maxTraces || s.spans > s.maxSpans) {
+		atomic.AddInt64(&s.tracesEvicted, 1)
+		if t := s.popLocked(); s.decideLocked(t) {
+			kept = append(kept, t.pending...)
+		}
+	}
+	s.mu.Unlock()
+	if len(kept) > 0 {
+		s.keep(kept)
+	}
+}
+
+// flush decides on the traces whose decision window is over at now, or on all the buffered
+// traces if all is true.
+func (s *TailSampler) flush(now time.Time, all bool) {
+	var kept []*tailTrace
+	s.mu.Lock()
+	for len(s.queue) > 0 && (all || !now.Before(s.queue[0].deadline)) {
+		if t := s.popLocked(); s.decideLocked(t) {
+			kept = append(kept, t)
+		}
+	}
+	s.mu.Unlock()
+	for _, t := range kept {
+		if len(t.pending) > 0 {
+			s.keep(t.pending)
+		}
+	}
+}
+
+// popLocked removes the oldest trace from the buffer and returns it. s.mu must be held.
+func (s *TailSampler) popLocked() *tailTrace {
+	t := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	delete(s.traces, t.id)
+	s.spans -= t.spans
+	return t
+}
+
+// decideLocked applies the policies to t and records the decision. s.mu must be held.
+func (s *TailSampler) decideLocked(t *tailTrace) bool {
+	keep := false
+	for _, p := range s.policies {
+		if p.matches(t.id, t.chunks) {
+			atomic.AddInt64(&p.kept, 1)
+			keep = true
+			break
+		}
+	}
+	if keep {
+		atomic.AddInt64(&s.tracesKept, 1)
+	} else {
+		atomic.AddInt64(&s.tracesDropped, 1)
+	}
+	s.decided[t.id] = keep
+	s.decidedQueue = append(s.decidedQueue, t.id)
+	if len(s.decidedQueue) > s.maxTraces {
+		delete(s.decided, s.decidedQueue[0])
+		s.decidedQueue = s.decidedQueue[1:]
+	}
+	return keep
+}
+
+// Stats returns the counts of the traces handled by the sampler.
+func (s *TailSampler) Stats() TailSamplerStats {
+	s.mu.Lock()
+	tracesBuffered, spansBuffered := len(s.traces), s.spans
+	s.mu.Unlock()
+	return TailSamplerStats{
+		TracesKept:     atomic.LoadInt64(&s.tracesKept),
+		TracesDropped:  atomic.LoadInt64(&s.tracesDropped),
+		TracesEvicted:  atomic.LoadInt64(&s.tracesEvicted),
+		SpansLate:      atomic.LoadInt64(&s.spansLate),
+		TracesBuffered: int64(tracesBuffered),
+		SpansBuffered:  int64(spansBuffered),
+	}
+}
+
+func (s *TailSampler) report() {
+	stats := s.Stats()
+	metrics.Count("datadog.trace_agent.sampler.tail.kept", stats.TracesKept-s.reported.TracesKept, nil, 1)
+	metrics.Count("datadog.trace_agent.sampler.tail.dropped", stats.TracesDropped-s.reported.TracesDropped, nil, 1)
+	metrics.Count("datadog.trace_agent.sampler.tail.evicted", stats.TracesEvicted-s.reported.TracesEvicted, nil, 1)
+	metrics.Count("datadog.trace_agent.sampler.tail.late_spans", stats.SpansLate-s.reported.SpansLate, nil, 1)
+	metrics.Gauge("datadog.trace_agent.sampler.tail.buffered_traces", float64(stats.TracesBuffered), nil, 1)
+	metrics.Gauge("datadog.trace_agent.sampler.tail.buffered_spans", float64(stats.SpansBuffered), nil, 1)
+	for _, p := range s.policies {
+		metrics.Count("datadog.trace_agent.sampler.tail.policy_kept", atomic.SwapInt64(&p.kept, 0), []string{"policy:" + p.name}, 1)
+	}
+	s.reported = stats
+}
+
+// tailPolicy is a compiled config.TailSamplingPolicy.
+type tailPolicy struct {
+	// kept counts the traces kept by the policy since the last report.
+	kept int64
+
+	name        string
+	minDuration int64
+	service     string
+	resource    *regexp.Regexp
+	error       bool
+	statusCodes []string
+	tags        map[string]string
+	rate        float64
+}
+
+func newTailPolicy(p *config.TailSamplingPolicy) (*tailPolicy, error) {
+	if p.Percentage <= 0 || p.Percentage > 100 {
+		return nil, fmt.Errorf("percentage must be in (0, 100], got %v", p.Percentage)
+	}
+	tp := &tailPolicy{
+		name:        p.Name,
+		minDuration: int64(p.MinDurationMs * float64(time.Millisecond)),
+		service:     p.Service,
+		error:       p.Error,
+		statusCodes: p.StatusCodes,
+		tags:        p.Tags,
+		rate:        p.Percentage / 100,
+	}
+	if p.Resource != "" {
+		re, err := regexp.Compile(p.Resource)
+		if err != nil {
+			return nil, err
+		}
+		tp.resource = re
+	}
+	return tp, nil
+}
+
+// matches reports whether the trace traceID, made of chunks, matches the policy.
+func (p *tailPolicy) matches(traceID uint64, chunks pb.Traces) bool {
+	if p.minDuration > 0 && traceDuration(chunks) < p.minDuration {
+		return false
+	}
+	if p.hasSpanConditions() && !p.matchesAnySpan(chunks) {
+		return false
+	}
+	return SampleByRate(traceID, p.rate)
+}
+
+func (p *tailPolicy) hasSpanConditions() bool {
+	return p.service != "" || p.resource != nil || p.error || len(p.statusCodes) > 0 || len(p.tags) > 0
+}
+
+func (p *tailPolicy) matchesAnySpan(chunks pb.Traces) bool {
+	for _, chunk := range chunks {
+		for _, span := range chunk {
+			if p.matchesSpan(span) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *tailPolicy) matchesSpan(span *pb.Span) bool {
+	if p.service != "" && span.Service != p.service {
+		return false
+	}
+	if p.resource != nil && !p.resource.MatchString(span.Resource) {
+		return false
+	}
+	if p.error && span.Error == 0 {
+		return false
+	}
+	if len(p.statusCodes) > 0 && !matchesStatusCode(span.Meta[KeyHTTPStatusCode], p.statusCodes) {
+		return false
+	}
+	for k, v := range p.tags {
+		if tv, ok := span.Meta[k]; !ok || (v != "" && tv != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesStatusCode reports whether the HTTP status code is one of codes, or of one of
+// their classes such as "5xx".
+func matchesStatusCode(code string, codes []string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range codes {
+		if c == code || (len(c) == 3 && strings.EqualFold(c[1:], "xx") && c[0] == code[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// traceDuration returns the duration of the trace made of chunks, from the start of its
+// first span to the end of its last one.
+func traceDuration(chunks pb.Traces) int64 {
+	var start, end int64
+	for _, chunk := range chunks {
+		for _, span := range chunk {
+			if start == 0 || span.Start < start {
+				start = span.Start
+			}
+			if e := span.Start + span.Duration; e > end {
+				end = e
+			}
+		}
+	}
+	return end - start
+}
@@ -0,0 +1,242 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sampler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/pb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// keptRecorder records the chunks kept by a TailSampler.
+type keptRecorder struct {
+	mu     sync.Mutex
+	chunks pb.Traces
+}
+
+func (r *keptRecorder) keep(chunks pb.Traces) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chunks = append(r.chunks, chunks...)
+}
+
+// traceIDs returns the IDs of the kept chunks, in order.
+func (r *keptRecorder) traceIDs() []uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []uint64
+	for _, c := range r.chunks {
+		ids = append(ids, c[0].TraceID)
+	}
+	return ids
+}
+
+func newTestTailSampler(policies ...*config.TailSamplingPolicy) (*TailSampler, *keptRecorder) {
+	r := &keptRecorder{}
+	s := NewTailSampler(&config.TailSamplingConfig{
+		Enabled:             true,
+		DecisionWaitSeconds: 10,
+		MaxTraces:           100,
+		MaxSpans:            1000,
+		Policies:            policies,
+	}, r.keep)
+	return s, r
+}
+
+func TestTailSamplerDecisionWait(t *testing.T) {
+	assert := assert.New(t)
+	s, r := newTestTailSampler(&config.TailSamplingPolicy{Name: "errors", Error: true, Percentage: 100})
+	now := time.Now()
+
+	s.add(now, pb.Trace{{TraceID: 1, SpanID: 1}}, false)
+	s.add(now.Add(time.Second), pb.Trace{{TraceID: 2, SpanID: 2, Error: 1}}, false)
+	// the error of trace 1 comes in a later chunk, within the decision window
+	s.add(now.Add(5*time.Second), pb.Trace{{TraceID: 1, SpanID: 3, ParentID: 1, Error: 1}}, false)
+
+	s.flush(now.Add(9*time.Second), false)
+	assert.Empty(r.traceIDs())
+
+	s.flush(now.Add(10*time.Second), false)
+	assert.Equal([]uint64{1, 1}, r.traceIDs())
+
+	s.flush(now.Add(11*time.Second), false)
+	assert.Equal([]uint64{1, 1, 2}, r.traceIDs())
+
+	stats := s.Stats()
+	assert.EqualValues(2, stats.TracesKept)
+	assert.EqualValues(0, stats.TracesDropped)
+	assert.EqualValues(0, stats.TracesBuffered)
+	assert.EqualValues(0, stats.SpansBuffered)
+}
+
+func TestTailSamplerPolicies(t *testing.T) {
+	root := func(s *pb.Span) pb.Trace {
+		s.TraceID, s.SpanID = 42, 1
+		return pb.Trace{s}
+	}
+	for name, tt := range map[string]struct {
+		policy *config.TailSamplingPolicy
+		chunk  pb.Trace
+		keep   bool
+	}{
+		"latency-keep": {
+			policy: &config.TailSamplingPolicy{MinDurationMs: 100, Percentage: 100},
+			chunk:  root(&pb.Span{Start: 1, Duration: int64(150 * time.Millisecond)}),
+			keep:   true,
+		},
+		"latency-drop": {
+			policy: &config.TailSamplingPolicy{MinDurationMs: 100, Percentage: 100},
+			chunk:  root(&pb.Span{Start: 1, Duration: int64(50 * time.Millisecond)}),
+		},
+		"service-resource-keep": {
+			policy: &config.TailSamplingPolicy{Service: "web", Resource: "^GET /users", Percentage: 100},
+			chunk:  root(&pb.Span{Service: "web", Resource: "GET /users/:id"}),
+			keep:   true,
+		},
+		"service-resource-drop": {
+			policy: &config.TailSamplingPolicy{Service: "web", Resource: "^GET /users", Percentage: 100},
+			chunk:  root(&pb.Span{Service: "web", Resource: "POST /users"}),
+		},
+		"status-class-keep": {
+			policy: &config.TailSamplingPolicy{StatusCodes: []string{"5xx"}, Percentage: 100},
+			chunk:  root(&pb.Span{Meta: map[string]string{KeyHTTPStatusCode: "503"}}),
+			keep:   true,
+		},
+		"status-code-keep": {
+			policy: &config.TailSamplingPolicy{StatusCodes: []string{"429"}, Percentage: 100},
+			chunk:  root(&pb.Span{Meta: map[string]string{KeyHTTPStatusCode: "429"}}),
+			keep:   true,
+		},
+		"status-drop": {
+			policy: &config.TailSamplingPolicy{StatusCodes: []string{"5xx", "429"}, Percentage: 100},
+			chunk:  root(&pb.Span{Meta: map[string]string{KeyHTTPStatusCode: "200"}}),
+		},
+		"tag-keep": {
+			policy: &config.TailSamplingPolicy{Tags: map[string]string{"customer.tier": "gold", "feature": ""}, Percentage: 100},
+			chunk:  root(&pb.Span{Meta: map[string]string{"customer.tier": "gold", "feature": "checkout"}}),
+			keep:   true,
+		},
+		"tag-drop": {
+			policy: &config.TailSamplingPolicy{Tags: map[string]string{"customer.tier": "gold"}, Percentage: 100},
+			chunk:  root(&pb.Span{Meta: map[string]string{"customer.tier": "silver"}}),
+		},
+		"percentage-keep": {
+			policy: &config.TailSamplingPolicy{Percentage: 100},
+			chunk:  root(&pb.Span{}),
+			keep:   true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, r := newTestTailSampler(tt.policy)
+			now := time.Now()
+			s.add(now, tt.chunk, false)
+			s.flush(now.Add(time.Minute), false)
+			assert.Equal(t, tt.keep, len(r.traceIDs()) == 1)
+		})
+	}
+}
+
+func TestTailSamplerPercentage(t *testing.T) {
+	s, r := newTestTailSampler(&config.TailSamplingPolicy{Percentage: 10})
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		s.add(now, pb.Trace{{TraceID: uint64(i) * 0x9e3779b97f4a7c15, SpanID: 1}}, false)
+	}
+	s.flush(now.Add(time.Minute), true)
+	kept := len(r.traceIDs())
+	assert.Greater(t, kept, 0)
+	assert.Less(t, kept, 30)
+}
+
+func TestTailSamplerEviction(t *testing.T) {
+	t.Run("traces", func(t *testing.T) {
+		assert := assert.New(t)
+		s, r := newTestTailSampler(&config.TailSamplingPolicy{Percentage: 100})
+		s.maxTraces = 2
+		now := time.Now()
+		for i := uint64(1); i <= 3; i++ {
+			s.add(now, pb.Trace{{TraceID: i, SpanID: i}}, false)
+		}
+		// the oldest trace was decided early to make room for the last one
+		assert.Equal([]uint64{1}, r.traceIDs())
+		stats := s.Stats()
+		assert.EqualValues(1, stats.TracesEvicted)
+		assert.EqualValues(2, stats.TracesBuffered)
+	})
+
+	t.Run("spans", func(t *testing.T) {
+		assert := assert.New(t)
+		s, r := newTestTailSampler(&config.TailSamplingPolicy{Percentage: 100})
+		s.maxSpans = 3
+		now := time.Now()
+		s.add(now, pb.Trace{{TraceID: 1, SpanID: 1}, {TraceID: 1, SpanID: 2}}, false)
+		s.add(now, pb.Trace{{TraceID: 2, SpanID: 3}, {TraceID: 2, SpanID: 4}}, false)
+		assert.Equal([]uint64{1}, r.traceIDs())
+		stats := s.Stats()
+		assert.EqualValues(1, stats.TracesEvicted)
+		assert.EqualValues(2, stats.SpansBuffered)
+	})
+}
+
+func TestTailSamplerLateChunks(t *testing.T) {
+	assert := assert.New(t)
+	s, r := newTestTailSampler(&config.TailSamplingPolicy{Service: "keep", Percentage: 100})
+	now := time.Now()
+	s.add(now, pb.Trace{{TraceID: 1, SpanID: 1, Service: "keep"}}, false)
+	s.add(now, pb.Trace{{TraceID: 2, SpanID: 2, Service: "drop"}}, false)
+	s.flush(now.Add(time.Minute), false)
+	assert.Equal([]uint64{1}, r.traceIDs())
+
+	// late chunks follow the decision taken on their trace
+	s.add(now.Add(time.Minute), pb.Trace{{TraceID: 1, SpanID: 3, ParentID: 1}}, false)
+	s.add(now.Add(time.Minute), pb.Trace{{TraceID: 2, SpanID: 4, ParentID: 2, Service: "keep"}}, false)
+	assert.Equal([]uint64{1, 1}, r.traceIDs())
+
+	stats := s.Stats()
+	assert.EqualValues(2, stats.SpansLate)
+	assert.EqualValues(0, stats.TracesBuffered)
+}
+
+func TestTailSamplerAlreadyKept(t *testing.T) {
+	assert := assert.New(t)
+	s, r := newTestTailSampler(&config.TailSamplingPolicy{Error: true, Percentage: 100})
+	now := time.Now()
+	// the first chunk, kept by another sampler, decides on the trace without being kept again
+	s.add(now, pb.Trace{{TraceID: 1, SpanID: 1, Error: 1}}, true)
+	s.add(now, pb.Trace{{TraceID: 1, SpanID: 2, ParentID: 1}}, false)
+	s.add(now, pb.Trace{{TraceID: 2, SpanID: 3}}, true)
+	s.flush(now.Add(time.Minute), false)
+	if assert.Len(r.chunks, 1) {
+		assert.EqualValues(2, r.chunks[0][0].SpanID)
+	}
+
+	s.add(now.Add(time.Minute), pb.Trace{{TraceID: 1, SpanID: 4, ParentID: 1}}, true)
+	s.add(now.Add(time.Minute), pb.Trace{{TraceID: 1, SpanID: 5, ParentID: 1}}, false)
+	assert.Equal([]uint64{1, 1}, r.traceIDs())
+}
+
+func TestTailSamplerStop(t *testing.T) {
+	s, r := newTestTailSampler(&config.TailSamplingPolicy{Percentage: 100})
+	s.Start()
+	s.Add(pb.Trace{{TraceID: 1, SpanID: 1}}, false)
+	s.Stop()
+	assert.Equal(t, []uint64{1}, r.traceIDs())
+}
+
+func TestNewTailSamplerInvalidPolicy(t *testing.T) {
+	s, _ := newTestTailSampler(
+		&config.TailSamplingPolicy{Name: "bad", Resource: "(", Percentage: 100},
+		&config.TailSamplingPolicy{Name: "no-percentage"},
+		&config.TailSamplingPolicy{Name: "good", Percentage: 100},
+	)
+	assert.Len(t, s.policies, 1)
+	assert.Equal(t, "good", s.policies[0].name)
+}
@@ -0,0 +1,106 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package compression
+
+import (
+	"fmt"
+	"io"
+)
+
+// Kinds of the codecs that can be selected at runtime
+const (
+	ZlibKind = "zlib"
+	GzipKind = "gzip"
+	ZstdKind = "zstd"
+	NoneKind = "none"
+)
+
+// Codec compresses and decompresses payloads with an algorithm and a level selected at
+// runtime, unlike the package level functions whose algorithm is selected at build time.
+type Codec interface {
+	// Kind returns the name of the algorithm, as used in the configuration
+	Kind() string
+	// ContentEncoding returns the value of the Content-Encoding HTTP header of the compressed
+	// payloads, empty when the payloads are not compressed
+	ContentEncoding() string
+	// Compress compresses src
+	Compress(dst []byte, src []byte) ([]byte, error)
+	// Decompress decompresses src, reusing the storage of dst when it is large enough
+	Decompress(dst []byte, src []byte) ([]byte, error)
+	// CompressBound returns the worst case size needed for a destination buffer
+	CompressBound(sourceLen int) int
+	// NewStreamWriter returns a writer compressing in stream the data written to it into output
+	NewStreamWriter(output io.Writer) (StreamWriter, error)
+}
+
+// StreamWriter compresses in stream the data written to it
+type StreamWriter interface {
+	io.WriteCloser
+	// Flush writes to the output all the data written so far
+	Flush() error
+}
+
+// DefaultCodec is the codec using the algorithm selected at build time, with its default level
+var DefaultCodec Codec = buildCodec{}
+
+// NewCodec returns the codec of the given kind. A level of 0 selects the default level of the
+// algorithm, an empty kind selects the algorithm and format selected at build time (DefaultCodec
+// when the level is 0).
+// The levels range from 1 to 9 for zlib and gzip, and from 1 to 22 for zstd (1 to 20 for the
+// pre-v1 format of the `zstd` build tag).
+func NewCodec(kind string, level int) (Codec, error) {
+	switch kind {
+	case "":
+		if level == 0 {
+			return DefaultCodec, nil
+		}
+		return newBuildCodec(level)
+	case ZlibKind:
+		return newZlibCodec(level)
+	case GzipKind:
+		return newGzipCodec(level)
+	case ZstdKind:
+		return newZstdCodec(level)
+	case NoneKind:
+		return noneCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unknown compression kind %q, it must be one of %s, %s, %s or %s", kind, ZlibKind, GzipKind, ZstdKind, NoneKind)
+	}
+}
+
+func checkLevel(kind string, level, min, max int) error {
+	if level != 0 && (level < min || level > max) {
+		return fmt.Errorf("invalid %s compression level %d, it must be between %d and %d", kind, level, min, max)
+	}
+	return nil
+}
+
+// buildCodec is the Codec of the package level functions
+type buildCodec struct{}
+
+func (buildCodec) Kind() string {
+	return buildKind
+}
+
+func (buildCodec) ContentEncoding() string {
+	return ContentEncoding
+}
+
+func (buildCodec) Compress(dst []byte, src []byte) ([]byte, error) {
+	return Compress(dst, src)
+}
+
+func (buildCodec) Decompress(dst []byte, src []byte) ([]byte, error) {
+	return Decompress(dst, src)
+}
+
+func (buildCodec) CompressBound(sourceLen int) int {
+	return CompressBound(sourceLen)
+}
+
+func (buildCodec) NewStreamWriter(output io.Writer) (StreamWriter, error) {
+	return newBuildStreamWriter(output)
+}
@@ -0,0 +1,85 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package compression
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkCodecs compares the CPU cost of the codecs, the `ratio` metric being the size of
+// the compressed payload divided by the size of the payload.
+func BenchmarkCodecs(b *testing.B) {
+	payload := testPayload()
+	for _, bc := range []struct {
+		kind   string
+		levels []int
+	}{
+		{ZlibKind, []int{1, 6, 9}},
+		{GzipKind, []int{1, 6, 9}},
+		{ZstdKind, []int{1, 5, 19}},
+		{NoneKind, []int{0}},
+	} {
+		for _, level := range bc.levels {
+			codec, err := NewCodec(bc.kind, level)
+			if err != nil {
+				continue
+			}
+			b.Run(fmt.Sprintf("%s_%d", bc.kind, level), func(b *testing.B) {
+				var compressed []byte
+				b.SetBytes(int64(len(payload)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for n := 0; n < b.N; n++ {
+					compressed, _ = codec.Compress(compressed, payload)
+				}
+				b.ReportMetric(float64(len(compressed))/float64(len(payload)), "ratio")
+			})
+		}
+	}
+}
+
+// BenchmarkCodecsStream compares the codecs when compressing in stream, as done by the
+// serializer: the payload is written in small items and flushed regularly.
+func BenchmarkCodecsStream(b *testing.B) {
+	payload := testPayload()
+	for _, kind := range []string{ZlibKind, GzipKind, ZstdKind, NoneKind} {
+		codec, err := NewCodec(kind, 0)
+		if err != nil {
+			continue
+		}
+		b.Run(kind, func(b *testing.B) {
+			var output discardCounter
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				output = 0
+				w, _ := codec.NewStreamWriter(&output)
+				for i := 0; i < len(payload); i += 128 {
+					end := i + 128
+					if end > len(payload) {
+						end = len(payload)
+					}
+					_, _ = w.Write(payload[i:end])
+					if i%8192 == 0 {
+						_ = w.Flush()
+					}
+				}
+				_ = w.Close()
+			}
+			b.ReportMetric(float64(output)/float64(len(payload)), "ratio")
+		})
+	}
+}
+
+// discardCounter discards the data written to it and counts its size
+type discardCounter int
+
+func (d *discardCounter) Write(p []byte) (int, error) {
+	*d += discardCounter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,73 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// The gzip header and footer are 18 bytes long, instead of 6 bytes for zlib
+const gzipExtraBound = 12
+
+type gzipCodec struct {
+	level int
+}
+
+func newGzipCodec(level int) (Codec, error) {
+	if err := checkLevel(GzipKind, level, gzip.BestSpeed, gzip.BestCompression); err != nil {
+		return nil, err
+	}
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzipCodec{level: level}, nil
+}
+
+func (c gzipCodec) Kind() string {
+	return GzipKind
+}
+
+func (c gzipCodec) ContentEncoding() string {
+	return "gzip"
+}
+
+func (c gzipCodec) Compress(dst []byte, src []byte) ([]byte, error) {
+	b := bytes.NewBuffer(dst[:0])
+	w, err := gzip.NewWriterLevel(b, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(src); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (c gzipCodec) Decompress(dst []byte, src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b := bytes.NewBuffer(dst[:0])
+	if _, err = b.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (c gzipCodec) CompressBound(sourceLen int) int {
+	return zlibCompressBound(sourceLen) + gzipExtraBound
+}
+
+func (c gzipCodec) NewStreamWriter(output io.Writer) (StreamWriter, error) {
+	return gzip.NewWriterLevel(output, c.level)
+}
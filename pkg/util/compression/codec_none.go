@@ -0,0 +1,51 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package compression
+
+import "io"
+
+// noneCodec sends the payloads uncompressed
+type noneCodec struct{}
+
+func (noneCodec) Kind() string {
+	return NoneKind
+}
+
+func (noneCodec) ContentEncoding() string {
+	return ""
+}
+
+func (noneCodec) Compress(dst []byte, src []byte) ([]byte, error) {
+	return src, nil
+}
+
+func (noneCodec) Decompress(dst []byte, src []byte) ([]byte, error) {
+	return src, nil
+}
+
+func (noneCodec) CompressBound(sourceLen int) int {
+	return sourceLen
+}
+
+func (noneCodec) NewStreamWriter(output io.Writer) (StreamWriter, error) {
+	return &noneStreamWriter{output: output}, nil
+}
+
+type noneStreamWriter struct {
+	output io.Writer
+}
+
+func (w *noneStreamWriter) Write(p []byte) (int, error) {
+	return w.output.Write(p)
+}
+
+func (w *noneStreamWriter) Flush() error {
+	return nil
+}
+
+func (w *noneStreamWriter) Close() error {
+	return nil
+}
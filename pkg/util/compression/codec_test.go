@@ -0,0 +1,126 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPayload() []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"series":[`)
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"metric":"test.metric%d","points":[[1600000000,%d]],"tags":["env:prod","service:web"],"host":"localhost","type":"gauge","interval":10}`, i%50, i)
+	}
+	b.WriteString(`]}`)
+	return b.Bytes()
+}
+
+func TestNewCodec(t *testing.T) {
+	for _, tc := range []struct {
+		kind            string
+		level           int
+		contentEncoding string
+	}{
+		{ZlibKind, 0, "deflate"},
+		{ZlibKind, 9, "deflate"},
+		{GzipKind, 1, "gzip"},
+		{NoneKind, 0, ""},
+	} {
+		t.Run(fmt.Sprintf("%s_%d", tc.kind, tc.level), func(t *testing.T) {
+			codec, err := NewCodec(tc.kind, tc.level)
+			require.NoError(t, err)
+			assert.Equal(t, tc.kind, codec.Kind())
+			assert.Equal(t, tc.contentEncoding, codec.ContentEncoding())
+		})
+	}
+
+	codec, err := NewCodec("", 0)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultCodec, codec)
+
+	// a level without kind keeps the algorithm and format selected at build time
+	codec, err = NewCodec("", 1)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultCodec.Kind(), codec.Kind())
+	assert.Equal(t, ContentEncoding, codec.ContentEncoding())
+	payload := testPayload()
+	compressed, err := codec.Compress(nil, payload)
+	require.NoError(t, err)
+	decompressed, err := DefaultCodec.Decompress(nil, compressed)
+	require.NoError(t, err)
+	assert.Equal(t, payload, decompressed)
+
+	_, err = NewCodec("lz4", 0)
+	assert.Error(t, err)
+	_, err = NewCodec(ZlibKind, 10)
+	assert.Error(t, err)
+	_, err = NewCodec(GzipKind, -1)
+	assert.Error(t, err)
+}
+
+func TestCodecs(t *testing.T) {
+	payload := testPayload()
+	for _, kind := range []string{ZlibKind, GzipKind, ZstdKind, NoneKind} {
+		t.Run(kind, func(t *testing.T) {
+			codec, err := NewCodec(kind, 0)
+			if kind == ZstdKind && err != nil {
+				t.Skip(err)
+			}
+			require.NoError(t, err)
+
+			compressed, err := codec.Compress(nil, payload)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(compressed), codec.CompressBound(len(payload)))
+			if kind != NoneKind {
+				assert.Less(t, len(compressed), len(payload))
+			}
+			decompressed, err := codec.Decompress(nil, compressed)
+			require.NoError(t, err)
+			assert.Equal(t, payload, decompressed)
+
+			// A payload compressed in stream is decompressed like any other payload
+			var output bytes.Buffer
+			w, err := codec.NewStreamWriter(&output)
+			require.NoError(t, err)
+			_, err = w.Write(payload[:100])
+			require.NoError(t, err)
+			require.NoError(t, w.Flush())
+			_, err = w.Write(payload[100:])
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			decompressed, err = codec.Decompress(nil, output.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, payload, decompressed)
+		})
+	}
+}
+
+func TestCodecsDecompressIntoDst(t *testing.T) {
+	payload := testPayload()
+	for _, kind := range []string{ZlibKind, GzipKind} {
+		t.Run(kind, func(t *testing.T) {
+			codec, err := NewCodec(kind, 0)
+			require.NoError(t, err)
+			compressed, err := codec.Compress(nil, payload)
+			require.NoError(t, err)
+
+			dst := make([]byte, 0, len(payload)+bytes.MinRead)
+			decompressed, err := codec.Decompress(dst, compressed)
+			require.NoError(t, err)
+			assert.Equal(t, payload, decompressed)
+			assert.Equal(t, &dst[:1][0], &decompressed[0])
+		})
+	}
+}
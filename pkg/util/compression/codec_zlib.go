@@ -0,0 +1,75 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package compression
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+)
+
+type zlibCodec struct {
+	level int
+}
+
+func newZlibCodec(level int) (Codec, error) {
+	if err := checkLevel(ZlibKind, level, zlib.BestSpeed, zlib.BestCompression); err != nil {
+		return nil, err
+	}
+	if level == 0 {
+		level = zlib.DefaultCompression
+	}
+	return zlibCodec{level: level}, nil
+}
+
+func (c zlibCodec) Kind() string {
+	return ZlibKind
+}
+
+func (c zlibCodec) ContentEncoding() string {
+	return "deflate"
+}
+
+func (c zlibCodec) Compress(dst []byte, src []byte) ([]byte, error) {
+	b := bytes.NewBuffer(dst[:0])
+	w, err := zlib.NewWriterLevel(b, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(src); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (c zlibCodec) Decompress(dst []byte, src []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b := bytes.NewBuffer(dst[:0])
+	if _, err = b.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (c zlibCodec) CompressBound(sourceLen int) int {
+	return zlibCompressBound(sourceLen)
+}
+
+func (c zlibCodec) NewStreamWriter(output io.Writer) (StreamWriter, error) {
+	return zlib.NewWriterLevel(output, c.level)
+}
+
+func zlibCompressBound(sourceLen int) int {
+	// From https://code.woboq.org/gcc/zlib/compress.c.html#compressBound
+	return sourceLen + (sourceLen >> 12) + (sourceLen >> 14) + (sourceLen >> 25) + 13
+}
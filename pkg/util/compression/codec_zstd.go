@@ -0,0 +1,57 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build cgo
+
+package compression
+
+import (
+	"io"
+
+	"github.com/DataDog/zstd"
+)
+
+// zstdMaxLevel is the highest level supported by the zstd library
+const zstdMaxLevel = 22
+
+// zstdCodec uses the stable (v1) zstd format, unlike the `zstd` build tag which uses
+// the pre-v1 format of the intake.
+type zstdCodec struct {
+	level int
+}
+
+func newZstdCodec(level int) (Codec, error) {
+	if err := checkLevel(ZstdKind, level, zstd.BestSpeed, zstdMaxLevel); err != nil {
+		return nil, err
+	}
+	if level == 0 {
+		level = zstd.DefaultCompression
+	}
+	return zstdCodec{level: level}, nil
+}
+
+func (c zstdCodec) Kind() string {
+	return ZstdKind
+}
+
+func (c zstdCodec) ContentEncoding() string {
+	return "zstd"
+}
+
+func (c zstdCodec) Compress(dst []byte, src []byte) ([]byte, error) {
+	return zstd.CompressLevel(dst, src, c.level)
+}
+
+func (c zstdCodec) Decompress(dst []byte, src []byte) ([]byte, error) {
+	return zstd.Decompress(dst, src)
+}
+
+func (c zstdCodec) CompressBound(sourceLen int) int {
+	return zstd.CompressBound(sourceLen)
+}
+
+func (c zstdCodec) NewStreamWriter(output io.Writer) (StreamWriter, error) {
+	return zstd.NewWriterLevel(output, c.level), nil
+}
@@ -0,0 +1,14 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build !cgo
+
+package compression
+
+import "errors"
+
+func newZstdCodec(level int) (Codec, error) {
+	return nil, errors.New("zstd compression is not available in builds without cgo")
+}
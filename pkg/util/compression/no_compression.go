@@ -7,6 +7,10 @@
 
 package compression
 
+import "io"
+
+const buildKind = NoneKind
+
 // ContentEncoding describes the HTTP header value associated with the compression method
 // empty here since there's no compression
 // var instead of const to ease testing
@@ -28,3 +32,12 @@ func Decompress(dst []byte, src []byte) ([]byte, error) {
 func CompressBound(sourceLen int) int {
 	return sourceLen
 }
+
+func newBuildStreamWriter(output io.Writer) (StreamWriter, error) {
+	return &noneStreamWriter{output: output}, nil
+}
+
+// newBuildCodec returns the codec sending the payloads uncompressed, the level is ignored
+func newBuildCodec(level int) (Codec, error) {
+	return noneCodec{}, nil
+}
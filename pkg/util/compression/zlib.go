@@ -10,9 +10,12 @@ package compression
 import (
 	"bytes"
 	"compress/zlib"
+	"io"
 	"io/ioutil"
 )
 
+const buildKind = ZlibKind
+
 // ContentEncoding describes the HTTP header value associated with the compression method
 // var instead of const to ease testing
 var ContentEncoding = "deflate"
@@ -50,6 +53,14 @@ func Decompress(dst []byte, src []byte) ([]byte, error) {
 
 //  CompressBound returns the worst case size needed for a destination buffer
 func CompressBound(sourceLen int) int {
-	// From https://code.woboq.org/gcc/zlib/compress.c.html#compressBound
-	return sourceLen + (sourceLen >> 12) + (sourceLen >> 14) + (sourceLen >> 25) + 13
+	return zlibCompressBound(sourceLen)
+}
+
+func newBuildStreamWriter(output io.Writer) (StreamWriter, error) {
+	return zlib.NewWriter(output), nil
+}
+
+// newBuildCodec returns the zlib codec with the given level
+func newBuildCodec(level int) (Codec, error) {
+	return newZlibCodec(level)
 }
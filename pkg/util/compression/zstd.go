@@ -8,9 +8,14 @@
 package compression
 
 import (
+	"errors"
+	"io"
+
 	zstd_0 "github.com/DataDog/zstd_0"
 )
 
+const buildKind = ZstdKind
+
 // TODO: the intake still uses a pre-v1 (unstable) version of the zstd compression format.
 // The agent shouldn't use zstd compression until the intake supports a stable v1 format.
 
@@ -32,3 +37,44 @@ func Decompress(dst []byte, src []byte) ([]byte, error) {
 func CompressBound(sourceLen int) int {
 	return zstd_0.CompressBound(sourceLen)
 }
+
+func newBuildStreamWriter(output io.Writer) (StreamWriter, error) {
+	return nil, errors.New("stream compression is not available with the pre-v1 zstd format")
+}
+
+// newBuildCodec returns a codec using the pre-v1 zstd format of the intake with the given level
+func newBuildCodec(level int) (Codec, error) {
+	if err := checkLevel(ZstdKind, level, zstd_0.BestSpeed, zstd_0.BestCompression); err != nil {
+		return nil, err
+	}
+	return legacyZstdCodec{level: level}, nil
+}
+
+// legacyZstdCodec is the codec of the package level functions with a compression level
+type legacyZstdCodec struct {
+	level int
+}
+
+func (c legacyZstdCodec) Kind() string {
+	return ZstdKind
+}
+
+func (c legacyZstdCodec) ContentEncoding() string {
+	return ContentEncoding
+}
+
+func (c legacyZstdCodec) Compress(dst []byte, src []byte) ([]byte, error) {
+	return zstd_0.CompressLevel(dst, src, c.level)
+}
+
+func (c legacyZstdCodec) Decompress(dst []byte, src []byte) ([]byte, error) {
+	return zstd_0.Decompress(dst, src)
+}
+
+func (c legacyZstdCodec) CompressBound(sourceLen int) int {
+	return zstd_0.CompressBound(sourceLen)
+}
+
+func (c legacyZstdCodec) NewStreamWriter(output io.Writer) (StreamWriter, error) {
+	return newBuildStreamWriter(output)
+}
@@ -8,6 +8,9 @@ package main
 import (
 	"bytes"
 	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -20,37 +23,47 @@ import (
 	proto "github.com/golang/protobuf/proto"
 )
 
+// Must match the format of the `.retry` files defined in pkg/forwarder/internal/retry/retry_file_codec.go
+const (
+	retryFileMagic         = "\xfeRTRY"
+	retryFileVersion       = 1
+	retryFileEncryptedFlag = 1
+	retryFileHeaderSize    = len(retryFileMagic) + 2 + sha256.Size
+)
+
 func main() {
-	folder, err := parseArg()
+	folder, encryptionKey, err := parseArg()
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	if err = dumpRetryFiles(folder); err != nil {
+	if err = dumpRetryFiles(folder, encryptionKey); err != nil {
 		fmt.Println(err)
 	}
 }
 
-func parseArg() (string, error) {
+func parseArg() (string, string, error) {
 	var folder = flag.String("folder", "", "The folder containing `.retry` files.")
+	var encryptionKey = flag.String("encryption_key", "", "The value of `forwarder_storage_encryption_key` when the files are encrypted.")
 	flag.Parse()
 	if *folder == "" {
-		return "", errors.New("Invalid folder: Usage `./retry_file_dump --folder=/opt/datadog-agent/run/transactions_to_retry/c47da40ac935c8fd5ca1441a5ee3d068/`")
+		return "", "", errors.New("Invalid folder: Usage `./retry_file_dump --folder=/opt/datadog-agent/run/transactions_to_retry/c47da40ac935c8fd5ca1441a5ee3d068/`")
 	}
-	return *folder, nil
+	return *folder, *encryptionKey, nil
 }
 
-func dumpRetryFiles(folder string) error {
+func dumpRetryFiles(folder string, encryptionKey string) error {
 	entries, err := ioutil.ReadDir(folder)
 	if err != nil {
 		return err
 	}
 
 	for _, entry := range entries {
-		if entry.Mode().IsRegular() && filepath.Ext(entry.Name()) == ".retry" {
+		ext := filepath.Ext(entry.Name())
+		if entry.Mode().IsRegular() && (ext == ".retry" || ext == ".quarantine") {
 			fmt.Println(entry.Name())
 			filePath := path.Join(folder, entry.Name())
-			fileContent, err := dumpRetryFile(filePath)
+			fileContent, err := dumpRetryFile(filePath, encryptionKey)
 			if err != nil {
 				return err
 			}
@@ -64,8 +77,12 @@ func dumpRetryFiles(folder string) error {
 	return nil
 }
 
-func dumpRetryFile(file string) ([]byte, error) {
-	content, err := ioutil.ReadFile(file)
+func dumpRetryFile(file string, encryptionKey string) ([]byte, error) {
+	fileContent, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	content, err := decodeRetryFile(fileContent, encryptionKey)
 	if err != nil {
 		return nil, err
 	}
@@ -90,6 +107,47 @@ func dumpRetryFile(file string) ([]byte, error) {
 	return json.MarshalIndent(jsonTrs, "", "  ")
 }
 
+// decodeRetryFile checks the checksum and decrypts the content of a `.retry` file.
+func decodeRetryFile(content []byte, encryptionKey string) ([]byte, error) {
+	if !bytes.HasPrefix(content, []byte(retryFileMagic)) {
+		// File written by a version of the Agent without checksum and encryption
+		return content, nil
+	}
+	if len(content) < retryFileHeaderSize {
+		return nil, errors.New("The file is corrupted: the header is truncated")
+	}
+	if version := content[len(retryFileMagic)]; version != retryFileVersion {
+		return nil, fmt.Errorf("Unsupported file version %v", version)
+	}
+	flags := content[len(retryFileMagic)+1]
+	checksum := content[len(retryFileMagic)+2 : retryFileHeaderSize]
+	data := content[retryFileHeaderSize:]
+	if computed := sha256.Sum256(data); !bytes.Equal(checksum, computed[:]) {
+		return nil, errors.New("The file is corrupted: invalid checksum")
+	}
+	if flags&retryFileEncryptedFlag == 0 {
+		return data, nil
+	}
+	if encryptionKey == "" {
+		return nil, errors.New("The file is encrypted, use `--encryption_key`")
+	}
+
+	key := sha256.Sum256([]byte(encryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("The file is corrupted: the nonce is truncated")
+	}
+	return aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+}
+
 type JsonHttpTransaction struct {
 	*HttpTransactionProto
 	Payload string // Same as HttpTransactionProto.Payload but the type is string instead of []byte